@@ -54,7 +54,6 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/require"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // partitioningTest represents a single test case used in the various
@@ -225,7 +224,7 @@ func (pt *partitioningTest) parse() error {
 		}
 
 		var parsedConstraints zonepb.ConstraintsList
-		if err := yaml.UnmarshalStrict([]byte("["+constraints+"]"), &parsedConstraints); err != nil {
+		if err := zonepb.UnmarshalYAMLStrict([]byte("["+constraints+"]"), &parsedConstraints); err != nil {
 			return errors.Wrapf(err, "parsing constraints: %s", constraints)
 		}
 		subzone.Config.Constraints = parsedConstraints.Constraints
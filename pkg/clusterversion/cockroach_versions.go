@@ -538,6 +538,18 @@ const (
 	// the system tenant.
 	V23_2_EnableRangeCoalescingForSystemTenant
 
+	// V23_2_ZoneConfigWildcardConstraints is the version where the values of
+	// locality constraints may contain wildcards.
+	V23_2_ZoneConfigWildcardConstraints
+
+	// V23_2_ZoneConfigAuditInfo is the version where SET ZONE records who made
+	// the change to a zone config, and when, in the zone config itself.
+	V23_2_ZoneConfigAuditInfo
+
+	// V23_2_ZoneConfigStoreConstraints is the version where constraints may
+	// refer to a store by its ID.
+	V23_2_ZoneConfigStoreConstraints
+
 	// *************************************************
 	// Step (1) Add new versions here.
 	// Do not add new versions to a patch release.
@@ -935,6 +947,18 @@ var rawVersionsSingleton = keyedVersions{
 		Key:     V23_2_EnableRangeCoalescingForSystemTenant,
 		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 8},
 	},
+	{
+		Key:     V23_2_ZoneConfigWildcardConstraints,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 10},
+	},
+	{
+		Key:     V23_2_ZoneConfigAuditInfo,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 12},
+	},
+	{
+		Key:     V23_2_ZoneConfigStoreConstraints,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 14},
+	},
 
 	// *************************************************
 	// Step (2): Add new versions here.
@@ -0,0 +1,162 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// DescriptorKind is the kind of object described by a descriptor.
+type DescriptorKind int
+
+const (
+	// DescriptorKindOther is any object which zone configs don't apply to,
+	// such as a type or a function.
+	DescriptorKindOther DescriptorKind = iota
+	// DescriptorKindDatabase is a database.
+	DescriptorKindDatabase
+	// DescriptorKindSchema is a schema.
+	DescriptorKindSchema
+	// DescriptorKindTable is a table, view or sequence.
+	DescriptorKindTable
+)
+
+// DescriptorInfo is the information about a descriptor which the SystemConfig
+// needs to name the targets of zone configs and to walk up their inheritance
+// chain, as interpreted by a DescriptorProvider.
+type DescriptorInfo struct {
+	Kind DescriptorKind
+	Name string
+	// ParentID and ParentSchemaID are the IDs of the database and schema
+	// containing the object, if any.
+	ParentID       ObjectID
+	ParentSchemaID ObjectID
+	// Dropped is whether the object is being dropped.
+	Dropped bool
+	// IndexNames maps the IDs of the public indexes of a table to their names.
+	IndexNames map[uint32]string
+}
+
+// DescriptorProvider interprets the descriptor values stored in a
+// SystemConfig. It allows the SQL layer, which owns the descriptors, to
+// decode them on behalf of pkg/config, and tests to inject synthetic schemas.
+type DescriptorProvider interface {
+	// DecodeDescriptor decodes the value of the descriptor with the given ID.
+	DecodeDescriptor(id ObjectID, value *roachpb.Value) (DescriptorInfo, error)
+}
+
+// descriptorProvider holds the provider registered with
+// SetDescriptorProvider.
+var descriptorProvider struct {
+	syncutil.Mutex
+	p DescriptorProvider
+}
+
+// SetDescriptorProvider registers the provider which the SystemConfig uses to
+// interpret descriptors, replacing the previous one, if any. The SQL layer
+// registers its provider at init. A nil provider restores the default one,
+// which decodes the descriptor protos directly, without upgrading them.
+// Descriptors already decoded by a SystemConfig aren't decoded again.
+func SetDescriptorProvider(p DescriptorProvider) {
+	descriptorProvider.Lock()
+	defer descriptorProvider.Unlock()
+	descriptorProvider.p = p
+}
+
+// getDescriptorProvider returns the provider registered with
+// SetDescriptorProvider, or the default one.
+func getDescriptorProvider() DescriptorProvider {
+	descriptorProvider.Lock()
+	defer descriptorProvider.Unlock()
+	if descriptorProvider.p == nil {
+		return protoDescriptorProvider{}
+	}
+	return descriptorProvider.p
+}
+
+// protoDescriptorProvider is the default DescriptorProvider.
+type protoDescriptorProvider struct{}
+
+// DecodeDescriptor implements DescriptorProvider.
+func (protoDescriptorProvider) DecodeDescriptor(
+	id ObjectID, value *roachpb.Value,
+) (DescriptorInfo, error) {
+	var desc descpb.Descriptor
+	if err := value.GetProto(&desc); err != nil {
+		return DescriptorInfo{}, errors.Wrapf(err, "decoding descriptor %d", id)
+	}
+	table, database, _, schema, _ := descpb.GetDescriptors(&desc)
+	switch {
+	case table != nil:
+		parentSchemaID := table.UnexposedParentSchemaID
+		if parentSchemaID == descpb.InvalidID {
+			parentSchemaID = keys.PublicSchemaID
+		}
+		info := DescriptorInfo{
+			Kind:           DescriptorKindTable,
+			Name:           table.Name,
+			ParentID:       ObjectID(table.ParentID),
+			ParentSchemaID: ObjectID(parentSchemaID),
+			Dropped:        table.Dropped(),
+			IndexNames:     make(map[uint32]string),
+		}
+		table.ForEachPublicIndex(func(index *descpb.IndexDescriptor) {
+			info.IndexNames[uint32(index.ID)] = index.Name
+		})
+		return info, nil
+	case database != nil:
+		return DescriptorInfo{
+			Kind:    DescriptorKindDatabase,
+			Name:    database.Name,
+			Dropped: database.State == descpb.DescriptorState_DROP,
+		}, nil
+	case schema != nil:
+		return DescriptorInfo{
+			Kind:     DescriptorKindSchema,
+			Name:     schema.Name,
+			ParentID: ObjectID(schema.ParentID),
+			Dropped:  schema.State == descpb.DescriptorState_DROP,
+		}, nil
+	default:
+		return DescriptorInfo{Kind: DescriptorKindOther}, nil
+	}
+}
+
+// GetDescriptorInfo returns the system tenant descriptor with the given ID, as
+// interpreted by the registered DescriptorProvider, and whether there is one.
+// Interpreted descriptors are cached.
+func (s *SystemConfig) GetDescriptorInfo(id ObjectID) (DescriptorInfo, bool, error) {
+	s.mu.RLock()
+	info, ok := s.mu.descInfoCache[id]
+	s.mu.RUnlock()
+	if ok {
+		return info, true, nil
+	}
+	val := s.GetValue(keys.SystemSQLCodec.DescMetadataKey(uint32(id)))
+	if val == nil {
+		return DescriptorInfo{}, false, nil
+	}
+	info, err := getDescriptorProvider().DecodeDescriptor(id, val)
+	if err != nil {
+		return DescriptorInfo{}, false, err
+	}
+	s.mu.Lock()
+	if s.mu.descInfoCache == nil {
+		s.mu.descInfoCache = map[ObjectID]DescriptorInfo{}
+	}
+	s.mu.descInfoCache[id] = info
+	s.mu.Unlock()
+	return info, true, nil
+}
@@ -15,12 +15,14 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync/atomic"
 
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/errors"
 )
@@ -89,7 +91,28 @@ type SystemConfig struct {
 		syncutil.RWMutex
 		zoneCache        map[ObjectID]zoneEntry
 		shouldSplitCache map[ObjectID]bool
-	}
+		// tenantZoneCache is like zoneCache, for the objects of secondary
+		// tenants, whose IDs overlap with those of the system tenant. It's
+		// lazily initialized. See GetZoneConfigForTenantKey.
+		tenantZoneCache map[tenantObjectID]zoneEntry
+		// internedZones maps the encoding of each zone config in zoneCache to
+		// a single shared instance. Most tables have the same hydrated zone
+		// config, which would otherwise be decoded and retained once per
+		// table. See internZoneConfigLocked.
+		internedZones map[string]*zonepb.ZoneConfig
+		// descInfoCache caches the system tenant descriptors interpreted by
+		// GetDescriptorInfo.
+		descInfoCache map[ObjectID]DescriptorInfo
+		// zoneChanges is lazily initialized and shared with the snapshots
+		// preceding and succeeding this one. See NotifyZoneChanges.
+		zoneChanges *zoneChangeRegistry
+	}
+	// epoch is assigned when the snapshot is installed in a
+	// SystemConfigHolder. See Epoch.
+	epoch atomic.Uint64
+	// preload signals the completion of PreloadZoneConfigs. It's shared with
+	// the snapshots derived from this one through ApplyDelta.
+	preload *preloadState
 }
 
 // NewSystemConfig returns an initialized instance of SystemConfig.
@@ -98,9 +121,24 @@ func NewSystemConfig(defaultZoneConfig *zonepb.ZoneConfig) *SystemConfig {
 	sc.DefaultZoneConfig = defaultZoneConfig
 	sc.mu.zoneCache = map[ObjectID]zoneEntry{}
 	sc.mu.shouldSplitCache = map[ObjectID]bool{}
+	sc.mu.internedZones = map[string]*zonepb.ZoneConfig{}
+	sc.mu.descInfoCache = map[ObjectID]DescriptorInfo{}
+	sc.preload = &preloadState{}
 	return sc
 }
 
+// Epoch returns the epoch which the snapshot was assigned when it was
+// installed in a SystemConfigHolder, or zero if it wasn't. Epochs increase with
+// every snapshot installed in a holder, so a consumer can tell that the
+// snapshot it's using was replaced by comparing its epoch with that of the
+// holder.
+func (s *SystemConfig) Epoch() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.epoch.Load()
+}
+
 // Equal checks for equality.
 //
 // It assumes that s.Values and other.Values are sorted in key order.
@@ -124,6 +162,132 @@ func (s *SystemConfig) Equal(other *SystemConfigEntries) bool {
 	return true
 }
 
+// SystemConfigDelta describes how the zone configs stored in a SystemConfig
+// changed relative to a previous snapshot.
+type SystemConfigDelta struct {
+	// ChangedZones contains, in ascending order, the IDs of the objects whose
+	// zone config entry was added, removed, or modified.
+	ChangedZones []ObjectID
+	// DefaultZoneChanged is set if either the zone config for the default zone
+	// or the fallback DefaultZoneConfig changed. Every zone inherits from the
+	// default zone, so consumers should treat all objects as changed.
+	DefaultZoneChanged bool
+}
+
+// IsEmpty returns whether the delta contains no changes.
+func (d SystemConfigDelta) IsEmpty() bool {
+	return len(d.ChangedZones) == 0 && !d.DefaultZoneChanged
+}
+
+// Diff computes which system tenant zone configs changed between prev and
+// this SystemConfig. Entries whose value was rewritten without modification
+// (i.e. only their timestamp changed) are not considered changed.
+//
+// It assumes that s.Values and prev.Values are sorted in key order.
+func (s *SystemConfig) Diff(prev *SystemConfig) SystemConfigDelta {
+	var delta SystemConfigDelta
+	delta.DefaultZoneChanged = !s.DefaultZoneConfig.Equal(prev.DefaultZoneConfig)
+
+	markChanged := func(key roachpb.Key) {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(key)
+		if err != nil {
+			// Every key within the zones table span is a zone key, so this can't
+			// happen for well-formed snapshots.
+			return
+		}
+		objID := ObjectID(id)
+		if n := len(delta.ChangedZones); n > 0 && delta.ChangedZones[n-1] == objID {
+			return
+		}
+		delta.ChangedZones = append(delta.ChangedZones, objID)
+		if objID == keys.RootNamespaceID {
+			delta.DefaultZoneChanged = true
+		}
+	}
+
+	// Both slices are sorted, and zone keys sort in object ID order, so a
+	// single merge pass yields the changed IDs in ascending order.
+	cur, old := s.zoneValues(), prev.zoneValues()
+	for i, j := 0, 0; i < len(cur) || j < len(old); {
+		switch {
+		case j == len(old) || (i < len(cur) && cur[i].Key.Compare(old[j].Key) < 0):
+			markChanged(cur[i].Key)
+			i++
+		case i == len(cur) || cur[i].Key.Compare(old[j].Key) > 0:
+			markChanged(old[j].Key)
+			j++
+		default:
+			if !cur[i].Value.EqualTagAndData(old[j].Value) {
+				markChanged(cur[i].Key)
+			}
+			i++
+			j++
+		}
+	}
+	return delta
+}
+
+// ApplyDelta returns a new SystemConfig whose values are those of this
+// SystemConfig updated with the supplied KVs, which need not be sorted. A KV
+// whose value isn't present deletes its key. If there are several KVs for a
+// key, or the key already has a newer value, the latest value wins. This
+// SystemConfig is left unmodified, as snapshots may be read concurrently.
+//
+// Unlike rebuilding the config from the merged values, this doesn't re-sort
+// the existing values: only the KVs are sorted and then merged in, which
+// matters for large schemas whose every change would otherwise cost a full
+// sort. The caches of the returned SystemConfig start out empty, but its
+// ZoneConfigsReady channel is that of this SystemConfig.
+//
+// It assumes that s.Values is sorted in key order.
+func (s *SystemConfig) ApplyDelta(kvs []roachpb.KeyValue) *SystemConfig {
+	updates := append([]roachpb.KeyValue(nil), kvs...)
+	sort.Slice(updates, func(i, j int) bool {
+		if cmp := updates[i].Key.Compare(updates[j].Key); cmp != 0 {
+			return cmp < 0
+		}
+		return updates[i].Value.Timestamp.Less(updates[j].Value.Timestamp)
+	})
+
+	values := make([]roachpb.KeyValue, 0, len(s.Values)+len(updates))
+	rest := s.Values
+	for i, kv := range updates {
+		if i+1 < len(updates) && updates[i+1].Key.Equal(kv.Key) {
+			// Superseded by a later update to the same key.
+			continue
+		}
+		n := sort.Search(len(rest), func(j int) bool {
+			return kv.Key.Compare(rest[j].Key) <= 0
+		})
+		values = append(values, rest[:n]...)
+		rest = rest[n:]
+		if len(rest) > 0 && rest[0].Key.Equal(kv.Key) {
+			existing := rest[0]
+			rest = rest[1:]
+			if kv.Value.Timestamp.Less(existing.Value.Timestamp) {
+				values = append(values, existing)
+				continue
+			}
+		}
+		if kv.Value.IsPresent() {
+			values = append(values, kv)
+		}
+	}
+	values = append(values, rest...)
+
+	cfg := NewSystemConfig(s.DefaultZoneConfig)
+	cfg.Values = values
+	cfg.preload = s.preload
+	return cfg
+}
+
+// zoneValues returns the slice of s.Values which belongs to the system
+// tenant's zones table.
+func (s *SystemConfig) zoneValues() []roachpb.KeyValue {
+	prefix := ZonesPrimaryIndexPrefix(keys.SystemSQLCodec)
+	return s.Values[s.getIndexBound(prefix):s.getIndexBound(prefix.PrefixEnd())]
+}
+
 // getSystemTenantDesc looks for the descriptor value given a key, if a
 // zone is created in a test without creating a Descriptor, a dummy
 // descriptor is returned. If the key is invalid in decoding an ID,
@@ -309,27 +473,38 @@ func (s *SystemConfig) getZoneConfigForKey(
 	if err != nil {
 		return 0, nil, err
 	}
-	if entry.zone != nil {
-		if entry.placeholder != nil {
-			if subzone, _ := entry.placeholder.GetSubzoneForKeySuffix(suffix); subzone != nil {
-				if indexSubzone := entry.placeholder.GetSubzone(subzone.IndexID, ""); indexSubzone != nil {
-					subzone.Config.InheritFromParent(&indexSubzone.Config)
-				}
-				subzone.Config.InheritFromParent(entry.zone)
-				return id, &subzone.Config, nil
-			}
-		} else if subzone, _ := entry.zone.GetSubzoneForKeySuffix(suffix); subzone != nil {
-			if indexSubzone := entry.zone.GetSubzone(subzone.IndexID, ""); indexSubzone != nil {
-				subzone.Config.InheritFromParent(&indexSubzone.Config)
-			}
-			subzone.Config.InheritFromParent(entry.zone)
-			return id, &subzone.Config, nil
-		}
-		return id, entry.zone, nil
+	if zone := entry.zoneConfigForKeySuffix(suffix); zone != nil {
+		return id, zone, nil
 	}
 	return id, s.DefaultZoneConfig, nil
 }
 
+// zoneConfigForKeySuffix returns the zone config which applies to the keys of
+// the object of the entry with the given suffix, i.e. that of the subzone
+// whose span contains the suffix, if any, hydrated from the zone config of
+// the object. It returns nil if the object has no zone config.
+func (e zoneEntry) zoneConfigForKeySuffix(suffix []byte) *zonepb.ZoneConfig {
+	if e.zone == nil {
+		return nil
+	}
+	subzones := e.zone
+	if e.placeholder != nil {
+		subzones = e.placeholder
+	}
+	if subzone, _ := subzones.GetSubzoneForKeySuffix(suffix); subzone != nil {
+		// The subzones may be shared with the entries of other objects (see
+		// internZoneConfigLocked), whose parents differ, so a copy of the
+		// config of the subzone is hydrated rather than the subzone itself.
+		cfg := subzone.Config
+		if indexSubzone := subzones.GetSubzone(subzone.IndexID, ""); indexSubzone != nil {
+			cfg.InheritFromParent(&indexSubzone.Config)
+		}
+		cfg.InheritFromParent(e.zone)
+		return &cfg
+	}
+	return e.zone
+}
+
 // GetSpanConfigForKey looks of the span config for the given key. It's part of
 // spanconfig.StoreReader interface. Note that it is only usable for the system
 // tenant config.
@@ -386,20 +561,21 @@ func DecodeKeyIntoZoneIDAndSuffix(
 }
 
 // GetZoneConfigForObject returns the combined zone config for the given object
-// identifier and SQL codec.
+// identifier and SQL codec. The view is nil if the object has no zone config.
+// The zone config is shared with the cache, hence the read-only view.
 //
 // NOTE: any subzones from the zone placeholder will be automatically merged
 // into the cached zone so the caller doesn't need special-case handling code.
 func (s *SystemConfig) GetZoneConfigForObject(
 	codec keys.SQLCodec, id ObjectID,
-) (*zonepb.ZoneConfig, error) {
+) (zonepb.ZoneConfigView, error) {
 	var entry zoneEntry
 	var err error
 	entry, err = s.getZoneEntry(codec, id)
 	if err != nil {
-		return nil, err
+		return zonepb.ZoneConfigView{}, err
 	}
-	return entry.combined, nil
+	return zonepb.MakeZoneConfigView(entry.combined), nil
 }
 
 // PurgeZoneConfigCache allocates a new zone config cache in this system config
@@ -413,19 +589,26 @@ func (s *SystemConfig) PurgeZoneConfigCache() {
 	if len(s.mu.zoneCache) != 0 {
 		s.mu.zoneCache = map[ObjectID]zoneEntry{}
 	}
+	s.mu.tenantZoneCache = nil
+	if len(s.mu.internedZones) != 0 {
+		s.mu.internedZones = map[string]*zonepb.ZoneConfig{}
+	}
+	if len(s.mu.descInfoCache) != 0 {
+		s.mu.descInfoCache = map[ObjectID]DescriptorInfo{}
+	}
 	if len(s.mu.shouldSplitCache) != 0 {
 		s.mu.shouldSplitCache = map[ObjectID]bool{}
 	}
 }
 
-// getZoneEntry returns the zone entry for the given system-tenant
-// object ID. In the fast path, the zone is already in the cache, and is
+// getZoneEntry returns the zone entry for the given object ID of the
+// tenant of the codec. In the fast path, the zone is already in the cache, and is
 // directly returned. Otherwise, getZoneEntry will hydrate new
 // zonepb.ZoneConfig(s) from the SystemConfig and install them as an
 // entry in the cache.
 func (s *SystemConfig) getZoneEntry(codec keys.SQLCodec, id ObjectID) (zoneEntry, error) {
 	s.mu.RLock()
-	entry, ok := s.mu.zoneCache[id]
+	entry, ok := s.cachedZoneEntryLocked(codec, id)
 	s.mu.RUnlock()
 	if ok {
 		return entry, nil
@@ -438,6 +621,14 @@ func (s *SystemConfig) getZoneEntry(codec keys.SQLCodec, id ObjectID) (zoneEntry
 		return zoneEntry{}, err
 	}
 	if zone != nil {
+		// The zone configs are retained by the cache, so make their constraints
+		// share their strings with those of the other cached zone configs.
+		if cache {
+			zone.InternConstraintStrings()
+			if placeholder != nil {
+				placeholder.InternConstraintStrings()
+			}
+		}
 		entry := zoneEntry{zone: zone, placeholder: placeholder, combined: zone}
 		if placeholder != nil {
 			// Merge placeholder with zone by copying over subzone information.
@@ -450,7 +641,14 @@ func (s *SystemConfig) getZoneEntry(codec keys.SQLCodec, id ObjectID) (zoneEntry
 
 		if cache {
 			s.mu.Lock()
-			s.mu.zoneCache[id] = entry
+			entry.zone = s.internZoneConfigLocked(entry.zone)
+			if placeholder != nil {
+				entry.placeholder = s.internZoneConfigLocked(entry.placeholder)
+				entry.combined = s.internZoneConfigLocked(entry.combined)
+			} else {
+				entry.combined = entry.zone
+			}
+			s.cacheZoneEntryLocked(codec, id, entry)
 			s.mu.Unlock()
 		}
 		return entry, nil
@@ -458,6 +656,38 @@ func (s *SystemConfig) getZoneEntry(codec keys.SQLCodec, id ObjectID) (zoneEntry
 	return zoneEntry{}, nil
 }
 
+// internZoneConfigLocked returns the zone config shared by all the cached
+// entries whose zone configs have the same encoding as the supplied one,
+// registering it as that shared instance if there is none yet. Since the
+// zone configs of different objects may be shared, cached zone configs must
+// never be mutated; readers which hydrate them, such as
+// zoneEntry.zoneConfigForKeySuffix, hydrate copies. s.mu must be held
+// exclusively.
+//
+// The audit info of the zone config is left out of the cache: it's only read
+// from system.zones, and it would otherwise keep the zone configs written by
+// different statements from being shared.
+func (s *SystemConfig) internZoneConfigLocked(zone *zonepb.ZoneConfig) *zonepb.ZoneConfig {
+	if s.mu.internedZones == nil {
+		s.mu.internedZones = map[string]*zonepb.ZoneConfig{}
+	}
+	if zone.AuditInfo != nil {
+		withoutAuditInfo := *zone
+		withoutAuditInfo.AuditInfo = nil
+		zone = &withoutAuditInfo
+	}
+	buf, err := protoutil.Marshal(zone)
+	if err != nil {
+		// The zone config can't be encoded, so it can't be shared either.
+		return zone
+	}
+	if interned, ok := s.mu.internedZones[string(buf)]; ok {
+		return interned
+	}
+	s.mu.internedZones[string(buf)] = zone
+	return zone
+}
+
 var staticSplits = []roachpb.RKey{
 	roachpb.RKey(keys.NodeLivenessPrefix),           // end of meta records / start of node liveness span
 	roachpb.RKey(keys.NodeLivenessKeyMax),           // end of node liveness span
@@ -491,6 +721,9 @@ func StaticSplits() []roachpb.RKey {
 // The system-config range is somewhat special in that it can contain multiple
 // SQL tables (/table/0-/table/<max-system-config-desc>) within a single range.
 //
+// Within a table, splits are required at the boundaries of its subzones and at
+// the start of the rows of the split hints of its zone config.
+//
 // Splits are also required between secondary tenants (i.e. /tenant/<id>).
 // However, splits are not required between the tables of secondary tenants.
 func (s *SystemConfig) ComputeSplitKey(
@@ -573,11 +806,12 @@ func (s *SystemConfig) systemTenantTableBoundarySplitKey(
 			if err := zoneVal.GetProto(&zone); err != nil {
 				// An error while decoding the zone proto is unfortunate, but logging a
 				// message here would be excessively spammy. Just move on, which
-				// effectively assumes there are no subzones for this table.
+				// effectively assumes there are no subzones or split hints for
+				// this table.
 				continue
 			}
 			// This logic is analogous to the well-commented static split logic above.
-			for _, s := range zone.SubzoneSplits() {
+			for _, s := range zone.SplitKeys() {
 				subzoneKey := append(tableKey, s...)
 				if startKey.Less(subzoneKey) {
 					if subzoneKey.Less(endKey) {
@@ -0,0 +1,119 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/errors"
+)
+
+// debugSnapshotVersion is the version of the format written by
+// WriteDebugSnapshot. It must be bumped whenever the format changes in a way
+// which ReadDebugSnapshot of older versions can't load.
+const debugSnapshotVersion = 1
+
+// debugSnapshot is the JSON encoding of a SystemConfig written by
+// WriteDebugSnapshot.
+type debugSnapshot struct {
+	Version int `json:"version"`
+	// Entries is the protobuf encoding of the SystemConfigEntries.
+	Entries []byte `json:"entries"`
+	// DefaultZoneConfig is the protobuf encoding of the default zone config,
+	// which is empty if there is none.
+	DefaultZoneConfig []byte `json:"default_zone_config,omitempty"`
+	// Zones are the zone configs of the entries, decoded for the benefit of
+	// humans reading the snapshot. They're not read back by
+	// ReadDebugSnapshot.
+	Zones []debugSnapshotZone `json:"zones"`
+}
+
+// debugSnapshotZone is a decoded zone config in a debugSnapshot.
+type debugSnapshotZone struct {
+	ID ObjectID `json:"id"`
+	// Config is the protobuf text encoding of the zone config, including its
+	// subzones, or the reason it couldn't be decoded.
+	Config string `json:"config"`
+}
+
+// WriteDebugSnapshot writes a gzipped, self-contained dump of the system
+// config to w, for debugging purposes: it holds the entries and default zone
+// config, from which ReadDebugSnapshot reconstructs an equivalent
+// SystemConfig, e.g. to reproduce allocator decisions offline, along with the
+// decoded zone configs. Zone configs which can't be decoded don't fail the
+// dump, since it's most useful when something is wrong.
+func (s *SystemConfig) WriteDebugSnapshot(w io.Writer) error {
+	snapshot := debugSnapshot{Version: debugSnapshotVersion}
+	var err error
+	if snapshot.Entries, err = protoutil.Marshal(&s.SystemConfigEntries); err != nil {
+		return errors.Wrap(err, "encoding entries")
+	}
+	if s.DefaultZoneConfig != nil {
+		if snapshot.DefaultZoneConfig, err = protoutil.Marshal(s.DefaultZoneConfig); err != nil {
+			return errors.Wrap(err, "encoding default zone config")
+		}
+	}
+	for _, kv := range s.zoneValues() {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(kv.Key)
+		if err != nil {
+			continue
+		}
+		zone := debugSnapshotZone{ID: ObjectID(id)}
+		var z zonepb.ZoneConfig
+		if err := kv.Value.GetProto(&z); err != nil {
+			zone.Config = errors.Wrap(err, "decoding zone config").Error()
+		} else {
+			zone.Config = z.MarshalProtoText()
+		}
+		snapshot.Zones = append(snapshot.Zones, zone)
+	}
+
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(&snapshot); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadDebugSnapshot reads a dump written by WriteDebugSnapshot, and returns the
+// system config it was written from.
+func ReadDebugSnapshot(r io.Reader) (*SystemConfig, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing system config snapshot")
+	}
+	defer gz.Close()
+	var snapshot debugSnapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "decoding system config snapshot")
+	}
+	if snapshot.Version != debugSnapshotVersion {
+		return nil, errors.Newf("unsupported system config snapshot version %d, expected %d",
+			snapshot.Version, debugSnapshotVersion)
+	}
+	var defaultZone *zonepb.ZoneConfig
+	if len(snapshot.DefaultZoneConfig) > 0 {
+		defaultZone = &zonepb.ZoneConfig{}
+		if err := protoutil.Unmarshal(snapshot.DefaultZoneConfig, defaultZone); err != nil {
+			return nil, errors.Wrap(err, "decoding default zone config")
+		}
+	}
+	cfg := NewSystemConfig(defaultZone)
+	if err := protoutil.Unmarshal(snapshot.Entries, &cfg.SystemConfigEntries); err != nil {
+		return nil, errors.Wrap(err, "decoding entries")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"sync/atomic"
+
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// SystemConfigHolder holds the current SystemConfig snapshot, which may be
+// swapped atomically for a newer one. Every snapshot installed by Swap is
+// assigned the next epoch of the holder, so that consumers which make several
+// decisions based on a snapshot, e.g. for every range of a batch, can detect
+// that it was replaced while they were using it, and retry with the current
+// one rather than make inconsistent decisions:
+//
+//	for {
+//		cfg := h.Load()
+//		// Make decisions based on cfg.
+//		if !h.IsStale(cfg) {
+//			break
+//		}
+//	}
+//
+// ReadConsistent implements this loop. The zero value holds no snapshot.
+type SystemConfigHolder struct {
+	// mu serializes Swap.
+	mu struct {
+		syncutil.Mutex
+		epoch uint64
+	}
+	cur atomic.Pointer[SystemConfig]
+}
+
+// Load returns the current snapshot, or nil if none was installed.
+func (h *SystemConfigHolder) Load() *SystemConfig {
+	return h.cur.Load()
+}
+
+// Epoch returns the epoch of the current snapshot, or zero if none was
+// installed.
+func (h *SystemConfigHolder) Epoch() uint64 {
+	return h.Load().Epoch()
+}
+
+// Swap installs cfg as the current snapshot, assigning it the next epoch of
+// the holder, and returns the snapshot it replaced, if any. Installing a
+// snapshot again assigns it a new epoch, so a snapshot must not be installed
+// in several holders.
+func (h *SystemConfigHolder) Swap(cfg *SystemConfig) (prev *SystemConfig) {
+	if cfg == nil {
+		panic(errors.AssertionFailedf("cannot install a nil SystemConfig"))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mu.epoch++
+	cfg.epoch.Store(h.mu.epoch)
+	return h.cur.Swap(cfg)
+}
+
+// IsStale returns whether cfg is no longer the current snapshot.
+func (h *SystemConfigHolder) IsStale(cfg *SystemConfig) bool {
+	return h.Load() != cfg
+}
+
+// ReadConsistent calls fn with the current snapshot until the snapshot is
+// still current once fn returns, and returns the error of the last call. It's
+// meant for consumers which make several decisions based on the snapshot, which
+// must all be made with the same one. fn may thus be called several times, and
+// its side effects must be discarded when it's called again.
+//
+// The snapshot passed to fn is nil if none was installed.
+func (h *SystemConfigHolder) ReadConsistent(fn func(cfg *SystemConfig) error) error {
+	for {
+		cfg := h.Load()
+		err := fn(cfg)
+		if !h.IsStale(cfg) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/util/ctxgroup"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// preloadState signals the completion of SystemConfig.PreloadZoneConfigs.
+type preloadState struct {
+	init, done sync.Once
+	ready      chan struct{}
+}
+
+// readyCh returns the channel which is closed once the zone configs are
+// preloaded, creating it if needed.
+func (p *preloadState) readyCh() chan struct{} {
+	p.init.Do(func() { p.ready = make(chan struct{}) })
+	return p.ready
+}
+
+// PreloadZoneConfigs warms the zone config cache of the snapshot, so that the
+// first lookups of zone configs, e.g. by the split and replicate queues when a
+// node receives its first system config, don't pay for decoding and hydrating
+// them on a large schema. The zone config of every system tenant object which
+// has one is decoded and hydrated through ZoneConfigHook, as by
+// GetZoneConfigForObject, across the given number of goroutines, and is then
+// validated.
+//
+// The problems found are returned in object ID order; the objects whose zone
+// config couldn't be decoded aren't cached, and are looked up again on use.
+// The channel returned by ZoneConfigsReady is closed once PreloadZoneConfigs
+// returns, including when the context is canceled, in which case the remaining
+// zone configs are decoded lazily as usual.
+func (s *SystemConfig) PreloadZoneConfigs(ctx context.Context, workers int) []TargetedError {
+	defer s.preload.done.Do(func() { close(s.preload.readyCh()) })
+
+	var ids []ObjectID
+	for _, kv := range s.zoneValues() {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(kv.Key)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, ObjectID(id))
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	var mu struct {
+		syncutil.Mutex
+		errs []TargetedError
+	}
+	report := func(id ObjectID, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		mu.errs = append(mu.errs, TargetedError{ID: id, Target: s.preloadTarget(id), Err: err})
+	}
+	if err := ctxgroup.GroupWorkers(ctx, workers, func(ctx context.Context, worker int) error {
+		for i := worker; i < len(ids); i += workers {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			entry, err := s.getZoneEntry(keys.SystemSQLCodec, ids[i])
+			if err != nil {
+				report(ids[i], errors.Wrap(err, "decoding zone config"))
+				continue
+			}
+			if entry.combined == nil {
+				continue
+			}
+			if err := entry.combined.Validate(); err != nil {
+				report(ids[i], err)
+			}
+		}
+		return nil
+	}); err != nil {
+		mu.errs = append(mu.errs, TargetedError{Err: errors.Wrap(err, "preloading zone configs")})
+	}
+	sort.SliceStable(mu.errs, func(i, j int) bool { return mu.errs[i].ID < mu.errs[j].ID })
+	return mu.errs
+}
+
+// preloadTarget returns the target of the zone config of the object, or an
+// empty string if it can't be resolved.
+func (s *SystemConfig) preloadTarget(id ObjectID) string {
+	zs, err := zonepb.ZoneSpecifierFromID(uint32(id), s.resolveIDForZoneExport)
+	if err != nil {
+		return ""
+	}
+	return tree.AsString(&zs)
+}
+
+// ZoneConfigsReady returns a channel which is closed once PreloadZoneConfigs
+// has returned, on this SystemConfig or on the one it was derived from through
+// ApplyDelta. The systemconfigwatcher preloads the zone configs of the first
+// complete system config it receives.
+func (s *SystemConfig) ZoneConfigsReady() <-chan struct{} {
+	return s.preload.readyCh()
+}
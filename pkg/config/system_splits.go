@@ -0,0 +1,56 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// SplitIterator iterates over the split boundaries within a span, in
+// ascending order. See SystemConfig.SplitBoundaries.
+type SplitIterator struct {
+	cfg      *SystemConfig
+	key, end roachpb.RKey
+	done     bool
+}
+
+// SplitBoundaries returns an iterator over the keys within span at which
+// ComputeSplitKey requires splits, i.e. the boundaries of static splits,
+// tables, subzones and secondary tenants, and the split hints of tables. This
+// allows work over the span to be partitioned along the boundaries of zone
+// configs. The boundaries are computed lazily, one per call to Next, so the
+// span can be arbitrarily large. The span's start key is never returned as a
+// boundary.
+func (s *SystemConfig) SplitBoundaries(span roachpb.RSpan) SplitIterator {
+	return SplitIterator{cfg: s, key: span.Key, end: span.EndKey}
+}
+
+// Next returns the next split boundary, or nil once there are no more
+// boundaries within the span.
+func (it *SplitIterator) Next(ctx context.Context) (roachpb.RKey, error) {
+	if it.done {
+		return nil, nil
+	}
+	split, err := it.cfg.ComputeSplitKey(ctx, it.key, it.end)
+	if err != nil {
+		return nil, err
+	}
+	if split == nil {
+		it.done = true
+		return nil, nil
+	}
+	// ComputeSplitKey only returns keys strictly after the start key, so the
+	// iteration makes progress.
+	it.key = split
+	return split, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/errors"
+)
+
+// tenantObjectID identifies an object of a secondary tenant.
+type tenantObjectID struct {
+	tenantID roachpb.TenantID
+	id       ObjectID
+}
+
+// makeTenantObjectID returns the identifier of the object with the given ID
+// in the tenant of the codec, which must not be the system tenant.
+func makeTenantObjectID(codec keys.SQLCodec, id ObjectID) tenantObjectID {
+	_, tenantID, err := keys.DecodeTenantPrefixE(codec.TenantPrefix())
+	if err != nil {
+		panic(errors.NewAssertionErrorWithWrappedErrf(err, "decoding tenant prefix"))
+	}
+	return tenantObjectID{tenantID: tenantID, id: id}
+}
+
+// cachedZoneEntryLocked returns the cached zone entry of the object with the
+// given ID in the tenant of the codec. s.mu must be held.
+func (s *SystemConfig) cachedZoneEntryLocked(codec keys.SQLCodec, id ObjectID) (zoneEntry, bool) {
+	if codec.ForSystemTenant() {
+		entry, ok := s.mu.zoneCache[id]
+		return entry, ok
+	}
+	entry, ok := s.mu.tenantZoneCache[makeTenantObjectID(codec, id)]
+	return entry, ok
+}
+
+// cacheZoneEntryLocked caches the zone entry of the object with the given ID
+// in the tenant of the codec. s.mu must be held exclusively.
+func (s *SystemConfig) cacheZoneEntryLocked(codec keys.SQLCodec, id ObjectID, entry zoneEntry) {
+	if codec.ForSystemTenant() {
+		s.mu.zoneCache[id] = entry
+		return
+	}
+	if s.mu.tenantZoneCache == nil {
+		s.mu.tenantZoneCache = map[tenantObjectID]zoneEntry{}
+	}
+	s.mu.tenantZoneCache[makeTenantObjectID(codec, id)] = entry
+}
+
+// DecodeTenantKeyIntoZoneIDAndSuffix is like DecodeKeyIntoZoneIDAndSuffix, for
+// the keys of the given tenant, whose zone configs are stored in the
+// system.zones table of the tenant. The keys of secondary tenants which aren't
+// in a table fall under the RANGE default zone of the tenant, and those of its
+// system tables under its system database. It returns false if the key isn't
+// in the keyspace of the tenant.
+func DecodeTenantKeyIntoZoneIDAndSuffix(
+	tenantID roachpb.TenantID, key roachpb.RKey,
+) (id ObjectID, keySuffix []byte, ok bool) {
+	_, keyTenantID, err := keys.DecodeTenantPrefixE(key.AsRawKey())
+	if err != nil || keyTenantID != tenantID {
+		return 0, nil, false
+	}
+	if tenantID == roachpb.SystemTenantID {
+		id, keySuffix = DecodeKeyIntoZoneIDAndSuffix(keys.SystemSQLCodec, key)
+		return id, keySuffix, true
+	}
+	id, keySuffix, ok = DecodeObjectID(keys.MakeSQLCodec(tenantID), key)
+	if !ok {
+		return keys.RootNamespaceID, nil, true
+	}
+	if id <= keys.SystemDatabaseID || keys.IsPseudoTableID(uint32(id)) {
+		id = keys.SystemDatabaseID
+	}
+	return id, keySuffix, true
+}
+
+// GetZoneConfigForTenantKey looks up the zone config which applies to the given
+// key of the given tenant. For the system tenant, it's the same as for
+// GetSpanConfigForKey.
+//
+// The keys of a secondary tenant resolve to the zone configs set by the tenant
+// itself, when the snapshot has the descriptors and the system.zones table of
+// the tenant, in which case the returned ID is that of the object in the
+// tenant. Otherwise, or if the tenant didn't set any zone config which
+// applies to the key, they resolve to the zone config set by the host for
+// every secondary tenant, i.e. that of keys.TenantsRangesID, which is the
+// returned ID. An error is returned if the key isn't in the keyspace of the
+// tenant.
+func (s *SystemConfig) GetZoneConfigForTenantKey(
+	tenantID roachpb.TenantID, key roachpb.RKey,
+) (ObjectID, *zonepb.ZoneConfig, error) {
+	id, suffix, ok := DecodeTenantKeyIntoZoneIDAndSuffix(tenantID, key)
+	if !ok {
+		return 0, nil, errors.Newf("key %s is not in the keyspace of tenant %s", key, tenantID)
+	}
+	if tenantID == roachpb.SystemTenantID {
+		return s.getZoneConfigForKey(keys.SystemSQLCodec, key)
+	}
+	entry, err := s.getZoneEntry(keys.MakeSQLCodec(tenantID), id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if zone := entry.zoneConfigForKeySuffix(suffix); zone != nil {
+		return id, zone, nil
+	}
+	// The keys of secondary tenants fall under keys.TenantsRangesID in the
+	// keyspace of the system tenant.
+	return s.getZoneConfigForKey(keys.SystemSQLCodec, key)
+}
@@ -11,27 +11,44 @@
 package config_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/bootstrap"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/catalogkeys"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/catpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/catprivilege"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/systemschema"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/datapathutils"
 	"github.com/cockroachdb/cockroach/pkg/util/encoding"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/errors"
+	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 // TODO(benesch): Don't reinvent the key encoding here.
@@ -94,6 +111,46 @@ func zoneConfig(descID descpb.ID, spans ...zonepb.SubzoneSpan) roachpb.KeyValue
 	return kv
 }
 
+// descriptorKV returns the system.descriptor row of the given descriptor.
+func descriptorKV(id descpb.ID, desc *descpb.Descriptor) roachpb.KeyValue {
+	kv := roachpb.KeyValue{Key: catalogkeys.MakeDescMetadataKey(keys.SystemSQLCodec, id)}
+	if err := kv.Value.SetProto(desc); err != nil {
+		panic(err)
+	}
+	return kv
+}
+
+// databaseKV returns the system.descriptor row of a database.
+func databaseKV(id descpb.ID, name string) roachpb.KeyValue {
+	return descriptorKV(id, &descpb.Descriptor{Union: &descpb.Descriptor_Database{
+		Database: &descpb.DatabaseDescriptor{ID: id, Name: name},
+	}})
+}
+
+// tableKV returns the system.descriptor row of a table.
+func tableKV(table *descpb.TableDescriptor) roachpb.KeyValue {
+	return descriptorKV(table.ID, &descpb.Descriptor{Union: &descpb.Descriptor_Table{Table: table}})
+}
+
+// testTable returns the descriptor of a table with a primary index, whose ID
+// is 1, and a secondary index named idx, whose ID is 2.
+func testTable(id, parentID descpb.ID, name string) *descpb.TableDescriptor {
+	return &descpb.TableDescriptor{
+		ID: id, ParentID: parentID, Name: name,
+		PrimaryIndex: descpb.IndexDescriptor{ID: 1, Name: name + "_pkey"},
+		Indexes:      []descpb.IndexDescriptor{{ID: 2, Name: "idx"}},
+	}
+}
+
+// zoneConfigKV returns the system.zones row of the given zone config.
+func zoneConfigKV(id descpb.ID, zone zonepb.ZoneConfig) roachpb.KeyValue {
+	kv := roachpb.KeyValue{Key: config.MakeZoneKey(keys.SystemSQLCodec, id)}
+	if err := kv.Value.SetProto(&zone); err != nil {
+		panic(err)
+	}
+	return kv
+}
+
 func subzone(start, end string) zonepb.SubzoneSpan {
 	return zonepb.SubzoneSpan{Key: []byte(start), EndKey: []byte(end)}
 }
@@ -540,6 +597,100 @@ func TestComputeSplitKeyTenantBoundaries(t *testing.T) {
 	}
 }
 
+func TestSplitBoundaries(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	schema := bootstrap.MakeMetadataSchema(
+		keys.SystemSQLCodec, zonepb.DefaultZoneConfigRef(), zonepb.DefaultSystemZoneConfigRef(),
+	)
+	kvs, _ /* splits */ := schema.GetInitialValues()
+	start := bootstrap.TestingUserDescID(0)
+	kvs = append(kvs, descriptor(start), descriptor(start+1), descriptor(start+5),
+		zoneConfig(descpb.ID(start+1), subzone("a", ""), subzone("c", "e")),
+		zoneConfig(descpb.ID(start+5), subzone("b", ""), subzone("c", "d"), subzone("d", "")))
+	sort.Sort(roachpb.KeyValueByKey(kvs))
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = kvs
+
+	testCases := []struct {
+		start, end roachpb.RKey
+		expected   []roachpb.RKey
+	}{
+		{tkey(start), tkey(start + 1), nil},
+		{tkey(start+1, "b"), tkey(start+1, "c"), nil},
+		{tkey(start), tkey(start + 6), []roachpb.RKey{
+			tkey(start + 1), tkey(start+1, "a"), tkey(start+1, "b"), tkey(start+1, "c"),
+			tkey(start+1, "e"), tkey(start + 5), tkey(start+5, "b"), tkey(start+5, "c"),
+			tkey(start+5, "d"), tkey(start+5, "e"),
+		}},
+		{tkey(start+1, "ba"), tkey(start+5, "c"), []roachpb.RKey{
+			tkey(start+1, "c"), tkey(start+1, "e"), tkey(start + 5), tkey(start+5, "b"),
+		}},
+	}
+	ctx := context.Background()
+	for _, tc := range testCases {
+		it := cfg.SplitBoundaries(roachpb.RSpan{Key: tc.start, EndKey: tc.end})
+		var boundaries []roachpb.RKey
+		for {
+			split, err := it.Next(ctx)
+			require.NoError(t, err)
+			if split == nil {
+				break
+			}
+			boundaries = append(boundaries, split)
+		}
+		require.Equal(t, tc.expected, boundaries, "[%s, %s)", tc.start, tc.end)
+
+		// The iterator remains exhausted.
+		split, err := it.Next(ctx)
+		require.NoError(t, err)
+		require.Nil(t, split)
+	}
+}
+
+func TestSplitBoundariesSplitHints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	schema := bootstrap.MakeMetadataSchema(
+		keys.SystemSQLCodec, zonepb.DefaultZoneConfigRef(), zonepb.DefaultSystemZoneConfigRef(),
+	)
+	kvs, _ /* splits */ := schema.GetInitialValues()
+	start := bootstrap.TestingUserDescID(0)
+	zoneKV := zoneConfigKV(descpb.ID(start+1), zonepb.ZoneConfig{
+		SubzoneSpans: []zonepb.SubzoneSpan{subzone("c", "e")},
+		// The hints needn't be sorted, and a hint which is also the boundary of
+		// a subzone is only split at once. The hint of a row splits the table at
+		// the start of the row.
+		SplitHints: []roachpb.Key{
+			roachpb.Key("d"), roachpb.Key("a"), roachpb.Key("c"), roachpb.Key("\x89\x92\x89\x89"),
+		},
+	})
+	kvs = append(kvs, descriptor(start), descriptor(start+1), zoneKV)
+	sort.Sort(roachpb.KeyValueByKey(kvs))
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = kvs
+
+	ctx := context.Background()
+	it := cfg.SplitBoundaries(roachpb.RSpan{Key: tkey(start), EndKey: tkey(start + 2)})
+	var boundaries []roachpb.RKey
+	for {
+		split, err := it.Next(ctx)
+		require.NoError(t, err)
+		if split == nil {
+			break
+		}
+		boundaries = append(boundaries, split)
+	}
+	require.Equal(t, []roachpb.RKey{
+		tkey(start + 1), tkey(start+1, "a"), tkey(start+1, "c"), tkey(start+1, "d"), tkey(start+1, "e"),
+		tkey(start+1, "\x89\x92"),
+	}, boundaries)
+
+	split, err := cfg.ComputeSplitKey(ctx, tkey(start+1, "a"), tkey(start+1, "b"))
+	require.NoError(t, err)
+	require.Nil(t, split)
+}
+
 func TestGetZoneConfigForKey(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -654,6 +805,118 @@ func TestSystemConfigMask(t *testing.T) {
 	require.Equal(t, exp, res)
 }
 
+func TestSystemConfigDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	id := func(i int) descpb.ID {
+		return descpb.ID(bootstrap.TestingUserDescID(uint32(i)))
+	}
+	makeCfg := func(kvs ...roachpb.KeyValue) *config.SystemConfig {
+		cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+		cfg.Values = append([]roachpb.KeyValue{descriptor(uint32(id(0)))}, kvs...)
+		sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+		return cfg
+	}
+	rewritten := zoneConfig(id(2))
+	rewritten.Value.Timestamp.WallTime = 123
+
+	prev := makeCfg(zoneConfig(id(1)), zoneConfig(id(2)), zoneConfig(id(3)))
+	testCases := []struct {
+		name     string
+		cur      *config.SystemConfig
+		expected config.SystemConfigDelta
+	}{
+		{
+			name: "no changes",
+			cur:  makeCfg(zoneConfig(id(1)), rewritten, zoneConfig(id(3))),
+		},
+		{
+			name: "added, removed and modified",
+			cur:  makeCfg(zoneConfig(id(0)), zoneConfig(id(2), subzone("a", "b")), zoneConfig(id(3))),
+			expected: config.SystemConfigDelta{
+				ChangedZones: []config.ObjectID{
+					config.ObjectID(id(0)), config.ObjectID(id(1)), config.ObjectID(id(2)),
+				},
+			},
+		},
+		{
+			name: "default zone",
+			cur:  makeCfg(zoneConfig(keys.RootNamespaceID), zoneConfig(id(1)), zoneConfig(id(2)), zoneConfig(id(3))),
+			expected: config.SystemConfigDelta{
+				ChangedZones:       []config.ObjectID{keys.RootNamespaceID},
+				DefaultZoneChanged: true,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delta := tc.cur.Diff(prev)
+			require.Equal(t, tc.expected, delta)
+			require.Equal(t, len(tc.expected.ChangedZones) == 0, delta.IsEmpty())
+		})
+	}
+
+	t.Run("fallback default zone", func(t *testing.T) {
+		cur := makeCfg(zoneConfig(id(1)), zoneConfig(id(2)), zoneConfig(id(3)))
+		cur.DefaultZoneConfig = zonepb.DefaultSystemZoneConfigRef()
+		delta := cur.Diff(prev)
+		require.True(t, delta.DefaultZoneChanged)
+		require.Empty(t, delta.ChangedZones)
+	})
+}
+
+func TestSystemConfigApplyDelta(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	at := func(kv roachpb.KeyValue, wallTime int64) roachpb.KeyValue {
+		kv.Value.Timestamp.WallTime = wallTime
+		return kv
+	}
+	deletion := func(k string, wallTime int64) roachpb.KeyValue {
+		return roachpb.KeyValue{Key: roachpb.Key(k), Value: roachpb.Value{
+			Timestamp: hlc.Timestamp{WallTime: wallTime},
+		}}
+	}
+
+	prev := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	prev.Values = []roachpb.KeyValue{
+		at(plainKV("b", "1"), 1),
+		at(plainKV("d", "1"), 1),
+		at(plainKV("f", "1"), 1),
+		at(plainKV("h", "1"), 5),
+	}
+	cur := prev.ApplyDelta([]roachpb.KeyValue{
+		// Unsorted, with several updates to some keys.
+		at(plainKV("g", "1"), 2),
+		deletion("d", 2),
+		at(plainKV("a", "1"), 2),
+		at(plainKV("f", "3"), 3),
+		at(plainKV("f", "2"), 2),
+		at(plainKV("c", "1"), 2),
+		deletion("c", 3),
+		deletion("e", 2),
+		// Older than the existing value.
+		at(plainKV("h", "2"), 2),
+		at(plainKV("i", "1"), 2),
+	})
+
+	var got []string
+	for _, kv := range cur.Values {
+		v, err := kv.Value.GetBytes()
+		require.NoError(t, err)
+		got = append(got, fmt.Sprintf("%s=%s", kv.Key, v))
+	}
+	require.Equal(t, []string{"a=1", "b=1", "f=3", "g=1", "h=1", "i=1"}, got)
+	require.Same(t, prev.DefaultZoneConfig, cur.DefaultZoneConfig)
+
+	// The previous config is unmodified.
+	require.Len(t, prev.Values, 4)
+	require.Equal(t, roachpb.Key("d"), prev.Values[1].Key)
+
+	// Applying no KVs yields the same values.
+	require.Equal(t, prev.Values, prev.ApplyDelta(nil).Values)
+}
+
 func TestShouldSplitAtDesc(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -681,3 +944,1283 @@ func TestShouldSplitAtDesc(t *testing.T) {
 		require.Equal(t, should, config.ShouldSplitAtDesc(&rawDesc))
 	}
 }
+
+func TestZoneChangeCallbacks(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	id := func(i int) descpb.ID {
+		return descpb.ID(bootstrap.TestingUserDescID(uint32(i)))
+	}
+	// Decode zone configs straight from the snapshot. The object id(3) inherits
+	// the zone config of id(4), as a table does that of its database.
+	parents := map[descpb.ID]descpb.ID{id(3): id(4)}
+	config.ZoneConfigHook = func(
+		cfg *config.SystemConfig, codec keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		val := cfg.GetValue(config.MakeZoneKey(codec, descpb.ID(objectID)))
+		if parent, ok := parents[descpb.ID(objectID)]; ok && val == nil {
+			val = cfg.GetValue(config.MakeZoneKey(codec, parent))
+		}
+		if val == nil {
+			return nil, nil, false, nil
+		}
+		var zone zonepb.ZoneConfig
+		if err := val.GetProto(&zone); err != nil {
+			return nil, nil, false, err
+		}
+		return &zone, nil, false, nil
+	}
+
+	makeCfg := func(kvs ...roachpb.KeyValue) *config.SystemConfig {
+		cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+		cfg.Values = append([]roachpb.KeyValue{descriptor(uint32(id(0)))}, kvs...)
+		sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+		return cfg
+	}
+
+	// Callbacks are invoked asynchronously, so their notifications are
+	// collected through a channel.
+	type notification struct {
+		objectID config.ObjectID
+		zone     zonepb.ZoneConfig
+	}
+	notifications := make(chan notification, 16)
+	register := func(cfg *config.SystemConfig, objectID descpb.ID) (unregister func()) {
+		return cfg.RegisterZoneChangeCallback(config.ObjectID(objectID), func(zone zonepb.ZoneConfig) {
+			notifications <- notification{objectID: config.ObjectID(objectID), zone: zone}
+		})
+	}
+	// expect waits for the notifications of the given objects, in any order,
+	// and returns the zone configs they were notified of.
+	expect := func(objectIDs ...descpb.ID) map[descpb.ID]zonepb.ZoneConfig {
+		t.Helper()
+		zones := make(map[descpb.ID]zonepb.ZoneConfig)
+		var notified []descpb.ID
+		for range objectIDs {
+			select {
+			case n := <-notifications:
+				zones[descpb.ID(n.objectID)] = n.zone
+				notified = append(notified, descpb.ID(n.objectID))
+			case <-time.After(testutils.DefaultSucceedsSoonDuration):
+				t.Fatalf("timed out waiting for the notifications of %v, got %v", objectIDs, notified)
+			}
+		}
+		require.ElementsMatch(t, objectIDs, notified)
+		return zones
+	}
+
+	cfg := makeCfg(zoneConfig(id(1)), zoneConfig(id(2)))
+	unregister1 := register(cfg, id(1))
+	register(cfg, id(2))
+	register(cfg, id(3))
+	require.Zero(t, cfg.ZoneVersion(config.ObjectID(id(1))))
+
+	// Modify the zone for id(1) only.
+	next := makeCfg(zoneConfig(id(1), subzone("a", "b")), zoneConfig(id(2)))
+	next.NotifyZoneChanges(ctx, cfg)
+	zone := expect(id(1))[id(1)]
+	require.Equal(t, []zonepb.SubzoneSpan{subzone("a", "b")}, zone.SubzoneSpans)
+	require.Equal(t, uint64(1), next.ZoneVersion(config.ObjectID(id(1))))
+	require.Zero(t, next.ZoneVersion(config.ObjectID(id(2))))
+
+	// The callbacks are handed a copy of the zone config, which they may
+	// modify.
+	zone.SubzoneSpans[0].Key = roachpb.Key("z")
+	cached, err := next.GetZoneConfigForObject(keys.SystemSQLCodec, config.ObjectID(id(1)))
+	require.NoError(t, err)
+	require.Equal(t, []zonepb.SubzoneSpan{subzone("a", "b")}, cached.Unwrap().SubzoneSpans)
+
+	// Notifying for an unchanged snapshot is a no-op.
+	next.NotifyZoneChanges(ctx, next)
+
+	// Removing the zone for id(2) notifies with the default zone config.
+	// Registrations made on an earlier snapshot carry over.
+	unregister1()
+	cfg, next = next, makeCfg(zoneConfig(id(1)))
+	next.NotifyZoneChanges(ctx, cfg)
+	require.Equal(t, *zonepb.DefaultZoneConfigRef(), expect(id(2))[id(2)])
+
+	// A change to the zone config which id(3) inherits from notifies it.
+	cfg, next = next, makeCfg(zoneConfig(id(1)), zoneConfig(id(4), subzone("c", "d")))
+	next.NotifyZoneChanges(ctx, cfg)
+	require.Equal(t, []zonepb.SubzoneSpan{subzone("c", "d")}, expect(id(3))[id(3)].SubzoneSpans)
+	require.Equal(t, uint64(1), next.ZoneVersion(config.ObjectID(id(3))))
+
+	// A change to the default zone notifies every callback.
+	cfg, next = next, makeCfg(
+		zoneConfig(keys.RootNamespaceID), zoneConfig(id(1)), zoneConfig(id(4), subzone("c", "d")))
+	next.NotifyZoneChanges(ctx, cfg)
+	expect(id(2), id(3))
+	require.Equal(t, uint64(2), next.ZoneVersion(config.ObjectID(id(3))))
+
+	select {
+	case n := <-notifications:
+		t.Fatalf("unexpected notification of %d", n.objectID)
+	default:
+	}
+}
+
+func TestExportZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	tableID := descpb.ID(bootstrap.TestingUserDescID(1))
+	droppedID := descpb.ID(bootstrap.TestingUserDescID(2))
+	table := testTable(tableID, dbID, "t")
+	dropped := &descpb.TableDescriptor{
+		ID: droppedID, ParentID: dbID, Name: "dropped", State: descpb.DescriptorState_DROP,
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		databaseKV(dbID, "db"),
+		tableKV(table),
+		tableKV(dropped),
+		zoneConfigKV(keys.RootNamespaceID, zonepb.DefaultZoneConfig()),
+		zoneConfigKV(keys.LivenessRangesID, zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 600}}),
+		zoneConfigKV(dbID, zonepb.ZoneConfig{NumReplicas: proto.Int32(5)}),
+		zoneConfigKV(tableID, zonepb.ZoneConfig{
+			NumReplicas: proto.Int32(0),
+			Subzones: []zonepb.Subzone{
+				{IndexID: 2, Config: zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 100}}},
+				{IndexID: 2, PartitionName: "p", Config: zonepb.ZoneConfig{NumReplicas: proto.Int32(7)}},
+				// The subzone of a dropped index is omitted.
+				{IndexID: 3, Config: zonepb.ZoneConfig{NumReplicas: proto.Int32(9)}},
+			},
+		}),
+		zoneConfigKV(droppedID, zonepb.ZoneConfig{NumReplicas: proto.Int32(1)}),
+		// A zone config whose descriptor is gone is omitted too.
+		zoneConfigKV(descpb.ID(bootstrap.TestingUserDescID(3)), zonepb.ZoneConfig{NumReplicas: proto.Int32(1)}),
+	}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	data, err := cfg.ExportZoneConfigs()
+	require.NoError(t, err)
+
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+	var targets []string
+	for i := 0; i < len(doc.Content[0].Content); i += 2 {
+		targets = append(targets, doc.Content[0].Content[i].Value)
+	}
+	require.Equal(t, []string{
+		"RANGE default",
+		"RANGE liveness",
+		"DATABASE db",
+		"INDEX db.public.t@idx",
+		"PARTITION p OF INDEX db.public.t@idx",
+	}, targets)
+
+	var zones map[string]zonepb.ZoneConfig
+	require.NoError(t, yaml.Unmarshal(data, &zones))
+	defaultZone := zonepb.DefaultZoneConfig()
+	for target, zone := range zones {
+		require.True(t, zone.IsComplete(), target)
+	}
+	for _, tc := range []struct {
+		target      string
+		numReplicas int32
+		ttlSeconds  int32
+	}{
+		{"RANGE default", *defaultZone.NumReplicas, defaultZone.GC.TTLSeconds},
+		{"RANGE liveness", *defaultZone.NumReplicas, 600},
+		{"DATABASE db", 5, defaultZone.GC.TTLSeconds},
+		{"INDEX db.public.t@idx", 5, 100},
+		{"PARTITION p OF INDEX db.public.t@idx", 7, 100},
+	} {
+		zone := zones[tc.target]
+		require.Equal(t, tc.numReplicas, *zone.NumReplicas, tc.target)
+		require.Equal(t, tc.ttlSeconds, zone.GC.TTLSeconds, tc.target)
+	}
+
+	// The annotated export decodes to the same zone configs, and names the
+	// target each inherited field comes from.
+	annotated, err := cfg.ExportAnnotatedZoneConfigs()
+	require.NoError(t, err)
+	var annotatedZones map[string]zonepb.ZoneConfig
+	require.NoError(t, yaml.Unmarshal(annotated, &annotatedZones))
+	require.Equal(t, zones, annotatedZones)
+
+	var annotatedDoc yaml.Node
+	require.NoError(t, yaml.Unmarshal(annotated, &annotatedDoc))
+	var annotatedTargets []string
+	for i := 0; i < len(annotatedDoc.Content[0].Content); i += 2 {
+		annotatedTargets = append(annotatedTargets, annotatedDoc.Content[0].Content[i].Value)
+	}
+	require.Equal(t, targets, annotatedTargets)
+
+	comments := make(map[string]map[string]string)
+	var target string
+	for _, line := range strings.Split(string(annotated), "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			target = strings.TrimSuffix(line, ":")
+			comments[target] = make(map[string]string)
+			continue
+		}
+		if i := strings.Index(line, " # inherited from "); i >= 0 {
+			field := strings.TrimSpace(line[:strings.Index(line, ":")])
+			comments[target][field] = line[i+len(" # inherited from "):]
+		}
+	}
+	require.Empty(t, comments["RANGE default"])
+	for _, tc := range []struct {
+		target, field, from string
+	}{
+		{"RANGE liveness", "num_replicas", "RANGE default"},
+		{"DATABASE db", "ttlseconds", "RANGE default"},
+		{"INDEX db.public.t@idx", "num_replicas", "DATABASE db"},
+		{"INDEX db.public.t@idx", "range_max_bytes", "RANGE default"},
+		{"PARTITION p OF INDEX db.public.t@idx", "ttlseconds", "INDEX db.public.t@idx"},
+	} {
+		require.Equal(t, tc.from, comments[tc.target][tc.field], "%s %s", tc.target, tc.field)
+	}
+	// Explicitly set fields aren't annotated.
+	require.NotContains(t, comments["RANGE liveness"], "ttlseconds")
+	require.NotContains(t, comments["DATABASE db"], "num_replicas")
+	require.NotContains(t, comments["INDEX db.public.t@idx"], "ttlseconds")
+	require.NotContains(t, comments["PARTITION p OF INDEX db.public.t@idx"], "num_replicas")
+
+	// The subzones exported by name carry over to a table whose indexes have
+	// other IDs. The subzone of the dropped index is omitted.
+	named, err := cfg.ExportNamedSubzones(config.ObjectID(tableID))
+	require.NoError(t, err)
+	require.Equal(t, "- index: idx\n"+
+		"  config: {gc: {ttlseconds: 100}}\n"+
+		"- index: idx\n"+
+		"  partition: p\n"+
+		"  config: {num_replicas: 7}\n", string(named))
+	var subzones []zonepb.NamedSubzone
+	require.NoError(t, yaml.Unmarshal(named, &subzones))
+	restored := config.DescriptorInfo{
+		Kind: config.DescriptorKindTable, Name: "t", IndexNames: map[uint32]string{1: "t_pkey", 4: "idx"},
+	}
+	var restoredZone zonepb.ZoneConfig
+	require.NoError(t, restoredZone.SetNamedSubzones(subzones, config.NewTableIndexNameResolver(restored)))
+	require.Len(t, restoredZone.Subzones, 2)
+	for _, s := range restoredZone.Subzones {
+		require.Equal(t, uint32(4), s.IndexID)
+	}
+	require.Equal(t, int32(7), *restoredZone.Subzones[1].Config.NumReplicas)
+	require.Error(t, restoredZone.SetNamedSubzones(subzones,
+		config.NewTableIndexNameResolver(config.DescriptorInfo{Kind: config.DescriptorKindTable})))
+
+	// Tables without subzones export an empty list.
+	named, err = cfg.ExportNamedSubzones(config.ObjectID(dbID))
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", string(named))
+}
+
+func TestValidateAllZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	t1ID := descpb.ID(bootstrap.TestingUserDescID(1))
+	t2ID := descpb.ID(bootstrap.TestingUserDescID(2))
+	makeConfig := func(values ...roachpb.KeyValue) *config.SystemConfig {
+		cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+		cfg.Values = append([]roachpb.KeyValue{
+			databaseKV(dbID, "db"),
+			tableKV(testTable(t1ID, dbID, "t1")),
+			tableKV(testTable(t2ID, dbID, "t2")),
+			zoneConfigKV(keys.RootNamespaceID, zonepb.DefaultZoneConfig()),
+		}, values...)
+		sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+		return cfg
+	}
+	errStrings := func(errs []config.TargetedError) []string {
+		var res []string
+		for _, err := range errs {
+			res = append(res, err.Error())
+		}
+		return res
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := makeConfig(
+			zoneConfigKV(dbID, zonepb.ZoneConfig{NumReplicas: proto.Int32(5)}),
+			zoneConfigKV(t1ID, zonepb.ZoneConfig{
+				NumReplicas: proto.Int32(0),
+				Subzones: []zonepb.Subzone{
+					{IndexID: 2, Config: zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 100}}},
+				},
+			}),
+		)
+		require.Empty(t, cfg.ValidateAllZoneConfigs())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cfg := makeConfig(
+			zoneConfigKV(keys.LivenessRangesID, zonepb.ZoneConfig{NumReplicas: proto.Int32(-1)}),
+			zoneConfigKV(dbID, zonepb.ZoneConfig{NumReplicas: proto.Int32(5)}),
+			// The zone config is only invalid once it inherits num_replicas.
+			zoneConfigKV(t1ID, zonepb.ZoneConfig{
+				NumVoters:                 proto.Int32(7),
+				InheritedConstraints:      true,
+				InheritedLeasePreferences: true,
+			}),
+			zoneConfigKV(t2ID, zonepb.ZoneConfig{
+				NumReplicas: proto.Int32(0),
+				Subzones: []zonepb.Subzone{
+					{IndexID: 2, Config: zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 100}}},
+					{IndexID: 2, Config: zonepb.ZoneConfig{NumReplicas: proto.Int32(7)}},
+				},
+			}),
+		)
+		errs := cfg.ValidateAllZoneConfigs()
+		require.Equal(t, []string{
+			"RANGE liveness: at least one replica is required",
+			"TABLE db.public.t1: once hydrated: num_voters cannot be greater than num_replicas",
+			"TABLE db.public.t2: index 2 has more than one subzone",
+		}, errStrings(errs))
+		require.Equal(t, config.ObjectID(t2ID), errs[2].ID)
+	})
+
+	t.Run("undecodable", func(t *testing.T) {
+		// Zone configs aren't hydrated if one of them can't be decoded, since
+		// it may be the parent of others.
+		cfg := makeConfig(
+			kv(config.MakeZoneKey(keys.SystemSQLCodec, dbID), []byte("garbage")),
+			zoneConfigKV(t1ID, zonepb.ZoneConfig{
+				NumVoters:                 proto.Int32(7),
+				InheritedConstraints:      true,
+				InheritedLeasePreferences: true,
+			}),
+		)
+		errs := cfg.ValidateAllZoneConfigs()
+		require.Len(t, errs, 1)
+		require.Equal(t, config.ObjectID(dbID), errs[0].ID)
+		require.Equal(t, "DATABASE db", errs[0].Target)
+	})
+}
+
+func TestSetDefaultSystemZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer config.TestingResetDefaultSystemZoneConfigs()
+
+	withGCTTL := func(ttlSeconds int32) zonepb.ZoneConfig {
+		zone := zonepb.DefaultSystemZoneConfig()
+		zone.GC = &zonepb.GCPolicy{TTLSeconds: ttlSeconds}
+		return zone
+	}
+	require.EqualError(t,
+		config.SetDefaultSystemZoneConfig(keys.SystemRangesID, withGCTTL(60)),
+		"the default zone config of 17 cannot be overridden")
+	require.EqualError(t,
+		config.SetDefaultSystemZoneConfig(keys.LivenessRangesID, withGCTTL(60*60)),
+		"the default zone config for RANGE liveness must set a GC TTL of at most 10m0s")
+	require.EqualError(t,
+		config.SetDefaultSystemZoneConfig(keys.MetaRangesID, withGCTTL(0)),
+		"the default zone config for RANGE meta must set a GC TTL of at most 1h0m0s")
+	require.EqualError(t,
+		config.SetDefaultSystemZoneConfig(keys.MetaRangesID, zonepb.ZoneConfig{}),
+		"the default zone config for RANGE meta must set a GC TTL of at most 1h0m0s")
+	invalid := withGCTTL(60)
+	invalid.NumReplicas = proto.Int32(-1)
+	require.Error(t, config.SetDefaultSystemZoneConfig(keys.MetaRangesID, invalid))
+	_, ok := config.DefaultSystemZoneConfigOverride(keys.MetaRangesID)
+	require.False(t, ok)
+
+	liveness := withGCTTL(5 * 60)
+	liveness.NumReplicas = proto.Int32(7)
+	require.NoError(t, config.SetDefaultSystemZoneConfig(keys.LivenessRangesID, liveness))
+	timeseries := zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 24 * 60 * 60}}
+	require.NoError(t, config.SetDefaultSystemZoneConfig(keys.TimeseriesRangesID, timeseries))
+	zone, ok := config.DefaultSystemZoneConfigOverride(keys.LivenessRangesID)
+	require.True(t, ok)
+	require.Equal(t, liveness, *zone)
+
+	// The overrides are used when bootstrapping.
+	kvs := bootstrap.InitialZoneConfigKVs(
+		keys.SystemSQLCodec, zonepb.DefaultZoneConfigRef(), zonepb.DefaultSystemZoneConfigRef(),
+	)
+	zones := make(map[uint32]zonepb.ZoneConfig)
+	for _, kv := range kvs {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(kv.Key)
+		require.NoError(t, err)
+		var zone zonepb.ZoneConfig
+		require.NoError(t, kv.Value.GetProto(&zone))
+		zones[id] = zone
+	}
+	require.Equal(t, liveness, zones[keys.LivenessRangesID])
+	require.Equal(t, timeseries, zones[keys.TimeseriesRangesID])
+	require.Equal(t, int32(60*60), zones[keys.MetaRangesID].GC.TTLSeconds)
+}
+
+func TestGetZoneConfigForObject(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	id := config.ObjectID(bootstrap.TestingUserDescID(0))
+	config.ZoneConfigHook = func(
+		_ *config.SystemConfig, _ keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		if objectID != id {
+			return nil, nil, false, nil
+		}
+		zone := zonepb.DefaultZoneConfig()
+		zone.Constraints = []zonepb.ConstraintsConjunction{{NumReplicas: 1, Constraints: []zonepb.Constraint{
+			{Type: zonepb.Constraint_REQUIRED, Key: "region", Value: "a"},
+		}}}
+		return &zone, nil, true /* cache */, nil
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	view, err := cfg.GetZoneConfigForObject(keys.SystemSQLCodec, id+1)
+	require.NoError(t, err)
+	require.True(t, view.IsNil())
+
+	view, err = cfg.GetZoneConfigForObject(keys.SystemSQLCodec, id)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), view.NumReplicas())
+
+	// Modifying the mutable copy doesn't affect the cached zone config.
+	zone := view.Mutate()
+	zone.NumReplicas = proto.Int32(5)
+	zone.Constraints[0].Constraints[0].Value = "b"
+	cached, err := cfg.GetZoneConfigForObject(keys.SystemSQLCodec, id)
+	require.NoError(t, err)
+	require.Same(t, view.Unwrap(), cached.Unwrap())
+	require.Equal(t, int32(3), cached.NumReplicas())
+	require.Equal(t, "a", cached.Unwrap().Constraints[0].Constraints[0].Value)
+}
+
+func TestZoneConfigInterning(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	id := config.ObjectID(bootstrap.TestingUserDescID(0))
+	config.ZoneConfigHook = func(
+		_ *config.SystemConfig, _ keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		// Every object gets its own decoded instance, as when decoding zone
+		// configs from the system config.
+		zone := zonepb.DefaultZoneConfig()
+		switch objectID {
+		case id + 2:
+			zone.NumReplicas = proto.Int32(5)
+		case id + 4:
+			zone.AuditInfo = &zonepb.ZoneConfigAuditInfo{ModifiedBy: "root"}
+		}
+		return &zone, nil, true /* cache */, nil
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	get := func(objectID config.ObjectID) *zonepb.ZoneConfig {
+		view, err := cfg.GetZoneConfigForObject(keys.SystemSQLCodec, objectID)
+		require.NoError(t, err)
+		return view.Unwrap()
+	}
+
+	// Objects with identical zone configs share a single instance.
+	require.Same(t, get(id), get(id+1))
+	require.Same(t, get(id), get(id+3))
+	// The audit info of zone configs isn't cached, so it doesn't keep them
+	// from being shared.
+	require.Same(t, get(id), get(id+4))
+	require.Nil(t, get(id+4).AuditInfo)
+	// Objects with different zone configs don't.
+	require.NotSame(t, get(id), get(id+2))
+	require.Equal(t, int32(5), *get(id + 2).NumReplicas)
+	require.Equal(t, int32(3), *get(id).NumReplicas)
+
+	// Purging the cache also purges the interned zone configs.
+	before := get(id)
+	cfg.PurgeZoneConfigCache()
+	require.NotSame(t, before, get(id))
+	require.Same(t, get(id), get(id+1))
+}
+
+// TestZoneConfigInterningSharedPlaceholder checks that the subzones of a
+// placeholder shared by tables with different zone configs inherit the fields
+// of the zone config of the table they're looked up for.
+func TestZoneConfigInterningSharedPlaceholder(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	id := bootstrap.TestingUserDescID(0)
+	config.ZoneConfigHook = func(
+		_ *config.SystemConfig, _ keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		// The tables are in different databases, whose zone configs differ, and
+		// are partitioned identically, so their placeholders have the same
+		// encoding.
+		zone := zonepb.DefaultZoneConfig()
+		if objectID == config.ObjectID(id) {
+			zone.NumReplicas = proto.Int32(5)
+		}
+		placeholder := zonepb.NewZoneConfig()
+		placeholder.NumReplicas = proto.Int32(0)
+		placeholder.Subzones = []zonepb.Subzone{{
+			IndexID: 1, Config: zonepb.ZoneConfig{GC: &zonepb.GCPolicy{TTLSeconds: 600}},
+		}}
+		placeholder.SubzoneSpans = []zonepb.SubzoneSpan{{Key: roachpb.Key{0x89}}}
+		return &zone, placeholder, true /* cache */, nil
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	numReplicas := func(tableID uint32) int32 {
+		_, zone, err := config.TestingGetSystemTenantZoneConfigForKey(
+			cfg, roachpb.RKey(tkey(tableID, "\x89\x92")),
+		)
+		require.NoError(t, err)
+		require.Equal(t, int32(600), zone.GC.TTLSeconds)
+		return *zone.NumReplicas
+	}
+	for i := 0; i < 2; i++ {
+		require.Equal(t, int32(5), numReplicas(id))
+		require.Equal(t, int32(3), numReplicas(id+1))
+	}
+}
+
+func TestPreloadZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	id := config.ObjectID(bootstrap.TestingUserDescID(0))
+	var calls int64
+	config.ZoneConfigHook = func(
+		_ *config.SystemConfig, _ keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		atomic.AddInt64(&calls, 1)
+		zone := zonepb.DefaultZoneConfig()
+		switch objectID {
+		case id + 1:
+			return nil, nil, false, errors.New("boom")
+		case id + 2:
+			zone.NumReplicas = proto.Int32(0)
+		}
+		return &zone, nil, true /* cache */, nil
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	for i := config.ObjectID(0); i < 10; i++ {
+		kv := roachpb.KeyValue{Key: config.MakeZoneKey(keys.SystemSQLCodec, descpb.ID(id+i))}
+		zone := zonepb.DefaultZoneConfig()
+		require.NoError(t, kv.Value.SetProto(&zone))
+		cfg.Values = append(cfg.Values, kv)
+	}
+	ready := cfg.ZoneConfigsReady()
+	select {
+	case <-ready:
+		t.Fatal("zone configs ready before being preloaded")
+	default:
+	}
+
+	errs := cfg.PreloadZoneConfigs(context.Background(), 4)
+	require.Len(t, errs, 2)
+	require.Equal(t, id+1, errs[0].ID)
+	require.Contains(t, errs[0].Error(), "decoding zone config: boom")
+	require.Equal(t, id+2, errs[1].ID)
+	require.Contains(t, errs[1].Error(), "at least one replica is required")
+	<-ready
+	require.Equal(t, int64(10), atomic.LoadInt64(&calls))
+
+	// The zone configs which were decoded are served from the cache, the
+	// others are looked up again.
+	_, err := cfg.GetZoneConfigForObject(keys.SystemSQLCodec, id)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), atomic.LoadInt64(&calls))
+	_, err = cfg.GetZoneConfigForObject(keys.SystemSQLCodec, id+1)
+	require.Error(t, err)
+	require.Equal(t, int64(11), atomic.LoadInt64(&calls))
+}
+
+func TestGetZoneConfigForTenantKey(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	originalZoneConfigHook := config.ZoneConfigHook
+	defer func() {
+		config.ZoneConfigHook = originalZoneConfigHook
+	}()
+	tenID := roachpb.MustMakeTenantID(10)
+	tenCodec := keys.MakeSQLCodec(tenID)
+	tableID := config.ObjectID(bootstrap.TestingUserDescID(0))
+	hostZone := zonepb.DefaultZoneConfig()
+	hostZone.NumReplicas = proto.Int32(5)
+	tenantZone := zonepb.DefaultZoneConfig()
+	tenantZone.NumReplicas = proto.Int32(7)
+	config.ZoneConfigHook = func(
+		_ *config.SystemConfig, codec keys.SQLCodec, objectID config.ObjectID,
+	) (*zonepb.ZoneConfig, *zonepb.ZoneConfig, bool, error) {
+		switch {
+		case codec.ForSystemTenant() && objectID == keys.TenantsRangesID:
+			zone := hostZone
+			return &zone, nil, true /* cache */, nil
+		case codec.ForSystemTenant():
+			return nil, nil, true /* cache */, nil
+		case bytes.Equal(codec.TenantPrefix(), tenCodec.TenantPrefix()) && objectID == tableID:
+			zone := tenantZone
+			return &zone, nil, true /* cache */, nil
+		}
+		return nil, nil, true /* cache */, nil
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	get := func(tenantID roachpb.TenantID, key roachpb.Key) (config.ObjectID, int32) {
+		id, zone, err := cfg.GetZoneConfigForTenantKey(tenantID, roachpb.RKey(key))
+		require.NoError(t, err)
+		return id, *zone.NumReplicas
+	}
+
+	// The tables of the tenant resolve to the zone configs of the tenant.
+	id, numReplicas := get(tenID, tenCodec.TablePrefix(uint32(tableID)))
+	require.Equal(t, tableID, id)
+	require.Equal(t, int32(7), numReplicas)
+	id, numReplicas = get(tenID, tenCodec.IndexPrefix(uint32(tableID), 1))
+	require.Equal(t, tableID, id)
+	require.Equal(t, int32(7), numReplicas)
+
+	// The other keys of the tenant resolve to the zone config of the host for
+	// tenants.
+	for _, key := range []roachpb.Key{
+		tenCodec.TenantPrefix(),
+		tenCodec.TablePrefix(keys.DescriptorTableID),
+		tenCodec.TablePrefix(uint32(tableID + 1)),
+	} {
+		id, numReplicas = get(tenID, key)
+		require.Equal(t, config.ObjectID(keys.TenantsRangesID), id, "%s", key)
+		require.Equal(t, int32(5), numReplicas, "%s", key)
+	}
+
+	// The objects of the system tenant with the same ID as those of the tenant
+	// have their own zone configs.
+	id, numReplicas = get(roachpb.SystemTenantID, keys.SystemSQLCodec.TablePrefix(uint32(tableID)))
+	require.Equal(t, tableID, id)
+	require.Equal(t, int32(3), numReplicas)
+
+	// The keys must be in the keyspace of the tenant.
+	_, _, err := cfg.GetZoneConfigForTenantKey(
+		roachpb.MustMakeTenantID(11), roachpb.RKey(tenCodec.TablePrefix(uint32(tableID))))
+	require.Error(t, err)
+	_, _, err = cfg.GetZoneConfigForTenantKey(
+		tenID, roachpb.RKey(keys.SystemSQLCodec.TablePrefix(uint32(tableID))))
+	require.Error(t, err)
+}
+
+func TestGetDescriptors(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	schemaID, tableID := dbID+1, dbID+2
+	badKV := roachpb.KeyValue{Key: catalogkeys.MakeDescMetadataKey(keys.SystemSQLCodec, tableID+1)}
+	badKV.Value.SetBytes([]byte("not a descriptor"))
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		databaseKV(dbID, "db"),
+		descriptorKV(schemaID, &descpb.Descriptor{Union: &descpb.Descriptor_Schema{
+			Schema: &descpb.SchemaDescriptor{ID: schemaID, ParentID: dbID, Name: "sc"},
+		}}),
+		tableKV(&descpb.TableDescriptor{ID: tableID, ParentID: dbID, Name: "t"}),
+		badKV,
+	}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	db, ok, err := cfg.GetDescriptorInfo(config.ObjectID(dbID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, config.DescriptorKindDatabase, db.Kind)
+	require.Equal(t, "db", db.Name)
+	schema, ok, err := cfg.GetDescriptorInfo(config.ObjectID(schemaID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, config.DescriptorKindSchema, schema.Kind)
+	require.Equal(t, "sc", schema.Name)
+	require.Equal(t, config.ObjectID(dbID), schema.ParentID)
+	table, ok, err := cfg.GetDescriptorInfo(config.ObjectID(tableID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, config.DescriptorKindTable, table.Kind)
+	require.Equal(t, "t", table.Name)
+	require.Equal(t, config.ObjectID(dbID), table.ParentID)
+
+	// Descriptors which don't exist aren't returned.
+	_, ok, err = cfg.GetDescriptorInfo(config.ObjectID(tableID + 2))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = cfg.GetDescriptorInfo(config.ObjectID(tableID + 1))
+	require.ErrorContains(t, err, "decoding descriptor")
+}
+
+func TestGetEffectiveZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	tableID := descpb.ID(bootstrap.TestingUserDescID(1))
+	otherID := descpb.ID(bootstrap.TestingUserDescID(2))
+	newZone := func(fn func(z *zonepb.ZoneConfig)) *zonepb.ZoneConfig {
+		z := zonepb.NewZoneConfig()
+		fn(z)
+		return z
+	}
+	table := testTable(tableID, dbID, "t")
+	// Index 2 is encoded as 0x8a, and its partition p spans the keys prefixed
+	// with p.
+	tableZone := newZone(func(z *zonepb.ZoneConfig) {
+		z.GC = &zonepb.GCPolicy{TTLSeconds: 100}
+		z.Subzones = []zonepb.Subzone{
+			{IndexID: 2, Config: *newZone(func(z *zonepb.ZoneConfig) { z.GlobalReads = proto.Bool(true) })},
+			{IndexID: 2, PartitionName: "p", Config: *newZone(func(z *zonepb.ZoneConfig) { z.NumReplicas = proto.Int32(7) })},
+		}
+		z.SubzoneSpans = []zonepb.SubzoneSpan{
+			{Key: []byte{0x8a}, EndKey: []byte{0x8a, 'p'}, SubzoneIndex: 0},
+			{Key: []byte{0x8a, 'p'}, EndKey: []byte{0x8a, 'q'}, SubzoneIndex: 1},
+			{Key: []byte{0x8a, 'q'}, EndKey: []byte{0x8b}, SubzoneIndex: 0},
+		}
+	})
+
+	defaultZone := zonepb.DefaultZoneConfig()
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		databaseKV(dbID, "db"),
+		tableKV(table),
+		zoneConfigKV(keys.RootNamespaceID, defaultZone),
+		zoneConfigKV(keys.LivenessRangesID, *newZone(func(z *zonepb.ZoneConfig) { z.GC = &zonepb.GCPolicy{TTLSeconds: 600} })),
+		zoneConfigKV(dbID, *newZone(func(z *zonepb.ZoneConfig) { z.NumReplicas = proto.Int32(5) })),
+		zoneConfigKV(tableID, *tableZone),
+	}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	tableKey := func(id descpb.ID, suffix ...byte) roachpb.RKey {
+		return roachpb.RKey(append(keys.SystemSQLCodec.TablePrefix(uint32(id)), suffix...))
+	}
+	// The fields which aren't overridden come from the default zone.
+	fromDefault := []string{
+		"range_min_bytes", "range_max_bytes", "gc.ttlseconds", "num_replicas",
+		"constraints", "voter_constraints", "lease_preferences",
+	}
+	testCases := []struct {
+		name        string
+		key         roachpb.RKey
+		numReplicas int32
+		ttlSeconds  int32
+		globalReads bool
+		// sources maps fields to the String of their source.
+		sources map[string]string
+	}{
+		{
+			name:        "partition",
+			key:         tableKey(tableID, 0x8a, 'p', '1'),
+			numReplicas: 7,
+			ttlSeconds:  100,
+			globalReads: true,
+			sources: map[string]string{
+				"num_replicas":  "partition p of index t@idx",
+				"global_reads":  "index t@idx",
+				"gc.ttlseconds": "table t",
+			},
+		},
+		{
+			name:        "index",
+			key:         tableKey(tableID, 0x8a, 'q'),
+			numReplicas: 5,
+			ttlSeconds:  100,
+			globalReads: true,
+			sources: map[string]string{
+				"num_replicas":  "database db",
+				"global_reads":  "index t@idx",
+				"gc.ttlseconds": "table t",
+			},
+		},
+		{
+			name:        "table",
+			key:         tableKey(tableID, 0x89),
+			numReplicas: 5,
+			ttlSeconds:  100,
+			sources: map[string]string{
+				"num_replicas":  "database db",
+				"gc.ttlseconds": "table t",
+			},
+		},
+		{
+			name:        "table without zone config or descriptor",
+			key:         tableKey(otherID),
+			numReplicas: 3,
+			ttlSeconds:  defaultZone.GC.TTLSeconds,
+		},
+		{
+			name:        "named zone",
+			key:         roachpb.RKey(keys.NodeLivenessPrefix),
+			numReplicas: 3,
+			ttlSeconds:  600,
+			sources: map[string]string{
+				"gc.ttlseconds": "range liveness",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone, provenance, err := cfg.GetEffectiveZoneConfig(tc.key)
+			require.NoError(t, err)
+			require.True(t, zone.IsComplete())
+			require.Empty(t, zone.Subzones)
+			require.Equal(t, tc.numReplicas, *zone.NumReplicas)
+			require.Equal(t, tc.ttlSeconds, zone.GC.TTLSeconds)
+			require.Equal(t, tc.globalReads, zone.GlobalReads != nil && *zone.GlobalReads)
+
+			expected := make(map[string]string)
+			for _, field := range fromDefault {
+				expected[field] = "range default"
+			}
+			for field, source := range tc.sources {
+				expected[field] = source
+			}
+			actual := make(map[string]string)
+			for field, source := range provenance {
+				actual[field] = source.String()
+			}
+			require.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestTargetForKey(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	tableID := descpb.ID(bootstrap.TestingUserDescID(1))
+	otherID := descpb.ID(bootstrap.TestingUserDescID(2))
+	table := testTable(tableID, dbID, "t")
+	// Index 2 is encoded as 0x8a, and its partition p spans the keys prefixed
+	// with p.
+	tableZone := zonepb.NewZoneConfig()
+	tableZone.Subzones = []zonepb.Subzone{{IndexID: 2, PartitionName: "p", Config: *zonepb.NewZoneConfig()}}
+	tableZone.SubzoneSpans = []zonepb.SubzoneSpan{{Key: []byte{0x8a, 'p'}, EndKey: []byte{0x8a, 'q'}}}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		databaseKV(dbID, "db"),
+		tableKV(table),
+		zoneConfigKV(tableID, *tableZone),
+	}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	tableKey := func(id descpb.ID, suffix ...byte) roachpb.RKey {
+		return roachpb.RKey(append(keys.SystemSQLCodec.TablePrefix(uint32(id)), suffix...))
+	}
+	testCases := []struct {
+		key      roachpb.RKey
+		expected string
+	}{
+		{tableKey(tableID, 0x8a, 'p', '1'), "partition p of index db.t@idx"},
+		{tableKey(tableID, 0x8a, 'q'), "index db.t@idx"},
+		{tableKey(tableID, 0x89, 'p'), "index db.t@t_pkey"},
+		{tableKey(tableID), "table db.t"},
+		{tableKey(otherID, 0x89), fmt.Sprintf("index 0.%d@1", otherID)},
+		{tableKey(dbID), "database db"},
+		{roachpb.RKey(keys.NodeLivenessPrefix), "range liveness"},
+	}
+	for _, tc := range testCases {
+		target, err := cfg.TargetForKey(tc.key)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, target.String(), "%s", tc.key)
+	}
+
+	target, err := cfg.TargetForKey(tableKey(tableID, 0x8a, 'p'))
+	require.NoError(t, err)
+	require.Equal(t, config.ZoneTarget{
+		Kind:          config.ZoneConfigSourcePartition,
+		DatabaseID:    config.ObjectID(dbID),
+		DatabaseName:  "db",
+		TableID:       config.ObjectID(tableID),
+		TableName:     "t",
+		IndexID:       2,
+		IndexName:     "idx",
+		PartitionName: "p",
+	}, target)
+}
+
+// testDescriptorProvider is a config.DescriptorProvider for a synthetic
+// schema, in which descriptor values are the names of the descriptors.
+type testDescriptorProvider map[string]config.DescriptorInfo
+
+func (p testDescriptorProvider) DecodeDescriptor(
+	id config.ObjectID, value *roachpb.Value,
+) (config.DescriptorInfo, error) {
+	name, err := value.GetBytes()
+	if err != nil {
+		return config.DescriptorInfo{}, err
+	}
+	info, ok := p[string(name)]
+	if !ok {
+		return config.DescriptorInfo{}, errors.Newf("unknown descriptor %d", id)
+	}
+	info.Name = string(name)
+	return info, nil
+}
+
+func TestDescriptorProvider(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer config.SetDescriptorProvider(nil)
+
+	dbID := config.ObjectID(bootstrap.TestingUserDescID(0))
+	tableID := config.ObjectID(bootstrap.TestingUserDescID(1))
+	provider := func(primaryIndex string) testDescriptorProvider {
+		return testDescriptorProvider{
+			"db": {Kind: config.DescriptorKindDatabase},
+			"t": {
+				Kind: config.DescriptorKindTable, ParentID: dbID, ParentSchemaID: keys.PublicSchemaID,
+				IndexNames: map[uint32]string{1: primaryIndex},
+			},
+		}
+	}
+	config.SetDescriptorProvider(provider("t_pkey"))
+	descKV := func(id config.ObjectID, name string) roachpb.KeyValue {
+		kv := roachpb.KeyValue{Key: keys.SystemSQLCodec.DescMetadataKey(uint32(id))}
+		kv.Value.SetBytes([]byte(name))
+		return kv
+	}
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{descKV(dbID, "db"), descKV(tableID, "t")}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	info, ok, err := cfg.GetDescriptorInfo(tableID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "t", info.Name)
+	_, ok, err = cfg.GetDescriptorInfo(tableID + 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	key := roachpb.RKey(keys.SystemSQLCodec.IndexPrefix(uint32(tableID), 1))
+	target, err := cfg.TargetForKey(key)
+	require.NoError(t, err)
+	require.Equal(t, "index db.t@t_pkey", target.String())
+
+	// Descriptors are only decoded again by a new provider once the caches
+	// are purged.
+	config.SetDescriptorProvider(provider("primary"))
+	target, err = cfg.TargetForKey(key)
+	require.NoError(t, err)
+	require.Equal(t, "index db.t@t_pkey", target.String())
+	cfg.PurgeZoneConfigCache()
+	target, err = cfg.TargetForKey(key)
+	require.NoError(t, err)
+	require.Equal(t, "index db.t@primary", target.String())
+}
+
+func TestZoneConfigPolicy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer config.SetZoneConfigPolicy(nil)
+
+	zone := zonepb.DefaultZoneConfig()
+	zone.Subzones = []zonepb.Subzone{{IndexID: 1, Config: zonepb.ZoneConfig{NumReplicas: proto.Int32(1)}}}
+
+	// Without a policy, every zone config is accepted.
+	require.NoError(t, config.CheckZoneConfigPolicy("RANGE default", zone))
+
+	var targets []string
+	config.SetZoneConfigPolicy(func(target string, zone zonepb.ZoneConfig) error {
+		targets = append(targets, target)
+		if len(zone.Subzones) > 0 {
+			return errors.New("unexpected subzones")
+		}
+		if *zone.NumReplicas < 3 {
+			return errors.Newf("num_replicas must be at least 3, found %d", *zone.NumReplicas)
+		}
+		return nil
+	})
+	require.NoError(t, config.CheckZoneConfigPolicy("RANGE default", zone))
+	zone.NumReplicas = proto.Int32(1)
+	require.EqualError(t, config.CheckZoneConfigPolicy("TABLE db.public.t", zone),
+		"zone config for TABLE db.public.t rejected by policy: num_replicas must be at least 3, found 1")
+	require.Equal(t, []string{"RANGE default", "TABLE db.public.t"}, targets)
+	// The policy doesn't modify the caller's zone config.
+	require.Len(t, zone.Subzones, 1)
+
+	config.SetZoneConfigPolicy(nil)
+	require.NoError(t, config.CheckZoneConfigPolicy("TABLE db.public.t", zone))
+}
+
+func TestMakeZoneConfigRow(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := zonepb.ZoneConfig{
+		NumReplicas:               proto.Int32(5),
+		InheritedConstraints:      true,
+		InheritedLeasePreferences: true,
+	}
+	full := zonepb.DefaultZoneConfig()
+	full.NumReplicas = proto.Int32(5)
+	zs := tree.ZoneSpecifier{Database: "db"}
+
+	row, err := config.MakeZoneConfigRow(&zs, &zone, &full)
+	require.NoError(t, err)
+	require.Equal(t, "DATABASE db", row.Target)
+	require.Equal(t, "ALTER DATABASE db CONFIGURE ZONE USING\n\tnum_replicas = 5", row.RawConfigSQL)
+	require.Equal(t, "ALTER DATABASE db CONFIGURE ZONE USING\n"+
+		"\trange_min_bytes = 134217728,\n"+
+		"\trange_max_bytes = 536870912,\n"+
+		"\tgc.ttlseconds = 14400,\n"+
+		"\tnum_replicas = 5,\n"+
+		"\tconstraints = '[]',\n"+
+		"\tlease_preferences = '[]'", row.FullConfigSQL)
+
+	rawYAML, err := zonepb.MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Equal(t, string(rawYAML), row.RawConfigYAML)
+	fullYAML, err := zonepb.MarshalYAML(full)
+	require.NoError(t, err)
+	require.Equal(t, string(fullYAML), row.FullConfigYAML)
+	var decoded zonepb.ZoneConfig
+	require.NoError(t, protoutil.Unmarshal(row.RawConfigProtobuf, &decoded))
+	require.Equal(t, zone, decoded)
+
+	// Without a specifier, the zone config itself stands in for the full zone
+	// config, and the SQL fields are empty.
+	row, err = config.MakeZoneConfigRow(nil, &zone, nil)
+	require.NoError(t, err)
+	require.Equal(t, config.ZoneConfigRow{
+		RawConfigYAML:     string(rawYAML),
+		RawConfigProtobuf: row.RawConfigProtobuf,
+		FullConfigYAML:    string(rawYAML),
+	}, row)
+}
+
+func TestZoneConfigMetrics(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	constraints := func(short ...string) []zonepb.Constraint {
+		res := make([]zonepb.Constraint, len(short))
+		for i := range short {
+			require.NoError(t, res[i].FromString(short[i]))
+		}
+		return res
+	}
+	table := zonepb.ZoneConfig{
+		Constraints: []zonepb.ConstraintsConjunction{
+			{NumReplicas: 1, Constraints: constraints("+region=a", "+zone=a1")},
+			{NumReplicas: 1, Constraints: constraints("+region=b")},
+		},
+		LeasePreferences: []zonepb.LeasePreference{{Constraints: constraints("+region=a")}},
+		Subzones: []zonepb.Subzone{
+			{IndexID: 1, Config: zonepb.ZoneConfig{
+				VoterConstraints: []zonepb.ConstraintsConjunction{{Constraints: constraints("+region=b")}},
+			}},
+			{IndexID: 2},
+		},
+	}
+	database := zonepb.ZoneConfig{
+		Constraints: []zonepb.ConstraintsConjunction{{Constraints: constraints("+region=a")}},
+		Subzones:    []zonepb.Subzone{{IndexID: 1}},
+	}
+
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		descriptor(bootstrap.TestingUserDescID(0)),
+		zoneConfigKV(descpb.ID(keys.RootNamespaceID), zonepb.DefaultZoneConfig()),
+		zoneConfigKV(descpb.ID(bootstrap.TestingUserDescID(0)), database),
+		zoneConfigKV(descpb.ID(bootstrap.TestingUserDescID(1)), table),
+	}
+	stats, err := cfg.ZoneConfigStats()
+	require.NoError(t, err)
+	require.Equal(t, config.ZoneConfigStats{
+		ZoneConfigs:    3,
+		Subzones:       3,
+		MaxSubzones:    2,
+		MaxConstraints: 5,
+	}, stats)
+
+	m := config.NewZoneConfigMetrics(nil /* registry */)
+	require.NoError(t, m.Update(cfg))
+	require.Equal(t, int64(3), m.ZoneConfigs.Value())
+	require.Equal(t, int64(3), m.Subzones.Value())
+	require.Equal(t, int64(2), m.MaxSubzones.Value())
+	require.Equal(t, int64(5), m.MaxConstraints.Value())
+	m.RecordYAMLParseError()
+	require.Equal(t, int64(1), m.YAMLParseErrors.Count())
+
+	// The metrics are optional.
+	var nilMetrics *config.ZoneConfigMetrics
+	require.NoError(t, nilMetrics.Update(cfg))
+	nilMetrics.RecordYAMLParseError()
+}
+
+func TestSystemConfigHolder(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var h config.SystemConfigHolder
+	require.Nil(t, h.Load())
+	require.Zero(t, h.Epoch())
+
+	a := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	b := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	require.Zero(t, a.Epoch())
+
+	require.Nil(t, h.Swap(a))
+	require.Equal(t, a, h.Load())
+	require.Equal(t, uint64(1), a.Epoch())
+	require.Equal(t, uint64(1), h.Epoch())
+	require.False(t, h.IsStale(a))
+
+	require.Equal(t, a, h.Swap(b))
+	require.Equal(t, uint64(1), a.Epoch())
+	require.Equal(t, uint64(2), b.Epoch())
+	require.Equal(t, uint64(2), h.Epoch())
+	require.True(t, h.IsStale(a))
+	require.False(t, h.IsStale(b))
+
+	// ReadConsistent retries when the snapshot is swapped while it's in use.
+	c := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	var seen []*config.SystemConfig
+	err := h.ReadConsistent(func(cfg *config.SystemConfig) error {
+		seen = append(seen, cfg)
+		if len(seen) == 1 {
+			h.Swap(c)
+			return errors.New("stale")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*config.SystemConfig{b, c}, seen)
+	require.Equal(t, uint64(3), c.Epoch())
+}
+
+func TestSystemConfigDebugSnapshot(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	schema := bootstrap.MakeMetadataSchema(
+		keys.SystemSQLCodec, zonepb.DefaultZoneConfigRef(), zonepb.DefaultSystemZoneConfigRef(),
+	)
+	kvs, _ /* splits */ := schema.GetInitialValues()
+	start := bootstrap.TestingUserDescID(0)
+	kvs = append(kvs, descriptor(start), zoneConfig(descpb.ID(start), subzone("a", "")))
+	// A zone config which can't be decoded doesn't fail the snapshot.
+	kvs = append(kvs, kv(config.MakeZoneKey(keys.SystemSQLCodec, descpb.ID(start+1)), []byte("garbage")))
+	sort.Sort(roachpb.KeyValueByKey(kvs))
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.SystemConfigEntries = config.SystemConfigEntries{Values: kvs}
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteDebugSnapshot(&buf))
+	loaded, err := config.ReadDebugSnapshot(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.True(t, loaded.Equal(&cfg.SystemConfigEntries))
+	require.Equal(t, cfg.DefaultZoneConfig, loaded.DefaultZoneConfig)
+	// The subzone spans of the loaded zone config are honored.
+	splitKey, err := loaded.ComputeSplitKey(ctx, tkey(start), tkey(start+1))
+	require.NoError(t, err)
+	require.Equal(t, tkey(start, "a"), []byte(splitKey))
+
+	// The snapshot is gzipped JSON, which lists the decoded zone configs.
+	gz, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	dump, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Contains(t, string(dump), `"version":1`)
+	require.Contains(t, string(dump), "subzone_spans")
+	require.Contains(t, string(dump), fmt.Sprintf(`{"id":%d,"config":"decoding zone config`, start+1))
+
+	_, err = config.ReadDebugSnapshot(strings.NewReader("garbage"))
+	require.ErrorContains(t, err, "decompressing system config snapshot")
+}
+
+func TestEvaluateConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dbID := descpb.ID(bootstrap.TestingUserDescID(0))
+	tableID := descpb.ID(bootstrap.TestingUserDescID(1))
+	dbZone := zonepb.NewZoneConfig()
+	dbZone.NumReplicas = proto.Int32(5)
+	cfg := config.NewSystemConfig(zonepb.DefaultZoneConfigRef())
+	cfg.Values = []roachpb.KeyValue{
+		databaseKV(dbID, "db"),
+		tableKV(testTable(tableID, dbID, "t")),
+		zoneConfigKV(dbID, *dbZone),
+	}
+	sort.Sort(roachpb.KeyValueByKey(cfg.Values))
+
+	var snapshot bytes.Buffer
+	require.NoError(t, cfg.WriteDebugSnapshot(&snapshot))
+	key := roachpb.RKey(keys.SystemSQLCodec.TablePrefix(uint32(tableID)))
+	eval, err := config.EvaluateConfig(bytes.NewReader(snapshot.Bytes()), key)
+	require.NoError(t, err)
+	require.Equal(t, "table db.t", eval.Target.String())
+	require.Equal(t, int32(5), *eval.Zone.NumReplicas)
+	require.Equal(t, "database db", eval.Provenance["num_replicas"].String())
+	require.Equal(t, "range default", eval.Provenance["gc.ttlseconds"].String())
+
+	// Evaluating against the system config itself gives the same answer.
+	direct, err := cfg.EvaluateConfig(key)
+	require.NoError(t, err)
+	require.Equal(t, eval, direct)
+
+	_, err = config.EvaluateConfig(strings.NewReader(""), key)
+	require.Error(t, err)
+}
+
+var addCompatRelease = flag.String("add-compat-release", "",
+	"add the zone config compatibility corpus of the given release, e.g. v23.2, to testdata")
+
+func TestZoneConfigCompatCorpus(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir := datapathutils.TestDataPath(t, "zone_config_compat")
+	if *addCompatRelease != "" {
+		require.NoError(t, config.WriteZoneConfigCompatRelease(dir, *addCompatRelease))
+	}
+	corpus, err := config.LoadZoneConfigCompatCorpus(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, corpus)
+	for _, err := range config.VerifyZoneConfigCompatCorpus(corpus) {
+		t.Error(err)
+	}
+
+	// The entries generated by the current code verify, in both encodings.
+	current, err := config.MakeZoneConfigCompatRelease("current")
+	require.NoError(t, err)
+	require.Len(t, current.Entries, 2*len(config.ZoneConfigCompatDocs))
+	require.Empty(t, config.VerifyZoneConfigCompatCorpus([]config.ZoneConfigCompatRelease{current}))
+
+	// A change to how an entry is decoded is caught.
+	entry := current.Entries[0]
+	entry.Decoded = "num_replicas: 7"
+	require.Error(t, entry.Verify())
+}
+
+func TestWatchNamedConstraintSets(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer func() { require.NoError(t, zonepb.SetNamedConstraintSets(nil)) }()
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	config.WatchNamedConstraintSets(ctx, &st.SV)
+	set := func(value string) error {
+		return settings.NewUpdater(&st.SV).Set(ctx, "zone_configs.named_constraint_sets",
+			settings.EncodedValue{Value: value, Type: "s"})
+	}
+	resolve := func() string {
+		var zone zonepb.ZoneConfig
+		require.NoError(t, yaml.Unmarshal([]byte("constraints: '@default'"), &zone))
+		return zone.Constraints[0].String()
+	}
+
+	require.NoError(t, set("{east: [+region=us-east1], default: '@east'}"))
+	require.Equal(t, "+region=us-east1", resolve())
+	require.NoError(t, set("{default: [+region=us-west1, +ssd]}"))
+	require.Equal(t, "+region=us-west1,+ssd", resolve())
+
+	// Invalid definitions are rejected, and leave the sets as they were.
+	require.ErrorContains(t, set("{default: '@missing'}"), `constraint set "missing" is not defined`)
+	require.ErrorContains(t, set("[+region=us-east1]"), "parsing named constraint sets")
+	require.Equal(t, "+region=us-west1,+ssd", resolve())
+}
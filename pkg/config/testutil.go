@@ -87,3 +87,11 @@ func testingZoneConfigHook(
 	}
 	return nil, nil, false, nil
 }
+
+// TestingResetDefaultSystemZoneConfigs removes the overrides registered with
+// SetDefaultSystemZoneConfig.
+func TestingResetDefaultSystemZoneConfigs() {
+	defaultSystemZoneConfigs.Lock()
+	defer defaultSystemZoneConfigs.Unlock()
+	defaultSystemZoneConfigs.overrides = nil
+}
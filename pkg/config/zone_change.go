@@ -0,0 +1,296 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// zoneChangeRegistry tracks the zone change callbacks registered on a
+// sequence of SystemConfig snapshots, along with a per-object version which is
+// bumped every time the object's zone config is observed to change. It is
+// shared by all the snapshots in the sequence (see NotifyZoneChanges).
+type zoneChangeRegistry struct {
+	mu struct {
+		syncutil.Mutex
+		nextID    int
+		callbacks map[ObjectID]map[int]*zoneChangeCallback
+		versions  map[ObjectID]uint64
+		// defaultVersion is bumped whenever the default zone changes, which
+		// affects every object.
+		defaultVersion uint64
+		// queue holds the notifications which are yet to be delivered, in
+		// order. They're delivered by a single goroutine, which runs while
+		// draining is set.
+		queue    []zoneChangeNotification
+		draining bool
+	}
+}
+
+// zoneChangeNotification is the invocation of a zone change callback with the
+// zone config it's notified of.
+type zoneChangeNotification struct {
+	fn   func(zonepb.ZoneConfig)
+	zone zonepb.ZoneConfig
+}
+
+type zoneChangeCallback struct {
+	fn func(zonepb.ZoneConfig)
+	// version and defaultVersion are the versions the callback was last
+	// invoked for (or registered at).
+	version, defaultVersion uint64
+}
+
+func newZoneChangeRegistry() *zoneChangeRegistry {
+	r := &zoneChangeRegistry{}
+	r.mu.callbacks = make(map[ObjectID]map[int]*zoneChangeCallback)
+	r.mu.versions = make(map[ObjectID]uint64)
+	return r
+}
+
+// zoneChangeRegistry returns the registry for the snapshot, creating it if
+// necessary.
+func (s *SystemConfig) zoneChangeRegistry() *zoneChangeRegistry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.zoneChanges == nil {
+		s.mu.zoneChanges = newZoneChangeRegistry()
+	}
+	return s.mu.zoneChanges
+}
+
+// RegisterZoneChangeCallback registers a callback which is invoked with the
+// zone config in effect for the given system tenant object whenever it
+// changes, be it through the object's own zone config entry, the entry it
+// inherits from, such as the database of a table, or the default zone.
+//
+// Callbacks are invoked asynchronously, one at a time and in snapshot order,
+// with their own copy of the zone config. Registrations carry over to every
+// snapshot which succeeds this one. The returned function unregisters the
+// callback, though notifications which are already queued are delivered.
+func (s *SystemConfig) RegisterZoneChangeCallback(
+	objectID ObjectID, fn func(zonepb.ZoneConfig),
+) (unregister func()) {
+	r := s.zoneChangeRegistry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.mu.nextID
+	r.mu.nextID++
+	cbs, ok := r.mu.callbacks[objectID]
+	if !ok {
+		cbs = make(map[int]*zoneChangeCallback)
+		r.mu.callbacks[objectID] = cbs
+	}
+	cbs[id] = &zoneChangeCallback{
+		fn:             fn,
+		version:        r.mu.versions[objectID],
+		defaultVersion: r.mu.defaultVersion,
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.mu.callbacks[objectID], id)
+		if len(r.mu.callbacks[objectID]) == 0 {
+			delete(r.mu.callbacks, objectID)
+		}
+	}
+}
+
+// ZoneVersion returns a counter which is incremented every time
+// NotifyZoneChanges observes a change to the zone config of the given object
+// or to the default zone. Changes to the entries an object inherits from are
+// only observed for the objects with registered callbacks. Versions are only
+// comparable among snapshots which share their registrations.
+func (s *SystemConfig) ZoneVersion(objectID ObjectID) uint64 {
+	r := s.zoneChangeRegistry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mu.versions[objectID] + r.mu.defaultVersion
+}
+
+// NotifyZoneChanges is called by the producer of SystemConfig snapshots after
+// replacing prev with this snapshot. It carries the zone change callbacks
+// registered on prev over to this snapshot and queues the invocation of those
+// whose zone config changed between the two, either as computed by Diff or
+// through the entry the zone config is inherited from. It doesn't wait for
+// the callbacks to run.
+//
+// Calls must be serialized by the caller, in snapshot order.
+func (s *SystemConfig) NotifyZoneChanges(ctx context.Context, prev *SystemConfig) {
+	r := prev.zoneChangeRegistry()
+	s.mu.Lock()
+	if own := s.mu.zoneChanges; own != nil && own != r {
+		// Callbacks were registered on this snapshot before it was linked to
+		// its predecessor. Fold them into the shared registry.
+		own.mu.Lock()
+		r.mu.Lock()
+		for objectID, cbs := range own.mu.callbacks {
+			if r.mu.callbacks[objectID] == nil {
+				r.mu.callbacks[objectID] = make(map[int]*zoneChangeCallback)
+			}
+			for _, cb := range cbs {
+				cb.version, cb.defaultVersion = r.mu.versions[objectID], r.mu.defaultVersion
+				r.mu.callbacks[objectID][r.mu.nextID] = cb
+				r.mu.nextID++
+			}
+		}
+		r.mu.Unlock()
+		own.mu.Unlock()
+	}
+	s.mu.zoneChanges = r
+	s.mu.Unlock()
+
+	delta := s.Diff(prev)
+	if delta.IsEmpty() {
+		return
+	}
+
+	changed := make(map[ObjectID]bool, len(delta.ChangedZones))
+	for _, objectID := range delta.ChangedZones {
+		changed[objectID] = true
+	}
+	if !delta.DefaultZoneChanged {
+		// The zone config in effect for an object also changes along with the
+		// entry it's inherited from, e.g. that of the database of a table,
+		// which only the objects with callbacks are checked for.
+		for _, objectID := range r.registeredObjects() {
+			if !changed[objectID] && s.effectiveZoneConfigChanged(prev, objectID) {
+				changed[objectID] = true
+			}
+		}
+	}
+
+	type pending struct {
+		objectID ObjectID
+		fns      []func(zonepb.ZoneConfig)
+	}
+	var toNotify []pending
+	r.mu.Lock()
+	for objectID := range changed {
+		r.mu.versions[objectID]++
+	}
+	if delta.DefaultZoneChanged {
+		r.mu.defaultVersion++
+	}
+	for objectID, cbs := range r.mu.callbacks {
+		p := pending{objectID: objectID}
+		for _, cb := range cbs {
+			version := r.mu.versions[objectID]
+			if cb.version == version && cb.defaultVersion == r.mu.defaultVersion {
+				continue
+			}
+			cb.version, cb.defaultVersion = version, r.mu.defaultVersion
+			p.fns = append(p.fns, cb.fn)
+		}
+		if len(p.fns) > 0 {
+			toNotify = append(toNotify, p)
+		}
+	}
+	r.mu.Unlock()
+
+	var notifications []zoneChangeNotification
+	for _, p := range toNotify {
+		zone, err := s.effectiveZoneConfig(p.objectID)
+		if err != nil {
+			log.Warningf(ctx, "unable to look up zone config for %d: %v", p.objectID, err)
+			continue
+		}
+		for _, fn := range p.fns {
+			// The callbacks may modify their zone config in place, so each is
+			// handed a deep copy rather than a clone sharing its slices.
+			notifications = append(notifications, zoneChangeNotification{
+				fn: fn, zone: *protoutil.Clone(zone).(*zonepb.ZoneConfig),
+			})
+		}
+	}
+	r.enqueue(notifications)
+}
+
+// registeredObjects returns the IDs of the objects with registered callbacks.
+func (r *zoneChangeRegistry) registeredObjects() []ObjectID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	objectIDs := make([]ObjectID, 0, len(r.mu.callbacks))
+	for objectID := range r.mu.callbacks {
+		objectIDs = append(objectIDs, objectID)
+	}
+	return objectIDs
+}
+
+// enqueue queues the notifications for delivery, after those which are already
+// queued, and starts delivering them if need be.
+func (r *zoneChangeRegistry) enqueue(notifications []zoneChangeNotification) {
+	if len(notifications) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mu.queue = append(r.mu.queue, notifications...)
+	if !r.mu.draining {
+		r.mu.draining = true
+		go r.drain()
+	}
+}
+
+// drain delivers the queued notifications, in order, until the queue is empty.
+func (r *zoneChangeRegistry) drain() {
+	for {
+		r.mu.Lock()
+		if len(r.mu.queue) == 0 {
+			r.mu.queue = nil
+			r.mu.draining = false
+			r.mu.Unlock()
+			return
+		}
+		n := r.mu.queue[0]
+		r.mu.queue = r.mu.queue[1:]
+		r.mu.Unlock()
+		n.fn(n.zone)
+	}
+}
+
+// effectiveZoneConfig returns the zone config in effect for the given system
+// tenant object, falling back to the default zone config. The zone config is
+// shared with the snapshot and must not be modified.
+func (s *SystemConfig) effectiveZoneConfig(objectID ObjectID) (*zonepb.ZoneConfig, error) {
+	entry, err := s.getZoneEntry(keys.SystemSQLCodec, objectID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.combined != nil {
+		return entry.combined, nil
+	}
+	if s.DefaultZoneConfig != nil {
+		return s.DefaultZoneConfig, nil
+	}
+	return &zonepb.ZoneConfig{}, nil
+}
+
+// effectiveZoneConfigChanged returns whether the zone config in effect for the
+// given system tenant object differs between prev and this snapshot. It
+// returns false if the zone config can't be looked up in either of them.
+func (s *SystemConfig) effectiveZoneConfigChanged(prev *SystemConfig, objectID ObjectID) bool {
+	before, err := prev.effectiveZoneConfig(objectID)
+	if err != nil {
+		return false
+	}
+	after, err := s.effectiveZoneConfig(objectID)
+	if err != nil {
+		return false
+	}
+	return !before.Equal(after)
+}
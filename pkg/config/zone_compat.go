@@ -0,0 +1,218 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ZoneConfigEncoding is an encoding of zone configs in the compatibility
+// corpus.
+type ZoneConfigEncoding string
+
+const (
+	// ZoneConfigEncodingYAML is the YAML accepted by ALTER ... CONFIGURE ZONE,
+	// which is decoded on top of zonepb.NewZoneConfig().
+	ZoneConfigEncodingYAML ZoneConfigEncoding = "yaml"
+	// ZoneConfigEncodingProto is the base64-encoded protobuf encoding stored
+	// in system.zones.
+	ZoneConfigEncodingProto ZoneConfigEncoding = "proto"
+)
+
+// ZoneConfigCompatEntry is a zone config encoded by a past release, along
+// with the zone config which it decoded to.
+type ZoneConfigCompatEntry struct {
+	Name     string             `json:"name"`
+	Encoding ZoneConfigEncoding `json:"encoding"`
+	Data     string             `json:"data"`
+	// Decoded is the protobuf text format of the decoded zone config.
+	Decoded string `json:"decoded"`
+}
+
+// ZoneConfigCompatRelease is the part of the compatibility corpus which was
+// encoded by a release. Each release is stored in its own file.
+type ZoneConfigCompatRelease struct {
+	Release string                  `json:"release"`
+	Entries []ZoneConfigCompatEntry `json:"entries"`
+}
+
+// ZoneConfigCompatDocs are the YAML documents which are added to the
+// compatibility corpus for each release, in both encodings. They exercise the
+// legacy list and map formats of constraints along with every field.
+var ZoneConfigCompatDocs = []struct {
+	Name string
+	YAML string
+}{
+	{"empty", `{}`},
+	{"scalars", `
+range_min_bytes: 1048576
+range_max_bytes: 67108864
+gc: {ttlseconds: 90000}
+global_reads: true
+num_replicas: 5
+num_voters: 3
+`},
+	{"list-constraints", `constraints: [+region=us-east1, -ssd]`},
+	{"empty-list-constraints", `constraints: []`},
+	{"map-constraints", `
+num_replicas: 3
+constraints: {"+region=us-east1": 2, "+region=us-west1,+ssd": 1}
+lease_preferences: [[+region=us-east1], [+region=us-west1]]
+`},
+	{"voter-constraints", `
+num_replicas: 5
+num_voters: 3
+constraints: {"+region=us-east1": 1}
+voter_constraints: {"+region=us-west1": 2}
+`},
+}
+
+// Decode decodes the entry with the current code.
+func (e ZoneConfigCompatEntry) Decode() (zonepb.ZoneConfig, error) {
+	switch e.Encoding {
+	case ZoneConfigEncodingYAML:
+		zone := zonepb.NewZoneConfig()
+		if err := yaml.Unmarshal([]byte(e.Data), zone); err != nil {
+			return zonepb.ZoneConfig{}, err
+		}
+		return *zone, nil
+	case ZoneConfigEncodingProto:
+		buf, err := base64.StdEncoding.DecodeString(e.Data)
+		if err != nil {
+			return zonepb.ZoneConfig{}, err
+		}
+		var zone zonepb.ZoneConfig
+		if err := protoutil.Unmarshal(buf, &zone); err != nil {
+			return zonepb.ZoneConfig{}, err
+		}
+		return zone, nil
+	default:
+		return zonepb.ZoneConfig{}, errors.Newf("unknown encoding %q", e.Encoding)
+	}
+}
+
+// Verify returns an error if the current code doesn't decode the entry to the
+// zone config which it decoded to when it was added to the corpus. The zone
+// configs are compared once they're normalized as by
+// zonepb.ParseZoneConfigFromText, so that the order of per-replica
+// constraints doesn't matter.
+func (e ZoneConfigCompatEntry) Verify() error {
+	zone, err := e.Decode()
+	if err != nil {
+		return errors.Wrapf(err, "decoding %s", e.Name)
+	}
+	expected, err := zonepb.ParseZoneConfigFromText(e.Decoded)
+	if err != nil {
+		return errors.Wrapf(err, "parsing the decoded zone config of %s", e.Name)
+	}
+	actual, err := zonepb.ParseZoneConfigFromText(zone.MarshalProtoText())
+	if err != nil {
+		return errors.Wrapf(err, "normalizing %s", e.Name)
+	}
+	if !expected.Equal(&actual) {
+		return errors.Newf("%s (%s) decoded to:\n%s\nexpected:\n%s",
+			e.Name, e.Encoding, actual.MarshalProtoText(), expected.MarshalProtoText())
+	}
+	return nil
+}
+
+// VerifyZoneConfigCompatCorpus verifies every entry of the corpus, and returns
+// the errors of the entries which fail.
+func VerifyZoneConfigCompatCorpus(corpus []ZoneConfigCompatRelease) []error {
+	var errs []error
+	for _, r := range corpus {
+		for _, e := range r.Entries {
+			if err := e.Verify(); err != nil {
+				errs = append(errs, errors.Wrapf(err, "%s", r.Release))
+			}
+		}
+	}
+	return errs
+}
+
+// LoadZoneConfigCompatCorpus reads the releases of the corpus stored in the
+// directory, ordered by file name.
+func LoadZoneConfigCompatCorpus(dir string) ([]ZoneConfigCompatRelease, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	corpus := make([]ZoneConfigCompatRelease, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var r ZoneConfigCompatRelease
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, errors.Wrapf(err, "decoding %s", path)
+		}
+		corpus = append(corpus, r)
+	}
+	return corpus, nil
+}
+
+// MakeZoneConfigCompatRelease encodes ZoneConfigCompatDocs with the current
+// code, as the part of the corpus for the given release.
+func MakeZoneConfigCompatRelease(release string) (ZoneConfigCompatRelease, error) {
+	r := ZoneConfigCompatRelease{Release: release}
+	for _, doc := range ZoneConfigCompatDocs {
+		data := strings.TrimSpace(doc.YAML)
+		yamlEntry := ZoneConfigCompatEntry{Name: doc.Name, Encoding: ZoneConfigEncodingYAML, Data: data}
+		zone, err := yamlEntry.Decode()
+		if err != nil {
+			return ZoneConfigCompatRelease{}, errors.Wrapf(err, "decoding %s", doc.Name)
+		}
+		buf, err := protoutil.Marshal(&zone)
+		if err != nil {
+			return ZoneConfigCompatRelease{}, errors.Wrapf(err, "encoding %s", doc.Name)
+		}
+		yamlEntry.Decoded = zone.MarshalProtoText()
+		r.Entries = append(r.Entries, yamlEntry, ZoneConfigCompatEntry{
+			Name:     doc.Name,
+			Encoding: ZoneConfigEncodingProto,
+			Data:     base64.StdEncoding.EncodeToString(buf),
+			Decoded:  yamlEntry.Decoded,
+		})
+	}
+	return r, nil
+}
+
+// WriteZoneConfigCompatRelease adds the part of the corpus for the given
+// release to the directory, as generated by MakeZoneConfigCompatRelease. It
+// is meant to be run once per release, and fails if the release is already
+// part of the corpus, since the entries of past releases must never change.
+func WriteZoneConfigCompatRelease(dir, release string) error {
+	path := filepath.Join(dir, release+".json")
+	if _, err := os.Stat(path); err == nil {
+		return errors.Newf("release %s is already part of the corpus", release)
+	}
+	r, err := MakeZoneConfigCompatRelease(release)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// defaultSystemZoneConfigs holds the overrides registered with
+// SetDefaultSystemZoneConfig.
+var defaultSystemZoneConfigs struct {
+	syncutil.Mutex
+	overrides map[ObjectID]*zonepb.ZoneConfig
+}
+
+// maxDefaultSystemGCTTL is the longest GC TTL that the default zone configs of
+// the system ranges which are rewritten constantly may have. Longer GC TTLs
+// make them accumulate MVCC garbage, which slows down every scan of them. The
+// limits are the GC TTLs the ranges are bootstrapped with when there is no
+// override.
+var maxDefaultSystemGCTTL = map[ObjectID]time.Duration{
+	keys.MetaRangesID:     time.Hour,
+	keys.LivenessRangesID: 10 * time.Minute,
+}
+
+// SetDefaultSystemZoneConfig overrides the zone config that the meta, liveness
+// or timeseries ranges, identified by their pseudo-table ID, are bootstrapped
+// with. It must be called at startup, before the cluster is bootstrapped, and
+// has no effect on clusters which are already bootstrapped.
+//
+// The zone config is validated, and the meta and liveness ranges must keep a
+// GC TTL no longer than the one they have by default. Fields which are not set
+// are inherited from RANGE default.
+func SetDefaultSystemZoneConfig(rangeID ObjectID, zone zonepb.ZoneConfig) error {
+	switch rangeID {
+	case keys.MetaRangesID, keys.LivenessRangesID, keys.TimeseriesRangesID:
+	default:
+		return errors.Newf("the default zone config of %d cannot be overridden", rangeID)
+	}
+	name := zonepb.NamedZonesByID[uint32(rangeID)]
+	if err := zone.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid default zone config for RANGE %s", name)
+	}
+	if maxTTL, ok := maxDefaultSystemGCTTL[rangeID]; ok {
+		if zone.GC == nil || zone.GC.TTLSeconds <= 0 ||
+			time.Duration(zone.GC.TTLSeconds)*time.Second > maxTTL {
+			return errors.Newf("the default zone config for RANGE %s must set a GC TTL of at most %s",
+				name, maxTTL)
+		}
+	}
+
+	defaultSystemZoneConfigs.Lock()
+	defer defaultSystemZoneConfigs.Unlock()
+	if defaultSystemZoneConfigs.overrides == nil {
+		defaultSystemZoneConfigs.overrides = make(map[ObjectID]*zonepb.ZoneConfig)
+	}
+	defaultSystemZoneConfigs.overrides[rangeID] = protoutil.Clone(&zone).(*zonepb.ZoneConfig)
+	return nil
+}
+
+// DefaultSystemZoneConfigOverride returns the default zone config registered
+// for the given system range with SetDefaultSystemZoneConfig, if any.
+func DefaultSystemZoneConfigOverride(rangeID ObjectID) (*zonepb.ZoneConfig, bool) {
+	defaultSystemZoneConfigs.Lock()
+	defer defaultSystemZoneConfigs.Unlock()
+	zone, ok := defaultSystemZoneConfigs.overrides[rangeID]
+	if !ok {
+		return nil, false
+	}
+	return protoutil.Clone(zone).(*zonepb.ZoneConfig), true
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"io"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// ConfigEvaluation describes the zone config which applies to a key, as
+// returned by EvaluateConfig.
+type ConfigEvaluation struct {
+	// Target is the most specific object which the key belongs to. See
+	// SystemConfig.TargetForKey.
+	Target ZoneTarget
+	// Zone is the zone config in effect for the key, hydrated from its
+	// parents, and Provenance maps each of its fields to the zone config which
+	// supplied it. See SystemConfig.GetEffectiveZoneConfig.
+	Zone       zonepb.ZoneConfig
+	Provenance Provenance
+}
+
+// EvaluateConfig answers which zone config applies to the given system tenant
+// key, according to a snapshot of the system config written by
+// SystemConfig.WriteDebugSnapshot, e.g. as captured by a debug zip. It works
+// entirely on the snapshot, so it can be used by standalone tools: descriptors
+// are interpreted by the registered DescriptorProvider, and the zone configs
+// aren't looked up through ZoneConfigHook. Use ReadDebugSnapshot and
+// SystemConfig.EvaluateConfig to evaluate several keys against a snapshot.
+func EvaluateConfig(snapshot io.Reader, key roachpb.RKey) (ConfigEvaluation, error) {
+	cfg, err := ReadDebugSnapshot(snapshot)
+	if err != nil {
+		return ConfigEvaluation{}, err
+	}
+	return cfg.EvaluateConfig(key)
+}
+
+// EvaluateConfig is like the EvaluateConfig function, but evaluates the key
+// against the system config.
+func (s *SystemConfig) EvaluateConfig(key roachpb.RKey) (ConfigEvaluation, error) {
+	target, err := s.TargetForKey(key)
+	if err != nil {
+		return ConfigEvaluation{}, err
+	}
+	zone, provenance, err := s.GetEffectiveZoneConfig(key)
+	if err != nil {
+		return ConfigEvaluation{}, err
+	}
+	return ConfigEvaluation{Target: target, Zone: zone, Provenance: provenance}, nil
+}
@@ -0,0 +1,365 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// errExportedObjectDropped is returned when resolving the name of an object
+// which no longer exists, or is being dropped.
+var errExportedObjectDropped = errors.New("object is dropped")
+
+// ExportZoneConfigs returns a YAML document which maps the target of every
+// system tenant zone config, as written in ALTER ... CONFIGURE ZONE (e.g.
+// "RANGE default", "DATABASE db" or "INDEX db.public.t@idx"), to the zone
+// config in effect for it, e.g.:
+//
+//	RANGE default:
+//	  range_min_bytes: 134217728
+//	  ...
+//	TABLE db.public.t:
+//	  range_min_bytes: 134217728
+//	  ...
+//
+// Each config is fully hydrated: fields inherited from the parent database,
+// table or index, or from the default zone, are filled in. Targets appear in
+// object ID order, with each table's indexes and partitions after it. Zone
+// configs of dropped objects, as well as subzone placeholders, are omitted.
+func (s *SystemConfig) ExportZoneConfigs() ([]byte, error) {
+	zones, err := s.exportZoneConfigs()
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, z := range zones {
+		var node yaml.Node
+		if err := node.Encode(z.zone); err != nil {
+			return nil, err
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: z.target}, &node)
+	}
+	return zonepb.MarshalYAML(doc)
+}
+
+// ExportAnnotatedZoneConfigs is like ExportZoneConfigs, except that the value
+// of each inherited field is followed by a comment naming the target it's
+// inherited from, e.g.:
+//
+//	TABLE db.public.t:
+//	  range_min_bytes: 134217728 # inherited from RANGE default
+//	  ...
+//	  num_replicas: 5 # inherited from DATABASE db
+//
+// Fields without such a comment are explicitly set on the target.
+func (s *SystemConfig) ExportAnnotatedZoneConfigs() ([]byte, error) {
+	zones, err := s.exportZoneConfigs()
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, z := range zones {
+		node, err := zonepb.YAMLNodeWithInheritedFields(z.zone, inheritedFieldsForZoneExport(z.levels))
+		if err != nil {
+			return nil, err
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: z.target}, node)
+	}
+	return zonepb.MarshalYAML(doc)
+}
+
+// ExportNamedSubzones returns a YAML document which lists the subzones of the
+// zone config of the given table, in order, with their indexes identified by
+// name rather than by ID. See zonepb.NamedSubzone for the encoding of each
+// subzone. Unlike the subzones themselves, the document carries over to a
+// table whose indexes were assigned new IDs, e.g. by RESTORE, through
+// zonepb.ZoneConfig.SetNamedSubzones. Subzones of indexes which are no longer
+// public are omitted, and the list is empty if the table has no subzones.
+func (s *SystemConfig) ExportNamedSubzones(id ObjectID) ([]byte, error) {
+	entry, err := s.getZoneEntry(keys.SystemSQLCodec, id)
+	if err != nil {
+		return nil, err
+	}
+	subzones := []zonepb.NamedSubzone{}
+	if entry.combined != nil && len(entry.combined.Subzones) > 0 {
+		table, err := s.getTableDescForZoneExport(uint32(id))
+		if err != nil {
+			return nil, err
+		}
+		subzones = append(subzones, entry.combined.NamedSubzones(NewTableIndexNameResolver(table))...)
+	}
+	return zonepb.MarshalYAML(subzones)
+}
+
+// NewTableIndexNameResolver returns a zonepb.IndexNameResolver which resolves
+// the public indexes of the table, as interpreted by a DescriptorProvider,
+// e.g. to install the subzones exported by ExportNamedSubzones on a restored
+// table.
+func NewTableIndexNameResolver(table DescriptorInfo) zonepb.IndexNameResolver {
+	return tableIndexNames{names: table.IndexNames}
+}
+
+// tableIndexNames implements zonepb.IndexNameResolver for a table.
+type tableIndexNames struct {
+	names map[uint32]string
+}
+
+// IndexName implements zonepb.IndexNameResolver.
+func (t tableIndexNames) IndexName(id uint32) (string, bool) {
+	name, ok := t.names[id]
+	return name, ok
+}
+
+// IndexID implements zonepb.IndexNameResolver.
+func (t tableIndexNames) IndexID(name string) (uint32, bool) {
+	for id, n := range t.names {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// exportedZoneConfig is a hydrated zone config returned by exportZoneConfigs.
+type exportedZoneConfig struct {
+	// id is the ID of the zone config's object; subzones have the ID of their
+	// table.
+	id     uint32
+	target string
+	zone   zonepb.ZoneConfig
+	// levels are the zone configs which the zone config was hydrated from,
+	// starting with its own.
+	levels []exportedZoneConfigLevel
+}
+
+// exportedZoneConfigLevel is an unhydrated zone config along with its target.
+type exportedZoneConfigLevel struct {
+	zone   *zonepb.ZoneConfig
+	target string
+}
+
+// exportZoneConfigs returns the zone configs exported by ExportZoneConfigs.
+func (s *SystemConfig) exportZoneConfigs() ([]exportedZoneConfig, error) {
+	zones := make(map[uint32]*zonepb.ZoneConfig)
+	var ids []uint32
+	for _, kv := range s.zoneValues() {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		zone := &zonepb.ZoneConfig{}
+		if err := kv.Value.GetProto(zone); err != nil {
+			return nil, errors.Wrapf(err, "decoding zone config for %d", id)
+		}
+		if _, ok := zones[id]; !ok {
+			ids = append(ids, id)
+		}
+		zones[id] = zone
+	}
+	targetOf := func(id uint32) (zonepb.ZoneSpecifier, error) {
+		zs, err := zonepb.ZoneSpecifierFromID(id, s.resolveIDForZoneExport)
+		if err != nil && !errors.Is(err, errExportedObjectDropped) {
+			err = errors.Wrapf(err, "resolving zone config target %d", id)
+		}
+		return zs, err
+	}
+	prepend := func(
+		level exportedZoneConfigLevel, levels []exportedZoneConfigLevel,
+	) []exportedZoneConfigLevel {
+		return append([]exportedZoneConfigLevel{level}, levels...)
+	}
+
+	defaultZone := zonepb.DefaultZoneConfig()
+	if s.DefaultZoneConfig != nil {
+		defaultZone = *s.DefaultZoneConfig
+	}
+	defaultSpecifier, err := targetOf(keys.RootNamespaceID)
+	if err != nil {
+		return nil, err
+	}
+	defaultTarget := tree.AsString(&defaultSpecifier)
+	fallbackZone := defaultZone
+	fallbackLevels := []exportedZoneConfigLevel{{zone: &fallbackZone, target: defaultTarget}}
+	defaultLevels := fallbackLevels
+	if zone, ok := zones[keys.RootNamespaceID]; ok {
+		defaultLevels = prepend(exportedZoneConfigLevel{zone: zone, target: defaultTarget}, defaultLevels)
+		hydrated := *zone
+		hydrated.InheritFromParent(&defaultZone)
+		defaultZone = hydrated
+	}
+
+	var res []exportedZoneConfig
+	for _, id := range ids {
+		zs, err := targetOf(id)
+		if errors.Is(err, errExportedObjectDropped) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		target := tree.AsString(&zs)
+
+		// Tables inherit from their database, if it has a zone config, and
+		// everything else from the default zone.
+		parentZone := defaultZone
+		parentLevels := defaultLevels
+		if id == keys.RootNamespaceID {
+			parentLevels = fallbackLevels
+		} else if zs.TargetsTable() {
+			parentID, _, _, err := s.resolveIDForZoneExport(id)
+			if err != nil {
+				return nil, err
+			}
+			if parent, ok := zones[parentID]; ok {
+				parentZone = *parent
+				parentZone.InheritFromParent(&defaultZone)
+				parentSpecifier, err := targetOf(parentID)
+				if err != nil {
+					return nil, err
+				}
+				parentLevels = prepend(exportedZoneConfigLevel{
+					zone: parent, target: tree.AsString(&parentSpecifier),
+				}, defaultLevels)
+			}
+		}
+		zone := *zones[id]
+		subzones := zone.Subzones
+		zoneLevels := parentLevels
+		if zone.IsSubzonePlaceholder() {
+			// The placeholder's fields are ignored in favor of its parent's.
+			zone = parentZone
+		} else {
+			zoneLevels = prepend(exportedZoneConfigLevel{zone: zones[id], target: target}, parentLevels)
+			zone.InheritFromParent(&parentZone)
+			zone.Subzones, zone.SubzoneSpans = nil, nil
+			res = append(res, exportedZoneConfig{id: id, target: target, zone: zone, levels: zoneLevels})
+		}
+		if len(subzones) == 0 {
+			continue
+		}
+
+		table, err := s.getTableDescForZoneExport(id)
+		if err != nil {
+			return nil, err
+		}
+		for i := range subzones {
+			subzone := &subzones[i]
+			indexName, ok := table.IndexNames[subzone.IndexID]
+			if !ok {
+				// The index has been dropped.
+				continue
+			}
+			subzoneSpecifier := zs
+			subzoneSpecifier.TableOrIndex.Index = tree.UnrestrictedName(indexName)
+			subzoneConfig := subzone.Config
+			subzoneLevels := zoneLevels
+			if subzone.PartitionName != "" {
+				// Partitions inherit from their index, if it has a zone config.
+				if index, ok := zones[id].GetSubzoneForIndexPartition(subzone.IndexID, ""); ok {
+					subzoneConfig.InheritFromParent(&index.Config)
+					subzoneLevels = prepend(exportedZoneConfigLevel{
+						zone: &index.Config, target: tree.AsString(&subzoneSpecifier),
+					}, subzoneLevels)
+				}
+			}
+			subzoneConfig.InheritFromParent(&zone)
+			subzoneSpecifier.Partition = tree.Name(subzone.PartitionName)
+			subzoneTarget := tree.AsString(&subzoneSpecifier)
+			res = append(res, exportedZoneConfig{
+				id:     id,
+				target: subzoneTarget,
+				zone:   subzoneConfig,
+				levels: prepend(exportedZoneConfigLevel{zone: &subzone.Config, target: subzoneTarget}, subzoneLevels),
+			})
+		}
+	}
+	return res, nil
+}
+
+// inheritedFieldsForZoneExport maps each field of the first of the given
+// levels which is inherited to the target of the level it's inherited from.
+// See Provenance.
+func inheritedFieldsForZoneExport(levels []exportedZoneConfigLevel) map[string]string {
+	res := make(map[string]string)
+	for _, f := range provenanceFields {
+		if f.isSet(levels[0].zone) {
+			continue
+		}
+		for _, level := range levels[1:] {
+			if f.isSet(level.zone) {
+				res[f.name] = level.target
+				break
+			}
+		}
+	}
+	return res
+}
+
+// resolveIDForZoneExport returns the parent ID, parent schema ID and name of
+// the given descriptor, for use with zonepb.ZoneSpecifierFromID.
+func (s *SystemConfig) resolveIDForZoneExport(
+	id uint32,
+) (parentID, parentSchemaID uint32, name string, err error) {
+	if id == keys.PublicSchemaID {
+		// Tables in the public schema of databases created before user-defined
+		// schemas may reference its pseudo ID, which has no descriptor.
+		return 0, 0, tree.PublicSchema, nil
+	}
+	desc, err := s.getDescForZoneExport(id)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if desc.Dropped {
+		return 0, 0, "", errExportedObjectDropped
+	}
+	switch desc.Kind {
+	case DescriptorKindTable:
+		return uint32(desc.ParentID), uint32(desc.ParentSchemaID), desc.Name, nil
+	case DescriptorKindDatabase:
+		return keys.RootNamespaceID, keys.RootNamespaceID, desc.Name, nil
+	case DescriptorKindSchema:
+		return uint32(desc.ParentID), keys.RootNamespaceID, desc.Name, nil
+	default:
+		return 0, 0, "", errors.AssertionFailedf("unexpected descriptor %d with zone config", id)
+	}
+}
+
+// getDescForZoneExport returns the descriptor with the given ID, as
+// interpreted by the registered DescriptorProvider.
+func (s *SystemConfig) getDescForZoneExport(id uint32) (DescriptorInfo, error) {
+	desc, ok, err := s.GetDescriptorInfo(ObjectID(id))
+	if err != nil {
+		return DescriptorInfo{}, err
+	}
+	if !ok {
+		// The descriptor was removed, but its zone config was not (yet).
+		return DescriptorInfo{}, errExportedObjectDropped
+	}
+	return desc, nil
+}
+
+// getTableDescForZoneExport returns the table descriptor with the given ID, as
+// interpreted by the registered DescriptorProvider.
+func (s *SystemConfig) getTableDescForZoneExport(id uint32) (DescriptorInfo, error) {
+	desc, err := s.getDescForZoneExport(id)
+	if err != nil {
+		return DescriptorInfo{}, err
+	}
+	if desc.Kind != DescriptorKindTable {
+		return DescriptorInfo{}, errors.AssertionFailedf("descriptor %d with subzones is not a table", id)
+	}
+	return desc, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/errors"
+)
+
+var (
+	metaZoneConfigs = metric.Metadata{
+		Name:        "zone_configs.count",
+		Help:        "Number of zone configs, as seen by the system config",
+		Measurement: "Zone Configs",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaZoneConfigSubzones = metric.Metadata{
+		Name:        "zone_configs.subzones",
+		Help:        "Number of subzones of all zone configs, as seen by the system config",
+		Measurement: "Subzones",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaZoneConfigMaxSubzones = metric.Metadata{
+		Name:        "zone_configs.subzones.max",
+		Help:        "Largest number of subzones of a single zone config, as seen by the system config",
+		Measurement: "Subzones",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaZoneConfigMaxConstraints = metric.Metadata{
+		Name: "zone_configs.constraints.max",
+		Help: "Largest number of constraints of a single zone config, including its voter " +
+			"constraints, lease preferences and subzones, as seen by the system config",
+		Measurement: "Constraints",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaZoneConfigYAMLParseErrors = metric.Metadata{
+		Name:        "zone_configs.yaml_parse_errors",
+		Help:        "Number of zone config YAML documents which failed to parse",
+		Measurement: "Errors",
+		Unit:        metric.Unit_COUNT,
+	}
+)
+
+// MetricRegistry is the registry which ZoneConfigMetrics are added to, such
+// as a *metric.Registry.
+type MetricRegistry interface {
+	AddMetricStruct(metricStruct interface{})
+}
+
+// ZoneConfigMetrics track the number and size of the zone configs of the
+// system config, so that runaway zone configs, e.g. generated by buggy
+// automation, can be caught. All of the methods may be called on a nil
+// *ZoneConfigMetrics, in which case they do nothing.
+type ZoneConfigMetrics struct {
+	ZoneConfigs    *metric.Gauge
+	Subzones       *metric.Gauge
+	MaxSubzones    *metric.Gauge
+	MaxConstraints *metric.Gauge
+	// YAMLParseErrors is incremented by the users of zone config YAML through
+	// RecordYAMLParseError.
+	YAMLParseErrors *metric.Counter
+}
+
+var _ metric.Struct = (*ZoneConfigMetrics)(nil)
+
+// MetricStruct implements the metric.Struct interface.
+func (*ZoneConfigMetrics) MetricStruct() {}
+
+// NewZoneConfigMetrics creates the zone config metrics and adds them to the
+// registry, if any.
+func NewZoneConfigMetrics(registry MetricRegistry) *ZoneConfigMetrics {
+	m := &ZoneConfigMetrics{
+		ZoneConfigs:     metric.NewGauge(metaZoneConfigs),
+		Subzones:        metric.NewGauge(metaZoneConfigSubzones),
+		MaxSubzones:     metric.NewGauge(metaZoneConfigMaxSubzones),
+		MaxConstraints:  metric.NewGauge(metaZoneConfigMaxConstraints),
+		YAMLParseErrors: metric.NewCounter(metaZoneConfigYAMLParseErrors),
+	}
+	if registry != nil {
+		registry.AddMetricStruct(m)
+	}
+	return m
+}
+
+// RecordYAMLParseError records that a zone config YAML document failed to
+// parse.
+func (m *ZoneConfigMetrics) RecordYAMLParseError() {
+	if m == nil {
+		return
+	}
+	m.YAMLParseErrors.Inc(1)
+}
+
+// Update sets the gauges from the zone configs of the system config.
+func (m *ZoneConfigMetrics) Update(cfg *SystemConfig) error {
+	if m == nil || cfg == nil {
+		return nil
+	}
+	stats, err := cfg.ZoneConfigStats()
+	if err != nil {
+		return err
+	}
+	m.ZoneConfigs.Update(int64(stats.ZoneConfigs))
+	m.Subzones.Update(int64(stats.Subzones))
+	m.MaxSubzones.Update(int64(stats.MaxSubzones))
+	m.MaxConstraints.Update(int64(stats.MaxConstraints))
+	return nil
+}
+
+// Start updates the gauges whenever the provider's system config changes,
+// until the stopper quiesces.
+func (m *ZoneConfigMetrics) Start(
+	ctx context.Context, stopper *stop.Stopper, provider SystemConfigProvider,
+) error {
+	if m == nil {
+		return nil
+	}
+	ch, unregister := provider.RegisterSystemConfigChannel()
+	if err := stopper.RunAsyncTask(ctx, "zone-config-metrics", func(ctx context.Context) {
+		defer unregister()
+		for {
+			select {
+			case <-ch:
+				if err := m.Update(provider.GetSystemConfig()); err != nil {
+					log.Warningf(ctx, "failed to update zone config metrics: %v", err)
+				}
+			case <-stopper.ShouldQuiesce():
+				return
+			}
+		}
+	}); err != nil {
+		unregister()
+		return err
+	}
+	return nil
+}
+
+// ZoneConfigStats are statistics about the zone configs of a system config.
+type ZoneConfigStats struct {
+	// ZoneConfigs is the number of zone configs, including subzone
+	// placeholders.
+	ZoneConfigs int
+	// Subzones is the total number of subzones.
+	Subzones int
+	// MaxSubzones is the largest number of subzones of a zone config.
+	MaxSubzones int
+	// MaxConstraints is the largest number of constraints of a zone config,
+	// counting the constraints, voter constraints and lease preferences of the
+	// zone config and of its subzones.
+	MaxConstraints int
+}
+
+// ZoneConfigStats returns statistics about the system tenant's zone configs.
+func (s *SystemConfig) ZoneConfigStats() (ZoneConfigStats, error) {
+	var stats ZoneConfigStats
+	for _, kv := range s.zoneValues() {
+		if !kv.Value.IsPresent() {
+			continue
+		}
+		var zone zonepb.ZoneConfig
+		if err := kv.Value.GetProto(&zone); err != nil {
+			return ZoneConfigStats{}, errors.Wrapf(err, "decoding zone config at %s", kv.Key)
+		}
+		stats.ZoneConfigs++
+		stats.Subzones += len(zone.Subzones)
+		if len(zone.Subzones) > stats.MaxSubzones {
+			stats.MaxSubzones = len(zone.Subzones)
+		}
+		n := numConstraints(&zone)
+		for i := range zone.Subzones {
+			n += numConstraints(&zone.Subzones[i].Config)
+		}
+		if n > stats.MaxConstraints {
+			stats.MaxConstraints = n
+		}
+	}
+	return stats, nil
+}
+
+// numConstraints returns the number of constraints of the zone config, not
+// counting its subzones.
+func numConstraints(zone *zonepb.ZoneConfig) int {
+	var n int
+	for _, c := range zone.Constraints {
+		n += len(c.Constraints)
+	}
+	for _, c := range zone.VoterConstraints {
+		n += len(c.Constraints)
+	}
+	for _, p := range zone.LeasePreferences {
+		n += len(p.Constraints)
+	}
+	return n
+}
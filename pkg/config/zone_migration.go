@@ -0,0 +1,32 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import "github.com/cockroachdb/cockroach/pkg/config/zonepb"
+
+// RegisterZoneConfigMigration registers a migration which upgrades the YAML
+// form of zone configs from fromVersion to fromVersion+1 when they're
+// unmarshaled. Each version has a single migration, and the versions must be
+// older than zonepb.CurrentZoneConfigYAMLVersion, so a migration is registered
+// along with the version which supersedes fromVersion.
+//
+// This is the hook through which migrations are registered from outside of
+// zonepb. The migrations of the fields which zonepb itself deprecated, such as
+// experimental_lease_preferences, are registered by zonepb, so that it can
+// unmarshal every document on its own.
+//
+// RegisterZoneConfigMigration is not thread safe and is meant to be called
+// from init functions.
+func RegisterZoneConfigMigration(
+	fromVersion zonepb.ZoneConfigYAMLVersion, fn zonepb.ZoneConfigMigration,
+) {
+	zonepb.RegisterZoneConfigMigration(fromVersion, fn)
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// namedConstraintSets defines the named constraint sets which the constraints
+// and voter constraints of zone configs may refer to, as a YAML mapping from
+// the name of each set to its definition (see zonepb.SetNamedConstraintSets),
+// e.g.:
+//
+//	{us_east_ssd: [+region=us-east1, +ssd], default: '@us_east_ssd'}
+//
+// The sets are shared by the whole process, so only the system tenant defines
+// them.
+var namedConstraintSets = settings.RegisterValidatedStringSetting(
+	settings.SystemOnly,
+	"zone_configs.named_constraint_sets",
+	"YAML mapping from the names of the constraint sets which zone configs may refer to, "+
+		"e.g. with constraints: '@name', to their constraints",
+	"",
+	func(_ *settings.Values, s string) error {
+		defs, err := parseNamedConstraintSets(s)
+		if err != nil {
+			return err
+		}
+		return zonepb.ValidateNamedConstraintSets(defs)
+	},
+)
+
+// parseNamedConstraintSets returns the definitions of the named constraint
+// sets held by the value of the zone_configs.named_constraint_sets cluster
+// setting, in the form which zonepb.SetNamedConstraintSets expects.
+func parseNamedConstraintSets(s string) (map[string]string, error) {
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &nodes); err != nil {
+		return nil, errors.Wrap(err, "parsing named constraint sets")
+	}
+	defs := make(map[string]string, len(nodes))
+	for name := range nodes {
+		node := nodes[name]
+		def, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, errors.Wrapf(err, "constraint set %q", name)
+		}
+		defs[name] = string(def)
+	}
+	return defs, nil
+}
+
+// WatchNamedConstraintSets registers the named constraint sets defined by the
+// zone_configs.named_constraint_sets cluster setting, and registers them again
+// whenever the setting changes.
+func WatchNamedConstraintSets(ctx context.Context, sv *settings.Values) {
+	update := func(ctx context.Context) {
+		defs, err := parseNamedConstraintSets(namedConstraintSets.Get(sv))
+		if err == nil {
+			err = zonepb.SetNamedConstraintSets(defs)
+		}
+		if err != nil {
+			// The setting is validated when it's set, so this is only possible
+			// if it was set by a binary which validated it differently.
+			log.Warningf(ctx, "could not register named constraint sets: %v", err)
+		}
+	}
+	namedConstraintSets.SetOnChange(sv, update)
+	update(ctx)
+}
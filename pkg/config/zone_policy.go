@@ -0,0 +1,58 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// ZoneConfigPolicy is a cluster-wide rule which zone configs must follow, such
+// as "num_replicas must be at least 3". It's called with the target of a zone
+// config being set, e.g. "TABLE db.public.t" or "RANGE default", and with the
+// zone config the target would end up with, with the fields it doesn't set
+// inherited from its parents, and returns an error if the zone config must be
+// rejected.
+type ZoneConfigPolicy func(target string, zone zonepb.ZoneConfig) error
+
+// zoneConfigPolicy holds the policy registered with SetZoneConfigPolicy.
+var zoneConfigPolicy struct {
+	syncutil.Mutex
+	fn ZoneConfigPolicy
+}
+
+// SetZoneConfigPolicy registers the policy which zone configs are checked
+// against before they're accepted, replacing the previous one, if any. A nil
+// policy accepts every zone config.
+func SetZoneConfigPolicy(fn ZoneConfigPolicy) {
+	zoneConfigPolicy.Lock()
+	defer zoneConfigPolicy.Unlock()
+	zoneConfigPolicy.fn = fn
+}
+
+// CheckZoneConfigPolicy returns an error if the policy registered with
+// SetZoneConfigPolicy rejects the zone config of the given target. The
+// subzones of the zone config are not passed to the policy, since they're
+// checked as targets of their own when they're set.
+func CheckZoneConfigPolicy(target string, zone zonepb.ZoneConfig) error {
+	zoneConfigPolicy.Lock()
+	fn := zoneConfigPolicy.fn
+	zoneConfigPolicy.Unlock()
+	if fn == nil {
+		return nil
+	}
+	zone.Subzones, zone.SubzoneSpans = nil, nil
+	if err := fn(target, zone); err != nil {
+		return errors.Wrapf(err, "zone config for %s rejected by policy", target)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
+	"github.com/cockroachdb/errors"
+)
+
+// ZoneConfigSourceKind is the kind of object whose zone config supplied a
+// field of an effective zone config.
+type ZoneConfigSourceKind int
+
+const (
+	// ZoneConfigSourceDefault is the default zone, i.e. RANGE default.
+	ZoneConfigSourceDefault ZoneConfigSourceKind = iota
+	// ZoneConfigSourceRange is one of the named zones of the system ranges,
+	// such as RANGE meta or RANGE liveness.
+	ZoneConfigSourceRange
+	// ZoneConfigSourceDatabase is a database.
+	ZoneConfigSourceDatabase
+	// ZoneConfigSourceTable is a table.
+	ZoneConfigSourceTable
+	// ZoneConfigSourceIndex is an index of a table.
+	ZoneConfigSourceIndex
+	// ZoneConfigSourcePartition is a partition of an index.
+	ZoneConfigSourcePartition
+)
+
+// ZoneConfigSource identifies the zone config which supplied a field of an
+// effective zone config.
+type ZoneConfigSource struct {
+	Kind ZoneConfigSourceKind
+	// ObjectID is the ID of the object owning the zone config. For indexes and
+	// partitions, it's the ID of their table.
+	ObjectID ObjectID
+	// Name is the name of the object, if it's known. For indexes and
+	// partitions, it's the name of their table.
+	Name string
+	// IndexID and IndexName identify the index of index and partition
+	// sources.
+	IndexID   uint32
+	IndexName string
+	// PartitionName is the name of the partition of partition sources.
+	PartitionName string
+}
+
+func (s ZoneConfigSource) String() string {
+	name := s.Name
+	if name == "" {
+		name = fmt.Sprintf("%d", s.ObjectID)
+	}
+	index := s.IndexName
+	if index == "" {
+		index = fmt.Sprintf("%d", s.IndexID)
+	}
+	switch s.Kind {
+	case ZoneConfigSourceDefault:
+		return "range default"
+	case ZoneConfigSourceRange:
+		return "range " + name
+	case ZoneConfigSourceDatabase:
+		return "database " + name
+	case ZoneConfigSourceTable:
+		return "table " + name
+	case ZoneConfigSourceIndex:
+		return fmt.Sprintf("index %s@%s", name, index)
+	case ZoneConfigSourcePartition:
+		return fmt.Sprintf("partition %s of index %s@%s", s.PartitionName, name, index)
+	default:
+		return fmt.Sprintf("unknown source %d", s.Kind)
+	}
+}
+
+// Provenance maps each field of an effective zone config to the zone config
+// which supplied it. Fields are named as in ALTER ... CONFIGURE ZONE, e.g.
+// num_replicas or gc.ttlseconds. Fields which aren't set by any zone config
+// along the inheritance chain are absent.
+type Provenance map[string]ZoneConfigSource
+
+// provenanceFields lists the fields tracked by Provenance, along with whether
+// they're explicitly set on a zone config rather than inherited from its
+// parent (see ZoneConfig.InheritFromParent).
+var provenanceFields = []struct {
+	name  string
+	isSet func(z *zonepb.ZoneConfig) bool
+}{
+	{"range_min_bytes", func(z *zonepb.ZoneConfig) bool { return z.RangeMinBytes != nil }},
+	{"range_max_bytes", func(z *zonepb.ZoneConfig) bool { return z.RangeMaxBytes != nil }},
+	{"gc.ttlseconds", func(z *zonepb.ZoneConfig) bool { return z.GC != nil }},
+	{"global_reads", func(z *zonepb.ZoneConfig) bool { return z.GlobalReads != nil }},
+	{"survival_goal", func(z *zonepb.ZoneConfig) bool { return z.SurvivalGoal != nil }},
+	{"primary_region", func(z *zonepb.ZoneConfig) bool { return z.PrimaryRegion != nil }},
+	{"secondary_region", func(z *zonepb.ZoneConfig) bool { return z.SecondaryRegion != nil }},
+	{"num_replicas", func(z *zonepb.ZoneConfig) bool { return z.NumReplicas != nil && *z.NumReplicas != 0 }},
+	{"num_voters", func(z *zonepb.ZoneConfig) bool { return z.NumVoters != nil && *z.NumVoters != 0 }},
+	{"constraints", func(z *zonepb.ZoneConfig) bool { return !z.InheritedConstraints }},
+	{"voter_constraints", func(z *zonepb.ZoneConfig) bool { return !z.InheritedVoterConstraints() }},
+	{"lease_preferences", func(z *zonepb.ZoneConfig) bool { return !z.InheritedLeasePreferences }},
+}
+
+// zoneConfigLevel is a zone config along the inheritance chain of a key.
+type zoneConfigLevel struct {
+	zone   *zonepb.ZoneConfig
+	source ZoneConfigSource
+}
+
+// GetEffectiveZoneConfig returns the zone config in effect for the given
+// system tenant key, hydrated from its parents in the same way as
+// GetSpanConfigForKey, along with the provenance of each of its fields. The
+// zone config has no subzones.
+func (s *SystemConfig) GetEffectiveZoneConfig(
+	key roachpb.RKey,
+) (zonepb.ZoneConfig, Provenance, error) {
+	levels, err := s.zoneConfigLevels(key)
+	if err != nil {
+		return zonepb.ZoneConfig{}, nil, err
+	}
+	var res zonepb.ZoneConfig
+	provenance := make(Provenance)
+	for i, level := range levels {
+		if i == 0 {
+			res = *level.zone
+		} else {
+			res.InheritFromParent(level.zone)
+		}
+		for _, f := range provenanceFields {
+			if _, ok := provenance[f.name]; !ok && f.isSet(level.zone) {
+				provenance[f.name] = level.source
+			}
+		}
+	}
+	res.Subzones, res.SubzoneSpans = nil, nil
+	return res, provenance, nil
+}
+
+// zoneConfigLevels returns the zone configs along the inheritance chain of the
+// given system tenant key, from the most specific to the default zone. This
+// must be kept in sync with the ZoneConfigHook and getZoneConfigForKey.
+func (s *SystemConfig) zoneConfigLevels(key roachpb.RKey) ([]zoneConfigLevel, error) {
+	id, suffix := DecodeKeyIntoZoneIDAndSuffix(keys.SystemSQLCodec, key)
+	var levels []zoneConfigLevel
+
+	object := ZoneConfigSource{Kind: ZoneConfigSourceTable, ObjectID: id}
+	var parentID ObjectID
+	if named, ok := zonepb.NamedZonesByID[uint32(id)]; ok {
+		object.Kind, object.Name = ZoneConfigSourceRange, string(named)
+	} else if info, ok, err := s.GetDescriptorInfo(id); err != nil {
+		return nil, err
+	} else if ok && info.Kind == DescriptorKindDatabase {
+		object.Kind, object.Name = ZoneConfigSourceDatabase, info.Name
+	} else if ok && info.Kind == DescriptorKindTable {
+		object.Name = info.Name
+		parentID = info.ParentID
+	}
+
+	if id != keys.RootNamespaceID {
+		zone, err := s.getRawZoneConfig(id)
+		if err != nil {
+			return nil, err
+		}
+		if zone != nil {
+			if subzone, _ := zone.GetSubzoneForKeySuffix(suffix); subzone != nil {
+				subzoneLevels, err := s.subzoneLevels(object, zone, subzone)
+				if err != nil {
+					return nil, err
+				}
+				levels = append(levels, subzoneLevels...)
+			}
+			if !zone.IsSubzonePlaceholder() {
+				// The fields of a placeholder are ignored in favor of those of its
+				// parent.
+				levels = append(levels, zoneConfigLevel{zone: zone, source: object})
+			}
+		}
+	}
+
+	if parentID != 0 {
+		zone, err := s.getRawZoneConfig(parentID)
+		if err != nil {
+			return nil, err
+		}
+		if zone != nil {
+			db, ok, err := s.GetDescriptorInfo(parentID)
+			if err != nil {
+				return nil, err
+			}
+			source := ZoneConfigSource{Kind: ZoneConfigSourceDatabase, ObjectID: parentID}
+			if ok && db.Kind == DescriptorKindDatabase {
+				source.Name = db.Name
+			}
+			levels = append(levels, zoneConfigLevel{zone: zone, source: source})
+		}
+	}
+
+	defaultSource := ZoneConfigSource{
+		Kind: ZoneConfigSourceDefault, ObjectID: keys.RootNamespaceID, Name: string(zonepb.DefaultZoneName),
+	}
+	zone, err := s.getRawZoneConfig(keys.RootNamespaceID)
+	if err != nil {
+		return nil, err
+	}
+	if zone != nil {
+		levels = append(levels, zoneConfigLevel{zone: zone, source: defaultSource})
+	}
+	if s.DefaultZoneConfig != nil {
+		levels = append(levels, zoneConfigLevel{zone: s.DefaultZoneConfig, source: defaultSource})
+	}
+	if len(levels) == 0 {
+		return nil, errors.AssertionFailedf("no zone config applies to key %s", key)
+	}
+	return levels, nil
+}
+
+// subzoneLevels returns the levels of the given subzone of a table's zone
+// config: the subzone itself and, for a partition, its index's subzone, if
+// any.
+func (s *SystemConfig) subzoneLevels(
+	table ZoneConfigSource, zone *zonepb.ZoneConfig, subzone *zonepb.Subzone,
+) ([]zoneConfigLevel, error) {
+	source := table
+	source.Kind, source.IndexID = ZoneConfigSourceIndex, subzone.IndexID
+	if info, ok, err := s.GetDescriptorInfo(table.ObjectID); err != nil {
+		return nil, err
+	} else if ok && info.Kind == DescriptorKindTable {
+		source.IndexName = info.IndexNames[subzone.IndexID]
+	}
+	if subzone.PartitionName == "" {
+		return []zoneConfigLevel{{zone: &subzone.Config, source: source}}, nil
+	}
+
+	partition := source
+	partition.Kind, partition.PartitionName = ZoneConfigSourcePartition, subzone.PartitionName
+	levels := []zoneConfigLevel{{zone: &subzone.Config, source: partition}}
+	if index := zone.GetSubzone(subzone.IndexID, ""); index != nil {
+		levels = append(levels, zoneConfigLevel{zone: &index.Config, source: source})
+	}
+	return levels, nil
+}
+
+// getRawZoneConfig returns the zone config stored for the given system tenant
+// object, without hydrating it, or nil if there is none.
+func (s *SystemConfig) getRawZoneConfig(id ObjectID) (*zonepb.ZoneConfig, error) {
+	val := s.GetValue(MakeZoneKey(keys.SystemSQLCodec, descpb.ID(id)))
+	if val == nil {
+		return nil, nil
+	}
+	zone := &zonepb.ZoneConfig{}
+	if err := val.GetProto(zone); err != nil {
+		return nil, errors.Wrapf(err, "decoding zone config for %d", id)
+	}
+	return zone, nil
+}
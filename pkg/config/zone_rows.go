@@ -0,0 +1,87 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+)
+
+// ZoneConfigRow is the formatted form of a zone config, as shown by the
+// crdb_internal.zones virtual table and by SHOW ZONE CONFIGURATION.
+type ZoneConfigRow struct {
+	// Target is the zone specifier of the zone config, as written in ALTER ...
+	// CONFIGURE ZONE, e.g. "TABLE db.public.t". It's empty if the zone config
+	// has no specifier, in which case the SQL fields are empty too.
+	Target string
+	// RawConfigYAML is the YAML encoding of the zone config.
+	RawConfigYAML string
+	// RawConfigSQL is the ALTER ... CONFIGURE ZONE statement which sets the
+	// fields of the zone config (see ZoneConfigToSQL).
+	RawConfigSQL string
+	// RawConfigProtobuf is the protobuf encoding of the zone config.
+	RawConfigProtobuf []byte
+	// FullConfigYAML and FullConfigSQL are like RawConfigYAML and
+	// RawConfigSQL, but for the zone config with its inherited fields filled
+	// in.
+	FullConfigYAML string
+	FullConfigSQL  string
+}
+
+// MakeZoneConfigRow returns the row of the zone config of the given zone
+// specifier, which may be nil. full is the zone config with its inherited
+// fields filled in; if it's nil, the zone config itself is used in its place.
+func MakeZoneConfigRow(
+	zs *tree.ZoneSpecifier, zone *zonepb.ZoneConfig, full *zonepb.ZoneConfig,
+) (ZoneConfigRow, error) {
+	if full == nil {
+		full = zone
+	}
+	var row ZoneConfigRow
+	yamlConfig, err := zonepb.MarshalYAML(zone)
+	if err != nil {
+		return ZoneConfigRow{}, err
+	}
+	row.RawConfigYAML = string(yamlConfig)
+	if row.RawConfigProtobuf, err = protoutil.Marshal(zone); err != nil {
+		return ZoneConfigRow{}, err
+	}
+	yamlConfig, err = zonepb.MarshalYAML(full)
+	if err != nil {
+		return ZoneConfigRow{}, err
+	}
+	row.FullConfigYAML = string(yamlConfig)
+
+	if zs != nil {
+		row.Target = zs.String()
+		if row.RawConfigSQL, err = ZoneConfigToSQL(zs, zone); err != nil {
+			return ZoneConfigRow{}, err
+		}
+		if row.FullConfigSQL, err = ZoneConfigToSQL(zs, full); err != nil {
+			return ZoneConfigRow{}, err
+		}
+	}
+	return row, nil
+}
+
+// ZoneConfigToSQL pretty prints the zone config as the ALTER ... CONFIGURE
+// ZONE statement which sets its fields on the given zone specifier, e.g.:
+//
+//	ALTER RANGE default CONFIGURE ZONE USING
+//		range_min_bytes = 134217728,
+//		...
+//		lease_preferences = '[]'
+//
+// Inherited fields are omitted. See zonepb.ZoneConfig.ToSQL.
+func ZoneConfigToSQL(zs *tree.ZoneSpecifier, zone *zonepb.ZoneConfig) (string, error) {
+	return zone.ToSQL(tree.AsStringWithFlags(zs, tree.FmtParsable)), nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// ZoneTarget is the most specific object which a key belongs to, as far as
+// zone configs are concerned, as returned by SystemConfig.TargetForKey. Names
+// are empty if the descriptors of the objects aren't known.
+type ZoneTarget struct {
+	Kind ZoneConfigSourceKind
+	// RangeName is the name of the named zone of range targets, e.g. meta or
+	// liveness.
+	RangeName string
+	// DatabaseID and DatabaseName identify the database of database, table,
+	// index and partition targets.
+	DatabaseID   ObjectID
+	DatabaseName string
+	// TableID and TableName identify the table of table, index and partition
+	// targets.
+	TableID   ObjectID
+	TableName string
+	// IndexID and IndexName identify the index of index and partition targets.
+	IndexID   uint32
+	IndexName string
+	// PartitionName is the name of the partition of partition targets.
+	PartitionName string
+}
+
+func (t ZoneTarget) String() string {
+	database := t.DatabaseName
+	if database == "" {
+		database = fmt.Sprintf("%d", t.DatabaseID)
+	}
+	table := t.TableName
+	if table == "" {
+		table = fmt.Sprintf("%d", t.TableID)
+	}
+	index := t.IndexName
+	if index == "" {
+		index = fmt.Sprintf("%d", t.IndexID)
+	}
+	switch t.Kind {
+	case ZoneConfigSourceDefault:
+		return "range default"
+	case ZoneConfigSourceRange:
+		return "range " + t.RangeName
+	case ZoneConfigSourceDatabase:
+		return "database " + database
+	case ZoneConfigSourceTable:
+		return fmt.Sprintf("table %s.%s", database, table)
+	case ZoneConfigSourceIndex:
+		return fmt.Sprintf("index %s.%s@%s", database, table, index)
+	case ZoneConfigSourcePartition:
+		return fmt.Sprintf("partition %s of index %s.%s@%s", t.PartitionName, database, table, index)
+	default:
+		return fmt.Sprintf("unknown target %d", t.Kind)
+	}
+}
+
+// TargetForKey returns the most specific object which the given system tenant
+// key belongs to: a named range, a database, or a table, index or partition
+// along with the objects containing it. Partitions are only known through the
+// subzones of their table's zone config, so a key in a partition without a
+// zone config of its own is attributed to the partition's index. Descriptors
+// are interpreted by the registered DescriptorProvider, and cached.
+func (s *SystemConfig) TargetForKey(key roachpb.RKey) (ZoneTarget, error) {
+	id, suffix := DecodeKeyIntoZoneIDAndSuffix(keys.SystemSQLCodec, key)
+	if id == keys.RootNamespaceID {
+		return ZoneTarget{Kind: ZoneConfigSourceDefault}, nil
+	}
+	if named, ok := zonepb.NamedZonesByID[uint32(id)]; ok {
+		return ZoneTarget{Kind: ZoneConfigSourceRange, RangeName: string(named)}, nil
+	}
+	info, ok, err := s.GetDescriptorInfo(id)
+	if err != nil {
+		return ZoneTarget{}, err
+	}
+	if ok && info.Kind == DescriptorKindDatabase {
+		return ZoneTarget{Kind: ZoneConfigSourceDatabase, DatabaseID: id, DatabaseName: info.Name}, nil
+	}
+
+	target := ZoneTarget{Kind: ZoneConfigSourceTable, TableID: id}
+	if ok && info.Kind == DescriptorKindTable {
+		target.TableName = info.Name
+		target.DatabaseID = info.ParentID
+		db, ok, err := s.GetDescriptorInfo(target.DatabaseID)
+		if err != nil {
+			return ZoneTarget{}, err
+		}
+		if ok && db.Kind == DescriptorKindDatabase {
+			target.DatabaseName = db.Name
+		}
+	}
+
+	_, tableID, indexID, err := keys.SystemSQLCodec.DecodeIndexPrefix(key.AsRawKey())
+	if err != nil || ObjectID(tableID) != id {
+		// The key is the prefix of the table itself.
+		return target, nil
+	}
+	// IndexNames is only set for tables.
+	target.Kind, target.IndexID = ZoneConfigSourceIndex, indexID
+	target.IndexName = info.IndexNames[indexID]
+
+	zone, err := s.getRawZoneConfig(id)
+	if err != nil {
+		return ZoneTarget{}, err
+	}
+	if zone != nil {
+		if subzone, _ := zone.GetSubzoneForKeySuffix(suffix); subzone != nil &&
+			subzone.IndexID == indexID && subzone.PartitionName != "" {
+			target.Kind, target.PartitionName = ZoneConfigSourcePartition, subzone.PartitionName
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/errors"
+)
+
+// TargetedError is a problem with the zone config of a target, as reported by
+// ValidateAllZoneConfigs.
+type TargetedError struct {
+	// ID is the ID of the object whose zone config has the problem. Problems
+	// with subzones are reported with the ID of their table.
+	ID ObjectID
+	// Target is the target of the zone config, as written in ALTER ...
+	// CONFIGURE ZONE, e.g. "INDEX db.public.t@idx". It's empty if the target
+	// can't be resolved.
+	Target string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e TargetedError) Error() string {
+	if e.Target == "" {
+		return fmt.Sprintf("zone config %d: %v", e.ID, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Target, e.Err)
+}
+
+// ValidateAllZoneConfigs validates every system tenant zone config of the
+// snapshot, and returns the problems found, in object ID order. It's meant
+// for health checks, e.g. after upgrades, of zone configs which may have been
+// written by older versions.
+//
+// Each stored zone config is first checked on its own, as by ALTER ...
+// CONFIGURE ZONE: its fields and those of its subzones must be valid (see
+// zonepb.ZoneConfig.Validate and ValidateTandemFields), only tables may have
+// subzones and split hints, every index or partition must have at most one
+// subzone, and the subzone spans must be sorted, must not overlap and must
+// refer to existing subzones. The zone configs which pass are then hydrated
+// from their parents, as by ExportZoneConfigs, and validated again, which
+// catches conflicts between levels, such as num_voters greater than an
+// inherited num_replicas.
+// Zone configs of dropped objects are skipped.
+func (s *SystemConfig) ValidateAllZoneConfigs() []TargetedError {
+	var errs []TargetedError
+	invalid := make(map[uint32]bool)
+	hydrate := true
+	for _, kv := range s.zoneValues() {
+		_, id, err := keys.SystemSQLCodec.DecodeZoneConfigMetadataID(kv.Key)
+		if err != nil {
+			errs = append(errs, TargetedError{Err: errors.Wrapf(err, "decoding key %s", kv.Key)})
+			hydrate = false
+			continue
+		}
+		var target string
+		zs, err := zonepb.ZoneSpecifierFromID(id, s.resolveIDForZoneExport)
+		if errors.Is(err, errExportedObjectDropped) {
+			continue
+		} else if err != nil {
+			errs = append(errs, TargetedError{ID: ObjectID(id), Err: errors.Wrap(err, "resolving target")})
+			invalid[id], hydrate = true, false
+		} else {
+			target = tree.AsString(&zs)
+		}
+		zone := &zonepb.ZoneConfig{}
+		if err := kv.Value.GetProto(zone); err != nil {
+			errs = append(errs, TargetedError{ID: ObjectID(id), Target: target, Err: err})
+			invalid[id], hydrate = true, false
+			continue
+		}
+		if err := s.validateStoredZoneConfig(id, zone); err != nil {
+			errs = append(errs, TargetedError{ID: ObjectID(id), Target: target, Err: err})
+			invalid[id] = true
+		}
+	}
+
+	// Zone configs can't be hydrated if some of them can't be decoded or
+	// resolved, since they may be the parents of others.
+	if hydrate {
+		zones, err := s.exportZoneConfigs()
+		if err != nil {
+			errs = append(errs, TargetedError{Err: errors.Wrap(err, "hydrating zone configs")})
+		}
+		for _, z := range zones {
+			if invalid[z.id] {
+				continue
+			}
+			if err := z.zone.Validate(); err != nil {
+				errs = append(errs, TargetedError{
+					ID: ObjectID(z.id), Target: z.target, Err: errors.Wrap(err, "once hydrated"),
+				})
+			}
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].ID < errs[j].ID })
+	return errs
+}
+
+// validateStoredZoneConfig returns an error if the zone config stored for the
+// given object isn't valid on its own. See ValidateAllZoneConfigs.
+func (s *SystemConfig) validateStoredZoneConfig(id uint32, zone *zonepb.ZoneConfig) error {
+	if err := zone.Validate(); err != nil {
+		return err
+	}
+	if !zone.IsSubzonePlaceholder() {
+		if err := zone.ValidateTandemFields(); err != nil {
+			return err
+		}
+	}
+	if len(zone.SplitHints) > 0 {
+		desc, err := s.getDescForZoneExport(id)
+		if err != nil {
+			return err
+		}
+		if desc.Kind != DescriptorKindTable {
+			return errors.Newf("split hints are set on %d, which is not a table", id)
+		}
+	}
+	if len(zone.Subzones) == 0 {
+		if len(zone.SubzoneSpans) > 0 {
+			return errors.New("subzone spans are set without subzones")
+		}
+		return nil
+	}
+	if _, err := s.getTableDescForZoneExport(id); err != nil {
+		return err
+	}
+	seen := make(map[subzoneID]bool)
+	for _, subzone := range zone.Subzones {
+		name := fmt.Sprintf("index %d", subzone.IndexID)
+		if subzone.PartitionName != "" {
+			name = fmt.Sprintf("partition %q of index %d", subzone.PartitionName, subzone.IndexID)
+		}
+		key := subzoneID{indexID: subzone.IndexID, partition: subzone.PartitionName}
+		if seen[key] {
+			return errors.Newf("%s has more than one subzone", name)
+		}
+		seen[key] = true
+		if err := subzone.Config.ValidateTandemFields(); err != nil {
+			return errors.Wrapf(err, "subzone of %s", name)
+		}
+	}
+	return errors.Wrap(zonepb.ValidateSubzoneSpans(zone.SubzoneSpans, len(zone.Subzones)),
+		"subzone spans")
+}
+
+// subzoneID identifies the subzone of an index or partition.
+type subzoneID struct {
+	indexID   uint32
+	partition string
+}
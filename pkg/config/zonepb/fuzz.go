@@ -0,0 +1,64 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+//go:build gofuzz
+// +build gofuzz
+
+package zonepb
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"gopkg.in/yaml.v3"
+)
+
+// FuzzZoneConfigYAML checks that unmarshaling a zone config from YAML doesn't
+// panic, and that the zone configs which can be unmarshaled round-trip through
+// YAML. GenerateZoneConfigYAMLCorpus generates a corpus for it.
+func FuzzZoneConfigYAML(data []byte) int {
+	if err := CheckYAMLAliases(data); err != nil {
+		return 0
+	}
+	zone := NewZoneConfig()
+	if err := yaml.Unmarshal(data, zone); err != nil {
+		return 0
+	}
+	_ = zone.Validate()
+	out, err := MarshalYAML(zone)
+	if err != nil {
+		panic(fmt.Errorf("-- original:\n%s\n-- error:\n%v", data, err))
+	}
+	reparsed := NewZoneConfig()
+	if err := yaml.Unmarshal(out, reparsed); err != nil {
+		panic(fmt.Errorf("-- original:\n%s\n-- marshaled:\n%s\n-- error:\n%v", data, out, err))
+	}
+	if out2, err := MarshalYAML(reparsed); err != nil || string(out) != string(out2) {
+		panic(fmt.Errorf("remarshal mismatch:\n-- original:\n%s\n-- marshaled:\n%s\n-- remarshaled:\n%s",
+			data, out, out2))
+	}
+	return 1
+}
+
+// FuzzZoneConfigProto checks that the zone configs which can be decoded from
+// their protobuf encoding can be validated and marshaled to YAML without
+// panicking.
+func FuzzZoneConfigProto(data []byte) int {
+	var zone ZoneConfig
+	if err := protoutil.Unmarshal(data, &zone); err != nil {
+		return 0
+	}
+	_ = zone.Validate()
+	_ = zone.ValidateTandemFields()
+	if _, err := MarshalYAML(zone); err != nil {
+		return 0
+	}
+	return 1
+}
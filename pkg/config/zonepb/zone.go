@@ -14,6 +14,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cockroachdb/cockroach/pkg/clusterversion"
@@ -176,26 +179,273 @@ func ResolveZoneSpecifier(
 	return tableID, err
 }
 
+// String returns the constraint in the shorthand notation, e.g.
+// +region=us-east1. Backslashes, commas and equal signs in the key and value
+// are escaped with a backslash, and so is a leading + or - of constraints
+// without a type, so that FromString parses any constraint back.
 func (c Constraint) String() string {
 	var str string
+	if len(c.Key) > 0 {
+		str = escapeConstraintPart(c.Key) + "="
+	}
+	str += escapeConstraintPart(c.Value)
+	switch c.Type {
+	case Constraint_REQUIRED:
+		str = "+" + str
+	case Constraint_PROHIBITED:
+		str = "-" + str
+	default:
+		if len(str) > 0 && (str[0] == '+' || str[0] == '-') {
+			str = `\` + str
+		}
+	}
+	return str
+}
+
+// constraintEscapedChars are the characters which are escaped in the
+// shorthand notation of constraints: the escape character itself, and the
+// separators of constraints and of their keys and values.
+const constraintEscapedChars = `\,=`
+
+func escapeConstraintPart(s string) string {
+	if !strings.ContainsAny(s, constraintEscapedChars) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(constraintEscapedChars, s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// unescapeConstraintPart removes the escaping of escapeConstraintPart. A
+// backslash escapes any character which follows it, and a trailing backslash
+// is dropped.
+func unescapeConstraintPart(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			if i++; i == len(s) {
+				break
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// splitEscapedConstraints splits s at the occurrences of sep which aren't
+// escaped with a backslash. The escaping of the pieces is left as is. For
+// instance, the constraints of +a=b\,c,+d are split as [+a=b\,c +d].
+func splitEscapedConstraints(s string, sep byte) []string {
+	var res []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+// ConstraintKind distinguishes constraints on store attributes from
+// constraints on locality tiers and on store IDs.
+type ConstraintKind int
+
+const (
+	// ConstraintKindAttribute is a constraint on a store or node attribute,
+	// such as +ssd. Attribute constraints have no key.
+	ConstraintKindAttribute ConstraintKind = iota
+	// ConstraintKindLocality is a constraint on a locality tier, such as
+	// +region=us-east1.
+	ConstraintKindLocality
+	// ConstraintKindStore is a constraint on the ID of a store, such as
+	// +store=5. See StoreConstraintKey.
+	ConstraintKindStore
+)
+
+// StoreConstraintKey is the key of the constraints which match a single store
+// by its ID, e.g. +store=5. They're meant for pinning replicas to, or away
+// from, specific stores during incident response. Unlike locality and
+// attribute constraints, they don't carry over to the store which replaces a
+// decommissioned one, which is why LintStoreConstraints flags them. Nodes
+// which predate V23_2_ZoneConfigStoreConstraints would take them for
+// constraints on a locality tier, so they can only be set once that version
+// is active, and locality tiers with this key can't be constrained.
+const StoreConstraintKey = "store"
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case ConstraintKindAttribute:
+		return "attribute"
+	case ConstraintKindLocality:
+		return "locality"
+	case ConstraintKindStore:
+		return "store"
+	default:
+		return fmt.Sprintf("ConstraintKind(%d)", int(k))
+	}
+}
+
+// Kind returns whether the constraint applies to a store attribute, to a
+// locality tier or to a store ID.
+func (c Constraint) Kind() ConstraintKind {
+	switch c.Key {
+	case "":
+		return ConstraintKindAttribute
+	case StoreConstraintKey:
+		return ConstraintKindStore
+	default:
+		return ConstraintKindLocality
+	}
+}
+
+// StoreID returns the ID of the store matched by a store constraint, and
+// whether the constraint is a store constraint with a valid store ID.
+func (c Constraint) StoreID() (roachpb.StoreID, bool) {
+	if c.Kind() != ConstraintKindStore {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(c.Value, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return roachpb.StoreID(id), true
+}
+
+// Matches returns whether the locality tier matches the constraint's key and
+// value. The value of a locality constraint may contain '*' wildcards, which
+// match any sequence of characters: +region=us-* matches region=us-east1 and
+// region=us-west2. Attribute constraints don't match any tier.
+func (c Constraint) Matches(tier roachpb.Tier) bool {
+	return c.Kind() == ConstraintKindLocality && c.Key == tier.Key &&
+		roachpb.LocalityValueMatches(c.Value, tier.Value)
+}
+
+// hasWildcard returns whether the constraint's value contains wildcards.
+func (c Constraint) hasWildcard() bool {
+	return strings.Contains(c.Value, "*")
+}
+
+// HasWildcardConstraints returns whether any of the constraints, voter
+// constraints or lease preferences of the zone config or of its subzones have
+// wildcards in their values.
+func (z *ZoneConfig) HasWildcardConstraints() bool {
+	return z.anyConstraint(Constraint.hasWildcard)
+}
+
+// HasStoreConstraints returns whether any of the constraints, voter
+// constraints or lease preferences of the zone config or of its subzones are
+// store constraints.
+func (z *ZoneConfig) HasStoreConstraints() bool {
+	return z.anyConstraint(func(c Constraint) bool { return c.Kind() == ConstraintKindStore })
+}
+
+// anyConstraint returns whether fn returns true for any of the constraints,
+// voter constraints or lease preferences of the zone config or of its
+// subzones.
+func (z *ZoneConfig) anyConstraint(fn func(Constraint) bool) bool {
+	for _, conjunctions := range [][]ConstraintsConjunction{z.Constraints, z.VoterConstraints} {
+		for _, conjunction := range conjunctions {
+			for _, c := range conjunction.Constraints {
+				if fn(c) {
+					return true
+				}
+			}
+		}
+	}
+	for _, leasePref := range z.LeasePreferences {
+		for _, c := range leasePref.Constraints {
+			if fn(c) {
+				return true
+			}
+		}
+	}
+	for i := range z.Subzones {
+		if z.Subzones[i].Config.anyConstraint(fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludes returns whether every store matching required also matches
+// prohibited, in which case no store can satisfy both constraints.
+func excludes(prohibited, required Constraint) bool {
+	if prohibited.Key == required.Key && prohibited.Value == required.Value {
+		return true
+	}
+	return !required.hasWildcard() &&
+		prohibited.Matches(roachpb.Tier{Key: required.Key, Value: required.Value})
+}
+
+// Compare returns -1, 0 or 1 depending on whether c sorts before, the same as,
+// or after other. Constraints are ordered by their String() form, ignoring
+// escaping, but Compare doesn't allocate, which makes it suitable for sorting.
+func (c Constraint) Compare(other Constraint) int {
+	a, b := c.stringParts(), other.stringParts()
+	var i, j, ai, bi int
+	for {
+		// Skip over exhausted parts.
+		for i < len(a) && ai == len(a[i]) {
+			i, ai = i+1, 0
+		}
+		for j < len(b) && bi == len(b[j]) {
+			j, bi = j+1, 0
+		}
+		switch {
+		case i == len(a) && j == len(b):
+			return 0
+		case i == len(a):
+			return -1
+		case j == len(b):
+			return 1
+		}
+		if ca, cb := a[i][ai], b[j][bi]; ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+		ai, bi = ai+1, bi+1
+	}
+}
+
+// stringParts returns the pieces which make up c.String(), before escaping.
+func (c Constraint) stringParts() [4]string {
+	var parts [4]string
 	switch c.Type {
 	case Constraint_REQUIRED:
-		str += "+"
+		parts[0] = "+"
 	case Constraint_PROHIBITED:
-		str += "-"
+		parts[0] = "-"
 	}
 	if len(c.Key) > 0 {
-		str += c.Key + "="
+		parts[1], parts[2] = c.Key, "="
 	}
-	str += c.Value
-	return str
+	parts[3] = c.Value
+	return parts
 }
 
-// FromString populates the constraint from the constraint shorthand notation.
+// FromString populates the constraint from the constraint shorthand notation
+// returned by String. The value of a store constraint must be a store ID.
 func (c *Constraint) FromString(short string) error {
 	if len(short) == 0 {
 		return fmt.Errorf("the empty string is not a valid constraint")
 	}
+	if n := len(short) - len(strings.TrimRight(short, `\`)); n%2 == 1 {
+		return errors.Errorf("constraint %q ends with an unescaped backslash", short)
+	}
 	switch short[0] {
 	case '+':
 		c.Type = Constraint_REQUIRED
@@ -206,16 +456,124 @@ func (c *Constraint) FromString(short string) error {
 	default:
 		c.Type = Constraint_DEPRECATED_POSITIVE
 	}
-	parts := strings.Split(short, "=")
-	if len(parts) == 1 {
-		c.Value = parts[0]
-	} else if len(parts) == 2 {
-		c.Key = parts[0]
-		c.Value = parts[1]
-	} else {
+	parts := splitEscapedConstraints(short, '=')
+	if len(parts) > 2 {
 		return errors.Errorf("constraint needs to be in the form \"(key=)value\", not %q", short)
 	}
-	return nil
+	c.Value = internConstraintString(unescapeConstraintPart(parts[len(parts)-1]))
+	if len(parts) == 2 {
+		c.Key = internConstraintString(unescapeConstraintPart(parts[0]))
+	}
+	return validateStoreConstraint(*c)
+}
+
+// shortConstraints returns the constraints in the shorthand notation.
+func shortConstraints(constraints []Constraint) []string {
+	short := make([]string, len(constraints))
+	for i, c := range constraints {
+		short[i] = c.String()
+	}
+	return short
+}
+
+// parseShortConstraints parses constraints from the shorthand notation. The
+// constraints must refer to allowed locality tiers (see SetAllowedLocalities).
+func parseShortConstraints(short []string) ([]Constraint, error) {
+	if err := checkYAMLConstraintCount(len(short)); err != nil {
+		return nil, err
+	}
+	constraints := make([]Constraint, len(short))
+	for i := range short {
+		if err := constraints[i].FromString(short[i]); err != nil {
+			return nil, err
+		}
+		if err := checkAllowedLocality(constraints[i]); err != nil {
+			return nil, err
+		}
+	}
+	return constraints, nil
+}
+
+// String returns the lease preference in the shorthand notation, e.g.
+// [+region=us-east1,+ssd]. ParseLeasePreference parses it back.
+func (l LeasePreference) String() string {
+	return "[" + strings.Join(shortConstraints(l.Constraints), ",") + "]"
+}
+
+// ParseLeasePreference parses a lease preference from the shorthand notation
+// returned by LeasePreference.String. Whitespace around the constraints is
+// ignored, so the YAML flow notation, e.g. [+region=us-east1, +ssd], is
+// accepted as well.
+func ParseLeasePreference(s string) (LeasePreference, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return LeasePreference{}, errors.Errorf(
+			"lease preference needs to be in the form \"[constraint,...]\", not %q", s)
+	}
+	var short []string
+	if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+		short = splitEscapedConstraints(inner, ',')
+		for i := range short {
+			short[i] = strings.TrimSpace(short[i])
+		}
+	}
+	constraints, err := parseShortConstraints(short)
+	if err != nil {
+		return LeasePreference{}, err
+	}
+	return LeasePreference{Constraints: constraints}, nil
+}
+
+// Required returns the required constraints of the lease preference, which
+// the leaseholder's store must match.
+func (l LeasePreference) Required() []Constraint {
+	return l.constraintsOfType(Constraint_REQUIRED)
+}
+
+// Prohibited returns the prohibited constraints of the lease preference, which
+// the leaseholder's store must not match.
+func (l LeasePreference) Prohibited() []Constraint {
+	return l.constraintsOfType(Constraint_PROHIBITED)
+}
+
+func (l LeasePreference) constraintsOfType(typ Constraint_Type) []Constraint {
+	var res []Constraint
+	for _, c := range l.Constraints {
+		if c.Type == typ {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// IsAntiPreference returns whether the lease preference only has prohibited
+// constraints, e.g. [-region=eu-west1], in which case it prefers every store
+// but the ones it names.
+func (l LeasePreference) IsAntiPreference() bool {
+	return len(l.Constraints) > 0 && len(l.Prohibited()) == len(l.Constraints)
+}
+
+// SatisfiedBy returns whether the store satisfies every constraint of the
+// lease preference, as checked by the allocator.
+func (l LeasePreference) SatisfiedBy(store roachpb.StoreDescriptor) bool {
+	for _, c := range l.Constraints {
+		if !StoreSatisfiesConstraint(store, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// LeasePreferenceIndex returns the index of the first lease preference of the
+// zone config which the store satisfies, and whether there is one. The
+// allocator places leases on the stores with the lowest index.
+func (z *ZoneConfig) LeasePreferenceIndex(store roachpb.StoreDescriptor) (int, bool) {
+	for i, pref := range z.LeasePreferences {
+		if pref.SatisfiedBy(store) {
+			return i, true
+		}
+	}
+	return 0, false
 }
 
 // NewZoneConfig is the zone configuration used when no custom
@@ -280,7 +638,9 @@ func (z *ZoneConfig) InheritedVoterConstraints() bool {
 }
 
 // ShouldInheritGC returns true if the zone config should inherit the GC policy
-// from the parent.
+// from the parent. A nil GC policy is unset and is always inherited, while a
+// set one must have a positive TTL (see Validate), so that a TTL of zero is
+// never mistaken for an unset policy.
 func (z *ZoneConfig) ShouldInheritGC(parent *ZoneConfig) bool {
 	return z.GC == nil && parent.GC != nil
 }
@@ -307,20 +667,13 @@ func (z *ZoneConfig) ShouldInheritVoterConstraints(parent *ZoneConfig) bool {
 // specifies a configuration that could cause problems with the introduction
 // of cascading zone configs.
 func (z *ZoneConfig) ValidateTandemFields() error {
-	var numConstrainedRepls int32
 	numVotersExplicit := z.NumVoters != nil && *z.NumVoters > 0
-	for _, constraint := range z.Constraints {
-		numConstrainedRepls += constraint.NumReplicas
-	}
-
+	numConstrainedRepls := ConstraintsList{Constraints: z.Constraints}.TotalConstrainedReplicas()
 	if numConstrainedRepls > 0 && z.NumReplicas == nil {
 		return fmt.Errorf("when per-replica constraints are set, num_replicas must be set as well")
 	}
 
-	var numConstrainedVoters int32
-	for _, constraint := range z.VoterConstraints {
-		numConstrainedVoters += constraint.NumReplicas
-	}
+	numConstrainedVoters := ConstraintsList{Constraints: z.VoterConstraints}.TotalConstrainedReplicas()
 
 	if (numConstrainedVoters > 0 && z.NumVoters == nil) ||
 		(!numVotersExplicit && len(z.VoterConstraints) > 0) {
@@ -353,6 +706,9 @@ func (z *ZoneConfig) Validate() error {
 		if err := s.Config.Validate(); err != nil {
 			return err
 		}
+		if len(s.Config.SplitHints) > 0 {
+			return errors.New("split hints can't be set on indexes or partitions")
+		}
 	}
 
 	if z.NumReplicas != nil {
@@ -362,7 +718,12 @@ func (z *ZoneConfig) Validate() error {
 		case *z.NumReplicas == 0:
 			if len(z.Subzones) > 0 {
 				// NumReplicas == 0 is allowed when this ZoneConfig is a subzone
-				// placeholder. See IsSubzonePlaceholder.
+				// placeholder. See IsSubzonePlaceholder. The other fields of a
+				// placeholder aren't applied, so global_reads would silently be
+				// ignored.
+				if z.GlobalReads != nil {
+					return fmt.Errorf("global_reads cannot be set on a subzone placeholder with zero replicas")
+				}
 				return nil
 			}
 			return fmt.Errorf("at least one replica is required")
@@ -406,24 +767,27 @@ func (z *ZoneConfig) Validate() error {
 		return fmt.Errorf("GC.TTLSeconds %d less than minimum allowed 1", z.GC.TTLSeconds)
 	}
 
-	for _, constraints := range z.Constraints {
+	for i, constraints := range z.Constraints {
 		for _, constraint := range constraints.Constraints {
 			if constraint.Type == Constraint_DEPRECATED_POSITIVE {
-				return fmt.Errorf("constraints must either be required (prefixed with a '+') or " +
-					"prohibited (prefixed with a '-')")
+				return invalidConstraint("constraints", i, errors.New(
+					"constraints must either be required (prefixed with a '+') or "+
+						"prohibited (prefixed with a '-')"))
 			}
 		}
 	}
 
-	for _, constraints := range z.VoterConstraints {
+	for i, constraints := range z.VoterConstraints {
 		for _, constraint := range constraints.Constraints {
 			if constraint.Type == Constraint_DEPRECATED_POSITIVE {
-				return fmt.Errorf("voter_constraints must be of type 'required' (prefixed with a '+')")
+				return invalidConstraint("voter_constraints", i, errors.New(
+					"voter_constraints must be of type 'required' (prefixed with a '+')"))
 			}
 			// TODO(aayush): Allowing these makes validating `voter_constraints`
 			// against `constraints` harder. Revisit this decision if need be.
 			if constraint.Type == Constraint_PROHIBITED {
-				return fmt.Errorf("voter_constraints cannot contain prohibitive constraints")
+				return invalidConstraint("voter_constraints", i, errors.New(
+					"voter_constraints cannot contain prohibitive constraints"))
 			}
 		}
 	}
@@ -433,24 +797,27 @@ func (z *ZoneConfig) Validate() error {
 	// require validation.
 	if len(z.Constraints) > 1 || (len(z.Constraints) == 1 && z.Constraints[0].NumReplicas != 0) {
 		var numConstrainedRepls int64
-		for _, constraints := range z.Constraints {
+		for i, constraints := range z.Constraints {
 			if constraints.NumReplicas <= 0 {
-				return fmt.Errorf("constraints must apply to at least one replica")
+				return invalidConstraint("constraints", i, errors.New(
+					"constraints must apply to at least one replica"))
 			}
 			numConstrainedRepls += int64(constraints.NumReplicas)
 			for _, constraint := range constraints.Constraints {
 				// TODO(a-robinson): Relax this constraint to allow prohibited replicas,
 				// as discussed on #23014.
 				if constraint.Type != Constraint_REQUIRED && z.NumReplicas != nil && constraints.NumReplicas != *z.NumReplicas {
-					return fmt.Errorf(
-						"only required constraints (prefixed with a '+') can be applied to a subset of replicas")
+					return invalidConstraint("constraints", i, errors.New(
+						"only required constraints (prefixed with a '+') can be applied to a subset of replicas"))
 				}
 			}
 		}
 		if z.NumReplicas != nil && numConstrainedRepls > int64(*z.NumReplicas) {
-			return fmt.Errorf("the number of replicas specified in constraints (%d) cannot be greater "+
-				"than the number of replicas configured for the zone (%d)",
-				numConstrainedRepls, *z.NumReplicas)
+			return &ErrUnsatisfiable{
+				Group:       "constraints",
+				Constrained: numConstrainedRepls,
+				Available:   int64(*z.NumReplicas),
+			}
 		}
 	}
 
@@ -475,35 +842,85 @@ func (z *ZoneConfig) Validate() error {
 	if numVotersExplicit {
 		if len(z.VoterConstraints) > 1 || (len(z.VoterConstraints) == 1 && z.VoterConstraints[0].NumReplicas != 0) {
 			var numConstrainedRepls int64
-			for _, constraints := range z.VoterConstraints {
+			for i, constraints := range z.VoterConstraints {
 				if constraints.NumReplicas <= 0 {
-					return fmt.Errorf("constraints must apply to at least one replica")
+					return invalidConstraint("voter_constraints", i, errors.New(
+						"constraints must apply to at least one replica"))
 				}
 				numConstrainedRepls += int64(constraints.NumReplicas)
 			}
 			// NB: These nil checks are not required in production code but they are
 			// for testing as some tests run `Validate()` on incomplete zone configs.
 			if z.NumVoters != nil && numConstrainedRepls > int64(*z.NumVoters) {
-				return fmt.Errorf("the number of replicas specified in voter_constraints (%d) cannot be greater "+
-					"than the number of voters configured for the zone (%d)",
-					numConstrainedRepls, *z.NumVoters)
+				return &ErrUnsatisfiable{
+					Group:       "voter_constraints",
+					Constrained: numConstrainedRepls,
+					Available:   int64(*z.NumVoters),
+				}
 			}
 		}
 	}
 
+	// Wildcards are only supported in the values of locality constraints, and
+	// store constraints must refer to a store ID.
+	for _, g := range []struct {
+		group        string
+		conjunctions []ConstraintsConjunction
+	}{
+		{"constraints", z.Constraints},
+		{"voter_constraints", z.VoterConstraints},
+	} {
+		for i, conjunction := range g.conjunctions {
+			if err := validateWildcards(conjunction.Constraints); err != nil {
+				return invalidConstraint(g.group, i, err)
+			}
+			if err := validateStoreConstraints(conjunction.Constraints); err != nil {
+				return invalidConstraint(g.group, i, err)
+			}
+		}
+	}
+	for i, leasePref := range z.LeasePreferences {
+		if err := validateWildcards(leasePref.Constraints); err != nil {
+			return invalidConstraint("lease_preferences", i, err)
+		}
+		if err := validateStoreConstraints(leasePref.Constraints); err != nil {
+			return invalidConstraint("lease_preferences", i, err)
+		}
+	}
+
 	//  Validate that `constraints` aren't incompatible with `voter_constraints`.
 	if err := validateVoterConstraintsCompatibility(z.VoterConstraints, z.Constraints); err != nil {
 		return err
 	}
 
-	for _, leasePref := range z.LeasePreferences {
+	if err := z.validateSurvivalGoalAndRegions(); err != nil {
+		return err
+	}
+
+	if err := z.validateSplitHints(); err != nil {
+		return err
+	}
+
+	for i, leasePref := range z.LeasePreferences {
 		if len(leasePref.Constraints) == 0 {
-			return fmt.Errorf("every lease preference must include at least one constraint")
+			return invalidConstraint("lease_preferences", i, errors.New(
+				"every lease preference must include at least one constraint"))
 		}
 		for _, constraint := range leasePref.Constraints {
 			if constraint.Type == Constraint_DEPRECATED_POSITIVE {
-				return fmt.Errorf("lease preference constraints must either be required " +
-					"(prefixed with a '+') or prohibited (prefixed with a '-')")
+				return invalidConstraint("lease_preferences", i, errors.New(
+					"lease preference constraints must either be required "+
+						"(prefixed with a '+') or prohibited (prefixed with a '-')"))
+			}
+		}
+		// A lease preference which no store can satisfy is ignored, which is
+		// unlikely to be what was intended, e.g. [+region=a,-region=a].
+		for j, c := range leasePref.Constraints {
+			for _, other := range leasePref.Constraints[j+1:] {
+				if constraintsConflict(c, other) {
+					return invalidConstraint("lease_preferences", i, errors.Newf(
+						"lease preference %s can't be satisfied: %s conflicts with %s", leasePref, c, other))
+				}
 			}
 		}
 	}
@@ -511,6 +928,42 @@ func (z *ZoneConfig) Validate() error {
 	return nil
 }
 
+// validateWildcards returns an error if a constraint uses wildcards anywhere
+// but in the value of a locality constraint.
+func validateWildcards(constraints []Constraint) error {
+	for _, c := range constraints {
+		if strings.Contains(c.Key, "*") || (c.hasWildcard() && c.Kind() != ConstraintKindLocality) {
+			return fmt.Errorf("invalid constraint %s: wildcards are only supported in "+
+				"the values of locality constraints", c)
+		}
+	}
+	return nil
+}
+
+// validateStoreConstraints returns an error if a store constraint doesn't
+// refer to a valid store ID.
+func validateStoreConstraints(constraints []Constraint) error {
+	for _, c := range constraints {
+		if err := validateStoreConstraint(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStoreConstraint returns an error if the constraint is a store
+// constraint which doesn't refer to a valid store ID.
+func validateStoreConstraint(c Constraint) error {
+	if c.Kind() != ConstraintKindStore {
+		return nil
+	}
+	if _, ok := c.StoreID(); !ok {
+		return errors.Errorf("invalid constraint %s: the value of a %s constraint must be a "+
+			"positive store ID", c, StoreConstraintKey)
+	}
+	return nil
+}
+
 // validateVoterConstraintsCompatibility cross-validates `voter_constraints`
 // against `constraints` and ensures that nothing that is prohibited at the
 // overall `constraints` level is required at the `voter_constraints` level,
@@ -528,8 +981,7 @@ func validateVoterConstraintsCompatibility(
 			if constraint.Type == Constraint_PROHIBITED {
 				for _, otherConstraints := range voterConstraints {
 					for _, otherConstraint := range otherConstraints.Constraints {
-						conflicting := otherConstraint.Value == constraint.Value && otherConstraint.Key == constraint.Key
-						if conflicting {
+						if excludes(constraint, otherConstraint) {
 							return fmt.Errorf("prohibitive constraint %s conflicts with voter_constraint %s", constraint, otherConstraint)
 						}
 					}
@@ -558,6 +1010,22 @@ func (z *ZoneConfig) InheritFromParent(parent *ZoneConfig) {
 			z.GlobalReads = proto.Bool(*parent.GlobalReads)
 		}
 	}
+	if z.SurvivalGoal == nil {
+		if parent.SurvivalGoal != nil {
+			g := *parent.SurvivalGoal
+			z.SurvivalGoal = &g
+		}
+	}
+	if z.PrimaryRegion == nil {
+		if parent.PrimaryRegion != nil {
+			z.PrimaryRegion = proto.String(*parent.PrimaryRegion)
+		}
+	}
+	if z.SecondaryRegion == nil {
+		if parent.SecondaryRegion != nil {
+			z.SecondaryRegion = proto.String(*parent.SecondaryRegion)
+		}
+	}
 	if z.RangeMinBytes == nil {
 		if parent.RangeMinBytes != nil {
 			z.RangeMinBytes = proto.Int64(*parent.RangeMinBytes)
@@ -576,10 +1044,12 @@ func (z *ZoneConfig) InheritFromParent(parent *ZoneConfig) {
 	if z.ShouldInheritConstraints(parent) {
 		z.Constraints = parent.Constraints
 		z.InheritedConstraints = false
+		z.ConstraintsSet = parent.ConstraintsSet
 	}
 	if z.ShouldInheritVoterConstraints(parent) {
 		z.VoterConstraints = parent.VoterConstraints
 		z.NullVoterConstraintsIsEmpty = parent.NullVoterConstraintsIsEmpty
+		z.VoterConstraintsSet = parent.VoterConstraintsSet
 
 	}
 	if z.ShouldInheritLeasePreferences(parent) {
@@ -617,6 +1087,22 @@ func (z *ZoneConfig) CopyFromZone(other ZoneConfig, fieldList []tree.Name) {
 			if other.GlobalReads != nil {
 				z.GlobalReads = proto.Bool(*other.GlobalReads)
 			}
+		case "survival_goal":
+			z.SurvivalGoal = nil
+			if other.SurvivalGoal != nil {
+				g := *other.SurvivalGoal
+				z.SurvivalGoal = &g
+			}
+		case "primary_region":
+			z.PrimaryRegion = nil
+			if other.PrimaryRegion != nil {
+				z.PrimaryRegion = proto.String(*other.PrimaryRegion)
+			}
+		case "secondary_region":
+			z.SecondaryRegion = nil
+			if other.SecondaryRegion != nil {
+				z.SecondaryRegion = proto.String(*other.SecondaryRegion)
+			}
 		case "gc.ttlseconds":
 			z.GC = nil
 			if other.GC != nil {
@@ -626,16 +1112,156 @@ func (z *ZoneConfig) CopyFromZone(other ZoneConfig, fieldList []tree.Name) {
 		case "constraints":
 			z.Constraints = other.Constraints
 			z.InheritedConstraints = other.InheritedConstraints
+			z.ConstraintsSet = other.ConstraintsSet
 		case "voter_constraints":
 			z.VoterConstraints = other.VoterConstraints
 			z.NullVoterConstraintsIsEmpty = other.NullVoterConstraintsIsEmpty
+			z.VoterConstraintsSet = other.VoterConstraintsSet
 		case "lease_preferences":
 			z.LeasePreferences = other.LeasePreferences
 			z.InheritedLeasePreferences = other.InheritedLeasePreferences
+		case "split_hints":
+			z.SplitHints = other.SplitHints
 		}
 	}
 }
 
+// EquivalentTo returns whether the zone config has the same meaning as other.
+// Unlike Equal, which compares the zone configs structurally, EquivalentTo
+// ignores the order of constraints conjunctions and of the constraints within
+// each conjunction and lease preference. The order of the lease preferences
+// themselves is significant, since it determines their priority. As with
+// Equal, nil and empty slices are treated the same. The audit info and
+// application ID of the zone configs are ignored.
+func (z *ZoneConfig) EquivalentTo(other *ZoneConfig) bool {
+	if z == nil || other == nil {
+		return z == other
+	}
+	if len(z.Subzones) != len(other.Subzones) {
+		return false
+	}
+	for i := range z.Subzones {
+		s, o := &z.Subzones[i], &other.Subzones[i]
+		if s.IndexID != o.IndexID || s.PartitionName != o.PartitionName ||
+			!s.Config.EquivalentTo(&o.Config) {
+			return false
+		}
+	}
+	a, b := z.canonicalize(), other.canonicalize()
+	return a.Equal(b)
+}
+
+// Hash returns a hash of the zone config which is the same for equivalent zone
+// configs (see EquivalentTo), so that changes can be detected without keeping
+// the previous zone config around. It hashes the protobuf encoding of the
+// canonicalized zone config, and so is stable across processes and
+// architectures, but may change if fields are added to ZoneConfig.
+func (z *ZoneConfig) Hash() uint64 {
+	c := z.canonicalize()
+	if len(z.Subzones) > 0 {
+		c.Subzones = make([]Subzone, len(z.Subzones))
+		for i := range z.Subzones {
+			s := &z.Subzones[i]
+			c.Subzones[i] = Subzone{
+				IndexID:       s.IndexID,
+				PartitionName: s.PartitionName,
+				Config:        *s.Config.canonicalize(),
+			}
+		}
+	}
+	data, err := c.Marshal()
+	if err != nil {
+		panic(errors.NewAssertionErrorWithWrappedErrf(err, "marshaling zone config"))
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// canonicalize returns a copy of the zone config, without subzones, audit
+// info or application ID, in which constraints and split hints are sorted.
+// See EquivalentTo.
+func (z *ZoneConfig) canonicalize() *ZoneConfig {
+	c := *z
+	c.Subzones = nil
+	c.AuditInfo = nil
+	c.ApplicationID = ""
+	c.Constraints = canonicalizeConjunctions(z.Constraints)
+	c.VoterConstraints = canonicalizeConjunctions(z.VoterConstraints)
+	c.SplitHints = sortedSplitHints(z.SplitHints)
+	if len(z.LeasePreferences) > 0 {
+		c.LeasePreferences = make([]LeasePreference, len(z.LeasePreferences))
+		for i, pref := range z.LeasePreferences {
+			c.LeasePreferences[i].Constraints = sortedConstraints(pref.Constraints)
+		}
+	}
+	return &c
+}
+
+func canonicalizeConjunctions(conjunctions []ConstraintsConjunction) []ConstraintsConjunction {
+	if len(conjunctions) == 0 {
+		return nil
+	}
+	res := make([]ConstraintsConjunction, len(conjunctions))
+	for i, c := range conjunctions {
+		res[i] = ConstraintsConjunction{
+			NumReplicas: c.NumReplicas,
+			Constraints: sortedConstraints(c.Constraints),
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Compare(res[j]) < 0
+	})
+	return res
+}
+
+func sortedConstraints(constraints []Constraint) []Constraint {
+	if len(constraints) == 0 {
+		return nil
+	}
+	res := append([]Constraint(nil), constraints...)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Compare(res[j]) < 0
+	})
+	return res
+}
+
+// ReferencedTierKeys returns the sorted locality tier keys, such as region,
+// which are referenced by the constraints, voter constraints and lease
+// preferences of the zone config and its subzones.
+func (z *ZoneConfig) ReferencedTierKeys() []string {
+	tierKeys := make(map[string]struct{})
+	var visit func(z *ZoneConfig)
+	addConstraints := func(constraints []Constraint) {
+		for _, c := range constraints {
+			if c.Kind() == ConstraintKindLocality {
+				tierKeys[c.Key] = struct{}{}
+			}
+		}
+	}
+	visit = func(z *ZoneConfig) {
+		for _, conjunction := range z.Constraints {
+			addConstraints(conjunction.Constraints)
+		}
+		for _, conjunction := range z.VoterConstraints {
+			addConstraints(conjunction.Constraints)
+		}
+		for _, pref := range z.LeasePreferences {
+			addConstraints(pref.Constraints)
+		}
+		for i := range z.Subzones {
+			visit(&z.Subzones[i].Config)
+		}
+	}
+	visit(z)
+	res := make([]string, 0, len(tierKeys))
+	for k := range tierKeys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // DiffWithZoneMismatch indicates a mismatch between zone configurations.
 type DiffWithZoneMismatch struct {
 	// NOTE: the below fields are only set if there is a subzone in the
@@ -798,17 +1424,13 @@ func (z *ZoneConfig) DiffWithZone(
 	// Look into all subzones and ensure they're equal across both zone
 	// configs.
 	// These need to be read in as a map as subzones can be added out-of-order.
-	type subzoneKey struct {
-		indexID       uint32
-		partitionName string
-	}
-	otherSubzonesBySubzoneKey := make(map[subzoneKey]Subzone, len(other.Subzones))
+	otherSubzonesBySubzoneKey := make(map[SubzoneKey]Subzone, len(other.Subzones))
 	for _, o := range other.Subzones {
-		k := subzoneKey{indexID: o.IndexID, partitionName: o.PartitionName}
+		k := SubzoneKey{IndexID: o.IndexID, PartitionName: o.PartitionName}
 		otherSubzonesBySubzoneKey[k] = o
 	}
 	for _, s := range z.Subzones {
-		k := subzoneKey{indexID: s.IndexID, partitionName: s.PartitionName}
+		k := SubzoneKey{IndexID: s.IndexID, PartitionName: s.PartitionName}
 		o, found := otherSubzonesBySubzoneKey[k]
 		if !found {
 			// There can be an extra zone config defined so long as
@@ -907,12 +1529,13 @@ func StoreSatisfiesConstraint(store roachpb.StoreDescriptor, constraint Constrai
 	return true
 }
 
-// StoreMatchesConstraint returns whether a store's attributes or node's
+// StoreMatchesConstraint returns whether a store's attributes, ID or node's
 // locality match the constraint's spec. It notably ignores whether the
 // constraint is required, prohibited, positive, or otherwise.
 // Also see StoreSatisfiesConstraint().
 func StoreMatchesConstraint(store roachpb.StoreDescriptor, c Constraint) bool {
-	if c.Key == "" {
+	switch c.Kind() {
+	case ConstraintKindAttribute:
 		for _, attrs := range []roachpb.Attributes{store.Attrs, store.Node.Attrs} {
 			for _, attr := range attrs.Attrs {
 				if attr == c.Value {
@@ -921,9 +1544,12 @@ func StoreMatchesConstraint(store roachpb.StoreDescriptor, c Constraint) bool {
 			}
 		}
 		return false
+	case ConstraintKindStore:
+		id, ok := c.StoreID()
+		return ok && id == store.StoreID
 	}
 	for _, tier := range store.Node.Locality.Tiers {
-		if c.Key == tier.Key && c.Value == tier.Value {
+		if c.Matches(tier) {
 			return true
 		}
 	}
@@ -939,9 +1565,11 @@ func StoreMatchesConstraint(store roachpb.StoreDescriptor, c Constraint) bool {
 func (z *ZoneConfig) DeleteTableConfig() {
 	*z = ZoneConfig{
 		// Have to set NumReplicas to 0 so it is recognized as a placeholder.
-		NumReplicas:  proto.Int32(0),
-		Subzones:     z.Subzones,
-		SubzoneSpans: z.SubzoneSpans,
+		NumReplicas:   proto.Int32(0),
+		Subzones:      z.Subzones,
+		SubzoneSpans:  z.SubzoneSpans,
+		AuditInfo:     z.AuditInfo,
+		ApplicationID: z.ApplicationID,
 	}
 }
 
@@ -963,14 +1591,11 @@ func (z *ZoneConfig) IsSubzonePlaceholder() bool {
 // to get the Subzone for an entire index, if it exists. indexID, however, must
 // always be provided, even when looking for a partition's Subzone.
 func (z *ZoneConfig) GetSubzone(indexID uint32, partition string) *Subzone {
-	for _, s := range z.Subzones {
-		if s.IndexID == indexID && s.PartitionName == partition {
-			copySubzone := s
-			return &copySubzone
-		}
+	if subzone := z.GetSubzoneExact(indexID, partition); subzone != nil {
+		return subzone
 	}
 	if partition != "" {
-		return z.GetSubzone(indexID, "")
+		return z.GetSubzoneExact(indexID, "")
 	}
 	return nil
 }
@@ -979,11 +1604,9 @@ func (z *ZoneConfig) GetSubzone(indexID uint32, partition string) *Subzone {
 // subzone that applies to a specified index and partition, as it finds either the
 // exact config that applies, or returns nil.
 func (z *ZoneConfig) GetSubzoneExact(indexID uint32, partition string) *Subzone {
-	for _, s := range z.Subzones {
-		if s.IndexID == indexID && s.PartitionName == partition {
-			copySubzone := s
-			return &copySubzone
-		}
+	if subzone, ok := z.GetSubzoneForIndexPartition(indexID, partition); ok {
+		copySubzone := *subzone
+		return &copySubzone
 	}
 	return nil
 }
@@ -1004,41 +1627,188 @@ func (z ZoneConfig) GetSubzoneForKeySuffix(keySuffix []byte) (*Subzone, int32) {
 	return nil, -1
 }
 
+// GetSubzoneForIndexPartition returns the subzone of the specified index and
+// partition, and whether there is one. An empty partition name refers to the
+// subzone of the index itself. Unlike GetSubzone, the subzone of a partition
+// doesn't fall back to the subzone of its index, and the returned subzone
+// points into z.Subzones rather than being a copy. It must thus only be
+// modified if the subzones aren't shared with a clone (see CloneSubzones).
+func (z *ZoneConfig) GetSubzoneForIndexPartition(
+	indexID uint32, partition string,
+) (*Subzone, bool) {
+	i, ok := z.subzoneIndex(indexID, partition)
+	if !ok {
+		return nil, false
+	}
+	return &z.Subzones[i], true
+}
+
+// SubzoneKey identifies the subzone of an index, or of one of its partitions.
+// An empty PartitionName refers to the subzone of the index itself.
+type SubzoneKey struct {
+	IndexID       uint32
+	PartitionName string
+}
+
+// MaterializeSubzoneConfigs returns the effective config of each of the
+// subzones, keyed by index and partition. The config of a partition inherits
+// the fields it leaves unset from the subzone of its index, if there is one,
+// and then from the zone config, while the config of an index inherits them
+// from the zone config directly. The zone config is expected to be hydrated
+// from its parents, so that the returned configs are fully hydrated too. It
+// is left unmodified, but the returned configs may share slices with it.
+func (z *ZoneConfig) MaterializeSubzoneConfigs() map[SubzoneKey]ZoneConfig {
+	if len(z.Subzones) == 0 {
+		return nil
+	}
+	configs := make(map[SubzoneKey]ZoneConfig, len(z.Subzones))
+	for i := range z.Subzones {
+		s := &z.Subzones[i]
+		config := s.Config
+		if s.PartitionName != "" {
+			if index, ok := z.GetSubzoneForIndexPartition(s.IndexID, ""); ok {
+				config.InheritFromParent(&index.Config)
+			}
+		}
+		config.InheritFromParent(z)
+		configs[SubzoneKey{IndexID: s.IndexID, PartitionName: s.PartitionName}] = config
+	}
+	return configs
+}
+
+// subzoneIndex returns the position in z.Subzones of the subzone of the
+// specified index and partition, and whether there is one.
+func (z *ZoneConfig) subzoneIndex(indexID uint32, partition string) (int, bool) {
+	for i := range z.Subzones {
+		if s := &z.Subzones[i]; s.IndexID == indexID && s.PartitionName == partition {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// subzoneLess orders subzones by index ID, with the subzone of an index
+// before the subzones of its partitions, which are ordered by name.
+func subzoneLess(a, b *Subzone) bool {
+	if a.IndexID != b.IndexID {
+		return a.IndexID < b.IndexID
+	}
+	return a.PartitionName < b.PartitionName
+}
+
 // SetSubzone installs subzone into the ZoneConfig, overwriting any existing
-// subzone with the same IndexID and PartitionName.
+// subzone with the same IndexID and PartitionName. New subzones are inserted
+// so as to keep the subzones ordered by index ID and partition name, and the
+// SubzoneSpans are renumbered to keep referring to the same subzones. The
+// spans of the new subzone itself must be generated by the caller. The
+// Subzones and SubzoneSpans are replaced rather than modified in place, since
+// they may be shared with clones (see Clone).
 func (z *ZoneConfig) SetSubzone(subzone Subzone) {
-	for i, s := range z.Subzones {
-		if s.IndexID == subzone.IndexID && s.PartitionName == subzone.PartitionName {
-			z.Subzones[i] = subzone
-			return
+	if i, ok := z.subzoneIndex(subzone.IndexID, subzone.PartitionName); ok {
+		z.CloneSubzones()
+		z.Subzones[i] = subzone
+		return
+	}
+	i := sort.Search(len(z.Subzones), func(i int) bool {
+		return !subzoneLess(&z.Subzones[i], &subzone)
+	})
+	subzones := make([]Subzone, 0, len(z.Subzones)+1)
+	subzones = append(subzones, z.Subzones[:i]...)
+	subzones = append(subzones, subzone)
+	z.Subzones = append(subzones, z.Subzones[i:]...)
+	if len(z.SubzoneSpans) == 0 {
+		return
+	}
+	spans := make([]SubzoneSpan, len(z.SubzoneSpans))
+	for j, span := range z.SubzoneSpans {
+		if span.SubzoneIndex >= int32(i) {
+			span.SubzoneIndex++
 		}
+		spans[j] = span
 	}
-	z.Subzones = append(z.Subzones, subzone)
+	z.SubzoneSpans = spans
 }
 
-// DeleteSubzone removes the subzone with the specified index ID and partition.
-// It returns whether it performed any work.
+// DeleteSubzone removes the subzone with the specified index ID and partition,
+// along with its SubzoneSpans. It returns whether it performed any work.
 func (z *ZoneConfig) DeleteSubzone(indexID uint32, partition string) bool {
-	for i, s := range z.Subzones {
-		if s.IndexID == indexID && s.PartitionName == partition {
-			z.Subzones = append(z.Subzones[:i], z.Subzones[i+1:]...)
-			return true
-		}
-	}
-	return false
+	return z.deleteSubzones(func(s *Subzone) bool {
+		return s.IndexID == indexID && s.PartitionName == partition
+	})
 }
 
 // DeleteIndexSubzones deletes all subzones that refer to the index with the
-// specified ID. This includes subzones for partitions of the index as well as
-// the index subzone itself.
+// specified ID, along with their SubzoneSpans. This includes subzones for
+// partitions of the index as well as the index subzone itself.
 func (z *ZoneConfig) DeleteIndexSubzones(indexID uint32) {
-	subzones := z.Subzones[:0]
-	for _, s := range z.Subzones {
-		if s.IndexID != indexID {
-			subzones = append(subzones, s)
+	z.deleteSubzones(func(s *Subzone) bool { return s.IndexID == indexID })
+}
+
+// deleteSubzones removes the subzones for which remove returns true, along
+// with their SubzoneSpans, and renumbers the remaining SubzoneSpans. It
+// returns whether any subzone was removed. Like SetSubzone, it replaces the
+// Subzones and SubzoneSpans rather than modifying them in place.
+func (z *ZoneConfig) deleteSubzones(remove func(*Subzone) bool) bool {
+	// newIndexes maps the position of each subzone to its new position, or -1
+	// if it's removed.
+	newIndexes := make([]int32, len(z.Subzones))
+	subzones := make([]Subzone, 0, len(z.Subzones))
+	for i := range z.Subzones {
+		if remove(&z.Subzones[i]) {
+			newIndexes[i] = -1
+			continue
 		}
+		newIndexes[i] = int32(len(subzones))
+		subzones = append(subzones, z.Subzones[i])
+	}
+	if len(subzones) == len(z.Subzones) {
+		return false
 	}
 	z.Subzones = subzones
+	spans := make([]SubzoneSpan, 0, len(z.SubzoneSpans))
+	for _, span := range z.SubzoneSpans {
+		if span.SubzoneIndex < 0 || int(span.SubzoneIndex) >= len(newIndexes) ||
+			newIndexes[span.SubzoneIndex] < 0 {
+			continue
+		}
+		span.SubzoneIndex = newIndexes[span.SubzoneIndex]
+		spans = append(spans, span)
+	}
+	z.SubzoneSpans = spans
+	return true
+}
+
+// ValidateSubzoneSpans returns an error if the subzone spans of a zone config
+// with numSubzones subzones aren't sorted, overlap, are empty, or refer to a
+// subzone which doesn't exist. As in the SubzoneSpans field, the EndKey of a
+// span may be omitted if it's the PrefixEnd of its Key.
+func ValidateSubzoneSpans(spans []SubzoneSpan, numSubzones int) error {
+	endKey := func(s *SubzoneSpan) []byte {
+		if s.EndKey == nil {
+			return s.Key.PrefixEnd()
+		}
+		return s.EndKey
+	}
+	for i := range spans {
+		s := &spans[i]
+		if s.SubzoneIndex < 0 || int(s.SubzoneIndex) >= numSubzones {
+			return errors.Newf("subzone span %d refers to subzone %d, but there are %d subzones",
+				i, s.SubzoneIndex, numSubzones)
+		}
+		if bytes.Compare(s.Key, endKey(s)) >= 0 {
+			return errors.Newf("subzone span %d [%x, %x) is empty", i, []byte(s.Key), endKey(s))
+		}
+		if i == 0 {
+			continue
+		}
+		if prev := &spans[i-1]; bytes.Compare(endKey(prev), s.Key) > 0 {
+			return &ErrSubzoneOverlap{
+				Index: i, Key: s.Key, EndKey: endKey(s),
+				Other: i - 1, OtherKey: prev.Key, OtherEndKey: endKey(prev),
+			}
+		}
+	}
+	return nil
 }
 
 // SubzoneSplits returns the split points determined by a ZoneConfig's subzones.
@@ -1076,6 +1846,30 @@ func (c ConstraintsConjunction) String() string {
 	return sb.String()
 }
 
+// Compare returns -1, 0 or 1 depending on whether c sorts before, the same as,
+// or after other. Conjunctions are ordered by their constraints, with a
+// conjunction which is a prefix of the other sorting first, and then by their
+// number of replicas.
+func (c ConstraintsConjunction) Compare(other ConstraintsConjunction) int {
+	for i := range c.Constraints {
+		if i >= len(other.Constraints) {
+			return 1
+		}
+		if cmp := c.Constraints[i].Compare(other.Constraints[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	switch {
+	case len(c.Constraints) < len(other.Constraints):
+		return -1
+	case c.NumReplicas < other.NumReplicas:
+		return -1
+	case c.NumReplicas > other.NumReplicas:
+		return 1
+	}
+	return 0
+}
+
 // EnsureFullyHydrated returns an assertion error if the zone config is not
 // fully hydrated. A fully hydrated zone configuration must have all required
 // fields set, which are RangeMaxBytes, RangeMinBytes, GC, and NumReplicas.
@@ -1128,6 +1922,8 @@ func (z *ZoneConfig) toSpanConfig() (roachpb.SpanConfig, error) {
 	if z.GlobalReads != nil {
 		sc.GlobalReads = *z.GlobalReads
 	}
+	// NB: The survival goal and regions aren't part of span configs, since they
+	// only describe the constraints, which are.
 	sc.NumReplicas = *z.NumReplicas
 	if z.NumVoters != nil {
 		sc.NumVoters = *z.NumVoters
@@ -0,0 +1,20 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+// AppliedBy returns whether the zone config was last written by the change
+// with the given application ID, so that automation re-applying its desired
+// zone configs can skip the ones it already applied. Changes without an
+// application ID are never considered applied. A nil zone config has not been
+// written yet.
+func (z *ZoneConfig) AppliedBy(applicationID string) bool {
+	return z != nil && applicationID != "" && z.ApplicationID == applicationID
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// auditInfoYAMLKey is the key under which ZoneConfigWithAuditInfo encodes the
+// audit info of the zone config.
+const auditInfoYAMLKey = "audit_info"
+
+// LastModified returns the timestamp of the last recorded change to the zone
+// config, or the empty timestamp if none was recorded.
+func (z *ZoneConfig) LastModified() hlc.Timestamp {
+	if z.AuditInfo == nil {
+		return hlc.Timestamp{}
+	}
+	return z.AuditInfo.LastModified
+}
+
+// ModifiedBy returns the user who made the last recorded change to the zone
+// config, or the empty string if none was recorded.
+func (z *ZoneConfig) ModifiedBy() string {
+	if z.AuditInfo == nil {
+		return ""
+	}
+	return z.AuditInfo.ModifiedBy
+}
+
+// StatementFingerprint returns the fingerprint of the statement which made the
+// last recorded change to the zone config, or the empty string if none was
+// recorded.
+func (z *ZoneConfig) StatementFingerprint() string {
+	if z.AuditInfo == nil {
+		return ""
+	}
+	return z.AuditInfo.StatementFingerprint
+}
+
+// ZoneConfigWithAuditInfo wraps a zone config so that its YAML encoding
+// includes the audit info of the zone config under the audit_info key. The
+// YAML encoding of ZoneConfig itself omits the audit info, so that it doesn't
+// get in the way of round-tripping zone configs through ALTER ... CONFIGURE
+// ZONE.
+type ZoneConfigWithAuditInfo struct {
+	ZoneConfig
+}
+
+// marshalableAuditInfo is the YAML encoding of ZoneConfigAuditInfo.
+type marshalableAuditInfo struct {
+	LastModified         string `yaml:"last_modified,omitempty"`
+	ModifiedBy           string `yaml:"modified_by,omitempty"`
+	StatementFingerprint string `yaml:"statement_fingerprint,omitempty"`
+}
+
+var _ yaml.Marshaler = ZoneConfigWithAuditInfo{}
+var _ yaml.Unmarshaler = &ZoneConfigWithAuditInfo{}
+
+// MarshalYAML implements yaml.Marshaler.
+func (c ZoneConfigWithAuditInfo) MarshalYAML() (interface{}, error) {
+	m := zoneConfigToMarshalable(c.ZoneConfig)
+	if c.AuditInfo == nil {
+		return zoneConfigYAMLNode(m)
+	}
+	var audit marshalableAuditInfo
+	if !c.AuditInfo.LastModified.IsEmpty() {
+		audit.LastModified = c.AuditInfo.LastModified.String()
+	}
+	audit.ModifiedBy = c.AuditInfo.ModifiedBy
+	audit.StatementFingerprint = c.AuditInfo.StatementFingerprint
+	return zoneConfigYAMLNode(struct {
+		marshalableZoneConfig `yaml:",inline"`
+		AuditInfo             marshalableAuditInfo `yaml:"audit_info"`
+	}{m, audit})
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The audit info is decoded
+// separately, and the rest of the document is decoded as a ZoneConfig.
+func (c *ZoneConfigWithAuditInfo) UnmarshalYAML(value *yaml.Node) error {
+	value = resolveYAMLAlias(value)
+	if value.Kind != yaml.MappingNode {
+		return value.Decode(&c.ZoneConfig)
+	}
+	rest := flattenYAMLMapping(value)
+	i := yamlMappingIndex(rest, auditInfoYAMLKey)
+	if i < 0 {
+		return rest.Decode(&c.ZoneConfig)
+	}
+	auditValue := rest.Content[i+1]
+	rest.Content = append(rest.Content[:i:i], rest.Content[i+2:]...)
+	if err := rest.Decode(&c.ZoneConfig); err != nil {
+		return err
+	}
+	if isYAMLNull(auditValue) {
+		return nil
+	}
+
+	var audit marshalableAuditInfo
+	if err := checkYAMLFields(auditValue, &audit); err != nil {
+		return errors.Wrap(err, "invalid audit_info")
+	}
+	if err := auditValue.Decode(&audit); err != nil {
+		return errors.Wrap(err, "invalid audit_info")
+	}
+	info := ZoneConfigAuditInfo{
+		ModifiedBy:           audit.ModifiedBy,
+		StatementFingerprint: audit.StatementFingerprint,
+	}
+	if audit.LastModified != "" {
+		var err error
+		if info.LastModified, err = hlc.ParseTimestamp(audit.LastModified); err != nil {
+			return yamlNodeError(auditValue, errors.Wrap(err, "invalid audit_info.last_modified"))
+		}
+	}
+	c.AuditInfo = &info
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// minZoneConfigsPerWorker is the minimum number of zone configs encoded or
+// decoded by each worker of EncodeZoneConfigs and DecodeZoneConfigs, below
+// which the cost of a goroutine outweighs the work it does.
+const minZoneConfigsPerWorker = 64
+
+// EncodeZoneConfigs returns the protobuf encoding of each of the given zone
+// configs, like protoutil.Marshal. The encodings share a single buffer, so
+// that encoding many zone configs, e.g. the per-table zone configs of a
+// restore, costs a couple of allocations instead of one per zone config. The
+// encodings must thus not be appended to in place; their capacity is clipped
+// so that appending to them reallocates them instead.
+func EncodeZoneConfigs(zones []ZoneConfig) ([][]byte, error) {
+	offsets := make([]int, len(zones)+1)
+	for i := range zones {
+		offsets[i+1] = offsets[i] + zones[i].Size()
+	}
+	buf := make([]byte, offsets[len(zones)])
+	res := make([][]byte, len(zones))
+	err := forEachZoneConfigBatch(len(zones), func(start, end int) error {
+		for i := start; i < end; i++ {
+			res[i] = buf[offsets[i]:offsets[i+1]:offsets[i+1]]
+			if _, err := zones[i].MarshalToSizedBuffer(res[i]); err != nil {
+				return errors.Wrapf(err, "encoding zone config %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DecodeZoneConfigs is the inverse of EncodeZoneConfigs: it decodes each of the
+// given protobuf encodings, like protoutil.Unmarshal. The zone configs are
+// decoded into a single slice, by as many workers as there are processors. The
+// decoded zone configs don't reference the encodings.
+func DecodeZoneConfigs(data [][]byte) ([]ZoneConfig, error) {
+	res := make([]ZoneConfig, len(data))
+	err := forEachZoneConfigBatch(len(data), func(start, end int) error {
+		for i := start; i < end; i++ {
+			if err := res[i].Unmarshal(data[i]); err != nil {
+				return errors.Wrapf(err, "decoding zone config %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// forEachZoneConfigBatch splits the indexes [0, n) into contiguous batches,
+// one per worker, and calls fn on each batch concurrently. It returns the error
+// of the first batch which failed.
+func forEachZoneConfigBatch(n int, fn func(start, end int) error) error {
+	workers := runtime.GOMAXPROCS(0)
+	if maxWorkers := n / minZoneConfigsPerWorker; workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		return fn(0, n)
+	}
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := n*w/workers, n*(w+1)/workers
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = fn(start, end)
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
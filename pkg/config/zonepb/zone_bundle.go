@@ -0,0 +1,298 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// zoneConfigBundleTargetKey is the name of the field which names the target of
+// each document in a zone config bundle.
+const zoneConfigBundleTargetKey = "target"
+
+// zoneConfigBundleApplicationIDKey is the name of the optional field which sets
+// the application ID of the zone config of a document in a zone config bundle.
+const zoneConfigBundleApplicationIDKey = "application_id"
+
+// ParseZoneConfigBundle parses a stream of YAML documents, each of which
+// configures the zone named by its target field, e.g.:
+//
+//	target: db.table@idx
+//	num_replicas: 5
+//	---
+//	target: RANGE default
+//	gc: {ttlseconds: 14400}
+//
+// The target is not interpreted. A document may also set the application ID
+// of its zone config (see ZoneConfig.AppliedBy) through an application_id
+// field. The remaining fields of each document are
+// parsed like the YAML accepted by ALTER ... CONFIGURE ZONE, on top of
+// NewZoneConfig(), so fields which aren't specified are inherited. Empty
+// documents are skipped, and a target may only be configured once. The
+// documents' aliases are checked with CheckYAMLAliases, and a document may be
+// no larger than the YAML of a single zone config.
+//
+// Use a ZoneConfigBundleDecoder to process the documents one at a time.
+func ParseZoneConfigBundle(r io.Reader) (map[string]ZoneConfig, error) {
+	decoder := NewZoneConfigBundleDecoder(r)
+	res := make(map[string]ZoneConfig)
+	for {
+		target, zone, err := decoder.Next()
+		if err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+		res[target] = zone
+	}
+}
+
+// ZoneConfigBundleDecoder decodes the documents of a zone config bundle (see
+// ParseZoneConfigBundle) one at a time, so that bundles which are too large to
+// be read at once, such as the exports of clusters with many partitioned
+// tables, can be processed with bounded memory: only the current document is
+// held in memory, along with the targets seen so far to reject duplicates.
+type ZoneConfigBundleDecoder struct {
+	r *bufio.Reader
+	// doc is the number of documents decoded so far, including empty ones.
+	doc     int
+	targets map[string]struct{}
+	eof     bool
+}
+
+// NewZoneConfigBundleDecoder returns a decoder reading a zone config bundle
+// from r.
+func NewZoneConfigBundleDecoder(r io.Reader) *ZoneConfigBundleDecoder {
+	return &ZoneConfigBundleDecoder{
+		r:       bufio.NewReader(r),
+		targets: make(map[string]struct{}),
+	}
+}
+
+// Next returns the target and zone config of the next non-empty document of
+// the bundle, or io.EOF once there are none left. The decoder must not be used
+// after Next returns another error.
+func (d *ZoneConfigBundleDecoder) Next() (string, ZoneConfig, error) {
+	for {
+		data, err := d.readDocument()
+		if err != nil {
+			return "", ZoneConfig{}, err
+		}
+		if data == nil {
+			return "", ZoneConfig{}, io.EOF
+		}
+		if err := CheckYAMLAliases(data); err != nil {
+			return "", ZoneConfig{}, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err == io.EOF {
+			// The chunk only holds comments or document markers.
+			continue
+		} else if err != nil {
+			return "", ZoneConfig{}, errors.Wrapf(err, "document %d", d.doc+1)
+		}
+		d.doc++
+		if len(doc.Content) == 0 || isYAMLNull(doc.Content[0]) {
+			continue
+		}
+		root := resolveYAMLAlias(doc.Content[0])
+		if root.Kind != yaml.MappingNode {
+			return "", ZoneConfig{}, errors.Wrapf(
+				yamlNodeErrorf(root, "zone config must be a mapping of fields to values"),
+				"document %d", d.doc)
+		}
+		if len(root.Content) == 0 {
+			continue
+		}
+		target, zone, err := parseZoneConfigBundleDocument(root)
+		if err != nil {
+			return "", ZoneConfig{}, errors.Wrapf(err, "document %d", d.doc)
+		}
+		if _, ok := d.targets[target]; ok {
+			return "", ZoneConfig{}, errors.Newf("document %d: duplicate target %q", d.doc, target)
+		}
+		d.targets[target] = struct{}{}
+		return target, zone, nil
+	}
+}
+
+// readDocument returns the next chunk of the stream which holds at most one
+// document, i.e. the lines up to the next document marker: "---" starts a
+// document and "..." ends one. It returns nil once the stream is exhausted.
+// Document markers can't appear within the content of a document, since its
+// nested lines are indented and its multi-line scalars must not contain them.
+func (d *ZoneConfigBundleDecoder) readDocument() ([]byte, error) {
+	if d.eof {
+		return nil, nil
+	}
+	var buf []byte
+	lineStart := true
+	for {
+		if lineStart && len(buf) > 0 {
+			if next, _ := d.r.Peek(4); isYAMLDocumentMarker(next, "---") {
+				return buf, nil
+			}
+		}
+		// Lines longer than the reader's buffer are read in several fragments.
+		line, err := d.r.ReadSlice('\n')
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		buf = append(buf, line...)
+		if int64(len(buf)) > maxYAMLDocumentBytes {
+			return nil, errors.Newf("document %d exceeds the maximum size of %s",
+				d.doc+1, humanizeutil.IBytes(maxYAMLDocumentBytes))
+		}
+		if err == io.EOF {
+			d.eof = true
+			if len(buf) == 0 {
+				return nil, nil
+			}
+			return buf, nil
+		}
+		end := lineStart && isYAMLDocumentMarker(line, "...")
+		lineStart = err == nil
+		if end {
+			return buf, nil
+		}
+	}
+}
+
+// isYAMLDocumentMarker returns whether the line starts with the given document
+// marker.
+func isYAMLDocumentMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	if len(line) == len(marker) {
+		return true
+	}
+	switch line[len(marker)] {
+	case ' ', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
+
+func parseZoneConfigBundleDocument(root *yaml.Node) (string, ZoneConfig, error) {
+	doc := flattenYAMLMapping(root)
+	j := yamlMappingIndex(doc, zoneConfigBundleTargetKey)
+	if j < 0 {
+		return "", ZoneConfig{}, errors.Newf("missing %s", zoneConfigBundleTargetKey)
+	}
+	value := resolveYAMLAlias(doc.Content[j+1])
+	target := strings.TrimSpace(value.Value)
+	if value.Kind != yaml.ScalarNode || value.ShortTag() != "!!str" || target == "" {
+		return "", ZoneConfig{}, yamlNodeErrorf(value,
+			"%s must be a non-empty string", zoneConfigBundleTargetKey)
+	}
+	doc.Content = append(doc.Content[:j:j], doc.Content[j+2:]...)
+	var applicationID string
+	if j := yamlMappingIndex(doc, zoneConfigBundleApplicationIDKey); j >= 0 {
+		value := resolveYAMLAlias(doc.Content[j+1])
+		if value.Kind != yaml.ScalarNode || isYAMLNull(value) {
+			return "", ZoneConfig{}, errors.Wrapf(yamlNodeErrorf(value,
+				"%s must be a string", zoneConfigBundleApplicationIDKey), "target %q", target)
+		}
+		applicationID = value.Value
+		doc.Content = append(doc.Content[:j:j], doc.Content[j+2:]...)
+	}
+	zone := NewZoneConfig()
+	if err := doc.Decode(zone); err != nil {
+		return "", ZoneConfig{}, errors.Wrapf(err, "target %q", target)
+	}
+	zone.ApplicationID = applicationID
+	return target, *zone, nil
+}
+
+// ZoneConfigChangeType is the type of a ZoneConfigChange.
+type ZoneConfigChangeType int
+
+const (
+	// ZoneConfigChangeCreate configures a target which has no zone config.
+	ZoneConfigChangeCreate ZoneConfigChangeType = iota
+	// ZoneConfigChangeUpdate replaces the zone config of a target.
+	ZoneConfigChangeUpdate
+	// ZoneConfigChangeDelete removes the zone config of a target.
+	ZoneConfigChangeDelete
+)
+
+func (t ZoneConfigChangeType) String() string {
+	switch t {
+	case ZoneConfigChangeCreate:
+		return "create"
+	case ZoneConfigChangeUpdate:
+		return "update"
+	case ZoneConfigChangeDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("ZoneConfigChangeType(%d)", int(t))
+	}
+}
+
+// ZoneConfigChange is an operation which converges the zone config of a
+// single target to its desired state.
+type ZoneConfigChange struct {
+	Target string
+	Type   ZoneConfigChangeType
+	// Current is the target's current zone config. It is nil for creations.
+	Current *ZoneConfig
+	// Desired is the target's desired zone config. It is nil for deletions.
+	Desired *ZoneConfig
+}
+
+// ReconcileZoneConfigs computes the changes which converge the current zone
+// configs, keyed by target, to the desired ones, e.g. as returned by
+// ParseZoneConfigBundle. Targets which are only desired are created, targets
+// which are only current are deleted, and targets whose zone configs aren't
+// equivalent (see EquivalentTo) are updated, unless the current zone config was
+// already written by the desired one's application ID (see AppliedBy). The
+// targets are not interpreted, and the changes are ordered by target.
+func ReconcileZoneConfigs(current, desired map[string]ZoneConfig) []ZoneConfigChange {
+	var changes []ZoneConfigChange
+	for target := range desired {
+		d := desired[target]
+		c, ok := current[target]
+		switch {
+		case !ok:
+			changes = append(changes, ZoneConfigChange{
+				Target: target, Type: ZoneConfigChangeCreate, Desired: &d,
+			})
+		case !c.EquivalentTo(&d) && !c.AppliedBy(d.ApplicationID):
+			changes = append(changes, ZoneConfigChange{
+				Target: target, Type: ZoneConfigChangeUpdate, Current: &c, Desired: &d,
+			})
+		}
+	}
+	for target := range current {
+		if _, ok := desired[target]; ok {
+			continue
+		}
+		c := current[target]
+		changes = append(changes, ZoneConfigChange{
+			Target: target, Type: ZoneConfigChangeDelete, Current: &c,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Target < changes[j].Target
+	})
+	return changes
+}
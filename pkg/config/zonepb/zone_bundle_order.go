@@ -0,0 +1,222 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/errors"
+)
+
+// zoneConfigTargetLevel is the level of a zone config target in the hierarchy
+// of zone configs. Targets inherit from the targets of lower levels.
+type zoneConfigTargetLevel int
+
+const (
+	zoneConfigTargetDefault zoneConfigTargetLevel = iota
+	zoneConfigTargetRange
+	zoneConfigTargetDatabase
+	zoneConfigTargetTable
+	zoneConfigTargetIndex
+	zoneConfigTargetPartition
+)
+
+// zoneConfigTarget is a parsed zone config target, as named in ALTER ...
+// CONFIGURE ZONE, e.g. "TABLE db.t" or "PARTITION p OF INDEX db.t@idx".
+type zoneConfigTarget struct {
+	level zoneConfigTargetLevel
+	// parent is the target which the target inherits from, other than RANGE
+	// default, e.g. "DATABASE db" for "TABLE db.t". It's empty if there is none.
+	parent string
+}
+
+// parseZoneConfigTarget parses the target of a zone config. Names are not
+// unquoted, and are compared as written.
+func parseZoneConfigTarget(s string) (zoneConfigTarget, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return zoneConfigTarget{}, errors.Newf("invalid zone config target %q", s)
+	}
+	name := strings.Join(fields[1:], " ")
+	switch strings.ToUpper(fields[0]) {
+	case "RANGE":
+		if name == string(DefaultZoneName) {
+			return zoneConfigTarget{level: zoneConfigTargetDefault}, nil
+		}
+		return zoneConfigTarget{level: zoneConfigTargetRange}, nil
+	case "DATABASE":
+		return zoneConfigTarget{level: zoneConfigTargetDatabase}, nil
+	case "TABLE":
+		if i := strings.LastIndexByte(name, '.'); i > 0 {
+			return zoneConfigTarget{level: zoneConfigTargetTable, parent: "DATABASE " + name[:i]}, nil
+		}
+		return zoneConfigTarget{level: zoneConfigTargetTable}, nil
+	case "INDEX":
+		i := strings.LastIndexByte(name, '@')
+		if i <= 0 {
+			return zoneConfigTarget{}, errors.Newf("invalid zone config target %q: missing table", s)
+		}
+		return zoneConfigTarget{level: zoneConfigTargetIndex, parent: "TABLE " + name[:i]}, nil
+	case "PARTITION":
+		if len(fields) < 5 || !strings.EqualFold(fields[2], "OF") {
+			return zoneConfigTarget{}, errors.Newf(
+				"invalid zone config target %q: expected PARTITION <name> OF <index or table>", s)
+		}
+		of := strings.ToUpper(fields[3]) + " " + strings.Join(fields[4:], " ")
+		parent, err := parseZoneConfigTarget(of)
+		if err != nil {
+			return zoneConfigTarget{}, errors.Wrapf(err, "invalid zone config target %q", s)
+		}
+		if parent.level != zoneConfigTargetIndex && parent.level != zoneConfigTargetTable {
+			return zoneConfigTarget{}, errors.Newf(
+				"invalid zone config target %q: partitions belong to an index or table", s)
+		}
+		return zoneConfigTarget{level: zoneConfigTargetPartition, parent: of}, nil
+	default:
+		return zoneConfigTarget{}, errors.Newf("invalid zone config target %q", s)
+	}
+}
+
+// ZoneConfigMaskWarning reports that a change to the zone config of a target
+// has no effect on a target inheriting from it, because every field which the
+// change affects is set on the child's zone config.
+type ZoneConfigMaskWarning struct {
+	// Parent is the target whose change is masked, and Child the target
+	// masking it.
+	Parent, Child string
+	// Fields lists the masked fields, named as in ALTER ... CONFIGURE ZONE.
+	Fields []tree.Name
+}
+
+func (w ZoneConfigMaskWarning) String() string {
+	names := make([]string, len(w.Fields))
+	for i, f := range w.Fields {
+		names[i] = string(f)
+	}
+	return fmt.Sprintf("the change to %s is masked by %s, which sets %s",
+		w.Parent, w.Child, strings.Join(names, ", "))
+}
+
+// OrderZoneConfigChanges orders the changes, e.g. as returned by
+// ReconcileZoneConfigs, so that the zone configs of targets are applied
+// before those of the targets inheriting from them: RANGE default first, then
+// the other ranges, databases, tables, indexes and partitions. Changes at the
+// same level are ordered by target.
+//
+// It also returns a warning for each change which is fully masked by the
+// desired zone config of a descendant, i.e. which has no effect on it, e.g.
+// setting num_replicas on a database whose table sets num_replicas too. Only
+// descendants which are themselves created or updated by the changes are
+// considered. An error is returned if a target can't be parsed.
+func OrderZoneConfigChanges(
+	changes []ZoneConfigChange,
+) ([]ZoneConfigChange, []ZoneConfigMaskWarning, error) {
+	targets := make(map[string]zoneConfigTarget, len(changes))
+	for _, c := range changes {
+		t, err := parseZoneConfigTarget(c.Target)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets[c.Target] = t
+	}
+	ordered := append([]ZoneConfigChange(nil), changes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		li, lj := targets[ordered[i].Target].level, targets[ordered[j].Target].level
+		if li != lj {
+			return li < lj
+		}
+		return ordered[i].Target < ordered[j].Target
+	})
+
+	var warnings []ZoneConfigMaskWarning
+	for _, parent := range ordered {
+		changed := changedZoneConfigFields(parent)
+		if len(changed) == 0 {
+			continue
+		}
+		for _, child := range ordered {
+			if child.Desired == nil || !inheritsFrom(targets, child.Target, parent.Target) {
+				continue
+			}
+			if masksFields(child.Desired, changed) {
+				warnings = append(warnings, ZoneConfigMaskWarning{
+					Parent: parent.Target, Child: child.Target, Fields: changed,
+				})
+			}
+		}
+	}
+	return ordered, warnings, nil
+}
+
+// inheritsFrom returns whether the child target inherits from the ancestor.
+// Every other target inherits from RANGE default.
+func inheritsFrom(targets map[string]zoneConfigTarget, child, ancestor string) bool {
+	if child == ancestor {
+		return false
+	}
+	if targets[ancestor].level == zoneConfigTargetDefault {
+		return true
+	}
+	for t := targets[child]; t.parent != ""; {
+		if t.parent == ancestor {
+			return true
+		}
+		var err error
+		if t, err = parseZoneConfigTarget(t.parent); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// changedZoneConfigFields returns the fields which the change affects. A
+// missing zone config is treated as one which sets no fields.
+func changedZoneConfigFields(c ZoneConfigChange) []tree.Name {
+	current, desired := NewZoneConfig(), NewZoneConfig()
+	if c.Current != nil {
+		current = c.Current
+	}
+	if c.Desired != nil {
+		desired = c.Desired
+	}
+	var changed []tree.Name
+	for _, f := range zoneChangeFields {
+		fieldList := []tree.Name{f.name}
+		cur, des := NewZoneConfig(), NewZoneConfig()
+		cur.CopyFromZone(*current, fieldList)
+		des.CopyFromZone(*desired, fieldList)
+		if !cur.Equal(des) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+// masksFields returns whether every one of the fields is set on the zone
+// config, so that it doesn't inherit them.
+func masksFields(z *ZoneConfig, fields []tree.Name) bool {
+	for _, name := range fields {
+		set := false
+		for _, f := range formatFields {
+			if tree.Name(f.name) == name {
+				set = f.isSet(z)
+				break
+			}
+		}
+		if !set {
+			return false
+		}
+	}
+	return true
+}
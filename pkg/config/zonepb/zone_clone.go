@@ -0,0 +1,70 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+// Clone returns a copy of the zone config which may be modified independently
+// of it, without deep-copying it: the copy shares the backing arrays of the
+// slices of the zone config, such as Constraints, Subzones and SubzoneSpans,
+// along with those of its subzones. Cloning the zone config of a table with
+// thousands of partitions is thus cheap, whereas protoutil.Clone copies every
+// subzone and constraint.
+//
+// This is safe because the slices are copied lazily, when they're first
+// modified: the methods of ZoneConfig which modify slices, such as SetSubzone,
+// DeleteSubzone and DeleteIndexSubzones, replace them rather than modifying
+// their elements in place, and appending to the slices of the copy
+// reallocates them. Code which modifies the elements of the slices of a
+// clone directly, e.g. z.Subzones[i].Config, must copy the slice first (see
+// CloneSubzones), or use protoutil.Clone instead.
+func (z *ZoneConfig) Clone() *ZoneConfig {
+	if z == nil {
+		return nil
+	}
+	c := *z
+	// The scalar fields are pointers, which may be modified through.
+	c.RangeMinBytes = clonePointee(z.RangeMinBytes)
+	c.RangeMaxBytes = clonePointee(z.RangeMaxBytes)
+	c.GlobalReads = clonePointee(z.GlobalReads)
+	c.SurvivalGoal = clonePointee(z.SurvivalGoal)
+	c.PrimaryRegion = clonePointee(z.PrimaryRegion)
+	c.SecondaryRegion = clonePointee(z.SecondaryRegion)
+	c.NumReplicas = clonePointee(z.NumReplicas)
+	c.NumVoters = clonePointee(z.NumVoters)
+	c.GC = clonePointee(z.GC)
+	c.AuditInfo = clonePointee(z.AuditInfo)
+	// Clip the capacity of the slices, so that appending to them reallocates
+	// them instead of writing past the end of the shared backing arrays.
+	c.Constraints = z.Constraints[:len(z.Constraints):len(z.Constraints)]
+	c.VoterConstraints = z.VoterConstraints[:len(z.VoterConstraints):len(z.VoterConstraints)]
+	c.LeasePreferences = z.LeasePreferences[:len(z.LeasePreferences):len(z.LeasePreferences)]
+	c.Subzones = z.Subzones[:len(z.Subzones):len(z.Subzones)]
+	c.SubzoneSpans = z.SubzoneSpans[:len(z.SubzoneSpans):len(z.SubzoneSpans)]
+	c.SplitHints = z.SplitHints[:len(z.SplitHints):len(z.SplitHints)]
+	return &c
+}
+
+// CloneSubzones replaces the subzones of the zone config with a shallow copy,
+// whose elements may be modified without affecting the zone configs which
+// the subzones are shared with (see Clone). The slices of the subzones' own
+// zone configs are still shared.
+func (z *ZoneConfig) CloneSubzones() {
+	if len(z.Subzones) > 0 {
+		z.Subzones = append([]Subzone(nil), z.Subzones...)
+	}
+}
+
+func clonePointee[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
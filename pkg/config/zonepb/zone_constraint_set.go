@@ -0,0 +1,132 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/errors"
+)
+
+// The set operations of conjunctions treat them as sets of constraints, which
+// constrain the stores that satisfy all of them: the more constraints, the
+// fewer stores. A constraint is in a set if the set has a constraint which
+// implies it, e.g. +region=us-east1 implies +region=us-*, and -region=us-*
+// implies -region=us-east1. Deprecated positive constraints only imply
+// themselves. The results of the operations are sorted and apply to all
+// replicas, i.e. they don't have a number of replicas.
+
+// implies returns whether every store which satisfies c also satisfies other.
+func (c Constraint) implies(other Constraint) bool {
+	if c == other {
+		return true
+	}
+	if c.Type != other.Type || c.Key != other.Key || c.Kind() != ConstraintKindLocality {
+		return false
+	}
+	switch c.Type {
+	case Constraint_REQUIRED:
+		return !c.hasWildcard() && other.Matches(roachpb.Tier{Key: c.Key, Value: c.Value})
+	case Constraint_PROHIBITED:
+		return !other.hasWildcard() && c.Matches(roachpb.Tier{Key: other.Key, Value: other.Value})
+	default:
+		return false
+	}
+}
+
+// impliesConstraint returns whether a constraint of the conjunction implies
+// the given constraint.
+func (c ConstraintsConjunction) impliesConstraint(constraint Constraint) bool {
+	for _, other := range c.Constraints {
+		if other.implies(constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubsetOf returns whether every constraint of the conjunction is implied by
+// a constraint of other, in which case every store which satisfies other also
+// satisfies the conjunction.
+func (c ConstraintsConjunction) IsSubsetOf(other ConstraintsConjunction) bool {
+	for _, constraint := range c.Constraints {
+		if !other.impliesConstraint(constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns the constraints of both conjunctions, which are satisfied by
+// the stores which satisfy both. It returns an error if no store can satisfy
+// them, because a prohibited constraint matches all of the stores of a
+// required constraint, e.g. +region=us-east1 and -region=us-*.
+func (c ConstraintsConjunction) Union(other ConstraintsConjunction) (ConstraintsConjunction, error) {
+	constraints := append(append([]Constraint(nil), c.Constraints...), other.Constraints...)
+	for _, prohibited := range constraints {
+		if prohibited.Type != Constraint_PROHIBITED {
+			continue
+		}
+		for _, required := range constraints {
+			if required.Type == Constraint_REQUIRED && excludes(prohibited, required) {
+				return ConstraintsConjunction{}, errors.Newf(
+					"constraints %s and %s can't both be satisfied", required, prohibited)
+			}
+		}
+	}
+	return constraintSet(constraints), nil
+}
+
+// Intersect returns the constraints which are implied by both conjunctions,
+// which are satisfied by the stores which satisfy either of them. For example,
+// the intersection of +region=us-east1,+ssd and +region=us-*,+hdd is
+// +region=us-*.
+func (c ConstraintsConjunction) Intersect(other ConstraintsConjunction) ConstraintsConjunction {
+	var constraints []Constraint
+	for _, constraint := range c.Constraints {
+		if other.impliesConstraint(constraint) {
+			constraints = append(constraints, constraint)
+		}
+	}
+	for _, constraint := range other.Constraints {
+		if c.impliesConstraint(constraint) {
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraintSet(constraints)
+}
+
+// Subtract returns the constraints of the conjunction which aren't implied by
+// other, i.e. those which other lacks to be a subset of the conjunction.
+func (c ConstraintsConjunction) Subtract(other ConstraintsConjunction) ConstraintsConjunction {
+	var constraints []Constraint
+	for _, constraint := range c.Constraints {
+		if !other.impliesConstraint(constraint) {
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraintSet(constraints)
+}
+
+// constraintSet returns the conjunction of the sorted constraints, without
+// duplicates.
+func constraintSet(constraints []Constraint) ConstraintsConjunction {
+	constraints = sortedConstraints(constraints)
+	res := constraints[:0]
+	for i := range constraints {
+		if i == 0 || constraints[i] != constraints[i-1] {
+			res = append(res, constraints[i])
+		}
+	}
+	if len(res) == 0 {
+		res = nil
+	}
+	return ConstraintsConjunction{Constraints: res}
+}
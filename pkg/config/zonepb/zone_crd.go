@@ -0,0 +1,233 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/gogo/protobuf/proto"
+)
+
+// The API version and kind of the Kubernetes custom resource of zone configs,
+// as managed by the CockroachDB operator.
+const (
+	ZoneConfigCRDAPIVersion = "crdb.cockroachlabs.com/v1alpha1"
+	ZoneConfigCRDKind       = "ZoneConfig"
+)
+
+// ZoneConfigCRD is a zone config in the shape of a Kubernetes custom resource,
+// meant to be encoded as JSON. The metadata of the resource, such as its name,
+// is left to the operator.
+type ZoneConfigCRD struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Spec       ZoneConfigCRDSpec `json:"spec"`
+}
+
+// ZoneConfigCRDSpec is the spec of a ZoneConfigCRD. It has the fields of the
+// YAML accepted by ALTER ... CONFIGURE ZONE, in camelCase, and constraints are
+// in the same shorthand notation, e.g. "+region=us-east1". Fields which are
+// omitted are inherited, while empty lists of constraints or lease preferences
+// are not.
+type ZoneConfigCRDSpec struct {
+	RangeMinBytes    *int64                          `json:"rangeMinBytes,omitempty"`
+	RangeMaxBytes    *int64                          `json:"rangeMaxBytes,omitempty"`
+	GC               *ZoneConfigCRDGCPolicy          `json:"gc,omitempty"`
+	GlobalReads      *bool                           `json:"globalReads,omitempty"`
+	NumReplicas      *int32                          `json:"numReplicas,omitempty"`
+	NumVoters        *int32                          `json:"numVoters,omitempty"`
+	Constraints      *[]ZoneConfigCRDConstraints     `json:"constraints,omitempty"`
+	VoterConstraints *[]ZoneConfigCRDConstraints     `json:"voterConstraints,omitempty"`
+	LeasePreferences *[]ZoneConfigCRDLeasePreference `json:"leasePreferences,omitempty"`
+
+	// SurvivalGoal is either "zone" or "region".
+	SurvivalGoal    *string `json:"survivalGoal,omitempty"`
+	PrimaryRegion   *string `json:"primaryRegion,omitempty"`
+	SecondaryRegion *string `json:"secondaryRegion,omitempty"`
+}
+
+// ZoneConfigCRDGCPolicy is the GC policy of a ZoneConfigCRDSpec.
+type ZoneConfigCRDGCPolicy struct {
+	TTLSeconds int32 `json:"ttlSeconds"`
+}
+
+// ZoneConfigCRDConstraints is a conjunction of constraints of a
+// ZoneConfigCRDSpec. NumReplicas isn't set for constraints which apply to all
+// replicas.
+type ZoneConfigCRDConstraints struct {
+	Constraints []string `json:"constraints"`
+	NumReplicas int32    `json:"numReplicas,omitempty"`
+}
+
+// ZoneConfigCRDLeasePreference is a lease preference of a ZoneConfigCRDSpec.
+type ZoneConfigCRDLeasePreference struct {
+	Constraints []string `json:"constraints"`
+}
+
+// ToCRDSpec converts the zone config into a Kubernetes custom resource. Like
+// its YAML, the resource doesn't include the subzones of the zone config. It's
+// the inverse of FromCRDSpec.
+func (c ZoneConfig) ToCRDSpec() ZoneConfigCRD {
+	m := zoneConfigToMarshalable(c)
+	spec := ZoneConfigCRDSpec{
+		NumReplicas: m.NumReplicas,
+		NumVoters:   m.NumVoters,
+	}
+	if m.GlobalReads != nil {
+		spec.GlobalReads = proto.Bool(bool(*m.GlobalReads))
+	}
+	if m.RangeMinBytes != nil {
+		spec.RangeMinBytes = proto.Int64(int64(*m.RangeMinBytes))
+	}
+	if m.RangeMaxBytes != nil {
+		spec.RangeMaxBytes = proto.Int64(int64(*m.RangeMaxBytes))
+	}
+	if m.GC != nil {
+		spec.GC = &ZoneConfigCRDGCPolicy{TTLSeconds: m.GC.TTLSeconds}
+	}
+	if c.SurvivalGoal != nil {
+		spec.SurvivalGoal = proto.String(survivalGoalYAMLNames[*c.SurvivalGoal])
+	}
+	spec.PrimaryRegion = m.PrimaryRegion
+	spec.SecondaryRegion = m.SecondaryRegion
+	spec.Constraints = constraintsListToCRD(m.Constraints)
+	spec.VoterConstraints = constraintsListToCRD(m.VoterConstraints)
+	if !c.InheritedLeasePreferences {
+		prefs := make([]ZoneConfigCRDLeasePreference, len(c.LeasePreferences))
+		for i, p := range c.LeasePreferences {
+			prefs[i].Constraints = shortConstraints(p.Constraints)
+		}
+		spec.LeasePreferences = &prefs
+	}
+	return ZoneConfigCRD{
+		APIVersion: ZoneConfigCRDAPIVersion,
+		Kind:       ZoneConfigCRDKind,
+		Spec:       spec,
+	}
+}
+
+func constraintsListToCRD(l ConstraintsList) *[]ZoneConfigCRDConstraints {
+	if l.Inherited {
+		return nil
+	}
+	res := make([]ZoneConfigCRDConstraints, len(l.Constraints))
+	for i, c := range l.Constraints {
+		res[i] = ZoneConfigCRDConstraints{
+			Constraints: shortConstraints(c.Constraints),
+			NumReplicas: c.NumReplicas,
+		}
+	}
+	return &res
+}
+
+// FromCRDSpec converts a Kubernetes custom resource back into a zone config.
+// The fields which the resource omits are inherited, as if it had been
+// converted from the YAML of the zone config on top of NewZoneConfig(). The
+// resource is subject to the same limits as YAML, but the zone config isn't
+// validated.
+func FromCRDSpec(crd ZoneConfigCRD) (ZoneConfig, error) {
+	if crd.APIVersion != ZoneConfigCRDAPIVersion {
+		return ZoneConfig{}, errors.Newf("unsupported apiVersion %q, expected %q",
+			crd.APIVersion, ZoneConfigCRDAPIVersion)
+	}
+	if crd.Kind != ZoneConfigCRDKind {
+		return ZoneConfig{}, errors.Newf("unsupported kind %q, expected %q", crd.Kind, ZoneConfigCRDKind)
+	}
+	spec := crd.Spec
+	c := *NewZoneConfig()
+	if spec.RangeMinBytes != nil {
+		c.RangeMinBytes = proto.Int64(*spec.RangeMinBytes)
+	}
+	if spec.RangeMaxBytes != nil {
+		c.RangeMaxBytes = proto.Int64(*spec.RangeMaxBytes)
+	}
+	if spec.GC != nil {
+		c.GC = &GCPolicy{TTLSeconds: spec.GC.TTLSeconds}
+	}
+	if spec.GlobalReads != nil {
+		c.GlobalReads = proto.Bool(*spec.GlobalReads)
+	}
+	if spec.SurvivalGoal != nil {
+		g, err := parseSurvivalGoal(*spec.SurvivalGoal)
+		if err != nil {
+			return ZoneConfig{}, err
+		}
+		c.SurvivalGoal = &g
+	}
+	if spec.PrimaryRegion != nil {
+		c.PrimaryRegion = proto.String(*spec.PrimaryRegion)
+	}
+	if spec.SecondaryRegion != nil {
+		c.SecondaryRegion = proto.String(*spec.SecondaryRegion)
+	}
+	if spec.NumReplicas != nil {
+		c.NumReplicas = proto.Int32(*spec.NumReplicas)
+	}
+	if spec.NumVoters != nil {
+		c.NumVoters = proto.Int32(*spec.NumVoters)
+	}
+	if spec.Constraints != nil {
+		constraints, err := constraintsListFromCRD(*spec.Constraints)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, "constraints")
+		}
+		c.Constraints, c.InheritedConstraints = constraints, false
+	}
+	if spec.VoterConstraints != nil {
+		constraints, err := constraintsListFromCRD(*spec.VoterConstraints)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, "voterConstraints")
+		}
+		c.VoterConstraints, c.NullVoterConstraintsIsEmpty = constraints, true
+	}
+	if spec.LeasePreferences != nil {
+		prefs := *spec.LeasePreferences
+		if len(prefs) > maxYAMLLeasePreferences {
+			return ZoneConfig{}, errors.Newf("at most %d lease preferences are allowed, found %d",
+				maxYAMLLeasePreferences, len(prefs))
+		}
+		c.LeasePreferences = make([]LeasePreference, len(prefs))
+		for i, p := range prefs {
+			constraints, err := parseShortConstraints(p.Constraints)
+			if err != nil {
+				return ZoneConfig{}, errors.Wrap(err, "leasePreferences")
+			}
+			c.LeasePreferences[i].Constraints = constraints
+		}
+		c.InheritedLeasePreferences = false
+	}
+	return c, nil
+}
+
+// constraintsListFromCRD converts constraints like ConstraintsList's
+// UnmarshalYAML: a single conjunction without a number of replicas applies to
+// all replicas, and per-replica constraints are sorted.
+func constraintsListFromCRD(l []ZoneConfigCRDConstraints) ([]ConstraintsConjunction, error) {
+	if len(l) > maxYAMLConjunctions {
+		return nil, errors.Newf("at most %d per-replica constraints are allowed, found %d",
+			maxYAMLConjunctions, len(l))
+	}
+	res := make([]ConstraintsConjunction, len(l))
+	for i, conj := range l {
+		if conj.NumReplicas < 0 {
+			return nil, errors.New("the number of replicas of per-replica constraints must be positive")
+		}
+		constraints, err := parseShortConstraints(conj.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = ConstraintsConjunction{
+			Constraints: constraints,
+			NumReplicas: conj.NumReplicas,
+		}
+	}
+	sortPerReplicaConstraints(res)
+	return res, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import "github.com/pmezard/go-difflib/difflib"
+
+// unifiedDiffContext is the number of unchanged lines shown around the changes
+// in UnifiedDiff, as in git diff.
+const unifiedDiffContext = 3
+
+// UnifiedDiff returns the changes from the old zone config to the new one, as
+// a unified diff of their YAML, e.g. for audit logs and for the notices of
+// ALTER ... CONFIGURE ZONE when it changes an existing zone config:
+//
+//	--- old
+//	+++ new
+//	@@ -3,7 +3,7 @@
+//	 gc:
+//	   ttlseconds: 14400
+//	 global_reads: null
+//	-num_replicas: 3
+//	+num_replicas: 5
+//	 num_voters: null
+//	 constraints: []
+//	 voter_constraints: []
+//
+// Both zone configs are rendered in their canonical form, as compared by
+// ZoneConfig.EquivalentTo, so that reordered constraints, subzones and audit
+// info don't show up as changes. The diff is empty if the zone configs are
+// equivalent.
+func UnifiedDiff(old, new ZoneConfig) string {
+	a, b := old.canonicalize(), new.canonicalize()
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a.Format(FormatOptions{Style: FormatYAML})),
+		B:        difflib.SplitLines(b.Format(FormatOptions{Style: FormatYAML})),
+		FromFile: "old",
+		ToFile:   "new",
+		Context:  unifiedDiffContext,
+	})
+	if err != nil {
+		// The diff is written to a strings.Builder, which never fails.
+		return ""
+	}
+	return diff
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// The errors below are returned, possibly wrapped, when zone configs are
+// parsed and validated. They expose what's wrong as structured data, so that
+// callers can tell them apart with errors.As, e.g. to map them to pgcodes or
+// to offer targeted hints, rather than matching on messages.
+
+// ErrUnknownField is returned when a YAML document sets a field which the type
+// it's decoded into doesn't have, e.g. a misspelled zone config field.
+type ErrUnknownField struct {
+	Field string
+	// Type is the Go type which the document is decoded into.
+	Type string
+}
+
+func (e *ErrUnknownField) Error() string {
+	return fmt.Sprintf("field %s not found in type %s", e.Field, e.Type)
+}
+
+// ErrInvalidConstraint is returned when a constraint of a zone config is
+// invalid, e.g. a prohibitive voter constraint.
+type ErrInvalidConstraint struct {
+	// Group is the field holding the constraint: constraints,
+	// voter_constraints or lease_preferences.
+	Group string
+	// Index is the index, within Group, of the conjunction or lease preference
+	// holding the constraint.
+	Index int
+	// Err describes why the constraint is invalid.
+	Err error
+}
+
+func (e *ErrInvalidConstraint) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *ErrInvalidConstraint) Unwrap() error {
+	return e.Err
+}
+
+// invalidConstraint returns an ErrInvalidConstraint, unless err is nil.
+func invalidConstraint(group string, index int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ErrInvalidConstraint{Group: group, Index: index, Err: err}
+}
+
+// ErrUnsatisfiable is returned when the per-replica constraints of a zone
+// config require more replicas than it has.
+type ErrUnsatisfiable struct {
+	// Group is the field holding the constraints: constraints or
+	// voter_constraints.
+	Group string
+	// Constrained is the number of replicas required by the constraints, and
+	// Available the number of replicas, or of voters for voter_constraints,
+	// of the zone config.
+	Constrained int64
+	Available   int64
+}
+
+func (e *ErrUnsatisfiable) Error() string {
+	replicas := "replicas"
+	if e.Group == "voter_constraints" {
+		replicas = "voters"
+	}
+	return fmt.Sprintf("the number of replicas specified in %s (%d) cannot be greater "+
+		"than the number of %s configured for the zone (%d)",
+		e.Group, e.Constrained, replicas, e.Available)
+}
+
+// ErrSubzoneOverlap is returned when a subzone span of a zone config overlaps
+// with, or isn't sorted after, the span preceding it.
+type ErrSubzoneOverlap struct {
+	// Index is the index of the subzone span, whose bounds are Key and EndKey,
+	// and Other is the index of the span it overlaps with.
+	Index, Other          int
+	Key, EndKey           roachpb.Key
+	OtherKey, OtherEndKey roachpb.Key
+}
+
+func (e *ErrSubzoneOverlap) Error() string {
+	return fmt.Sprintf("subzone span %d [%x, %x) overlaps with or precedes span %d [%x, %x)",
+		e.Index, []byte(e.Key), []byte(e.EndKey), e.Other, []byte(e.OtherKey), []byte(e.OtherEndKey))
+}
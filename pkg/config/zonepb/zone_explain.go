@@ -0,0 +1,125 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// ExplainUnsatisfied returns human-readable reasons why the given stores can't
+// satisfy the zone config: its number of replicas, each of its groups of
+// constraints and voter constraints, and each of its lease preferences, in
+// that order. The reason for a group names the constraints which rule out
+// stores, e.g.
+//
+//	constraints "+region=us-east1,+ssd:2": 1 of 5 stores match, 2 needed; +region=us-east1 rules out 3, +ssd rules out 2
+//
+// so that operators can tell why the ranges of the zone are reported as
+// violating their constraints. Like the allocator, it assumes that a store
+// holds at most one replica of a range. Groups are checked on their own,
+// except that lease preferences are only checked against the stores which may
+// hold voters, since leaseholders are voters.
+//
+// The zone config is expected to be hydrated from its parents. It returns nil
+// if the stores satisfy the zone config.
+func ExplainUnsatisfied(zc ZoneConfig, stores []roachpb.StoreDescriptor) []string {
+	numReplicas := int(int32Value(zc.NumReplicas))
+	numVoters := numReplicas
+	if n := int(int32Value(zc.NumVoters)); n > 0 {
+		numVoters = n
+	}
+
+	var reasons []string
+	if numReplicas > len(stores) {
+		reasons = append(reasons, fmt.Sprintf("num_replicas is %d but there are only %d stores",
+			numReplicas, len(stores)))
+	}
+	explain := func(field string, conjunctions []ConstraintsConjunction, numReplicas int) {
+		for _, c := range conjunctions {
+			required := int(c.NumReplicas)
+			if required == 0 {
+				required = numReplicas
+			}
+			if reason, ok := explainConjunction(
+				fmt.Sprintf("%s %q", field, c.String()), c.Constraints, required, stores, "stores",
+			); !ok {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	explain("constraints", zc.Constraints, numReplicas)
+	explain("voter_constraints", zc.VoterConstraints, numVoters)
+
+	_, replicaFilter := splitConjunctions(zc.Constraints)
+	_, voterFilter := splitConjunctions(zc.VoterConstraints)
+	var voterStores []roachpb.StoreDescriptor
+	for _, store := range stores {
+		if (replicaFilter == nil || storeSatisfiesConjunction(store, *replicaFilter)) &&
+			(voterFilter == nil || storeSatisfiesConjunction(store, *voterFilter)) {
+			voterStores = append(voterStores, store)
+		}
+	}
+	for _, pref := range zc.LeasePreferences {
+		if reason, ok := explainConjunction(
+			fmt.Sprintf("lease preference %q", pref.String()), pref.Constraints, 1, voterStores,
+			"stores which may hold voters",
+		); !ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
+
+// explainConjunction returns whether at least required of the stores satisfy
+// all of the constraints and, if not, the reason why, which counts the stores
+// that each constraint rules out. The stores are described by noun.
+func explainConjunction(
+	subject string,
+	constraints []Constraint,
+	required int,
+	stores []roachpb.StoreDescriptor,
+	noun string,
+) (reason string, ok bool) {
+	if required < 1 {
+		required = 1
+	}
+	var matching int
+	ruledOut := make([]int, len(constraints))
+	for _, store := range stores {
+		satisfied := true
+		for i, c := range constraints {
+			if !StoreSatisfiesConstraint(store, c) {
+				ruledOut[i]++
+				satisfied = false
+			}
+		}
+		if satisfied {
+			matching++
+		}
+	}
+	if matching >= required {
+		return "", true
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d of %d %s match, %d needed", subject, matching, len(stores), noun, required)
+	sep := "; "
+	for i, c := range constraints {
+		if ruledOut[i] == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s rules out %d", sep, c.String(), ruledOut[i])
+		sep = ", "
+	}
+	return b.String(), false
+}
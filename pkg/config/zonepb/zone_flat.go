@@ -0,0 +1,287 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gogo/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Attributes of the flat encoding of zone configs.
+const (
+	flatRangeMinBytes    = "range_min_bytes"
+	flatRangeMaxBytes    = "range_max_bytes"
+	flatGCTTLSeconds     = "gc_ttlseconds"
+	flatGlobalReads      = "global_reads"
+	flatSurvivalGoal     = "survival_goal"
+	flatPrimaryRegion    = "primary_region"
+	flatSecondaryRegion  = "secondary_region"
+	flatNumReplicas      = "num_replicas"
+	flatNumVoters        = "num_voters"
+	flatConstraints      = "constraints"
+	flatVoterConstraints = "voter_constraints"
+	flatLeasePreferences = "lease_preferences"
+)
+
+// ToFlatAttributes encodes the zone config as a flat set of attributes, in the
+// shape of the attributes of a Terraform resource. The values are scalars
+// (int64 for numbers, bool for global_reads and strings otherwise), except for
+// the lease preferences, which are a list of strings:
+//
+//	range_min_bytes:   134217728
+//	range_max_bytes:   536870912
+//	gc_ttlseconds:     14400
+//	global_reads:      false
+//	survival_goal:     "region"
+//	primary_region:    "a"
+//	secondary_region:  "b"
+//	num_replicas:      5
+//	num_voters:        3
+//	constraints:       "{+region=a: 2, +region=b: 2}"
+//	voter_constraints: "[+region=a]"
+//	lease_preferences: ["[+region=a]", "[+region=b]"]
+//
+// The constraints are encoded in the canonical YAML flow notation accepted by
+// ALTER ... CONFIGURE ZONE, and the lease preferences as in
+// LeasePreference.String. Fields which are inherited are omitted, and like in
+// YAML, the subzones aren't encoded. FromFlatAttributes decodes the
+// attributes.
+func (c ZoneConfig) ToFlatAttributes() (map[string]interface{}, error) {
+	m := zoneConfigToMarshalable(c)
+	attrs := make(map[string]interface{})
+	if m.RangeMinBytes != nil {
+		attrs[flatRangeMinBytes] = int64(*m.RangeMinBytes)
+	}
+	if m.RangeMaxBytes != nil {
+		attrs[flatRangeMaxBytes] = int64(*m.RangeMaxBytes)
+	}
+	if m.GC != nil {
+		attrs[flatGCTTLSeconds] = int64(m.GC.TTLSeconds)
+	}
+	if m.GlobalReads != nil {
+		attrs[flatGlobalReads] = bool(*m.GlobalReads)
+	}
+	if c.SurvivalGoal != nil {
+		attrs[flatSurvivalGoal] = survivalGoalYAMLNames[*c.SurvivalGoal]
+	}
+	if c.PrimaryRegion != nil {
+		attrs[flatPrimaryRegion] = *c.PrimaryRegion
+	}
+	if c.SecondaryRegion != nil {
+		attrs[flatSecondaryRegion] = *c.SecondaryRegion
+	}
+	if m.NumReplicas != nil {
+		attrs[flatNumReplicas] = int64(*m.NumReplicas)
+	}
+	if m.NumVoters != nil {
+		attrs[flatNumVoters] = int64(*m.NumVoters)
+	}
+	for key, l := range map[string]ConstraintsList{
+		flatConstraints:      m.Constraints,
+		flatVoterConstraints: m.VoterConstraints,
+	} {
+		if l.Inherited {
+			continue
+		}
+		s, err := MarshalYAMLFlow(l)
+		if err != nil {
+			return nil, errors.Wrap(err, key)
+		}
+		attrs[key] = string(s)
+	}
+	if !c.InheritedLeasePreferences {
+		prefs := make([]string, len(c.LeasePreferences))
+		for i, p := range c.LeasePreferences {
+			prefs[i] = p.String()
+		}
+		attrs[flatLeasePreferences] = prefs
+	}
+	return attrs, nil
+}
+
+// FromFlatAttributes decodes a zone config from the attributes returned by
+// ToFlatAttributes. The fields whose attributes are absent are inherited, as
+// if the zone config had been unmarshaled from YAML on top of NewZoneConfig().
+// Numbers may be of any integer type, or integral float64s, and unknown
+// attributes are rejected. The zone config isn't validated.
+func FromFlatAttributes(attrs map[string]interface{}) (ZoneConfig, error) {
+	var unknown []string
+	for key := range attrs {
+		switch key {
+		case flatRangeMinBytes, flatRangeMaxBytes, flatGCTTLSeconds, flatGlobalReads, flatClosedTSTarget,
+			flatSurvivalGoal, flatPrimaryRegion, flatSecondaryRegion, flatNumReplicas, flatNumVoters, flatConstraints, flatVoterConstraints,
+			flatLeasePreferences:
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return ZoneConfig{}, errors.Newf("unknown attributes: %s", strings.Join(unknown, ", "))
+	}
+
+	c := *NewZoneConfig()
+	for _, f := range []struct {
+		key string
+		max int64
+		set func(int64)
+	}{
+		{flatRangeMinBytes, math.MaxInt64, func(v int64) { c.RangeMinBytes = proto.Int64(v) }},
+		{flatRangeMaxBytes, math.MaxInt64, func(v int64) { c.RangeMaxBytes = proto.Int64(v) }},
+		{flatGCTTLSeconds, math.MaxInt32, func(v int64) { c.GC = &GCPolicy{TTLSeconds: int32(v)} }},
+		{flatNumReplicas, math.MaxInt32, func(v int64) { c.NumReplicas = proto.Int32(int32(v)) }},
+		{flatNumVoters, math.MaxInt32, func(v int64) { c.NumVoters = proto.Int32(int32(v)) }},
+	} {
+		v, ok := attrs[f.key]
+		if !ok {
+			continue
+		}
+		n, err := flatInt(v)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, f.key)
+		}
+		if n < -f.max-1 || n > f.max {
+			return ZoneConfig{}, errors.Newf("%s: %d is out of range", f.key, n)
+		}
+		f.set(n)
+	}
+	if v, ok := attrs[flatGlobalReads]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return ZoneConfig{}, errors.Newf("%s: expected a bool, found %T", flatGlobalReads, v)
+		}
+		c.GlobalReads = proto.Bool(b)
+	}
+	for _, f := range []struct {
+		key string
+		set func(string) error
+	}{
+		{flatSurvivalGoal, func(s string) error {
+			g, err := parseSurvivalGoal(s)
+			if err != nil {
+				return err
+			}
+			c.SurvivalGoal = &g
+			return nil
+		}},
+		{flatPrimaryRegion, func(s string) error { c.PrimaryRegion = proto.String(s); return nil }},
+		{flatSecondaryRegion, func(s string) error { c.SecondaryRegion = proto.String(s); return nil }},
+	} {
+		v, ok := attrs[f.key]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return ZoneConfig{}, errors.Newf("%s: expected a string, found %T", f.key, v)
+		}
+		if err := f.set(s); err != nil {
+			return ZoneConfig{}, errors.Wrap(err, f.key)
+		}
+	}
+	if v, ok := attrs[flatConstraints]; ok {
+		l, err := unmarshalFlatConstraints(v)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, flatConstraints)
+		}
+		c.Constraints, c.InheritedConstraints = l.Constraints, false
+	}
+	if v, ok := attrs[flatVoterConstraints]; ok {
+		l, err := unmarshalFlatConstraints(v)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, flatVoterConstraints)
+		}
+		c.VoterConstraints, c.NullVoterConstraintsIsEmpty = l.Constraints, true
+	}
+	if v, ok := attrs[flatLeasePreferences]; ok {
+		prefs, err := unmarshalFlatLeasePreferences(v)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, flatLeasePreferences)
+		}
+		c.LeasePreferences, c.InheritedLeasePreferences = prefs, false
+	}
+	return c, nil
+}
+
+// flatInt converts a number of an attribute to an int64.
+func flatInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, errors.Newf("expected an integer, found %v", n)
+		}
+		return int64(n), nil
+	default:
+		return 0, errors.Newf("expected an integer, found %T", v)
+	}
+}
+
+func unmarshalFlatConstraints(v interface{}) (ConstraintsList, error) {
+	s, ok := v.(string)
+	if !ok {
+		return ConstraintsList{}, errors.Newf("expected a string, found %T", v)
+	}
+	if err := CheckYAMLAliases([]byte(s)); err != nil {
+		return ConstraintsList{}, err
+	}
+	var l ConstraintsList
+	if err := yaml.Unmarshal([]byte(s), &l); err != nil {
+		return ConstraintsList{}, err
+	}
+	// The constraints of an empty document are left unset.
+	if l.Constraints == nil {
+		return ConstraintsList{}, errors.Newf("invalid constraints %q", s)
+	}
+	return l, nil
+}
+
+func unmarshalFlatLeasePreferences(v interface{}) ([]LeasePreference, error) {
+	var strs []string
+	switch l := v.(type) {
+	case []string:
+		strs = l
+	case []interface{}:
+		strs = make([]string, len(l))
+		for i := range l {
+			s, ok := l[i].(string)
+			if !ok {
+				return nil, errors.Newf("expected a list of strings, found %T", l[i])
+			}
+			strs[i] = s
+		}
+	default:
+		return nil, errors.Newf("expected a list of strings, found %T", v)
+	}
+	if len(strs) > maxYAMLLeasePreferences {
+		return nil, errors.Newf("at most %d lease preferences are allowed, found %d",
+			maxYAMLLeasePreferences, len(strs))
+	}
+	prefs := make([]LeasePreference, len(strs))
+	for i, s := range strs {
+		p, err := ParseLeasePreference(s)
+		if err != nil {
+			return nil, err
+		}
+		prefs[i] = p
+	}
+	return prefs, nil
+}
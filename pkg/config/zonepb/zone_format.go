@@ -0,0 +1,162 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatStyle is the rendering of a zone config by ZoneConfig.Format.
+type FormatStyle int
+
+const (
+	// FormatCompact renders the fields which are set on the zone config on a
+	// single line, in the YAML flow notation, e.g. for log and error messages:
+	//
+	//	{gc: {ttlseconds: 600}, num_replicas: 5}
+	FormatCompact FormatStyle = iota
+	// FormatYAML renders the YAML of the zone config, as accepted by ALTER ...
+	// CONFIGURE ZONE and shown by SHOW ZONE CONFIGURATION.
+	FormatYAML
+	// FormatVerbose renders the YAML of the zone config once it's hydrated from
+	// its parent (see FormatOptions), in which each inherited field is
+	// annotated with the parent it's inherited from:
+	//
+	//	range_min_bytes: 134217728 # inherited from default
+	//	...
+	//	num_replicas: 5
+	FormatVerbose
+)
+
+// FormatOptions are the options of ZoneConfig.Format.
+type FormatOptions struct {
+	Style FormatStyle
+	// Parent is the zone config which the zone config is hydrated from in
+	// FormatVerbose, which should itself be fully hydrated. It defaults to
+	// DefaultZoneConfig().
+	Parent *ZoneConfig
+	// ParentName names the parent in the annotations of FormatVerbose, e.g.
+	// "RANGE default". It defaults to "default".
+	ParentName string
+}
+
+// formatFields lists the fields of zone configs which may be inherited, along
+// with their key in YAML, their name as in ALTER ... CONFIGURE ZONE, and
+// whether they're set on a zone config.
+var formatFields = []struct {
+	key   string
+	name  string
+	isSet func(z *ZoneConfig) bool
+}{
+	{"range_min_bytes", "range_min_bytes", func(z *ZoneConfig) bool { return z.RangeMinBytes != nil }},
+	{"range_max_bytes", "range_max_bytes", func(z *ZoneConfig) bool { return z.RangeMaxBytes != nil }},
+	{"gc", "gc.ttlseconds", func(z *ZoneConfig) bool { return z.GC != nil }},
+	{"global_reads", "global_reads", func(z *ZoneConfig) bool { return z.GlobalReads != nil }},
+	{"survival_goal", "survival_goal", func(z *ZoneConfig) bool { return z.SurvivalGoal != nil }},
+	{"primary_region", "primary_region", func(z *ZoneConfig) bool { return z.PrimaryRegion != nil }},
+	{"secondary_region", "secondary_region", func(z *ZoneConfig) bool { return z.SecondaryRegion != nil }},
+	{"num_replicas", "num_replicas", func(z *ZoneConfig) bool { return z.NumReplicas != nil && *z.NumReplicas != 0 }},
+	{"num_voters", "num_voters", func(z *ZoneConfig) bool { return z.NumVoters != nil && *z.NumVoters != 0 }},
+	{"constraints", "constraints", func(z *ZoneConfig) bool { return !z.InheritedConstraints }},
+	{"voter_constraints", "voter_constraints", func(z *ZoneConfig) bool { return !z.InheritedVoterConstraints() }},
+	{"lease_preferences", "lease_preferences", func(z *ZoneConfig) bool { return !z.InheritedLeasePreferences }},
+}
+
+// Format renders the zone config in the given style. The subzones of the zone
+// config aren't rendered. If the zone config can't be rendered, which should
+// never happen, its protobuf text format is returned instead.
+func (c ZoneConfig) Format(opts FormatOptions) string {
+	c.Subzones, c.SubzoneSpans = nil, nil
+	var out []byte
+	var err error
+	switch opts.Style {
+	case FormatCompact:
+		out, err = c.formatCompact()
+	case FormatVerbose:
+		out, err = c.formatVerbose(opts)
+	default:
+		out, err = MarshalYAML(c)
+	}
+	if err != nil {
+		return c.MarshalProtoText()
+	}
+	return string(out)
+}
+
+func (c ZoneConfig) formatCompact() ([]byte, error) {
+	set, err := c.setFieldsYAMLNode()
+	if err != nil {
+		return nil, err
+	}
+	return MarshalYAMLFlow(set)
+}
+
+// setFieldsYAMLNode returns the YAML mapping of the fields which are set on
+// the zone config.
+func (c ZoneConfig) setFieldsYAMLNode() (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := doc.Encode(c); err != nil {
+		return nil, err
+	}
+	set := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, f := range formatFields {
+		if !f.isSet(&c) {
+			continue
+		}
+		if i := yamlMappingIndex(&doc, f.key); i >= 0 {
+			set.Content = append(set.Content, doc.Content[i], doc.Content[i+1])
+		}
+	}
+	return set, nil
+}
+
+// MarshalYAMLFlow returns the YAML encoding of v in the flow notation, on a
+// single line, e.g. [+region=us-east1] for the constraints of a zone config.
+// Long strings aren't wrapped.
+func MarshalYAMLFlow(v interface{}) ([]byte, error) {
+	n, ok := v.(*yaml.Node)
+	if !ok {
+		n = &yaml.Node{}
+		if err := n.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		n.Style |= yaml.FlowStyle
+	}
+	out, err := yaml.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+func (c ZoneConfig) formatVerbose(opts FormatOptions) ([]byte, error) {
+	parent := opts.Parent
+	if parent == nil {
+		parent = DefaultZoneConfigRef()
+	}
+	parentName := opts.ParentName
+	if parentName == "" {
+		parentName = "default"
+	}
+	inheritedFrom := make(map[string]string)
+	for _, f := range formatFields {
+		if !f.isSet(&c) && f.isSet(parent) {
+			inheritedFrom[f.name] = parentName
+		}
+	}
+	hydrated := c
+	hydrated.InheritFromParent(parent)
+	return MarshalYAMLWithInheritedFields(hydrated, inheritedFrom)
+}
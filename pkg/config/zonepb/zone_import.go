@@ -0,0 +1,160 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Columns of the placement tables read by ParsePlacementTable.
+const (
+	placementTableColumn       = "table"
+	placementRegionColumn      = "region"
+	placementReplicasColumn    = "replicas"
+	placementLeaseRegionColumn = "lease_region"
+)
+
+// TableZoneConfig is a zone config for the table with the given name.
+type TableZoneConfig struct {
+	Table string
+	Zone  ZoneConfig
+}
+
+// ParsePlacementTable converts a placement table, as maintained in a
+// spreadsheet, into per-table zone configs. The table is read as CSV using the
+// supplied delimiter (e.g. ',' or '\t'). Its first row is a header naming the
+// columns, which may be in any order:
+//
+//   - table: the name of the table.
+//   - region: a region the table has replicas in.
+//   - replicas: the number of replicas of the table in that region.
+//   - lease_region: optionally, the region the table's leaseholders should be
+//     placed in. All of a table's rows which specify it must agree.
+//
+// Each table gets a zone config with num_replicas set to the total number of
+// replicas across its rows and one per-replica constraint per region. The
+// remaining fields are inherited. Tables are returned in the order they first
+// appear in.
+func ParsePlacementTable(r io.Reader, delimiter rune) ([]TableZoneConfig, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("placement table is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{placementLeaseRegionColumn: -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case placementTableColumn, placementRegionColumn, placementReplicasColumn,
+			placementLeaseRegionColumn:
+		default:
+			return nil, errors.Newf("unknown placement table column %q", name)
+		}
+		if j, ok := columns[name]; ok && j >= 0 {
+			return nil, errors.Newf("duplicate placement table column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{placementTableColumn, placementRegionColumn, placementReplicasColumn} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Newf("placement table is missing the %q column", name)
+		}
+	}
+
+	type tablePlacement struct {
+		regions     map[string]struct{}
+		leaseRegion string
+	}
+	var res []TableZoneConfig
+	placements := make(map[string]*tablePlacement)
+	indexes := make(map[string]int)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i := columns[name]; i >= 0 {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		table, region := field(placementTableColumn), field(placementRegionColumn)
+		if table == "" || region == "" {
+			return nil, errors.Newf("line %d: table and region must be set", line)
+		}
+		replicas, err := strconv.ParseInt(field(placementReplicasColumn), 10, 32)
+		if err != nil || replicas <= 0 {
+			return nil, errors.Newf("line %d: invalid number of replicas %q", line,
+				field(placementReplicasColumn))
+		}
+
+		p, ok := placements[table]
+		if !ok {
+			p = &tablePlacement{regions: make(map[string]struct{})}
+			placements[table] = p
+			indexes[table] = len(res)
+			zone := NewZoneConfig()
+			zone.NumReplicas = proto.Int32(0)
+			zone.InheritedConstraints = false
+			zone.Constraints = []ConstraintsConjunction{}
+			res = append(res, TableZoneConfig{Table: table, Zone: *zone})
+		}
+		if _, ok := p.regions[region]; ok {
+			return nil, errors.Newf("line %d: duplicate region %q for table %q", line, region, table)
+		}
+		p.regions[region] = struct{}{}
+		if leaseRegion := field(placementLeaseRegionColumn); leaseRegion != "" {
+			if p.leaseRegion != "" && p.leaseRegion != leaseRegion {
+				return nil, errors.Newf("line %d: conflicting lease regions %q and %q for table %q",
+					line, p.leaseRegion, leaseRegion, table)
+			}
+			p.leaseRegion = leaseRegion
+		}
+
+		zone := &res[indexes[table]].Zone
+		*zone.NumReplicas += int32(replicas)
+		zone.Constraints = append(zone.Constraints, ConstraintsConjunction{
+			NumReplicas: int32(replicas),
+			Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: regionTierKey, Value: region}},
+		})
+	}
+
+	for i := range res {
+		p := placements[res[i].Table]
+		if p.leaseRegion == "" {
+			continue
+		}
+		if _, ok := p.regions[p.leaseRegion]; !ok {
+			return nil, errors.Newf("lease region %q of table %q is not one of its regions",
+				p.leaseRegion, res[i].Table)
+		}
+		res[i].Zone.InheritedLeasePreferences = false
+		res[i].Zone.LeasePreferences = []LeasePreference{{Constraints: []Constraint{
+			{Type: Constraint_REQUIRED, Key: regionTierKey, Value: p.leaseRegion},
+		}}}
+	}
+	return res, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// InferConstraints proposes the tightest constraints which are satisfied by
+// every one of the supplied placements, where each placement lists the
+// localities of one range's replicas. It's meant to help operators codify an
+// existing, de facto placement (e.g. the ranges of a table) into an explicit
+// zone config.
+//
+// Locality tiers shared by every replica become constraints which apply to
+// all replicas. Below those, a locality tier becomes a per-replica constraint
+// for as many replicas as every placement has in it, and those replicas are
+// in turn constrained to the tier's child tiers wherever the placements agree.
+// The returned list is empty if the placements have nothing in common.
+func InferConstraints(placements [][]roachpb.Locality) ConstraintsList {
+	empty := ConstraintsList{Constraints: []ConstraintsConjunction{}}
+	if len(placements) == 0 {
+		return empty
+	}
+	minReplicas := -1
+	for _, placement := range placements {
+		if len(placement) == 0 {
+			return empty
+		}
+		if minReplicas == -1 || len(placement) < minReplicas {
+			minReplicas = len(placement)
+		}
+	}
+
+	common := placements[0][0].Tiers
+	for _, placement := range placements {
+		for _, locality := range placement {
+			common = common[:commonTierPrefixLen(common, locality.Tiers)]
+		}
+	}
+
+	var conjunctions []ConstraintsConjunction
+	var refine func(prefix []roachpb.Tier, numReplicas int)
+	refine = func(prefix []roachpb.Tier, numReplicas int) {
+		children := childTiers(placements, prefix)
+		var sum int
+		counts := make([]int, len(children))
+		for i, child := range children {
+			counts[i] = minReplicasWithPrefix(placements, child)
+			sum += counts[i]
+		}
+		// Replicas which every placement has in this tier but which can't be
+		// pinned down to a particular child tier are constrained to this tier.
+		// At the root, with no common tiers, they're left unconstrained.
+		if rem := numReplicas - sum; rem > 0 && (len(prefix) > 0 || sum == 0) {
+			conjunctions = append(conjunctions, ConstraintsConjunction{
+				NumReplicas: int32(rem),
+				Constraints: tiersToConstraints(prefix),
+			})
+		}
+		for i, child := range children {
+			if counts[i] > 0 {
+				refine(child, counts[i])
+			}
+		}
+	}
+	refine(common, minReplicas)
+
+	// If no tier below the common ones is shared by all placements, constrain
+	// all replicas to the common tiers rather than a subset of them.
+	if len(conjunctions) == 1 && len(conjunctions[0].Constraints) == len(common) {
+		if len(common) == 0 {
+			return empty
+		}
+		conjunctions[0].NumReplicas = 0
+	}
+	return ConstraintsList{Constraints: conjunctions}
+}
+
+// SuggestConstraints proposes constraints which meet the survival goal on a
+// cluster whose nodes have the supplied localities. It's meant to give
+// operators a reasonable starting point for the zone config of a new
+// cluster, in the spirit of InferConstraints for existing placements.
+//
+// Nodes are grouped by their region tier. For REGION_FAILURE, one replica is
+// constrained to each of the regions, of which there must be at least
+// MinRegionsForRegionSurvival. For ZONE_FAILURE, all replicas are constrained
+// to the region with the most distinct localities, which must have at least
+// three so that a quorum survives the loss of any of them, keeping writes
+// within a single region. The returned list is empty if the topology can't
+// meet the goal, or if nodes have no region tier.
+//
+// Lease preferences for the suggested constraints can be obtained from the
+// LintMultiRegionLeasePreferences rule of LintZoneConfig.
+func SuggestConstraints(localities []roachpb.Locality, goal SurvivalGoal) ConstraintsList {
+	empty := ConstraintsList{Constraints: []ConstraintsConjunction{}}
+	domains := make(map[string]map[string]struct{})
+	var regions []string
+	for _, locality := range localities {
+		region, ok := locality.Find(regionTierKey)
+		if !ok {
+			continue
+		}
+		if _, ok := domains[region]; !ok {
+			domains[region] = make(map[string]struct{})
+			regions = append(regions, region)
+		}
+		domains[region][locality.String()] = struct{}{}
+	}
+	sort.Strings(regions)
+
+	regionConstraint := func(region string) []Constraint {
+		return []Constraint{{Type: Constraint_REQUIRED, Key: regionTierKey, Value: region}}
+	}
+	switch goal {
+	case SurvivalGoal_REGION_FAILURE:
+		if len(regions) < MinRegionsForRegionSurvival {
+			return empty
+		}
+		conjunctions := make([]ConstraintsConjunction, len(regions))
+		for i, region := range regions {
+			conjunctions[i] = ConstraintsConjunction{NumReplicas: 1, Constraints: regionConstraint(region)}
+		}
+		return ConstraintsList{Constraints: conjunctions}
+	case SurvivalGoal_ZONE_FAILURE:
+		var best string
+		for _, region := range regions {
+			if len(domains[region]) > len(domains[best]) {
+				best = region
+			}
+		}
+		if len(domains[best]) < 3 {
+			return empty
+		}
+		return ConstraintsList{Constraints: []ConstraintsConjunction{{Constraints: regionConstraint(best)}}}
+	default:
+		return empty
+	}
+}
+
+// commonTierPrefixLen returns the number of leading tiers a and b share.
+func commonTierPrefixLen(a, b []roachpb.Tier) int {
+	var i int
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// hasTierPrefix returns whether the first tiers of locality match prefix.
+func hasTierPrefix(locality roachpb.Locality, prefix []roachpb.Tier) bool {
+	return len(locality.Tiers) >= len(prefix) &&
+		commonTierPrefixLen(locality.Tiers, prefix) == len(prefix)
+}
+
+// minReplicasWithPrefix returns the smallest number of replicas any of the
+// placements has with localities starting with prefix.
+func minReplicasWithPrefix(placements [][]roachpb.Locality, prefix []roachpb.Tier) int {
+	min := -1
+	for _, placement := range placements {
+		var n int
+		for _, locality := range placement {
+			if hasTierPrefix(locality, prefix) {
+				n++
+			}
+		}
+		if min == -1 || n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+// childTiers returns the distinct extensions of prefix by one tier found in
+// the placements' localities, in sorted order.
+func childTiers(placements [][]roachpb.Locality, prefix []roachpb.Tier) [][]roachpb.Tier {
+	seen := make(map[roachpb.Tier]struct{})
+	var children [][]roachpb.Tier
+	for _, placement := range placements {
+		for _, locality := range placement {
+			if len(locality.Tiers) <= len(prefix) || !hasTierPrefix(locality, prefix) {
+				continue
+			}
+			tier := locality.Tiers[len(prefix)]
+			if _, ok := seen[tier]; ok {
+				continue
+			}
+			seen[tier] = struct{}{}
+			child := append(append([]roachpb.Tier(nil), prefix...), tier)
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i][len(prefix)], children[j][len(prefix)]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Value < b.Value
+	})
+	return children
+}
+
+// tiersToConstraints returns required constraints matching each of the tiers.
+func tiersToConstraints(tiers []roachpb.Tier) []Constraint {
+	constraints := make([]Constraint, len(tiers))
+	for i, tier := range tiers {
+		constraints[i] = Constraint{Type: Constraint_REQUIRED, Key: tier.Key, Value: tier.Value}
+	}
+	return constraints
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import "github.com/cockroachdb/cockroach/pkg/util/syncutil"
+
+// maxInternedConstraintStrings bounds the number of strings in
+// constraintStrings. Constraints refer to a handful of locality tiers and
+// attributes, so the bound is only reached if constraints are generated, in
+// which case the strings beyond it are simply not interned.
+const maxInternedConstraintStrings = 1 << 14
+
+// constraintStrings is the process-wide pool of the keys and values of
+// constraints, e.g. "region" and "us-east1", which are otherwise duplicated
+// across the zone configs of every table of a large cluster.
+var constraintStrings struct {
+	syncutil.Mutex
+	m map[string]string
+}
+
+// internConstraintString returns the pooled string equal to s, adding s to the
+// pool if there is none.
+func internConstraintString(s string) string {
+	if s == "" {
+		return s
+	}
+	constraintStrings.Lock()
+	defer constraintStrings.Unlock()
+	if interned, ok := constraintStrings.m[s]; ok {
+		return interned
+	}
+	if len(constraintStrings.m) >= maxInternedConstraintStrings {
+		return s
+	}
+	if constraintStrings.m == nil {
+		constraintStrings.m = map[string]string{}
+	}
+	constraintStrings.m[s] = s
+	return s
+}
+
+// internConstraints returns a copy of the constraints whose keys and values
+// are interned.
+func internConstraints(constraints []Constraint) []Constraint {
+	if constraints == nil {
+		return nil
+	}
+	res := make([]Constraint, len(constraints))
+	for i, c := range constraints {
+		c.Key = internConstraintString(c.Key)
+		c.Value = internConstraintString(c.Value)
+		res[i] = c
+	}
+	return res
+}
+
+// internConjunctions is like internConstraints, for lists of conjunctions.
+func internConjunctions(conjunctions []ConstraintsConjunction) []ConstraintsConjunction {
+	if conjunctions == nil {
+		return nil
+	}
+	res := make([]ConstraintsConjunction, len(conjunctions))
+	for i, c := range conjunctions {
+		c.Constraints = internConstraints(c.Constraints)
+		res[i] = c
+	}
+	return res
+}
+
+// InternConstraintStrings makes the keys and values of the constraints and
+// lease preferences of the zone config, including those of its subzones, share
+// their backing strings with those of every other zone config interned this
+// way. It's meant to be called on zone configs which are decoded to be
+// retained, e.g. by the zone config cache of the SystemConfig. The lists of
+// the zone config are replaced rather than modified in place, so they may be
+// shared with other zone configs, but the zone config itself must not be
+// accessed concurrently. Constraints parsed by Constraint.FromString
+// are interned already.
+func (z *ZoneConfig) InternConstraintStrings() {
+	z.Constraints = internConjunctions(z.Constraints)
+	z.VoterConstraints = internConjunctions(z.VoterConstraints)
+	if z.LeasePreferences != nil {
+		prefs := make([]LeasePreference, len(z.LeasePreferences))
+		for i, p := range z.LeasePreferences {
+			prefs[i] = LeasePreference{Constraints: internConstraints(p.Constraints)}
+		}
+		z.LeasePreferences = prefs
+	}
+	if z.Subzones != nil {
+		subzones := make([]Subzone, len(z.Subzones))
+		copy(subzones, z.Subzones)
+		for i := range subzones {
+			subzones[i].Config.InternConstraintStrings()
+		}
+		z.Subzones = subzones
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// LintRule identifies a check performed by LintZoneConfig.
+type LintRule string
+
+const (
+	// LintEvenReplicaCount flags zones with an even number of voters, which
+	// tolerate no more failures than one voter fewer would.
+	LintEvenReplicaCount LintRule = "even_replica_count"
+	// LintShortGCTTL flags zones whose GC TTL is shorter than the interval of
+	// hourly incremental backups, which are then unable to capture revision
+	// history.
+	LintShortGCTTL LintRule = "short_gc_ttl"
+	// LintContradictoryLeasePreferences flags lease preferences which can't be
+	// satisfied by any replica because of the zone's constraints.
+	LintContradictoryLeasePreferences LintRule = "contradictory_lease_preferences"
+	// LintUnsatisfiableLeasePreferences flags lease preferences which no voter
+	// can satisfy given the zone's per-replica constraints and voter
+	// constraints, e.g. a preference for a region other than the ones all
+	// replicas are constrained to. Such preferences have no effect.
+	LintUnsatisfiableLeasePreferences LintRule = "unsatisfiable_lease_preferences"
+	// LintSubzoneShortGCTTL flags subzones whose GC TTL is shorter than the one
+	// they would otherwise inherit, so that the data of an index or partition is
+	// garbage collected before the data of the rest of its table.
+	LintSubzoneShortGCTTL LintRule = "subzone_short_gc_ttl"
+	// LintStoreConstraints flags constraints and lease preferences which refer
+	// to a store by its ID. They're brittle: they don't carry over to the store
+	// which replaces a decommissioned one, and are easily forgotten once the
+	// incident they were added for is over.
+	LintStoreConstraints LintRule = "store_constraints"
+	// LintMultiRegionLeasePreferences flags zones whose constraints place
+	// replicas in more than one region but which have no lease preferences, so
+	// leaseholders can land in any of those regions. This rule is opt-in.
+	LintMultiRegionLeasePreferences LintRule = "multi_region_lease_preferences"
+)
+
+// regionTierKey is the locality tier key used to name regions.
+const regionTierKey = "region"
+
+// LintFinding is a warning-level observation about a zone config. Unlike the
+// errors returned by Validate, findings don't prevent a config from being
+// applied.
+type LintFinding struct {
+	// Rule is the rule which produced the finding.
+	Rule LintRule
+	// Message is a human-readable description of the finding.
+	Message string
+	// SuggestedLeasePreferences, if set, are lease preferences which would
+	// address the finding.
+	SuggestedLeasePreferences []LeasePreference
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Rule, f.Message)
+}
+
+// minBackupGCTTL is the minimum GC TTL which is compatible with hourly
+// incremental backups, the most frequent cadence commonly scheduled.
+const minBackupGCTTL = time.Hour
+
+// defaultLintRules contains the rules which are always run by LintZoneConfig.
+var defaultLintRules = []func(zone *ZoneConfig) []LintFinding{
+	lintEvenReplicaCount,
+	lintShortGCTTL,
+	lintContradictoryLeasePreferences,
+	lintUnsatisfiableLeasePreferences,
+	lintSubzoneShortGCTTL,
+	lintStoreConstraints,
+}
+
+// optInLintRules contains the rules which are only run by LintZoneConfig when
+// explicitly requested.
+var optInLintRules = map[LintRule]func(zone *ZoneConfig) []LintFinding{
+	LintMultiRegionLeasePreferences: lintMultiRegionLeasePreferences,
+}
+
+// LintZoneConfig returns warning-level findings about the supplied zone
+// config, which is expected to have been hydrated from its parents. Rules
+// which aren't run by default can be enabled through optIn.
+//
+// Checks which depend on the stores of the cluster, such as whether there are
+// enough stores in a region to satisfy its constraints, are performed by
+// PlanZoneChange instead.
+func LintZoneConfig(zone ZoneConfig, optIn ...LintRule) []LintFinding {
+	var findings []LintFinding
+	for _, fn := range defaultLintRules {
+		findings = append(findings, fn(&zone)...)
+	}
+	for _, rule := range optIn {
+		if fn, ok := optInLintRules[rule]; ok {
+			findings = append(findings, fn(&zone)...)
+		}
+	}
+	return findings
+}
+
+// lintEvenReplicaCount implements LintEvenReplicaCount. The number of voters
+// is num_voters if it's set and num_replicas otherwise. Subzone placeholders
+// are ignored.
+func lintEvenReplicaCount(zone *ZoneConfig) []LintFinding {
+	field, voters := "num_replicas", int32Value(zone.NumReplicas)
+	if zone.NumVoters != nil && *zone.NumVoters > 0 {
+		field, voters = "num_voters", *zone.NumVoters
+	}
+	if voters == 0 || voters%2 != 0 {
+		return nil
+	}
+	return []LintFinding{{
+		Rule: LintEvenReplicaCount,
+		Message: fmt.Sprintf("%s is %d, which tolerates no more failures than %d voters would",
+			field, voters, voters-1),
+	}}
+}
+
+// lintShortGCTTL implements LintShortGCTTL.
+func lintShortGCTTL(zone *ZoneConfig) []LintFinding {
+	if zone.GC == nil {
+		return nil
+	}
+	ttl := time.Duration(zone.GC.TTLSeconds) * time.Second
+	if ttl >= minBackupGCTTL {
+		return nil
+	}
+	return []LintFinding{{
+		Rule: LintShortGCTTL,
+		Message: fmt.Sprintf("gc.ttlseconds is %d, which is shorter than the %s interval of "+
+			"hourly incremental backups with revision history", zone.GC.TTLSeconds, minBackupGCTTL),
+	}}
+}
+
+// lintSubzoneShortGCTTL implements LintSubzoneShortGCTTL. Only subzones
+// which set their own GC policy are considered, since an unset policy is
+// inherited. The parent of a partition is the subzone of its index if that
+// sets a GC policy, and the zone otherwise.
+func lintSubzoneShortGCTTL(zone *ZoneConfig) []LintFinding {
+	var findings []LintFinding
+	for i := range zone.Subzones {
+		s := &zone.Subzones[i]
+		if s.Config.GC == nil {
+			continue
+		}
+		parent, parentName := zone.GC, "zone"
+		if s.PartitionName != "" {
+			if index, ok := zone.GetSubzoneForIndexPartition(s.IndexID, ""); ok && index.Config.GC != nil {
+				parent, parentName = index.Config.GC, fmt.Sprintf("index %d", s.IndexID)
+			}
+		}
+		if parent == nil || s.Config.GC.TTLSeconds >= parent.TTLSeconds {
+			continue
+		}
+		name := fmt.Sprintf("index %d", s.IndexID)
+		if s.PartitionName != "" {
+			name = fmt.Sprintf("partition %s of index %d", s.PartitionName, s.IndexID)
+		}
+		findings = append(findings, LintFinding{
+			Rule: LintSubzoneShortGCTTL,
+			Message: fmt.Sprintf("gc.ttlseconds of %s is %d, which is shorter than the %d of its %s",
+				name, s.Config.GC.TTLSeconds, parent.TTLSeconds, parentName),
+		})
+	}
+	return findings
+}
+
+// lintStoreConstraints implements LintStoreConstraints. Each distinct store
+// constraint is reported once.
+func lintStoreConstraints(zone *ZoneConfig) []LintFinding {
+	var findings []LintFinding
+	seen := make(map[Constraint]struct{})
+	check := func(constraints []Constraint) {
+		for _, c := range constraints {
+			if _, ok := seen[c]; ok || c.Kind() != ConstraintKindStore {
+				continue
+			}
+			seen[c] = struct{}{}
+			findings = append(findings, LintFinding{
+				Rule: LintStoreConstraints,
+				Message: fmt.Sprintf("constraint %s refers to a store by its ID, which doesn't carry over "+
+					"to the store replacing it; remove it once it's no longer needed", c),
+			})
+		}
+	}
+	for _, conjunction := range zone.Constraints {
+		check(conjunction.Constraints)
+	}
+	for _, conjunction := range zone.VoterConstraints {
+		check(conjunction.Constraints)
+	}
+	for _, pref := range zone.LeasePreferences {
+		check(pref.Constraints)
+	}
+	return findings
+}
+
+// lintContradictoryLeasePreferences implements
+// LintContradictoryLeasePreferences. A lease preference is contradictory if
+// one of its constraints conflicts with a constraint which applies to every
+// replica.
+func lintContradictoryLeasePreferences(zone *ZoneConfig) []LintFinding {
+	var all []Constraint
+	for _, conjunction := range zone.Constraints {
+		if conjunction.NumReplicas == 0 {
+			all = append(all, conjunction.Constraints...)
+		}
+	}
+	var findings []LintFinding
+	for _, pref := range zone.LeasePreferences {
+		for _, c := range pref.Constraints {
+			for _, other := range all {
+				if !constraintsConflict(c, other) {
+					continue
+				}
+				findings = append(findings, LintFinding{
+					Rule: LintContradictoryLeasePreferences,
+					Message: fmt.Sprintf("lease preference %s conflicts with constraint %s, "+
+						"which applies to every replica", c, other),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// lintUnsatisfiableLeasePreferences implements
+// LintUnsatisfiableLeasePreferences. Leases are held by voters, each of which
+// is subject to the constraints and voter constraints which apply to every
+// replica, and, when the per-replica constraints or voter constraints account
+// for all the replicas or voters, to one of their conjunctions. A lease
+// preference is unsatisfiable if it conflicts with every such combination of
+// constraints. Preferences which conflict with a constraint applying to every
+// replica are flagged by LintContradictoryLeasePreferences instead.
+func lintUnsatisfiableLeasePreferences(zone *ZoneConfig) []LintFinding {
+	if len(zone.LeasePreferences) == 0 {
+		return nil
+	}
+	replicas := int32Value(zone.NumReplicas)
+	voters := replicas
+	if zone.NumVoters != nil && *zone.NumVoters > 0 {
+		voters = *zone.NumVoters
+	}
+	// split returns the constraints applying to every replica, and the
+	// per-replica conjunctions if they account for all n replicas. A nil
+	// conjunction stands for the replicas which are otherwise unconstrained.
+	split := func(conjunctions []ConstraintsConjunction, n int32) ([]Constraint, []*ConstraintsConjunction) {
+		var all []Constraint
+		var perReplica []*ConstraintsConjunction
+		var constrained int32
+		for i := range conjunctions {
+			if conjunctions[i].NumReplicas == 0 {
+				all = append(all, conjunctions[i].Constraints...)
+				continue
+			}
+			perReplica = append(perReplica, &conjunctions[i])
+			constrained += conjunctions[i].NumReplicas
+		}
+		if n == 0 || constrained < n {
+			perReplica = append(perReplica, nil)
+		}
+		return all, perReplica
+	}
+	allReplicas, replicaConjunctions := split(zone.Constraints, replicas)
+	allVoters, voterConjunctions := split(zone.VoterConstraints, voters)
+
+	conflicts := func(a, b []Constraint) bool {
+		for _, c := range a {
+			for _, other := range b {
+				if constraintsConflict(c, other) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	constraintsOf := func(c *ConstraintsConjunction) []Constraint {
+		if c == nil {
+			return nil
+		}
+		return c.Constraints
+	}
+
+	var findings []LintFinding
+	for _, pref := range zone.LeasePreferences {
+		if conflicts(pref.Constraints, allReplicas) {
+			continue
+		}
+		if conflicts(pref.Constraints, allVoters) {
+			findings = append(findings, LintFinding{
+				Rule: LintUnsatisfiableLeasePreferences,
+				Message: fmt.Sprintf("lease preference %s conflicts with the voter constraints, "+
+					"which apply to every voter", pref),
+			})
+			continue
+		}
+		satisfiable := false
+		for _, rc := range replicaConjunctions {
+			for _, vc := range voterConjunctions {
+				// A voter can't be subject to conflicting conjunctions.
+				if conflicts(constraintsOf(rc), constraintsOf(vc)) {
+					continue
+				}
+				if !conflicts(pref.Constraints, constraintsOf(rc)) &&
+					!conflicts(pref.Constraints, constraintsOf(vc)) {
+					satisfiable = true
+				}
+			}
+		}
+		if satisfiable {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Rule: LintUnsatisfiableLeasePreferences,
+			Message: fmt.Sprintf("lease preference %s can't be satisfied by any voter "+
+				"given the per-replica constraints, so it has no effect", pref),
+		})
+	}
+	return findings
+}
+
+// constraintsConflict returns whether no store can satisfy both constraints.
+func constraintsConflict(a, b Constraint) bool {
+	switch {
+	case a.Type == Constraint_REQUIRED && b.Type == Constraint_REQUIRED:
+		// A store has a single ID.
+		if a.Kind() == ConstraintKindStore && b.Kind() == ConstraintKindStore {
+			aID, aOK := a.StoreID()
+			bID, bOK := b.StoreID()
+			return aOK && bOK && aID != bID
+		}
+		// A locality tier has a single value.
+		if a.Kind() != ConstraintKindLocality || a.Key != b.Key {
+			return false
+		}
+		if a.hasWildcard() && b.hasWildcard() {
+			// The patterns may have values in common.
+			return false
+		}
+		return !a.Matches(roachpb.Tier{Key: b.Key, Value: b.Value}) &&
+			!b.Matches(roachpb.Tier{Key: a.Key, Value: a.Value})
+	case a.Type == Constraint_REQUIRED && b.Type == Constraint_PROHIBITED:
+		return excludes(b, a)
+	case a.Type == Constraint_PROHIBITED && b.Type == Constraint_REQUIRED:
+		return excludes(a, b)
+	default:
+		return false
+	}
+}
+
+// lintMultiRegionLeasePreferences implements LintMultiRegionLeasePreferences.
+// The suggested lease preferences list every constrained region, starting
+// with the ones constrained to hold the most voters and then the most
+// replicas.
+func lintMultiRegionLeasePreferences(zone *ZoneConfig) []LintFinding {
+	if len(zone.LeasePreferences) > 0 {
+		return nil
+	}
+	type regionReplicas struct {
+		voters, replicas int32
+	}
+	replicas := int32Value(zone.NumReplicas)
+	voters := replicas
+	if zone.NumVoters != nil && *zone.NumVoters > 0 {
+		voters = *zone.NumVoters
+	}
+	regions := make(map[string]*regionReplicas)
+	// count adds the replicas of each conjunction to the regions it requires.
+	// A conjunction which applies to every replica accounts for all n of them.
+	count := func(conjunctions []ConstraintsConjunction, n int32, get func(*regionReplicas) *int32) {
+		for _, conjunction := range conjunctions {
+			numReplicas := conjunction.NumReplicas
+			if conjunction.NumReplicas == 0 {
+				numReplicas = n
+			}
+			for _, c := range conjunction.Constraints {
+				if c.Type != Constraint_REQUIRED || c.Key != regionTierKey {
+					continue
+				}
+				r, ok := regions[c.Value]
+				if !ok {
+					r = &regionReplicas{}
+					regions[c.Value] = r
+				}
+				*get(r) += numReplicas
+			}
+		}
+	}
+	count(zone.Constraints, replicas, func(r *regionReplicas) *int32 { return &r.replicas })
+	count(zone.VoterConstraints, voters, func(r *regionReplicas) *int32 { return &r.voters })
+	if len(regions) < 2 {
+		return nil
+	}
+
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := regions[names[i]], regions[names[j]]
+		if ri.voters != rj.voters {
+			return ri.voters > rj.voters
+		}
+		if ri.replicas != rj.replicas {
+			return ri.replicas > rj.replicas
+		}
+		return names[i] < names[j]
+	})
+	suggested := make([]LeasePreference, len(names))
+	for i, name := range names {
+		suggested[i] = LeasePreference{Constraints: []Constraint{
+			{Type: Constraint_REQUIRED, Key: regionTierKey, Value: name},
+		}}
+	}
+	return []LintFinding{{
+		Rule: LintMultiRegionLeasePreferences,
+		Message: fmt.Sprintf("constraints span %d regions (%s) but no lease preferences are set; "+
+			"leaseholders may be placed in any of them", len(names), strings.Join(names, ", ")),
+		SuggestedLeasePreferences: suggested,
+	}}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// allowedLocalities holds the allowlist registered with SetAllowedLocalities.
+var allowedLocalities struct {
+	syncutil.RWMutex
+	tiers map[string][]string
+}
+
+// SetAllowedLocalities restricts the locality tiers that constraints parsed
+// from their shorthand notation, e.g. in YAML zone configs or lease
+// preferences, may refer to. The allowlist maps each allowed tier key to its
+// allowed values; a key without values allows any value. For instance, with
+//
+//	{"region": {"us-east1", "us-west1"}, "zone": nil}
+//
+// +region=us-east1 and -zone=us-east1-b are accepted, but +region=eu-west1 and
+// +rack=1 are rejected. A constraint whose value has wildcards is accepted if
+// it matches an allowed value. Constraints on store attributes, such as +ssd,
+// aren't restricted.
+//
+// An empty allowlist, which is the default, allows any locality tier.
+func SetAllowedLocalities(allowed map[string][]string) {
+	var tiers map[string][]string
+	if len(allowed) > 0 {
+		tiers = make(map[string][]string, len(allowed))
+		for key, values := range allowed {
+			tiers[key] = append([]string(nil), values...)
+		}
+	}
+	allowedLocalities.Lock()
+	defer allowedLocalities.Unlock()
+	allowedLocalities.tiers = tiers
+}
+
+// checkAllowedLocality returns an error if the constraint refers to a locality
+// tier outside of the allowlist registered with SetAllowedLocalities.
+func checkAllowedLocality(c Constraint) error {
+	if c.Kind() != ConstraintKindLocality {
+		return nil
+	}
+	allowedLocalities.RLock()
+	defer allowedLocalities.RUnlock()
+	if allowedLocalities.tiers == nil {
+		return nil
+	}
+	values, ok := allowedLocalities.tiers[c.Key]
+	if !ok {
+		return errors.Newf("constraint %q refers to locality tier key %q, which is not allowed",
+			c.String(), c.Key)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	for _, value := range values {
+		if roachpb.LocalityValueMatches(c.Value, value) {
+			return nil
+		}
+	}
+	return errors.Newf("constraint %q refers to value %q of locality tier key %q, which is not allowed",
+		c.String(), c.Value, c.Key)
+}
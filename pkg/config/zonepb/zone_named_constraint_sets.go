@@ -0,0 +1,161 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// namedConstraintSetPrefix prefixes the name of a named constraint set to
+// refer to it in YAML, e.g. constraints: "@us_east_ssd".
+const namedConstraintSetPrefix = "@"
+
+// namedConstraintSets holds the sets registered with SetNamedConstraintSets,
+// resolved to their constraints.
+var namedConstraintSets struct {
+	syncutil.RWMutex
+	sets map[string][]ConstraintsConjunction
+}
+
+// SetNamedConstraintSets replaces the named constraint sets which the
+// constraints and voter constraints of zone configs may refer to. Each set is
+// defined by constraints in the YAML format of ConstraintsList, or by a
+// reference to another set, e.g.
+//
+//	{
+//	  "us_east_ssd": "[+region=us-east1, +ssd]",
+//	  "us_spread":   "{+region=us-east1: 2, +region=us-west1: 1}",
+//	  "default":     "'@us_east_ssd'",
+//	}
+//
+// which lets zone configs refer to them by name:
+//
+//	constraints: '@us_east_ssd'
+//
+// Names consist of letters, digits and underscores. References between sets
+// are resolved when the sets are registered, and an error is returned if a
+// reference is undefined or cyclic, in which case the registered sets are left
+// unchanged. Zone configs resolve the sets they refer to when they're
+// unmarshaled, i.e. when they're written, so changing a set applies to the zone
+// configs written from then on.
+//
+// The sets are defined by the zone_configs.named_constraint_sets cluster
+// setting, and there are none by default.
+func SetNamedConstraintSets(defs map[string]string) error {
+	sets, err := resolveNamedConstraintSetDefs(defs)
+	if err != nil {
+		return err
+	}
+	namedConstraintSets.Lock()
+	defer namedConstraintSets.Unlock()
+	namedConstraintSets.sets = sets
+	return nil
+}
+
+// ValidateNamedConstraintSets returns the error which SetNamedConstraintSets
+// would return for the definitions, without registering them.
+func ValidateNamedConstraintSets(defs map[string]string) error {
+	_, err := resolveNamedConstraintSetDefs(defs)
+	return err
+}
+
+// resolveNamedConstraintSetDefs returns the constraints of each of the named
+// constraint sets, with the references between them resolved.
+func resolveNamedConstraintSetDefs(
+	defs map[string]string,
+) (map[string][]ConstraintsConjunction, error) {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		if err := checkNamedConstraintSetName(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make(map[string][]ConstraintsConjunction, len(defs))
+	var resolve func(name string, path []string) ([]ConstraintsConjunction, error)
+	resolve = func(name string, path []string) ([]ConstraintsConjunction, error) {
+		if constraints, ok := sets[name]; ok {
+			return constraints, nil
+		}
+		for i := range path {
+			if path[i] == name {
+				return nil, errors.Newf("constraint set %q refers to itself: %s",
+					name, strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+		def, ok := defs[name]
+		if !ok {
+			return nil, errors.Newf("constraint set %q is not defined", name)
+		}
+		path = append(path, name)
+
+		var constraints []ConstraintsConjunction
+		var ref string
+		if err := yaml.Unmarshal([]byte(def), &ref); err == nil &&
+			strings.HasPrefix(ref, namedConstraintSetPrefix) {
+			var err error
+			if constraints, err = resolve(strings.TrimPrefix(ref, namedConstraintSetPrefix), path); err != nil {
+				return nil, err
+			}
+		} else {
+			var l ConstraintsList
+			if err := yaml.Unmarshal([]byte(def), &l); err != nil {
+				return nil, errors.Wrapf(err, "constraint set %q", name)
+			}
+			constraints = l.Constraints
+		}
+		sets[name] = constraints
+		return constraints, nil
+	}
+	for _, name := range names {
+		if _, err := resolve(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// checkNamedConstraintSetName returns an error if the name isn't a valid name
+// for a named constraint set.
+func checkNamedConstraintSetName(name string) error {
+	if name == "" {
+		return errors.New("constraint set names must not be empty")
+	}
+	for _, ch := range name {
+		if !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && !(ch >= '0' && ch <= '9') &&
+			ch != '_' {
+			return errors.Newf("invalid constraint set name %q: "+
+				"names consist of letters, digits and underscores", name)
+		}
+	}
+	return nil
+}
+
+// lookupNamedConstraintSet returns the constraints of the named constraint
+// set registered with SetNamedConstraintSets.
+func lookupNamedConstraintSet(name string) ([]ConstraintsConjunction, error) {
+	namedConstraintSets.RLock()
+	defer namedConstraintSets.RUnlock()
+	constraints, ok := namedConstraintSets.sets[name]
+	if !ok {
+		return nil, errors.Newf("constraint set %q is not defined", name)
+	}
+	// The conjunctions are shared by the zone configs which refer to the set,
+	// which mustn't be able to modify them.
+	return append([]ConstraintsConjunction{}, constraints...), nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// IndexNameResolver resolves the indexes of the table of a zone config by ID
+// and by name, e.g. through its table descriptor.
+type IndexNameResolver interface {
+	// IndexName returns the name of the index with the given ID, or false if
+	// the table has no such index.
+	IndexName(id uint32) (string, bool)
+	// IndexID returns the ID of the index with the given name, or false if the
+	// table has no such index.
+	IndexID(name string) (uint32, bool)
+}
+
+// NamedSubzone is a subzone whose index is identified by its name rather than
+// by its ID. Unlike subzones, named subzones carry over to a table whose
+// indexes were assigned new IDs, e.g. by RESTORE, and are human-readable. Their
+// YAML encoding is, e.g.:
+//
+//	index: t_pkey
+//	partition: us_east
+//	config: {num_replicas: 5}
+//
+// The partition is omitted for the subzones of indexes, and the config only
+// holds the fields which are set on the subzone.
+type NamedSubzone struct {
+	Index     string
+	Partition string
+	Config    ZoneConfig
+}
+
+// marshalableNamedSubzone is the YAML encoding of a NamedSubzone.
+type marshalableNamedSubzone struct {
+	Index     string    `yaml:"index"`
+	Partition string    `yaml:"partition,omitempty"`
+	Config    yaml.Node `yaml:"config"`
+}
+
+var _ yaml.Marshaler = NamedSubzone{}
+var _ yaml.Unmarshaler = &NamedSubzone{}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s NamedSubzone) MarshalYAML() (interface{}, error) {
+	config, err := s.Config.setFieldsYAMLNode()
+	if err != nil {
+		return nil, err
+	}
+	config.Style |= yaml.FlowStyle
+	return marshalableNamedSubzone{
+		Index:     s.Index,
+		Partition: s.Partition,
+		Config:    *config,
+	}, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The fields which the subzone
+// doesn't set are inherited.
+func (s *NamedSubzone) UnmarshalYAML(value *yaml.Node) error {
+	var m marshalableNamedSubzone
+	if err := checkYAMLFields(value, &m); err != nil {
+		return err
+	}
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+	if m.Index == "" {
+		return yamlNodeErrorf(value, "the index of a subzone must be named")
+	}
+	config := NewZoneConfig()
+	if m.Config.Kind != 0 && !isYAMLNull(&m.Config) {
+		if err := m.Config.Decode(config); err != nil {
+			return err
+		}
+	}
+	*s = NamedSubzone{Index: m.Index, Partition: m.Partition, Config: *config}
+	return nil
+}
+
+// NamedSubzones returns the subzones of the zone config, in order, with their
+// indexes resolved to their names. The subzones of indexes which can't be
+// resolved, e.g. because they were dropped, are omitted.
+func (z *ZoneConfig) NamedSubzones(indexes IndexNameResolver) []NamedSubzone {
+	var res []NamedSubzone
+	for _, s := range z.Subzones {
+		name, ok := indexes.IndexName(s.IndexID)
+		if !ok {
+			continue
+		}
+		res = append(res, NamedSubzone{Index: name, Partition: s.PartitionName, Config: s.Config})
+	}
+	return res
+}
+
+// SetNamedSubzones replaces the subzones of the zone config with the given
+// named subzones, whose indexes are resolved to their IDs. An error is
+// returned if an index can't be resolved, or if a subzone is repeated, in
+// which case the zone config is left unchanged. As with SetSubzone, the spans
+// of the subzones must be generated by the caller.
+func (z *ZoneConfig) SetNamedSubzones(subzones []NamedSubzone, indexes IndexNameResolver) error {
+	res := ZoneConfig{}
+	for _, s := range subzones {
+		id, ok := indexes.IndexID(s.Index)
+		if !ok {
+			return errors.Newf("index %q not found", s.Index)
+		}
+		if _, ok := res.subzoneIndex(id, s.Partition); ok {
+			if s.Partition != "" {
+				return errors.Newf("partition %q of index %q is repeated", s.Partition, s.Index)
+			}
+			return errors.Newf("index %q is repeated", s.Index)
+		}
+		res.SetSubzone(Subzone{IndexID: id, PartitionName: s.Partition, Config: s.Config})
+	}
+	z.Subzones, z.SubzoneSpans = res.Subzones, nil
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// PlacementScore quantifies how well the replicas of a range are placed with
+// respect to its zone config, as computed by ScorePlacement.
+type PlacementScore struct {
+	// ConstraintViolations is the number of replicas which violate the
+	// constraints and voter constraints: replicas which don't satisfy a
+	// conjunction applying to all of them, and replicas missing to satisfy a
+	// per-replica conjunction.
+	ConstraintViolations int
+	// Violations describes each violated conjunction, e.g. for diagnostics.
+	Violations []string
+	// LeasePreference is the index of the first lease preference which the
+	// leaseholder satisfies, or -1 if it satisfies none of them. It's zero if
+	// the zone config has no lease preferences.
+	LeasePreference int
+	// Diversity is the mean locality diversity of every pair of replicas (see
+	// roachpb.Locality.DiversityScore), from 0 when all the replicas share a
+	// locality to 1 when they all differ at the top tier. It's zero if there
+	// are fewer than two replicas.
+	Diversity float64
+}
+
+// ViolatesConstraints returns whether some replica violates the constraints
+// or voter constraints of the zone config.
+func (s PlacementScore) ViolatesConstraints() bool {
+	return s.ConstraintViolations > 0
+}
+
+// ScorePlacement scores the placement of a range's replicas on the given
+// stores, against the range's zone config, which is expected to be hydrated
+// from its parents. The voters come first in replicas: they're the first
+// num_voters replicas, or all of them if num_voters isn't set, and the first
+// replica is the leaseholder. Every component of the allocator and of the
+// diagnostic endpoints which decides whether a range violates its constraints
+// should use ScorePlacement, so that they never disagree. The number of
+// replicas itself isn't scored.
+func ScorePlacement(zc ZoneConfig, replicas []roachpb.StoreDescriptor) PlacementScore {
+	score := PlacementScore{LeasePreference: -1}
+	voters := replicas
+	if zc.NumVoters != nil && *zc.NumVoters > 0 && int(*zc.NumVoters) < len(replicas) {
+		voters = replicas[:*zc.NumVoters]
+	}
+	score.scoreConstraints("constraints", zc.Constraints, replicas)
+	score.scoreConstraints("voter_constraints", zc.VoterConstraints, voters)
+
+	if len(zc.LeasePreferences) == 0 {
+		score.LeasePreference = 0
+	} else if len(replicas) > 0 {
+		if i, ok := zc.LeasePreferenceIndex(replicas[0]); ok {
+			score.LeasePreference = i
+		}
+	}
+
+	var pairs int
+	for i := range replicas {
+		for j := i + 1; j < len(replicas); j++ {
+			score.Diversity += replicas[i].Node.Locality.DiversityScore(replicas[j].Node.Locality)
+			pairs++
+		}
+	}
+	if pairs > 0 {
+		score.Diversity /= float64(pairs)
+	}
+	return score
+}
+
+// scoreConstraints adds the violations of the given list of constraints by the
+// stores to the score.
+func (s *PlacementScore) scoreConstraints(
+	field string, conjunctions []ConstraintsConjunction, stores []roachpb.StoreDescriptor,
+) {
+	violated := func(c ConstraintsConjunction, n int) {
+		s.ConstraintViolations += n
+		s.Violations = append(s.Violations, fmt.Sprintf("%s %q: %d replicas in violation", field, c.String(), n))
+	}
+	perReplica, all := splitConjunctions(conjunctions)
+	if all != nil {
+		if n := len(stores) - countSatisfyingStores(stores, *all); n > 0 {
+			violated(*all, n)
+		}
+	}
+	// Replicas can only count towards one per-replica conjunction each.
+	nodes := make([]int, len(stores))
+	for i := range nodes {
+		nodes[i] = i
+	}
+	m := newSlotMatcher(stores, perReplica)
+	m.match(nodes)
+	placed := make([]int, len(perReplica))
+	for slot := range m.slotNodes {
+		placed[m.slots[slot]]++
+	}
+	for i, c := range perReplica {
+		if n := int(c.NumReplicas) - placed[i]; n > 0 {
+			violated(c, n)
+		}
+	}
+}
+
+// countSatisfyingStores returns the number of stores which satisfy the
+// conjunction.
+func countSatisfyingStores(stores []roachpb.StoreDescriptor, c ConstraintsConjunction) int {
+	var n int
+	for _, store := range stores {
+		if storeSatisfiesConjunction(store, c) {
+			n++
+		}
+	}
+	return n
+}
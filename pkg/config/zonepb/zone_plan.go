@@ -0,0 +1,318 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+)
+
+// ZoneChangeImpact classifies the effect that applying a zone config change
+// has on the ranges the zone applies to. Impacts are ordered by severity.
+type ZoneChangeImpact int
+
+const (
+	// ZoneChangeImpactNone indicates that the change is a no-op.
+	ZoneChangeImpactNone ZoneChangeImpact = iota
+	// ZoneChangeImpactMetadata indicates that the change only affects
+	// settings, such as the GC TTL, which don't move replicas or leases.
+	ZoneChangeImpactMetadata
+	// ZoneChangeImpactRangeSizes indicates that the change may cause ranges to
+	// be split or merged.
+	ZoneChangeImpactRangeSizes
+	// ZoneChangeImpactLeases indicates that the change may cause leases to be
+	// transferred, but doesn't move replicas.
+	ZoneChangeImpactLeases
+	// ZoneChangeImpactReplicas indicates that the change may cause replicas to
+	// be added, removed or moved.
+	ZoneChangeImpactReplicas
+)
+
+func (i ZoneChangeImpact) String() string {
+	switch i {
+	case ZoneChangeImpactNone:
+		return "none"
+	case ZoneChangeImpactMetadata:
+		return "metadata"
+	case ZoneChangeImpactRangeSizes:
+		return "range sizes"
+	case ZoneChangeImpactLeases:
+		return "leases"
+	case ZoneChangeImpactReplicas:
+		return "replicas"
+	default:
+		return fmt.Sprintf("ZoneChangeImpact(%d)", int(i))
+	}
+}
+
+// LintUnsatisfiableConstraints flags constraints which can't be satisfied by
+// the stores of a cluster. It's reported by PlanZoneChange, which is the only
+// place the cluster topology is known.
+const LintUnsatisfiableConstraints LintRule = "unsatisfiable_constraints"
+
+// zoneChangeFields are the fields compared by PlanZoneChange, along with the
+// impact of changing each of them.
+var zoneChangeFields = []struct {
+	name   tree.Name
+	impact ZoneChangeImpact
+}{
+	{"num_replicas", ZoneChangeImpactReplicas},
+	{"num_voters", ZoneChangeImpactReplicas},
+	{"range_min_bytes", ZoneChangeImpactRangeSizes},
+	{"range_max_bytes", ZoneChangeImpactRangeSizes},
+	{"global_reads", ZoneChangeImpactMetadata},
+	{"survival_goal", ZoneChangeImpactMetadata},
+	{"primary_region", ZoneChangeImpactMetadata},
+	{"secondary_region", ZoneChangeImpactMetadata},
+	{"gc.ttlseconds", ZoneChangeImpactMetadata},
+	{"constraints", ZoneChangeImpactReplicas},
+	{"voter_constraints", ZoneChangeImpactReplicas},
+	{"lease_preferences", ZoneChangeImpactLeases},
+	{"split_hints", ZoneChangeImpactRangeSizes},
+}
+
+// ZoneChangePlan describes the outcome of applying a zone config change,
+// without applying it. It's meant to be the single source of the pre-apply
+// analysis shown by every front end.
+type ZoneChangePlan struct {
+	// ValidationError is the error returned by validating the desired zone
+	// config, if any. A plan with a validation error should not be applied.
+	ValidationError error
+	// Warnings are warning-level findings about the desired zone config.
+	Warnings []LintFinding
+	// ChangedFields lists the fields which differ between the current and the
+	// desired zone configs.
+	ChangedFields []tree.Name
+	// ReplicaAdditions and ReplicaRemovals estimate the number of replicas
+	// which have to be added to and removed from each range. Since the actual
+	// placement of the ranges isn't known, every replica whose constraints
+	// changed is assumed to move.
+	ReplicaAdditions, ReplicaRemovals int32
+	// Impact is the most severe impact of the changed fields.
+	Impact ZoneChangeImpact
+}
+
+// PlanZoneChange compares the current and desired zone configs, which are
+// expected to have been hydrated from their parents, and returns a plan
+// describing the effects of the change. If topology, which lists the stores of
+// the cluster, is non-empty, the desired constraints are also checked against
+// it.
+func PlanZoneChange(current, desired ZoneConfig, topology []roachpb.StoreDescriptor) ZoneChangePlan {
+	var plan ZoneChangePlan
+	if err := desired.ValidateTandemFields(); err != nil {
+		plan.ValidationError = err
+	} else if err := desired.Validate(); err != nil {
+		plan.ValidationError = err
+	}
+	plan.Warnings = LintZoneConfig(desired)
+	if len(topology) > 0 {
+		plan.Warnings = append(plan.Warnings, checkSatisfiable(&desired, topology)...)
+	}
+
+	for _, f := range zoneChangeFields {
+		fieldList := []tree.Name{f.name}
+		cur, des := NewZoneConfig(), NewZoneConfig()
+		cur.CopyFromZone(current, fieldList)
+		des.CopyFromZone(desired, fieldList)
+		if cur.EquivalentTo(des) {
+			continue
+		}
+		plan.ChangedFields = append(plan.ChangedFields, f.name)
+		if f.impact > plan.Impact {
+			plan.Impact = f.impact
+		}
+	}
+	if plan.Impact == ZoneChangeImpactReplicas {
+		plan.ReplicaAdditions, plan.ReplicaRemovals = estimateReplicaMoves(&current, &desired)
+	}
+	return plan
+}
+
+// estimateReplicaMoves returns the number of replicas that each range has to
+// gain and lose to go from the current to the desired zone config.
+func estimateReplicaMoves(current, desired *ZoneConfig) (additions, removals int32) {
+	curReplicas, desReplicas := int32Value(current.NumReplicas), int32Value(desired.NumReplicas)
+	if desired.NumReplicas == nil {
+		desReplicas = curReplicas
+	}
+	if desReplicas > curReplicas {
+		additions += desReplicas - curReplicas
+	} else {
+		removals += curReplicas - desReplicas
+	}
+	// Replicas which are retained but whose constraints changed are assumed to
+	// be replaced.
+	retained := curReplicas
+	if desReplicas < retained {
+		retained = desReplicas
+	}
+	moved := changedConstrainedReplicas(current.Constraints, desired.Constraints, desReplicas)
+	if voters := int32Value(desired.NumVoters); voters > 0 {
+		// Voters may be moved onto replicas which are already in place, so
+		// only count them if they exceed the moved replicas.
+		movedVoters := changedConstrainedReplicas(current.VoterConstraints, desired.VoterConstraints, voters)
+		if movedVoters > moved {
+			moved = movedVoters
+		}
+	}
+	if moved > retained {
+		moved = retained
+	}
+	return additions + moved, removals + moved
+}
+
+// changedConstrainedReplicas returns the number of replicas constrained by
+// conjunctions in desired which are not present in current, regardless of the
+// order of the constraints within them. Conjunctions which apply to all
+// replicas count as numReplicas.
+func changedConstrainedReplicas(current, desired []ConstraintsConjunction, numReplicas int32) int32 {
+	current, desired = canonicalizeConjunctions(current), canonicalizeConjunctions(desired)
+	var changed int32
+	for _, c := range desired {
+		if containsConjunction(current, c) {
+			continue
+		}
+		if c.NumReplicas == 0 {
+			return numReplicas
+		}
+		changed += c.NumReplicas
+	}
+	if changed > numReplicas {
+		changed = numReplicas
+	}
+	return changed
+}
+
+func containsConjunction(conjunctions []ConstraintsConjunction, c ConstraintsConjunction) bool {
+	for _, other := range conjunctions {
+		if other.Compare(c) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSatisfiable returns findings for the constraints of the zone which
+// aren't satisfied by enough of the supplied stores. A conjunction which
+// applies to every replica, or to every voter, requires as many stores as
+// there are replicas or voters.
+func checkSatisfiable(zone *ZoneConfig, stores []roachpb.StoreDescriptor) []LintFinding {
+	var findings []LintFinding
+	replicas := int32Value(zone.NumReplicas)
+	if int(replicas) > len(stores) {
+		findings = append(findings, LintFinding{
+			Rule: LintUnsatisfiableConstraints,
+			Message: fmt.Sprintf("num_replicas is %d but the cluster only has %d stores",
+				replicas, len(stores)),
+		})
+	}
+	voters := replicas
+	if zone.NumVoters != nil && *zone.NumVoters > 0 {
+		voters = *zone.NumVoters
+	}
+	check := func(field string, conjunctions []ConstraintsConjunction, n int32) {
+		for _, conjunction := range conjunctions {
+			required := conjunction.NumReplicas
+			if required == 0 {
+				required = n
+			}
+			if required == 0 {
+				// The number of replicas isn't known.
+				required = 1
+			}
+			var matching int32
+			for _, store := range stores {
+				if storeSatisfiesConjunction(store, conjunction) {
+					matching++
+				}
+			}
+			if matching < required {
+				findings = append(findings, LintFinding{
+					Rule: LintUnsatisfiableConstraints,
+					Message: fmt.Sprintf("%s %q require %d stores but only %d match",
+						field, conjunction.String(), required, matching),
+				})
+			}
+		}
+	}
+	check("constraints", zone.Constraints, replicas)
+	check("voter_constraints", zone.VoterConstraints, voters)
+	return findings
+}
+
+// storeSatisfiesConjunction returns whether the store satisfies every
+// constraint in the conjunction.
+func storeSatisfiesConjunction(store roachpb.StoreDescriptor, c ConstraintsConjunction) bool {
+	for _, constraint := range c.Constraints {
+		if !StoreSatisfiesConstraint(store, constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+func int32Value(v *int32) int32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// ImpactReport estimates the cluster-wide effect of applying a zone config
+// change. See EstimateImpact.
+type ImpactReport struct {
+	// Impact is the most severe impact of the change.
+	Impact ZoneChangeImpact
+	// ReplicaAdditions and ReplicaRemovals estimate the total number of
+	// replicas which have to be added and removed across all of the zone's
+	// ranges.
+	ReplicaAdditions, ReplicaRemovals int64
+	// RebalanceBytes estimates the number of bytes which have to be copied to
+	// the added replicas. It's based on the average replica size reported by
+	// the stores, and is zero if they report none.
+	RebalanceBytes int64
+	// Satisfiable is false if the stores can't satisfy the desired zone
+	// config's replication factor or constraints, as described by
+	// Unsatisfiable.
+	Satisfiable   bool
+	Unsatisfiable []LintFinding
+}
+
+// EstimateImpact estimates the effect of changing the zone config of
+// rangeCount ranges from current to desired, given the stores of the cluster. Like
+// PlanZoneChange, which it builds on, it expects both zone configs to have
+// been hydrated from their parents and assumes that every replica whose
+// constraints changed has to move.
+func EstimateImpact(
+	current, desired ZoneConfig, rangeCount int, stores []roachpb.StoreDescriptor,
+) ImpactReport {
+	plan := PlanZoneChange(current, desired, nil /* topology */)
+	report := ImpactReport{
+		Impact:           plan.Impact,
+		ReplicaAdditions: int64(plan.ReplicaAdditions) * int64(rangeCount),
+		ReplicaRemovals:  int64(plan.ReplicaRemovals) * int64(rangeCount),
+	}
+
+	var logicalBytes, replicas int64
+	for _, store := range stores {
+		logicalBytes += store.Capacity.LogicalBytes
+		replicas += int64(store.Capacity.RangeCount)
+	}
+	if replicas > 0 {
+		report.RebalanceBytes = report.ReplicaAdditions * (logicalBytes / replicas)
+	}
+
+	report.Unsatisfiable = checkSatisfiable(&desired, stores)
+	report.Satisfiable = len(report.Unsatisfiable) == 0
+	return report
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// randomRegions are the regions which random zone configs are constrained to.
+var randomRegions = []string{"us-east1", "us-west1", "europe-west1", "asia-northeast1"}
+
+// zoneConfigYAMLSeeds are hand-written zone configs which exercise the
+// different formats accepted by ZoneConfig.UnmarshalYAML.
+var zoneConfigYAMLSeeds = []string{
+	"{}",
+	"num_replicas: 3",
+	"range_min_bytes: 1MiB\nrange_max_bytes: 64MiB",
+	"gc: {ttlseconds: 600}",
+	"gc: {ttl: 25h}",
+	"global_reads: true",
+	"constraints: [+region=us-east1, -ssd]",
+	"num_replicas: 5\nconstraints: {+region=us-east1: 2, '+region=us-west1,+ssd': 1}",
+	"num_replicas: 5\nnum_voters: 3\nvoter_constraints: {+region=us-east1: 2}\n" +
+		"lease_preferences: [[+region=us-east1], [+region=us-*]]",
+	"constraints: &east [+region=us-east1]\nlease_preferences: [*east]",
+}
+
+// RandomZoneConfig returns a random zone config which passes Validate and
+// ValidateTandemFields, for use in randomized tests. Constraints only refer to
+// a handful of regions and store attributes.
+//
+// The zone config may have subzones, whose configs are random as well, and may
+// then be a subzone placeholder (see IsSubzonePlaceholder). SubzoneSpans are
+// never set, since they depend on the descriptor of the table.
+func RandomZoneConfig(rng *rand.Rand) ZoneConfig {
+	z := randomZoneConfig(rng)
+	if rng.Intn(3) != 0 {
+		return z
+	}
+	for i, n := 0, 1+rng.Intn(4); i < n; i++ {
+		subzone := Subzone{IndexID: uint32(1 + rng.Intn(3)), Config: randomZoneConfig(rng)}
+		if rng.Intn(2) == 0 {
+			subzone.PartitionName = fmt.Sprintf("p%d", rng.Intn(3))
+		}
+		z.SetSubzone(subzone)
+	}
+	if rng.Intn(4) == 0 {
+		z.DeleteTableConfig()
+	}
+	return z
+}
+
+// randomZoneConfig returns a random zone config without subzones. See
+// RandomZoneConfig.
+func randomZoneConfig(rng *rand.Rand) ZoneConfig {
+	z := *NewZoneConfig()
+	numReplicas := []int32{1, 3, 4, 5, 6, 7}[rng.Intn(6)]
+	z.NumReplicas = proto.Int32(numReplicas)
+	if rng.Intn(2) == 0 {
+		rangeMaxBytes := minRangeMaxBytes << rng.Intn(4)
+		z.RangeMaxBytes = proto.Int64(rangeMaxBytes)
+		z.RangeMinBytes = proto.Int64(rng.Int63n(rangeMaxBytes))
+	}
+	if rng.Intn(2) == 0 {
+		z.GC = &GCPolicy{TTLSeconds: 1 + rng.Int31n(90000)}
+	}
+	if rng.Intn(4) == 0 {
+		z.GlobalReads = proto.Bool(rng.Intn(2) == 0)
+	}
+
+	regions := append([]string(nil), randomRegions...)
+	rng.Shuffle(len(regions), func(i, j int) { regions[i], regions[j] = regions[j], regions[i] })
+	region := func(i int) Constraint {
+		return Constraint{Type: Constraint_REQUIRED, Key: "region", Value: regions[i%len(regions)]}
+	}
+	switch rng.Intn(3) {
+	case 0:
+		// Inherit the constraints.
+	case 1:
+		// Constraints which apply to all replicas. Prohibited constraints only
+		// refer to hdd, which voter constraints never do, so that they never
+		// conflict.
+		conjunction := ConstraintsConjunction{Constraints: []Constraint{region(0)}}
+		if rng.Intn(2) == 0 {
+			conjunction.Constraints = append(conjunction.Constraints,
+				Constraint{Type: Constraint_PROHIBITED, Value: "hdd"})
+		}
+		z.Constraints = []ConstraintsConjunction{conjunction}
+		z.InheritedConstraints = false
+	case 2:
+		// Per-replica constraints.
+		remaining := numReplicas
+		for i := 0; i < len(regions) && remaining > 0; i++ {
+			n := 1 + rng.Int31n(remaining)
+			remaining -= n
+			z.Constraints = append(z.Constraints, ConstraintsConjunction{
+				NumReplicas: n, Constraints: []Constraint{region(i)},
+			})
+		}
+		sortPerReplicaConstraints(z.Constraints)
+		z.InheritedConstraints = false
+	}
+
+	if numReplicas >= 3 && rng.Intn(2) == 0 {
+		numVoters := 3 + rng.Int31n(numReplicas-2)
+		z.NumVoters = proto.Int32(numVoters)
+		if rng.Intn(2) == 0 {
+			z.VoterConstraints = []ConstraintsConjunction{{
+				NumReplicas: 1 + rng.Int31n(numVoters), Constraints: []Constraint{region(0)},
+			}}
+			if rng.Intn(2) == 0 {
+				z.VoterConstraints[0].Constraints = append(z.VoterConstraints[0].Constraints,
+					Constraint{Type: Constraint_REQUIRED, Value: "ssd"})
+			}
+			z.NullVoterConstraintsIsEmpty = true
+		}
+	}
+
+	// Lease preferences can only be set along with the constraints they refer
+	// to.
+	if !z.InheritedVoterConstraints() || (z.NumVoters == nil && !z.InheritedConstraints) {
+		if numPrefs := rng.Intn(3); numPrefs > 0 {
+			for i := 0; i < numPrefs; i++ {
+				z.LeasePreferences = append(z.LeasePreferences, LeasePreference{
+					Constraints: []Constraint{region(i)},
+				})
+			}
+			if rng.Intn(2) == 0 {
+				z.LeasePreferences = append(z.LeasePreferences, LeasePreference{
+					Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "us-*"}},
+				})
+			}
+			z.InheritedLeasePreferences = false
+		}
+	}
+
+	// The number of replicas may be inherited when constraints don't depend on
+	// it.
+	constrained := ConstraintsList{Constraints: z.Constraints}.TotalConstrainedReplicas()
+	if z.NumVoters == nil && constrained == 0 && rng.Intn(4) == 0 {
+		z.NumReplicas = nil
+	}
+	return z
+}
+
+// GenerateZoneConfigYAMLCorpus returns a corpus of YAML zone configs, e.g. to
+// seed a fuzzer of ZoneConfig.UnmarshalYAML: hand-written configs covering the
+// formats it accepts, followed by n random configs (see RandomZoneConfig).
+func GenerateZoneConfigYAMLCorpus(rng *rand.Rand, n int) ([][]byte, error) {
+	corpus := make([][]byte, 0, len(zoneConfigYAMLSeeds)+n)
+	for _, seed := range zoneConfigYAMLSeeds {
+		corpus = append(corpus, []byte(seed))
+	}
+	for i := 0; i < n; i++ {
+		out, err := MarshalYAML(RandomZoneConfig(rng))
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, out)
+	}
+	return corpus, nil
+}
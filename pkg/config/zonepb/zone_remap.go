@@ -0,0 +1,129 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
+)
+
+// RemapZoneConfig returns a copy of the zone config of a table whose indexes
+// were assigned new IDs, e.g. by RESTORE, which refers to the new IDs. idMap
+// maps the old ID of each remapped index to its new one; indexes which aren't
+// in it keep their IDs. The index IDs of the subzones are rewritten, along
+// with the index prefixes of the key suffixes of the subzone spans and split
+// hints, and the subzones and their spans are re-sorted so that the copy
+// stays valid when the relative order of the indexes changes. Keys which
+// don't start with an index ID are left as is.
+//
+// The zone config is otherwise shared with the copy, as by Clone. The ID of
+// the table itself isn't part of its zone config, which is rekeyed along with
+// the system.zones table.
+func RemapZoneConfig(zc ZoneConfig, idMap map[uint32]uint32) ZoneConfig {
+	res := *zc.Clone()
+	if len(idMap) == 0 {
+		return res
+	}
+	res.remapSubzones(idMap)
+	if len(res.SplitHints) > 0 {
+		hints := make([]roachpb.Key, len(res.SplitHints))
+		for i, h := range res.SplitHints {
+			hints[i] = remapIndexKey(h, idMap)
+		}
+		res.SplitHints = sortedSplitHints(hints)
+	}
+	return res
+}
+
+// remapSubzones implements RemapZoneConfig for the subzones and subzone spans
+// of the zone config, which are replaced rather than modified in place.
+func (z *ZoneConfig) remapSubzones(idMap map[uint32]uint32) {
+	if len(z.Subzones) == 0 {
+		return
+	}
+	subzones := make([]Subzone, len(z.Subzones))
+	order := make([]int, len(z.Subzones))
+	for i, s := range z.Subzones {
+		if id, ok := idMap[s.IndexID]; ok {
+			s.IndexID = id
+		}
+		subzones[i] = s
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return subzoneLess(&subzones[order[i]], &subzones[order[j]])
+	})
+	// newIndexes maps the position of each subzone to its new position.
+	newIndexes := make([]int32, len(subzones))
+	z.Subzones = make([]Subzone, len(subzones))
+	for i, old := range order {
+		newIndexes[old] = int32(i)
+		z.Subzones[i] = subzones[old]
+	}
+
+	if len(z.SubzoneSpans) == 0 {
+		return
+	}
+	spans := make([]SubzoneSpan, len(z.SubzoneSpans))
+	for i, span := range z.SubzoneSpans {
+		if span.SubzoneIndex >= 0 && int(span.SubzoneIndex) < len(newIndexes) {
+			span.SubzoneIndex = newIndexes[span.SubzoneIndex]
+		}
+		key := remapIndexKey(span.Key, idMap)
+		if len(span.EndKey) > 0 {
+			span.EndKey = remapIndexEndKey(span.Key, key, span.EndKey, idMap)
+		}
+		span.Key = key
+		spans[i] = span
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return bytes.Compare(spans[i].Key, spans[j].Key) < 0
+	})
+	z.SubzoneSpans = spans
+}
+
+// remapIndexKey returns the key suffix with its leading index ID remapped
+// through idMap, or the key itself if its index isn't remapped.
+func remapIndexKey(key roachpb.Key, idMap map[uint32]uint32) roachpb.Key {
+	rest, id, err := encoding.DecodeUvarintAscending(key)
+	if err != nil || id > uint64(^uint32(0)) {
+		return key
+	}
+	newID, ok := idMap[uint32(id)]
+	if !ok {
+		return key
+	}
+	res := encoding.EncodeUvarintAscending(make(roachpb.Key, 0, len(key)+1), uint64(newID))
+	return append(res, rest...)
+}
+
+// remapIndexEndKey is like remapIndexKey, for the end key of a span whose start
+// key is remapped from key to newKey. An end key which is the end of the
+// index of the start key, e.g. /2 for a span starting at /1/5, decodes as the
+// next index ID, and is instead remapped to the end of the new index.
+func remapIndexEndKey(key, newKey, endKey roachpb.Key, idMap map[uint32]uint32) roachpb.Key {
+	rest, _, err := encoding.DecodeUvarintAscending(key)
+	if err != nil {
+		return remapIndexKey(endKey, idMap)
+	}
+	prefix := key[:len(key)-len(rest)]
+	if bytes.Equal(endKey, prefix.PrefixEnd()) {
+		newRest, _, err := encoding.DecodeUvarintAscending(newKey)
+		if err != nil {
+			return endKey
+		}
+		return newKey[:len(newKey)-len(newRest)].PrefixEnd()
+	}
+	return remapIndexKey(endKey, idMap)
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage/enginepb"
+	"github.com/cockroachdb/errors"
+)
+
+// Placement is an example placement of a range's replicas, as computed by
+// Simulate. Replicas are identified by the index of their node in the
+// simulated topology.
+type Placement struct {
+	Voters    []int
+	NonVoters []int
+	// Leaseholder is the voter which holds the lease.
+	Leaseholder int
+	// LeasePreference is the index of the first lease preference which the
+	// leaseholder satisfies, or -1 if no voter satisfies any of them.
+	LeasePreference int
+}
+
+// PlacementError explains why Simulate couldn't place a range's replicas:
+// the zone config field (and, if the field is a list of constraints, the
+// conjunction) which requires more replicas than could be placed.
+type PlacementError struct {
+	Field       string
+	Conjunction string
+	Required    int
+	Placed      int
+}
+
+func (e *PlacementError) Error() string {
+	field := e.Field
+	if e.Conjunction != "" {
+		field = fmt.Sprintf("%s %q", e.Field, e.Conjunction)
+	}
+	return fmt.Sprintf("%s requires %d replicas but only %d can be placed",
+		field, e.Required, e.Placed)
+}
+
+// Simulate computes an example placement of the replicas of a range with the
+// given zone config, on a cluster with one single-store node per locality in
+// topology. Since stores have no attributes, attribute constraints can't be
+// satisfied. Like the allocator, Simulate places at most one replica per node,
+// but it doesn't try to diversify the placement: among equally suitable nodes,
+// the first ones in the topology are picked.
+//
+// The zone config is expected to be hydrated from its parents. If the replicas
+// can't be placed, a *PlacementError describes the constraint which couldn't
+// be satisfied. Unsatisfiable lease preferences don't prevent a placement;
+// see Placement.LeasePreference.
+func Simulate(zone ZoneConfig, topology []roachpb.Locality) (Placement, error) {
+	if zone.NumReplicas == nil || *zone.NumReplicas <= 0 {
+		return Placement{}, errors.New("num_replicas must be set")
+	}
+	if err := zone.Validate(); err != nil {
+		return Placement{}, err
+	}
+	numReplicas := int(*zone.NumReplicas)
+	numVoters := numReplicas
+	if zone.NumVoters != nil && *zone.NumVoters > 0 {
+		numVoters = int(*zone.NumVoters)
+	}
+	stores := make([]roachpb.StoreDescriptor, len(topology))
+	for i := range topology {
+		stores[i].Node.Locality = topology[i]
+	}
+
+	// Apply the constraints which hold for all replicas, or all voters.
+	replicaSlots, replicaFilter := splitConjunctions(zone.Constraints)
+	voterSlots, voterFilter := splitConjunctions(zone.VoterConstraints)
+	var eligible, eligibleVoters []int
+	for i, store := range stores {
+		if replicaFilter != nil && !storeSatisfiesConjunction(store, *replicaFilter) {
+			continue
+		}
+		eligible = append(eligible, i)
+		if voterFilter == nil || storeSatisfiesConjunction(store, *voterFilter) {
+			eligibleVoters = append(eligibleVoters, i)
+		}
+	}
+	if len(eligible) < numReplicas {
+		if replicaFilter != nil {
+			return Placement{}, &PlacementError{Field: "constraints",
+				Conjunction: replicaFilter.String(), Required: numReplicas, Placed: len(eligible)}
+		}
+		return Placement{}, &PlacementError{Field: "num_replicas",
+			Required: numReplicas, Placed: len(eligible)}
+	}
+	if len(eligibleVoters) < numVoters {
+		field := "num_voters"
+		if numVoters == numReplicas {
+			field = "num_replicas"
+		}
+		err := &PlacementError{Field: field, Required: numVoters, Placed: len(eligibleVoters)}
+		if voterFilter != nil {
+			err.Field, err.Conjunction = "voter_constraints", voterFilter.String()
+		}
+		return Placement{}, err
+	}
+
+	// Place the voters first, preferring nodes which satisfy per-replica
+	// constraints so that they can count towards them.
+	sort.SliceStable(eligibleVoters, func(i, j int) bool {
+		return satisfiesAny(stores[eligibleVoters[i]], replicaSlots) &&
+			!satisfiesAny(stores[eligibleVoters[j]], replicaSlots)
+	})
+	voters := newSlotMatcher(stores, voterSlots)
+	voters.match(eligibleVoters)
+	if err := voters.err("voter_constraints"); err != nil {
+		return Placement{}, err
+	}
+	isVoter := make(map[int]bool)
+	var p Placement
+	for _, node := range voters.slotNodes {
+		isVoter[node] = true
+	}
+	for _, node := range eligibleVoters {
+		if len(isVoter) == numVoters {
+			break
+		}
+		isVoter[node] = true
+	}
+	for _, node := range eligible {
+		if isVoter[node] {
+			p.Voters = append(p.Voters, node)
+		}
+	}
+
+	// Then satisfy the per-replica constraints, using as few non-voters as
+	// possible.
+	replicas := newSlotMatcher(stores, replicaSlots)
+	replicas.match(p.Voters)
+	replicas.match(eligible)
+	if err := replicas.err("constraints"); err != nil {
+		return Placement{}, err
+	}
+	isReplica := make(map[int]bool)
+	for node := range isVoter {
+		isReplica[node] = true
+	}
+	for _, node := range replicas.slotNodes {
+		isReplica[node] = true
+	}
+	if len(isReplica) > numReplicas {
+		return Placement{}, &PlacementError{Field: "constraints",
+			Required: len(isReplica), Placed: numReplicas}
+	}
+	for _, node := range eligible {
+		if len(isReplica) == numReplicas {
+			break
+		}
+		isReplica[node] = true
+	}
+	for _, node := range eligible {
+		if isReplica[node] && !isVoter[node] {
+			p.NonVoters = append(p.NonVoters, node)
+		}
+	}
+
+	// Finally, pick the leaseholder.
+	p.Leaseholder, p.LeasePreference = p.Voters[0], -1
+	for i, pref := range zone.LeasePreferences {
+		conjunction := ConstraintsConjunction{Constraints: pref.Constraints}
+		for _, node := range p.Voters {
+			if storeSatisfiesConjunction(stores[node], conjunction) {
+				p.Leaseholder, p.LeasePreference = node, i
+				return p, nil
+			}
+		}
+	}
+	return p, nil
+}
+
+// GCImpact is the estimated effect of a change of GC TTL on the MVCC history
+// of a span, as computed by EstimateGCImpact.
+type GCImpact struct {
+	// LostHistory is how much less MVCC history is retained with the new TTL.
+	LostHistory time.Duration
+	// EligibleBytes is the estimated number of non-live bytes which become
+	// eligible for GC as soon as the new TTL applies, on top of those which
+	// were already eligible with the old TTL.
+	EligibleBytes int64
+}
+
+// EstimateGCImpact estimates how much MVCC history is made eligible for GC by
+// changing the GC TTL of a span from oldTTL to newTTL seconds, given the MVCC
+// stats of its ranges, which are expected to have been aged to the current
+// time (see MVCCStats.AgeTo). Increasing the TTL has no impact.
+//
+// The stats only record the total age of the non-live bytes (GCBytesAge), not
+// how it's distributed, so the estimate assumes that the bytes were made
+// non-live at a steady rate, i.e. that their ages are spread evenly from zero
+// to twice their average age. This is the case for a workload which
+// continuously overwrites or deletes data, and which has been running under
+// the old TTL for longer than the TTL.
+func EstimateGCImpact(oldTTL, newTTL int32, stats enginepb.MVCCStats) GCImpact {
+	if newTTL >= oldTTL {
+		return GCImpact{}
+	}
+	impact := GCImpact{LostHistory: time.Duration(oldTTL-newTTL) * time.Second}
+	gcBytes := stats.GCBytes()
+	if gcBytes <= 0 || stats.GCBytesAge <= 0 {
+		return impact
+	}
+	maxAge := 2 * float64(stats.GCBytesAge) / float64(gcBytes)
+	// olderThan returns the fraction of the non-live bytes older than ttl.
+	olderThan := func(ttl int32) float64 {
+		return math.Max(1-math.Max(float64(ttl), 0)/maxAge, 0)
+	}
+	impact.EligibleBytes = int64(math.Round(float64(gcBytes) * (olderThan(newTTL) - olderThan(oldTTL))))
+	return impact
+}
+
+// splitConjunctions splits a list of constraints into its per-replica
+// conjunctions and, if it applies to all replicas, its only conjunction.
+func splitConjunctions(
+	conjunctions []ConstraintsConjunction,
+) (perReplica []ConstraintsConjunction, all *ConstraintsConjunction) {
+	if len(conjunctions) == 1 && conjunctions[0].NumReplicas == 0 {
+		return nil, &conjunctions[0]
+	}
+	return conjunctions, nil
+}
+
+func satisfiesAny(store roachpb.StoreDescriptor, conjunctions []ConstraintsConjunction) bool {
+	for _, c := range conjunctions {
+		if storeSatisfiesConjunction(store, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// slotMatcher assigns distinct nodes to the replicas required by per-replica
+// constraints, by computing a maximum bipartite matching.
+type slotMatcher struct {
+	stores       []roachpb.StoreDescriptor
+	conjunctions []ConstraintsConjunction
+	// slots holds the index of the conjunction of every required replica.
+	slots []int
+	// slotNodes and nodeSlots hold the current matching.
+	slotNodes map[int]int
+	nodeSlots map[int]int
+}
+
+func newSlotMatcher(
+	stores []roachpb.StoreDescriptor, conjunctions []ConstraintsConjunction,
+) *slotMatcher {
+	m := &slotMatcher{
+		stores:       stores,
+		conjunctions: conjunctions,
+		slotNodes:    make(map[int]int),
+		nodeSlots:    make(map[int]int),
+	}
+	for i, c := range conjunctions {
+		for j := int32(0); j < c.NumReplicas; j++ {
+			m.slots = append(m.slots, i)
+		}
+	}
+	return m
+}
+
+// match extends the matching using the given nodes. Nodes which are already
+// matched stay matched, although possibly to other slots.
+func (m *slotMatcher) match(nodes []int) {
+	for slot := range m.slots {
+		if _, ok := m.slotNodes[slot]; !ok {
+			m.augment(slot, nodes, make(map[int]bool))
+		}
+	}
+}
+
+// augment looks for an augmenting path starting at the slot.
+func (m *slotMatcher) augment(slot int, nodes []int, visited map[int]bool) bool {
+	conjunction := m.conjunctions[m.slots[slot]]
+	for _, node := range nodes {
+		if visited[node] || !storeSatisfiesConjunction(m.stores[node], conjunction) {
+			continue
+		}
+		visited[node] = true
+		if other, ok := m.nodeSlots[node]; ok && !m.augment(other, nodes, visited) {
+			continue
+		}
+		m.slotNodes[slot], m.nodeSlots[node] = node, slot
+		return true
+	}
+	return false
+}
+
+// err returns an error for the first conjunction whose replicas couldn't all
+// be matched, if any.
+func (m *slotMatcher) err(field string) error {
+	placed := make([]int, len(m.conjunctions))
+	for slot := range m.slotNodes {
+		placed[m.slots[slot]]++
+	}
+	for i, c := range m.conjunctions {
+		if placed[i] < int(c.NumReplicas) {
+			return &PlacementError{Field: field, Conjunction: c.String(),
+				Required: int(c.NumReplicas), Placed: placed[i]}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxSplitHints is the maximum number of split hints of a zone config. Split
+// hints are meant to pre-split hot tables, not to replace load-based
+// splitting.
+const MaxSplitHints = 1024
+
+// splitHint is the YAML encoding of a split hint: the hexadecimal encoding of
+// the key suffix, e.g. 899288 for the first column family of the row of a
+// table whose primary key is the integer 10 (/1/10/0).
+type splitHint roachpb.Key
+
+var _ yaml.Marshaler = splitHint(nil)
+var _ yaml.Unmarshaler = (*splitHint)(nil)
+
+// MarshalYAML implements yaml.Marshaler.
+func (h splitHint) MarshalYAML() (interface{}, error) {
+	// Hints which only have digits are quoted, so that they're not decoded as
+	// integers by other YAML decoders.
+	return &yaml.Node{
+		Kind: yaml.ScalarNode, Tag: "!!str", Value: hex.EncodeToString(h),
+	}, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (h *splitHint) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return yamlNodeError(value, errors.New("split hints must be hexadecimal keys"))
+	}
+	key, err := hex.DecodeString(value.Value)
+	if err != nil {
+		return yamlNodeError(value, errors.Wrapf(err, "invalid split hint %q", value.Value))
+	}
+	*h = key
+	return nil
+}
+
+func splitHintsToMarshalable(hints []roachpb.Key) []splitHint {
+	if hints == nil {
+		return nil
+	}
+	res := make([]splitHint, len(hints))
+	for i, h := range hints {
+		res[i] = splitHint(h)
+	}
+	return res
+}
+
+func splitHintsFromMarshalable(hints []splitHint) []roachpb.Key {
+	if hints == nil {
+		return nil
+	}
+	res := make([]roachpb.Key, len(hints))
+	for i, h := range hints {
+		res[i] = roachpb.Key(h)
+	}
+	return res
+}
+
+// validateSplitHints returns an error if the zone config has too many split
+// hints, or if one of them is empty, repeated or not the key of a row.
+func (z *ZoneConfig) validateSplitHints() error {
+	if len(z.SplitHints) > MaxSplitHints {
+		return errors.Newf("%d split hints exceed the maximum of %d", len(z.SplitHints), MaxSplitHints)
+	}
+	sorted := sortedSplitHints(z.SplitHints)
+	for i, h := range sorted {
+		if len(h) == 0 {
+			return errors.New("split hints can't be empty")
+		}
+		if i > 0 && h.Equal(sorted[i-1]) {
+			return errors.Newf("split hint %x is repeated", []byte(h))
+		}
+		if _, err := splitHintRowPrefix(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitHintTablePrefix is the table prefix with which split hints are
+// decoded. The row prefix of a key doesn't depend on its table.
+var splitHintTablePrefix = keys.SystemSQLCodec.TablePrefix(keys.MaxReservedDescID + 1)
+
+// splitHintRowPrefix returns the prefix of the row of the split hint, at
+// which the ranges of the table are split so that the column families of the
+// row aren't split apart (see keys.EnsureSafeSplitKey). It returns an error if
+// the hint isn't the key of a row, e.g. because it lacks its column family
+// suffix.
+func splitHintRowPrefix(h roachpb.Key) (roachpb.Key, error) {
+	prefix := splitHintTablePrefix[:len(splitHintTablePrefix):len(splitHintTablePrefix)]
+	key, err := keys.EnsureSafeSplitKey(append(prefix, h...))
+	// The column family suffix can't cover the table prefix.
+	if err != nil || len(key) <= len(prefix) {
+		return nil, errors.Newf("split hint %x is not the key of a row", []byte(h))
+	}
+	return key[len(prefix):], nil
+}
+
+// sortedSplitHints returns a sorted copy of the split hints.
+func sortedSplitHints(hints []roachpb.Key) []roachpb.Key {
+	if len(hints) == 0 {
+		return nil
+	}
+	res := append([]roachpb.Key(nil), hints...)
+	sort.Slice(res, func(i, j int) bool { return res[i].Compare(res[j]) < 0 })
+	return res
+}
+
+// SplitKeys returns the key suffixes at which the ranges of the table of the
+// zone config must be split, in ascending order: the boundaries of its
+// subzones (see SubzoneSplits) along with the row prefixes of its split hints.
+func (z ZoneConfig) SplitKeys() []roachpb.RKey {
+	splits := z.SubzoneSplits()
+	if len(z.SplitHints) == 0 {
+		return splits
+	}
+	for _, h := range z.SplitHints {
+		// Hints which aren't the keys of rows, which Validate rejects, are
+		// ignored rather than risk splitting a row apart.
+		if prefix, err := splitHintRowPrefix(h); err == nil {
+			splits = append(splits, roachpb.RKey(prefix))
+		}
+	}
+	if len(splits) == 0 {
+		return nil
+	}
+	sort.Slice(splits, func(i, j int) bool { return bytes.Compare(splits[i], splits[j]) < 0 })
+	res := splits[:1]
+	for _, s := range splits[1:] {
+		if !s.Equal(res[len(res)-1]) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/lexbase"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ToSQL returns the ALTER ... CONFIGURE ZONE statement which sets the fields
+// of the zone config on the target, written as in ALTER ... CONFIGURE ZONE,
+// e.g. "TABLE db.public.t":
+//
+//	ALTER TABLE db.public.t CONFIGURE ZONE USING
+//		num_replicas = 5,
+//		constraints = '[+region=us-east1]'
+//
+// Inherited fields are omitted, as are the fields which can't be set through
+// SQL. Constraints and lease preferences are written as quoted YAML strings.
+// See ParseZoneConfigSQL for the reverse.
+func (c ZoneConfig) ToSQL(target string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ALTER %s CONFIGURE ZONE USING\n", target)
+	sep := ""
+	write := func(format string, args ...interface{}) {
+		b.WriteString(sep)
+		fmt.Fprintf(&b, "\t"+format, args...)
+		sep = ",\n"
+	}
+	if c.RangeMinBytes != nil {
+		write("range_min_bytes = %d", *c.RangeMinBytes)
+	}
+	if c.RangeMaxBytes != nil {
+		write("range_max_bytes = %d", *c.RangeMaxBytes)
+	}
+	if c.GC != nil {
+		write("gc.ttlseconds = %d", c.GC.TTLSeconds)
+	}
+	if c.GlobalReads != nil {
+		write("global_reads = %t", *c.GlobalReads)
+	}
+	if c.NumReplicas != nil {
+		write("num_replicas = %d", *c.NumReplicas)
+	}
+	if c.NumVoters != nil {
+		write("num_voters = %d", *c.NumVoters)
+	}
+	if !c.InheritedConstraints {
+		write("constraints = %s", sqlYAMLString(ConstraintsList{
+			Constraints: c.Constraints,
+			SetName:     c.ConstraintsSet,
+		}))
+	}
+	if !c.InheritedVoterConstraints() && c.NumVoters != nil && *c.NumVoters > 0 {
+		write("voter_constraints = %s", sqlYAMLString(ConstraintsList{
+			Constraints: c.VoterConstraints,
+			SetName:     c.VoterConstraintsSet,
+		}))
+	}
+	if !c.InheritedLeasePreferences {
+		write("lease_preferences = %s", sqlYAMLString(c.LeasePreferences))
+	}
+	return b.String()
+}
+
+// sqlYAMLString returns the YAML flow encoding of v as a SQL string literal.
+func sqlYAMLString(v interface{}) string {
+	out, err := MarshalYAMLFlow(v)
+	if err != nil {
+		// Constraints and lease preferences always have a YAML encoding.
+		panic(errors.NewAssertionErrorWithWrappedErrf(err, "encoding %T", v))
+	}
+	return lexbase.EscapeSQLString(string(out))
+}
+
+// ParseZoneConfigSQL is a best-effort parser of the statements produced by
+// ZoneConfig.ToSQL. It returns the target of the statement, as written, and
+// the zone config setting the assigned fields on top of NewZoneConfig(), so
+// that the other fields are inherited. Assignments are comma-separated and
+// their values may be integers, booleans, or string literals, which may use
+// the e'...' escape syntax; DEFAULT, COPY FROM PARENT and other expressions
+// aren't supported. It isn't a substitute for the SQL parser: the target in
+// particular isn't validated.
+func ParseZoneConfigSQL(stmt string) (string, ZoneConfig, error) {
+	s := strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	const prefix, using = "ALTER ", " CONFIGURE ZONE USING"
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", ZoneConfig{}, errors.Newf("expected %q at the start of %q", strings.TrimSpace(prefix), stmt)
+	}
+	i := strings.Index(strings.ToUpper(s), using)
+	if i < 0 {
+		return "", ZoneConfig{}, errors.Newf("expected %q in %q", strings.TrimSpace(using), stmt)
+	}
+	target := strings.TrimSpace(s[len(prefix):i])
+	if target == "" {
+		return "", ZoneConfig{}, errors.Newf("missing target in %q", stmt)
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	p := zoneSQLParser{s: s[i+len(using):]}
+	for p.skipSpace(); !p.done(); {
+		name, value, err := p.assignment()
+		if err != nil {
+			return "", ZoneConfig{}, err
+		}
+		if err := addZoneSQLAssignment(doc, name, value); err != nil {
+			return "", ZoneConfig{}, err
+		}
+	}
+	if len(doc.Content) == 0 {
+		return "", ZoneConfig{}, errors.Newf("no fields are set in %q", stmt)
+	}
+	zone := NewZoneConfig()
+	if err := doc.Decode(zone); err != nil {
+		return "", ZoneConfig{}, err
+	}
+	return target, *zone, nil
+}
+
+// zoneSQLValue is the value of an assignment parsed by zoneSQLParser.
+type zoneSQLValue struct {
+	s string
+	// quoted is set for string literals, whose contents are in s.
+	quoted bool
+}
+
+// addZoneSQLAssignment adds the field assignment to the YAML mapping of a
+// zone config. The values of string fields hold YAML themselves.
+func addZoneSQLAssignment(doc *yaml.Node, name string, value zoneSQLValue) error {
+	var key string
+	for _, f := range formatFields {
+		if f.name == name {
+			key = f.key
+			break
+		}
+	}
+	if key == "" {
+		return errors.Newf("unsupported zone config field %q", name)
+	}
+	// Nested fields, i.e. gc.ttlseconds, are named after their parent's key.
+	nested := strings.TrimPrefix(name, key+".")
+	if nested == name {
+		nested = ""
+	}
+	if yamlMappingIndex(doc, key) >= 0 {
+		return errors.Newf("duplicate assignment to %q", name)
+	}
+	var node *yaml.Node
+	if value.quoted {
+		var v yaml.Node
+		if err := yaml.Unmarshal([]byte(value.s), &v); err != nil {
+			return errors.Wrapf(err, "invalid value of %q", name)
+		}
+		if len(v.Content) == 0 {
+			return errors.Newf("invalid value of %q: empty YAML", name)
+		}
+		node = v.Content[0]
+	} else {
+		node = &yaml.Node{Kind: yaml.ScalarNode, Value: value.s}
+	}
+	if nested != "" {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: nested}, node,
+		}}
+	}
+	doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+	return nil
+}
+
+// zoneSQLParser scans the assignments of an ALTER ... CONFIGURE ZONE USING
+// statement.
+type zoneSQLParser struct {
+	s   string
+	pos int
+}
+
+func (p *zoneSQLParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *zoneSQLParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// assignment scans an assignment of the form name = value, along with the
+// comma which follows it, if any.
+func (p *zoneSQLParser) assignment() (string, zoneSQLValue, error) {
+	eq := strings.IndexByte(p.s[p.pos:], '=')
+	if eq < 0 {
+		return "", zoneSQLValue{}, errors.Newf("expected an assignment at %q", p.s[p.pos:])
+	}
+	name := strings.ToLower(strings.TrimSpace(p.s[p.pos : p.pos+eq]))
+	p.pos += eq + 1
+	p.skipSpace()
+	var value zoneSQLValue
+	var err error
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "'"):
+		value, err = p.stringLiteral()
+	case strings.HasPrefix(p.s[p.pos:], "e'"), strings.HasPrefix(p.s[p.pos:], "E'"):
+		p.pos++
+		value, err = p.escapedStringLiteral()
+	default:
+		end := strings.IndexByte(p.s[p.pos:], ',')
+		if end < 0 {
+			end = len(p.s) - p.pos
+		}
+		value.s = strings.TrimSpace(p.s[p.pos : p.pos+end])
+		p.pos += end
+		if strings.EqualFold(value.s, "true") || strings.EqualFold(value.s, "false") {
+			value.s = strings.ToLower(value.s)
+		} else if _, err := strconv.ParseInt(value.s, 10, 64); err != nil {
+			return "", zoneSQLValue{}, errors.Newf("unsupported value of %q: %s", name, value.s)
+		}
+	}
+	if err != nil {
+		return "", zoneSQLValue{}, errors.Wrapf(err, "invalid value of %q", name)
+	}
+	p.skipSpace()
+	if !p.done() {
+		if p.s[p.pos] != ',' {
+			return "", zoneSQLValue{}, errors.Newf("expected ',' at %q", p.s[p.pos:])
+		}
+		p.pos++
+		p.skipSpace()
+	}
+	return name, value, nil
+}
+
+// stringLiteral scans a '...' string, in which quotes are doubled.
+func (p *zoneSQLParser) stringLiteral() (zoneSQLValue, error) {
+	var b strings.Builder
+	for p.pos++; !p.done(); p.pos++ {
+		if c := p.s[p.pos]; c != '\'' {
+			b.WriteByte(c)
+			continue
+		}
+		if p.pos+1 < len(p.s) && p.s[p.pos+1] == '\'' {
+			b.WriteByte('\'')
+			p.pos++
+			continue
+		}
+		p.pos++
+		return zoneSQLValue{s: b.String(), quoted: true}, nil
+	}
+	return zoneSQLValue{}, errors.New("unterminated string")
+}
+
+// escapedStringLiteral scans an e'...' string, whose opening quote is at the
+// current position, in which characters are escaped with backslashes.
+func (p *zoneSQLParser) escapedStringLiteral() (zoneSQLValue, error) {
+	var b strings.Builder
+	rest := p.s[p.pos+1:]
+	for len(rest) > 0 {
+		if rest[0] == '\'' {
+			p.pos = len(p.s) - len(rest) + 1
+			return zoneSQLValue{s: b.String(), quoted: true}, nil
+		}
+		r, _, tail, err := strconv.UnquoteChar(rest, '\'')
+		if err != nil {
+			return zoneSQLValue{}, err
+		}
+		b.WriteRune(r)
+		rest = tail
+	}
+	return zoneSQLValue{}, errors.New("unterminated string")
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// MinRegionsForRegionSurvival is the minimum number of regions which the
+// constraints of a zone with the REGION_FAILURE survival goal must place
+// replicas in, so that a quorum of replicas survives the loss of any region.
+const MinRegionsForRegionSurvival = 3
+
+// survivalGoalYAMLNames are the names of the survival goals in YAML.
+var survivalGoalYAMLNames = map[SurvivalGoal]string{
+	SurvivalGoal_ZONE_FAILURE:   "zone",
+	SurvivalGoal_REGION_FAILURE: "region",
+}
+
+// parseSurvivalGoal returns the survival goal with the given YAML name.
+func parseSurvivalGoal(s string) (SurvivalGoal, error) {
+	for goal, name := range survivalGoalYAMLNames {
+		if s == name {
+			return goal, nil
+		}
+	}
+	return 0, errors.Newf("invalid survival_goal %q, expected zone or region", s)
+}
+
+// survivalGoal is a SurvivalGoal which is marshaled to and unmarshaled from
+// YAML as zone or region.
+type survivalGoal SurvivalGoal
+
+var _ yaml.Marshaler = survivalGoal(0)
+var _ yaml.Unmarshaler = (*survivalGoal)(nil)
+
+// MarshalYAML implements yaml.Marshaler.
+func (g survivalGoal) MarshalYAML() (interface{}, error) {
+	name, ok := survivalGoalYAMLNames[SurvivalGoal(g)]
+	if !ok {
+		return nil, errors.AssertionFailedf("unknown survival goal %d", g)
+	}
+	return name, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (g *survivalGoal) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	goal, err := parseSurvivalGoal(s)
+	if err != nil {
+		return yamlNodeError(value, err)
+	}
+	*g = survivalGoal(goal)
+	return nil
+}
+
+// constrainedRegions returns the sorted regions which the required constraints
+// and voter constraints of the zone place replicas in. Wildcard constraints
+// don't name a region, so they're ignored.
+func (z *ZoneConfig) constrainedRegions() []string {
+	seen := make(map[string]struct{})
+	var regions []string
+	for _, conjunctions := range [][]ConstraintsConjunction{z.Constraints, z.VoterConstraints} {
+		for _, conjunction := range conjunctions {
+			for _, c := range conjunction.Constraints {
+				if c.Type != Constraint_REQUIRED || c.Key != regionTierKey || c.hasWildcard() {
+					continue
+				}
+				if _, ok := seen[c.Value]; !ok {
+					seen[c.Value] = struct{}{}
+					regions = append(regions, c.Value)
+				}
+			}
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// validateSurvivalGoalAndRegions returns an error if the survival goal or the
+// primary and secondary regions of the zone don't agree with each other or
+// with its constraints.
+func (z *ZoneConfig) validateSurvivalGoalAndRegions() error {
+	regions := z.constrainedRegions()
+	if z.SurvivalGoal != nil && *z.SurvivalGoal == SurvivalGoal_REGION_FAILURE &&
+		len(regions) < MinRegionsForRegionSurvival {
+		return fmt.Errorf("survival_goal region requires constraints in at least %d regions, found %d",
+			MinRegionsForRegionSurvival, len(regions))
+	}
+	if z.SecondaryRegion != nil {
+		if z.PrimaryRegion == nil {
+			return fmt.Errorf("secondary_region requires primary_region")
+		}
+		if *z.SecondaryRegion == *z.PrimaryRegion {
+			return fmt.Errorf("secondary_region %q must differ from primary_region", *z.SecondaryRegion)
+		}
+	}
+	for _, f := range []struct {
+		name   string
+		region *string
+	}{
+		{"primary_region", z.PrimaryRegion},
+		{"secondary_region", z.SecondaryRegion},
+	} {
+		if f.region == nil {
+			continue
+		}
+		if *f.region == "" {
+			return fmt.Errorf("%s must not be empty", f.name)
+		}
+		if len(regions) == 0 {
+			continue
+		}
+		if i := sort.SearchStrings(regions, *f.region); i == len(regions) || regions[i] != *f.region {
+			return fmt.Errorf("%s %q is not among the regions of the constraints (%s)",
+				f.name, *f.region, strings.Join(regions, ", "))
+		}
+	}
+	return nil
+}
@@ -12,23 +12,43 @@ package zonepb
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/storage/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	proto "github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/require"
-	yaml "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
+// zoneFromYAML returns the zone config which the YAML document unmarshals to
+// on top of base, e.g. NewZoneConfig() or DefaultZoneConfig(). The aliases of
+// the document are checked as they are by SET ZONE.
+func zoneFromYAML(t *testing.T, base ZoneConfig, s string) ZoneConfig {
+	t.Helper()
+	require.NoError(t, CheckYAMLAliases([]byte(s)))
+	require.NoError(t, yaml.Unmarshal([]byte(s), &base))
+	return base
+}
+
 func TestZoneConfigValidate(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -48,6 +68,21 @@ func TestZoneConfigValidate(t *testing.T) {
 			},
 			"at least one replica is required",
 		},
+		{
+			ZoneConfig{
+				NumReplicas: proto.Int32(0),
+				GlobalReads: proto.Bool(true),
+				Subzones:    []Subzone{{IndexID: 1, Config: ZoneConfig{GlobalReads: proto.Bool(true)}}},
+			},
+			"global_reads cannot be set on a subzone placeholder with zero replicas",
+		},
+		{
+			ZoneConfig{
+				NumReplicas: proto.Int32(0),
+				Subzones:    []Subzone{{IndexID: 1, Config: ZoneConfig{GlobalReads: proto.Bool(true)}}},
+			},
+			"",
+		},
 		{
 			ZoneConfig{
 				NumReplicas: proto.Int32(2),
@@ -228,6 +263,39 @@ func TestZoneConfigValidate(t *testing.T) {
 			},
 			"lease preference constraints must either be required .+ or prohibited .+",
 		},
+		{
+			ZoneConfig{
+				NumReplicas:   proto.Int32(1),
+				RangeMaxBytes: DefaultZoneConfig().RangeMaxBytes,
+				GC:            &GCPolicy{TTLSeconds: 1},
+				Constraints: []ConstraintsConjunction{
+					{Constraints: []Constraint{{Value: "ssd*", Type: Constraint_REQUIRED}}},
+				},
+			},
+			"wildcards are only supported in the values of locality constraints",
+		},
+		{
+			ZoneConfig{
+				NumReplicas:   proto.Int32(1),
+				RangeMaxBytes: DefaultZoneConfig().RangeMaxBytes,
+				GC:            &GCPolicy{TTLSeconds: 1},
+				LeasePreferences: []LeasePreference{
+					{Constraints: []Constraint{{Key: "reg*", Value: "a", Type: Constraint_REQUIRED}}},
+				},
+			},
+			"wildcards are only supported in the values of locality constraints",
+		},
+		{
+			ZoneConfig{
+				NumReplicas:   proto.Int32(1),
+				RangeMaxBytes: DefaultZoneConfig().RangeMaxBytes,
+				GC:            &GCPolicy{TTLSeconds: 1},
+				Constraints: []ConstraintsConjunction{
+					{Constraints: []Constraint{{Key: "region", Value: "us-*", Type: Constraint_REQUIRED}}},
+				},
+			},
+			"",
+		},
 		{
 			ZoneConfig{
 				NumReplicas:   proto.Int32(1),
@@ -274,6 +342,86 @@ func TestZoneConfigValidateNonVoterSpecific(t *testing.T) {
 			},
 			expected: "prohibitive constraint .* conflicts with voter_constraint .*",
 		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(3),
+				NumVoters:   proto.Int32(1),
+				Constraints: []ConstraintsConjunction{
+					{Constraints: []Constraint{{Key: "region", Value: "us-*", Type: Constraint_PROHIBITED}}},
+				},
+				VoterConstraints: []ConstraintsConjunction{
+					{Constraints: []Constraint{{Key: "region", Value: "us-east1", Type: Constraint_REQUIRED}}},
+				},
+			},
+			expected: "prohibitive constraint -region=us-\\* conflicts with voter_constraint \\+region=us-east1",
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(5),
+				NumVoters:   proto.Int32(3),
+				VoterConstraints: []ConstraintsConjunction{
+					{NumReplicas: 2, Constraints: []Constraint{{Key: "region", Value: "a", Type: Constraint_REQUIRED}}},
+					{NumReplicas: 2, Constraints: []Constraint{{Key: "region", Value: "b", Type: Constraint_REQUIRED}}},
+				},
+			},
+			expected: `the number of replicas specified in voter_constraints \(4\) cannot be greater than ` +
+				`the number of voters configured for the zone \(3\)`,
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(5),
+				NumVoters:   proto.Int32(3),
+				VoterConstraints: []ConstraintsConjunction{
+					{NumReplicas: 2, Constraints: []Constraint{{Key: "region", Value: "a", Type: Constraint_REQUIRED}}},
+					{NumReplicas: 1, Constraints: []Constraint{{Key: "region", Value: "b", Type: Constraint_REQUIRED}}},
+				},
+			},
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(5),
+				NumVoters:   proto.Int32(3),
+				VoterConstraints: []ConstraintsConjunction{
+					{NumReplicas: 2, Constraints: []Constraint{{Key: "region", Value: "a", Type: Constraint_REQUIRED}}},
+					{NumReplicas: 0, Constraints: []Constraint{{Key: "region", Value: "b", Type: Constraint_REQUIRED}}},
+				},
+			},
+			expected: "constraints must apply to at least one replica",
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(3),
+				NumVoters:   proto.Int32(5),
+			},
+			expected: "num_voters cannot be greater than num_replicas",
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(3),
+				NumVoters:   proto.Int32(0),
+			},
+			expected: "at least one voting replica is required",
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(3),
+				NumVoters:   proto.Int32(2),
+			},
+			expected: "at least 3 voting replicas are required for multi-replica configurations",
+		},
+		{
+			// Two replicas are allowed if only one of them is a voter.
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(2),
+				NumVoters:   proto.Int32(1),
+			},
+		},
+		{
+			cfg: ZoneConfig{
+				NumReplicas: proto.Int32(5),
+				NumVoters:   proto.Int32(4),
+			},
+		},
 	}
 
 	for i, c := range testCases {
@@ -284,6 +432,49 @@ func TestZoneConfigValidateNonVoterSpecific(t *testing.T) {
 	}
 }
 
+func TestZoneConfigInheritNumVoters(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	parent := ZoneConfig{NumReplicas: proto.Int32(5), NumVoters: proto.Int32(3)}
+	testCases := []struct {
+		name                      string
+		zone                      ZoneConfig
+		expReplicas, expNumVoters int32
+	}{
+		{
+			name:         "unset",
+			zone:         ZoneConfig{},
+			expReplicas:  5,
+			expNumVoters: 3,
+		},
+		{
+			name:         "subzone placeholder",
+			zone:         ZoneConfig{NumReplicas: proto.Int32(0), NumVoters: proto.Int32(0)},
+			expReplicas:  5,
+			expNumVoters: 3,
+		},
+		{
+			name:         "num_voters set",
+			zone:         ZoneConfig{NumVoters: proto.Int32(5)},
+			expReplicas:  5,
+			expNumVoters: 5,
+		},
+		{
+			name:         "both set",
+			zone:         ZoneConfig{NumReplicas: proto.Int32(7), NumVoters: proto.Int32(1)},
+			expReplicas:  7,
+			expNumVoters: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.zone.InheritFromParent(&parent)
+			require.Equal(t, tc.expReplicas, *tc.zone.NumReplicas)
+			require.Equal(t, tc.expNumVoters, *tc.zone.NumVoters)
+		})
+	}
+}
+
 func TestZoneConfigValidateTandemFields(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -395,6 +586,347 @@ func TestZoneConfigValidateTandemFields(t *testing.T) {
 	}
 }
 
+func TestConstraintCompare(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	constraints := []string{
+		"+a", "+a=1", "+a=10", "+a=2", "+b", "+region=us-east1", "+region=us-west1",
+		"-a", "-a=1", "a", "a=1", "b",
+	}
+	for i := range constraints {
+		var ci Constraint
+		require.NoError(t, ci.FromString(constraints[i]))
+		for j := range constraints {
+			var cj Constraint
+			require.NoError(t, cj.FromString(constraints[j]))
+			expected := strings.Compare(ci.String(), cj.String())
+			require.Equal(t, expected, ci.Compare(cj), "%s vs %s", ci, cj)
+		}
+	}
+}
+
+func TestConstraintEscaping(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		c     Constraint
+		short string
+	}{
+		{Constraint{Type: Constraint_REQUIRED, Key: "region", Value: "us-east1"}, "+region=us-east1"},
+		{Constraint{Type: Constraint_REQUIRED, Key: "dc", Value: "a,b"}, `+dc=a\,b`},
+		{Constraint{Type: Constraint_PROHIBITED, Key: "k=v", Value: "x=y"}, `-k\=v=x\=y`},
+		{Constraint{Type: Constraint_REQUIRED, Value: `ssd\`}, `+ssd\\`},
+		{Constraint{Type: Constraint_DEPRECATED_POSITIVE, Value: "+a"}, `\+a`},
+		{Constraint{Type: Constraint_DEPRECATED_POSITIVE, Key: "-k", Value: "v"}, `\-k=v`},
+	} {
+		require.Equal(t, tc.short, tc.c.String())
+		var c Constraint
+		require.NoError(t, c.FromString(tc.short))
+		require.Equal(t, tc.c, c)
+	}
+
+	var c Constraint
+	require.EqualError(t, c.FromString(`+a=b\`), `constraint "+a=b\\" ends with an unescaped backslash`)
+	require.Error(t, c.FromString(`+a=b=c`))
+
+	// Arbitrary keys and values round-trip, including in lists of constraints.
+	rng := rand.New(rand.NewSource(timeutil.Now().UnixNano()))
+	const alphabet = `ab,=\+- `
+	randString := func() string {
+		b := make([]byte, rng.Intn(6))
+		for i := range b {
+			b[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 1000; i++ {
+		constraints := make([]Constraint, 1+rng.Intn(3))
+		for j := range constraints {
+			constraints[j] = Constraint{
+				Type:  Constraint_Type(rng.Intn(3)),
+				Key:   randString(),
+				Value: randString(),
+			}
+			if constraints[j].Value == "" && constraints[j].Type == Constraint_DEPRECATED_POSITIVE {
+				// The empty string isn't a valid constraint.
+				constraints[j].Value = "v"
+			}
+		}
+		parsed, err := parseShortConstraints(shortConstraints(constraints))
+		require.NoError(t, err)
+		require.Equal(t, constraints, parsed)
+
+		joined := strings.Join(shortConstraints(constraints), ",")
+		parsed, err = parseShortConstraints(splitEscapedConstraints(joined, ','))
+		require.NoError(t, err)
+		require.Equal(t, constraints, parsed, joined)
+	}
+
+	// Per-replica constraints are keyed by their joined form in YAML.
+	zone := NewZoneConfig()
+	zone.NumReplicas = proto.Int32(3)
+	zone.Constraints = []ConstraintsConjunction{{NumReplicas: 2, Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: "dc", Value: "a,b"},
+		{Type: Constraint_REQUIRED, Key: "rack", Value: "1=2"},
+	}}}
+	zone.InheritedConstraints = false
+	zone.LeasePreferences = []LeasePreference{{Constraints: zone.Constraints[0].Constraints}}
+	zone.InheritedLeasePreferences = false
+	out, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	roundTripped := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal(out, roundTripped))
+	require.Equal(t, zone.Constraints, roundTripped.Constraints)
+	require.Equal(t, zone.LeasePreferences, roundTripped.LeasePreferences)
+
+	pref, err := ParseLeasePreference(zone.LeasePreferences[0].String())
+	require.NoError(t, err)
+	require.Equal(t, zone.LeasePreferences[0], pref)
+}
+
+func TestConstraintsConjunctionSetOperations(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	conj := func(s string) ConstraintsConjunction {
+		if s == "" {
+			return ConstraintsConjunction{}
+		}
+		constraints, err := parseShortConstraints(strings.Split(s, ","))
+		require.NoError(t, err)
+		return ConstraintsConjunction{Constraints: constraints}
+	}
+	for _, tc := range []struct {
+		a, b      string
+		union     string
+		intersect string
+		subtract  string
+		subset    bool
+	}{
+		{a: "", b: "", union: "", intersect: "", subtract: "", subset: true},
+		{a: "", b: "+ssd", union: "+ssd", intersect: "", subtract: "", subset: true},
+		{a: "+ssd", b: "", union: "+ssd", intersect: "", subtract: "+ssd", subset: false},
+		{
+			a: "+region=a,+ssd", b: "+ssd,+region=a,-zone=a1",
+			union: "+region=a,+ssd,-zone=a1", intersect: "+region=a,+ssd", subtract: "", subset: true,
+		},
+		{
+			a: "+region=a,+ssd", b: "+region=a,+hdd",
+			union: "+hdd,+region=a,+ssd", intersect: "+region=a", subtract: "+ssd", subset: false,
+		},
+		{
+			// +region=us-east1 implies +region=us-*.
+			a: "+region=us-*", b: "+region=us-east1,+ssd",
+			union: "+region=us-*,+region=us-east1,+ssd", intersect: "+region=us-*", subtract: "", subset: true,
+		},
+		{
+			a: "+region=us-east1", b: "+region=us-*",
+			union: "+region=us-*,+region=us-east1", intersect: "+region=us-*", subtract: "+region=us-east1",
+			subset: false,
+		},
+		{
+			// -region=us-* implies -region=us-east1.
+			a: "-region=us-east1", b: "-region=us-*",
+			union: "-region=us-*,-region=us-east1", intersect: "-region=us-east1", subtract: "", subset: true,
+		},
+		{
+			// Required and prohibited constraints don't imply each other.
+			a: "+region=a", b: "-region=b",
+			union: "+region=a,-region=b", intersect: "", subtract: "+region=a", subset: false,
+		},
+	} {
+		a, b := conj(tc.a), conj(tc.b)
+		union, err := a.Union(b)
+		require.NoError(t, err)
+		require.Equal(t, conj(tc.union), union, "%s ∪ %s", a, b)
+		reversed, err := b.Union(a)
+		require.NoError(t, err)
+		require.Equal(t, union, reversed)
+		require.Equal(t, conj(tc.intersect), a.Intersect(b), "%s ∩ %s", a, b)
+		require.Equal(t, a.Intersect(b), b.Intersect(a))
+		require.Equal(t, conj(tc.subtract), a.Subtract(b), "%s - %s", a, b)
+		require.Equal(t, tc.subset, a.IsSubsetOf(b), "%s ⊆ %s", a, b)
+		// The union is a superset of both conjunctions, and the intersection a
+		// subset of both.
+		require.True(t, a.IsSubsetOf(union) && b.IsSubsetOf(union))
+		require.True(t, a.Intersect(b).IsSubsetOf(a) && a.Intersect(b).IsSubsetOf(b))
+		require.Equal(t, len(a.Subtract(b).Constraints) == 0, a.IsSubsetOf(b))
+	}
+
+	for _, tc := range []struct{ a, b, expectedErr string }{
+		{"+ssd", "-ssd", "constraints +ssd and -ssd can't both be satisfied"},
+		{"+region=us-east1", "-region=us-*", "constraints +region=us-east1 and -region=us-* can't both be satisfied"},
+	} {
+		_, err := conj(tc.a).Union(conj(tc.b))
+		require.EqualError(t, err, tc.expectedErr)
+	}
+	// Prohibiting some of the stores of a required wildcard leaves others.
+	_, err := conj("+region=us-*").Union(conj("-region=us-east1"))
+	require.NoError(t, err)
+}
+
+func TestConstraintsConjunctionCompare(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	conjunction := func(numReplicas int32, shorts ...string) ConstraintsConjunction {
+		c := ConstraintsConjunction{NumReplicas: numReplicas, Constraints: make([]Constraint, len(shorts))}
+		for i, short := range shorts {
+			require.NoError(t, c.Constraints[i].FromString(short))
+		}
+		return c
+	}
+	// In ascending order.
+	conjunctions := []ConstraintsConjunction{
+		conjunction(0),
+		conjunction(1, "+a"),
+		conjunction(2, "+a"),
+		conjunction(1, "+a", "+b"),
+		conjunction(1, "+a", "+c"),
+		conjunction(1, "+b"),
+	}
+	for i := range conjunctions {
+		for j := range conjunctions {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			require.Equal(t, expected, conjunctions[i].Compare(conjunctions[j]),
+				"%s vs %s", conjunctions[i], conjunctions[j])
+		}
+	}
+}
+
+func TestZoneConfigEquivalentTo(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	conjunction := func(numReplicas int32, shorts ...string) ConstraintsConjunction {
+		c := ConstraintsConjunction{NumReplicas: numReplicas, Constraints: make([]Constraint, len(shorts))}
+		for i, short := range shorts {
+			require.NoError(t, c.Constraints[i].FromString(short))
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name       string
+		a, b       ZoneConfig
+		equivalent bool
+	}{
+		{
+			name:       "identical",
+			a:          zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3\nconstraints: {+a: 1, +b: 2}"),
+			b:          zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3\nconstraints: {+a: 1, +b: 2}"),
+			equivalent: true,
+		},
+		{
+			name:       "different fields",
+			a:          zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3"),
+			b:          zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 5"),
+			equivalent: false,
+		},
+		{
+			name: "constraint order",
+			a: zoneFromYAML(t, *NewZoneConfig(),
+				"constraints: [+a, -b]\nlease_preferences: [[+a, +c], [+b]]"),
+			b: zoneFromYAML(t, *NewZoneConfig(),
+				"constraints: [-b, +a]\nlease_preferences: [[+c, +a], [+b]]"),
+			equivalent: true,
+		},
+		{
+			name: "lease preference order",
+			a: zoneFromYAML(t, *NewZoneConfig(),
+				"constraints: [+a]\nlease_preferences: [[+a], [+b]]"),
+			b: zoneFromYAML(t, *NewZoneConfig(),
+				"constraints: [+a]\nlease_preferences: [[+b], [+a]]"),
+			equivalent: false,
+		},
+		{
+			name: "conjunction order",
+			a: ZoneConfig{Constraints: []ConstraintsConjunction{
+				conjunction(1, "+a", "+b"), conjunction(2, "+c"),
+			}},
+			b: ZoneConfig{Constraints: []ConstraintsConjunction{
+				conjunction(2, "+c"), conjunction(1, "+b", "+a"),
+			}},
+			equivalent: true,
+		},
+		{
+			name:       "nil and empty",
+			a:          ZoneConfig{Constraints: []ConstraintsConjunction{}, LeasePreferences: []LeasePreference{}},
+			b:          ZoneConfig{},
+			equivalent: true,
+		},
+		{
+			name: "subzones",
+			a: ZoneConfig{Subzones: []Subzone{
+				{IndexID: 1, Config: zoneFromYAML(t, *NewZoneConfig(), "constraints: [+a, +b]")},
+			}},
+			b: ZoneConfig{Subzones: []Subzone{
+				{IndexID: 1, Config: zoneFromYAML(t, *NewZoneConfig(), "constraints: [+b, +a]")},
+			}},
+			equivalent: true,
+		},
+		{
+			name: "different subzones",
+			a: ZoneConfig{Subzones: []Subzone{
+				{IndexID: 1, Config: zoneFromYAML(t, *NewZoneConfig(), "constraints: [+a]")},
+			}},
+			b: ZoneConfig{Subzones: []Subzone{
+				{IndexID: 2, Config: zoneFromYAML(t, *NewZoneConfig(), "constraints: [+a]")},
+			}},
+			equivalent: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			aBefore, bBefore := protoutil.Clone(&tc.a), protoutil.Clone(&tc.b)
+			require.Equal(t, tc.equivalent, tc.a.EquivalentTo(&tc.b))
+			require.Equal(t, tc.equivalent, tc.b.EquivalentTo(&tc.a))
+			// Equivalent zone configs, and only those, hash the same.
+			require.Equal(t, tc.equivalent, tc.a.Hash() == tc.b.Hash())
+			require.Equal(t, tc.a.Hash(), protoutil.Clone(&tc.a).(*ZoneConfig).Hash())
+			// EquivalentTo and Hash don't modify their arguments.
+			require.Equal(t, aBefore, &tc.a)
+			require.Equal(t, bBefore, &tc.b)
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	old := DefaultZoneConfig()
+	old.Constraints = []ConstraintsConjunction{{Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: "region", Value: "us-east1"},
+		{Type: Constraint_PROHIBITED, Value: "ssd"},
+	}}}
+	require.Empty(t, UnifiedDiff(old, old))
+
+	// Reordered constraints and audit info aren't changes.
+	reordered := *protoutil.Clone(&old).(*ZoneConfig)
+	reordered.Constraints[0].Constraints[0], reordered.Constraints[0].Constraints[1] =
+		reordered.Constraints[0].Constraints[1], reordered.Constraints[0].Constraints[0]
+	reordered.AuditInfo = &ZoneConfigAuditInfo{ModifiedBy: "root"}
+	require.Empty(t, UnifiedDiff(old, reordered))
+
+	updated := *protoutil.Clone(&old).(*ZoneConfig)
+	updated.NumReplicas = proto.Int32(5)
+	require.Equal(t, `--- old
++++ new
+@@ -3,7 +3,7 @@
+ gc:
+   ttlseconds: 14400
+ global_reads: null
+-num_replicas: 3
++num_replicas: 5
+ num_voters: null
+ constraints: [+region=us-east1, -ssd]
+ voter_constraints: []
+`, UnifiedDiff(old, updated))
+}
+
 func TestZoneConfigSubzones(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -482,52 +1014,279 @@ func TestZoneConfigSubzones(t *testing.T) {
 	}
 }
 
-// TestZoneConfigMarshalYAML makes sure that ZoneConfig is correctly marshaled
-// to YAML and back.
-func TestZoneConfigMarshalYAML(t *testing.T) {
+func TestZoneConfigSubzoneOrderAndSpans(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
-	original := ZoneConfig{
-		RangeMinBytes: proto.Int64(1),
-		RangeMaxBytes: proto.Int64(1),
-		GC: &GCPolicy{
-			TTLSeconds: 1,
-		},
-		GlobalReads:                 proto.Bool(true),
-		NullVoterConstraintsIsEmpty: true,
-		NumReplicas:                 proto.Int32(2),
-		NumVoters:                   proto.Int32(1),
+	subzone := func(indexID uint32, partition string) Subzone {
+		return Subzone{IndexID: indexID, PartitionName: partition, Config: DefaultZoneConfig()}
+	}
+	subzoneNames := func(zone ZoneConfig) []string {
+		var names []string
+		for _, s := range zone.Subzones {
+			names = append(names, fmt.Sprintf("%d/%s", s.IndexID, s.PartitionName))
+		}
+		return names
+	}
+	spanNames := func(zone ZoneConfig) []string {
+		var names []string
+		for _, span := range zone.SubzoneSpans {
+			s := zone.Subzones[span.SubzoneIndex]
+			names = append(names, fmt.Sprintf("%s:%d/%s", string(span.Key), s.IndexID, s.PartitionName))
+		}
+		return names
 	}
 
-	testCases := []struct {
-		constraints      []ConstraintsConjunction
-		voterConstraints []ConstraintsConjunction
-		leasePreferences []LeasePreference
-		expected         string
-	}{
-		{
-			expected: `range_min_bytes: 1
-range_max_bytes: 1
-gc:
-  ttlseconds: 1
-global_reads: true
-num_replicas: 2
-num_voters: 1
-constraints: []
-voter_constraints: []
-lease_preferences: []
-`,
-		},
-		{
-			constraints: []ConstraintsConjunction{
-				{
-					Constraints: []Constraint{
-						{
-							Type:  Constraint_REQUIRED,
-							Key:   "duck",
-							Value: "foo",
-						},
-					},
+	var zone ZoneConfig
+	zone.SetSubzone(subzone(2, "b"))
+	zone.SetSubzone(subzone(1, ""))
+	zone.SubzoneSpans = []SubzoneSpan{
+		{Key: roachpb.Key("a"), SubzoneIndex: 0},
+		{Key: roachpb.Key("b"), SubzoneIndex: 1},
+	}
+	require.Equal(t, []string{"a:1/", "b:2/b"}, spanNames(zone))
+
+	// New subzones are inserted in order, and the spans keep referring to the
+	// same subzones.
+	zone.SetSubzone(subzone(2, ""))
+	zone.SetSubzone(subzone(2, "a"))
+	zone.SetSubzone(subzone(3, ""))
+	zone.SetSubzone(subzone(1, "a"))
+	require.Equal(t, []string{"1/", "1/a", "2/", "2/a", "2/b", "3/"}, subzoneNames(zone))
+	require.Equal(t, []string{"a:1/", "b:2/b"}, spanNames(zone))
+
+	// Overwriting a subzone keeps its position.
+	updated := subzone(2, "a")
+	updated.Config.NumReplicas = proto.Int32(7)
+	zone.SetSubzone(updated)
+	require.Equal(t, []string{"1/", "1/a", "2/", "2/a", "2/b", "3/"}, subzoneNames(zone))
+
+	// The subzone of an index is distinct from the subzones of its partitions.
+	s, ok := zone.GetSubzoneForIndexPartition(2, "a")
+	require.True(t, ok)
+	require.Equal(t, int32(7), *s.Config.NumReplicas)
+	s, ok = zone.GetSubzoneForIndexPartition(2, "")
+	require.True(t, ok)
+	require.Equal(t, "", s.PartitionName)
+	_, ok = zone.GetSubzoneForIndexPartition(3, "a")
+	require.False(t, ok)
+	_, ok = zone.GetSubzoneForIndexPartition(4, "")
+	require.False(t, ok)
+	// The returned subzone can be modified in place.
+	s.Config.NumReplicas = proto.Int32(9)
+	require.Equal(t, int32(9), *zone.GetSubzoneExact(2, "").Config.NumReplicas)
+
+	// Deleting subzones deletes their spans and renumbers the others.
+	require.True(t, zone.DeleteSubzone(1, ""))
+	require.Equal(t, []string{"1/a", "2/", "2/a", "2/b", "3/"}, subzoneNames(zone))
+	require.Equal(t, []string{"b:2/b"}, spanNames(zone))
+	require.False(t, zone.DeleteSubzone(1, ""))
+	zone.SubzoneSpans = append(zone.SubzoneSpans, SubzoneSpan{Key: roachpb.Key("c"), SubzoneIndex: 4})
+	zone.DeleteIndexSubzones(2)
+	require.Equal(t, []string{"1/a", "3/"}, subzoneNames(zone))
+	require.Equal(t, []string{"c:3/"}, spanNames(zone))
+}
+
+func TestMaterializeSubzoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	east := []ConstraintsConjunction{
+		{Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "us-east1"}}},
+	}
+	zone := DefaultZoneConfig()
+	require.Nil(t, zone.MaterializeSubzoneConfigs())
+
+	index := NewZoneConfig()
+	index.NumReplicas = proto.Int32(5)
+	index.Constraints = east
+	index.InheritedConstraints = false
+	zone.SetSubzone(Subzone{IndexID: 1, Config: *index})
+	partitionA := NewZoneConfig()
+	partitionA.GC = &GCPolicy{TTLSeconds: 60}
+	zone.SetSubzone(Subzone{IndexID: 1, PartitionName: "a", Config: *partitionA})
+	partitionB := NewZoneConfig()
+	partitionB.NumReplicas = proto.Int32(7)
+	zone.SetSubzone(Subzone{IndexID: 1, PartitionName: "b", Config: *partitionB})
+	// The partition of an index without a subzone of its own.
+	partitionC := NewZoneConfig()
+	partitionC.RangeMaxBytes = proto.Int64(1 << 30)
+	zone.SetSubzone(Subzone{IndexID: 2, PartitionName: "c", Config: *partitionC})
+	before := protoutil.Clone(&zone).(*ZoneConfig)
+
+	configs := zone.MaterializeSubzoneConfigs()
+	require.Len(t, configs, 4)
+	for key, config := range configs {
+		require.NoError(t, config.Validate(), "%+v", key)
+		require.Equal(t, zone.RangeMinBytes, config.RangeMinBytes)
+	}
+
+	config := configs[SubzoneKey{IndexID: 1}]
+	require.Equal(t, int32(5), *config.NumReplicas)
+	require.Equal(t, east, config.Constraints)
+	require.Equal(t, zone.GC, config.GC)
+
+	// Partitions inherit from their index before inheriting from the zone.
+	config = configs[SubzoneKey{IndexID: 1, PartitionName: "a"}]
+	require.Equal(t, int32(5), *config.NumReplicas)
+	require.Equal(t, east, config.Constraints)
+	require.Equal(t, int32(60), config.GC.TTLSeconds)
+	config = configs[SubzoneKey{IndexID: 1, PartitionName: "b"}]
+	require.Equal(t, int32(7), *config.NumReplicas)
+	require.Equal(t, east, config.Constraints)
+	config = configs[SubzoneKey{IndexID: 2, PartitionName: "c"}]
+	require.Equal(t, int32(3), *config.NumReplicas)
+	require.Nil(t, config.Constraints)
+	require.Equal(t, int64(1<<30), *config.RangeMaxBytes)
+
+	// The zone config and its subzones are left unmodified.
+	require.Equal(t, before, &zone)
+}
+
+func TestZoneConfigClone(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := DefaultZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+constraints: [+region=a]
+lease_preferences: [[+region=a]]
+`), &zone))
+	// The first subzone sets every field, and the others only the GC policy.
+	zone.SetSubzone(Subzone{IndexID: 1, Config: DefaultZoneConfig()})
+	for i := uint32(2); i <= 3; i++ {
+		zone.SetSubzone(Subzone{IndexID: i, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 100}}})
+	}
+	zone.SubzoneSpans = []SubzoneSpan{
+		{Key: roachpb.Key("a"), SubzoneIndex: 0},
+		{Key: roachpb.Key("b"), SubzoneIndex: 1},
+		{Key: roachpb.Key("c"), SubzoneIndex: 2},
+	}
+	before := protoutil.Clone(&zone).(*ZoneConfig)
+
+	// The clone shares the slices of the original until they're modified.
+	clone := zone.Clone()
+	require.Equal(t, &zone, clone)
+	require.True(t, &zone.Subzones[0] == &clone.Subzones[0])
+	require.True(t, &zone.Constraints[0] == &clone.Constraints[0])
+
+	for _, mutate := range []func(c *ZoneConfig){
+		func(c *ZoneConfig) { *c.NumReplicas = 7 },
+		func(c *ZoneConfig) { c.GC.TTLSeconds = 1 },
+		func(c *ZoneConfig) {
+			c.Constraints = append(c.Constraints, ConstraintsConjunction{
+				Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "b"}},
+			})
+		},
+		func(c *ZoneConfig) { c.SetSubzone(Subzone{IndexID: 2, PartitionName: "p"}) },
+		func(c *ZoneConfig) {
+			c.SetSubzone(Subzone{IndexID: 1, Config: ZoneConfig{NumReplicas: proto.Int32(9)}})
+		},
+		func(c *ZoneConfig) { c.DeleteSubzone(1, "") },
+		func(c *ZoneConfig) { c.DeleteIndexSubzones(3) },
+		func(c *ZoneConfig) {
+			c.CloneSubzones()
+			c.Subzones[0].Config.GC = &GCPolicy{TTLSeconds: 1}
+		},
+	} {
+		clone := zone.Clone()
+		mutate(clone)
+		require.NotEqual(t, before, clone)
+		require.Equal(t, before, &zone)
+	}
+	require.Nil(t, (*ZoneConfig)(nil).Clone())
+}
+
+func TestValidateSubzoneSpans(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	span := func(key, endKey string, subzoneIndex int32) SubzoneSpan {
+		s := SubzoneSpan{Key: roachpb.Key(key), SubzoneIndex: subzoneIndex}
+		if endKey != "" {
+			s.EndKey = roachpb.Key(endKey)
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		spans []SubzoneSpan
+		err   string
+	}{
+		{spans: nil},
+		// The EndKey of "b" is "c".
+		{spans: []SubzoneSpan{span("a", "b", 0), span("b", "", 1), span("c", "e", 0)}},
+		{
+			spans: []SubzoneSpan{span("a", "b", 0), span("b", "c", 2)},
+			err:   "subzone span 1 refers to subzone 2, but there are 2 subzones",
+		},
+		{
+			spans: []SubzoneSpan{span("a", "b", -1)},
+			err:   "subzone span 0 refers to subzone -1, but there are 2 subzones",
+		},
+		{
+			spans: []SubzoneSpan{span("b", "a", 0)},
+			err:   "subzone span 0 [62, 61) is empty",
+		},
+		{
+			spans: []SubzoneSpan{span("a", "", 0), span("a", "c", 1)},
+			err:   "subzone span 1 [61, 63) overlaps with or precedes span 0 [61, 62)",
+		},
+		{
+			spans: []SubzoneSpan{span("c", "d", 0), span("a", "b", 1)},
+			err:   "subzone span 1 [61, 62) overlaps with or precedes span 0 [63, 64)",
+		},
+	} {
+		err := ValidateSubzoneSpans(tc.spans, 2)
+		if tc.err == "" {
+			require.NoError(t, err)
+		} else {
+			require.EqualError(t, err, tc.err)
+		}
+	}
+}
+
+// TestZoneConfigMarshalYAML makes sure that ZoneConfig is correctly marshaled
+// to YAML and back.
+func TestZoneConfigMarshalYAML(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	original := ZoneConfig{
+		RangeMinBytes: proto.Int64(1),
+		RangeMaxBytes: proto.Int64(1),
+		GC: &GCPolicy{
+			TTLSeconds: 1,
+		},
+		GlobalReads:                 proto.Bool(true),
+		NullVoterConstraintsIsEmpty: true,
+		NumReplicas:                 proto.Int32(2),
+		NumVoters:                   proto.Int32(1),
+	}
+
+	testCases := []struct {
+		constraints      []ConstraintsConjunction
+		voterConstraints []ConstraintsConjunction
+		leasePreferences []LeasePreference
+		expected         string
+	}{
+		{
+			expected: `range_min_bytes: 1
+range_max_bytes: 1
+gc:
+  ttlseconds: 1
+global_reads: true
+num_replicas: 2
+num_voters: 1
+constraints: []
+voter_constraints: []
+lease_preferences: []
+`,
+		},
+		{
+			constraints: []ConstraintsConjunction{
+				{
+					Constraints: []Constraint{
+						{
+							Type:  Constraint_REQUIRED,
+							Key:   "duck",
+							Value: "foo",
+						},
+					},
 				},
 			},
 			voterConstraints: []ConstraintsConjunction{
@@ -829,20 +1588,20 @@ lease_preferences: [[+duck=bar1, +duck=bar2], [-duck=foo]]
 			original.Constraints = tc.constraints
 			original.VoterConstraints = tc.voterConstraints
 			original.LeasePreferences = tc.leasePreferences
-			body, err := yaml.Marshal(original)
+			body, err := MarshalYAML(original)
 			if err != nil {
 				t.Fatal(err)
 			}
 			if string(body) != tc.expected {
-				t.Fatalf("yaml.Marshal(%+v)\ngot:\n%s\nwant:\n%s", original, body, tc.expected)
+				t.Fatalf("MarshalYAML(%+v)\ngot:\n%s\nwant:\n%s", original, body, tc.expected)
 			}
 
 			var unmarshaled ZoneConfig
-			if err := yaml.UnmarshalStrict(body, &unmarshaled); err != nil {
+			if err := yaml.Unmarshal(body, &unmarshaled); err != nil {
 				t.Fatal(err)
 			}
 			if !unmarshaled.Equal(&original) {
-				t.Errorf("yaml.UnmarshalStrict(%q)\ngot:\n%+v\nwant:\n%+v", body, unmarshaled, original)
+				t.Errorf("yaml.Unmarshal(%q)\ngot:\n%+v\nwant:\n%+v", body, unmarshaled, original)
 			}
 		})
 	}
@@ -851,6 +1610,53 @@ lease_preferences: [[+duck=bar1, +duck=bar2], [-duck=foo]]
 // TestExperimentalLeasePreferencesYAML makes sure that we accept the
 // lease_preferences YAML field both with and without the "experimental_"
 // prefix.
+func TestZoneConfigYAMLKeyOrder(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// Every field of the YAML encoding has a documented position.
+	typ := reflect.TypeOf(marshalableZoneConfig{})
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		keys = append(keys, name)
+	}
+	require.ElementsMatch(t, zoneConfigYAMLKeyOrder, keys)
+
+	zone := DefaultZoneConfig()
+	zone.NumVoters = proto.Int32(3)
+	zone.GlobalReads = proto.Bool(true)
+	zone.SurvivalGoal = SurvivalGoal_REGION_FAILURE.Enum()
+	zone.PrimaryRegion = proto.String("us-east1")
+	zone.SecondaryRegion = proto.String("us-west1")
+	zone.SplitHints = []roachpb.Key{{0x8a}}
+	topLevelKeys := func(out []byte) []string {
+		var doc yaml.Node
+		require.NoError(t, yaml.Unmarshal(out, &doc))
+		var keys []string
+		for i := 0; i < len(doc.Content[0].Content); i += 2 {
+			keys = append(keys, doc.Content[0].Content[i].Value)
+		}
+		return keys
+	}
+	out, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Equal(t, zoneConfigYAMLKeyOrder, topLevelKeys(out))
+
+	// The audit info follows the documented keys.
+	zone.AuditInfo = &ZoneConfigAuditInfo{ModifiedBy: "root"}
+	out, err = MarshalYAML(ZoneConfigWithAuditInfo{zone})
+	require.NoError(t, err)
+	require.Equal(t, append(zoneConfigYAMLKeyOrder[:len(zoneConfigYAMLKeyOrder):len(zoneConfigYAMLKeyOrder)],
+		"audit_info"), topLevelKeys(out))
+}
+
 func TestExperimentalLeasePreferencesYAML(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -919,78 +1725,857 @@ func TestExperimentalLeasePreferencesYAML(t *testing.T) {
 
 	for _, tc := range testCases {
 		zone := originalZone
-		if err := yaml.UnmarshalStrict([]byte(tc.input), &zone); err != nil {
+		if err := yaml.Unmarshal([]byte(tc.input), &zone); err != nil {
 			t.Fatal(err)
 		}
 		if !reflect.DeepEqual(zone.LeasePreferences, tc.expected) {
 			t.Errorf("unmarshaling %q got %+v; want %+v", tc.input, zone.LeasePreferences, tc.expected)
 		}
+
+		// The deprecated field is reported by UnmarshalYAMLWithWarnings.
+		zone = originalZone
+		warnings, err := UnmarshalYAMLWithWarnings([]byte(tc.input), &zone)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, zone.LeasePreferences)
+		if strings.Contains(tc.input, "experimental_lease_preferences") {
+			require.Equal(t, []Warning{{
+				Code:    WarningDeprecatedField,
+				Field:   "experimental_lease_preferences",
+				Message: "experimental_lease_preferences is deprecated, use lease_preferences",
+			}}, warnings, tc.input)
+		} else {
+			require.Empty(t, warnings, tc.input)
+		}
 	}
+
+	// Documents which fail to unmarshal don't have warnings.
+	warnings, err := UnmarshalYAMLWithWarnings(
+		[]byte("experimental_lease_preferences: [[+a=b]]\nunknown: 1"), &ZoneConfig{})
+	require.Error(t, err)
+	require.Nil(t, warnings)
 }
 
-func TestConstraintsListYAML(t *testing.T) {
+func TestValidateYAML(t *testing.T) {
 	defer leaktest.AfterTest(t)()
+	defer func() { require.NoError(t, SetNamedConstraintSets(nil)) }()
 
-	testCases := []struct {
-		input     string
-		expectErr bool
+	const badConstraint = `constraint needs to be in the form "(key=)value", not "a=b=c"`
+	for _, tc := range []struct {
+		input    string
+		expected []ValidationFinding
 	}{
-		{},
-		{input: "[]"},
-		{input: "[+a]"},
-		{input: "[+a, -b=2, +c=d, e]"},
-		{input: "{+a: 1}"},
-		{input: "{+a: 1, '+a=1,+b,+c=d': 2}"},
-		{input: "{'+a: 1'}"},  // unfortunately this parses just fine because yaml autoconverts it to a list...
-		{input: "{+a,+b: 1}"}, // this also parses fine but will fail ZoneConfig.Validate()
-		{input: "{+a: 1, '+a=1,+b,+c=d': b}", expectErr: true},
-		{input: "[+a: 1]", expectErr: true},
-		{input: "[+a: 1, '+a=1,+b,+c=d': 2]", expectErr: true},
-		{input: "{\"+a=1,+b=2\": 1}"},    // this will work in SQL: constraints='{"+a=1,+b=2": 1}'
-		{input: "{\"+a=1,+b=2,+c\": 1}"}, // won't work in SQL: constraints='{"+a=1,+b=2,+c": 1}'
-		{input: "{'+a=1,+b=2,+c': 1}"},   // this will work in SQL: constraints=e'{\'+a=1,+b=2,+c\': 1}'
+		{input: ""},
+		{input: "num_replicas: 5\nconstraints: [+region=us-east1]\nlease_preferences: [[+region=us-east1]]"},
+		{
+			input: "[1, 2]",
+			expected: []ValidationFinding{
+				{ValidationError, "", 1, 1, "zone config must be a mapping of fields to values"},
+			},
+		},
+		{
+			input: "num_replicas: 3\nnum_replicas: 5",
+			expected: []ValidationFinding{
+				{ValidationError, "num_replicas", 2, 1, `field "num_replicas" is specified more than once`},
+			},
+		},
+		{
+			input: "num_replicas: 5\nnum_replica: 3\nfoo: 1",
+			expected: []ValidationFinding{
+				{ValidationError, "num_replica", 2, 1, `unknown field "num_replica"`},
+				{ValidationError, "foo", 3, 1, `unknown field "foo"`},
+			},
+		},
+		{
+			input: "constraints: [+region=us-east1, +a=b=c]",
+			expected: []ValidationFinding{
+				{ValidationError, "constraints", 1, 33, badConstraint},
+			},
+		},
+		{
+			input: "num_replicas: 3\nconstraints:\n  +region=us-east1,+a=b=c: 1",
+			expected: []ValidationFinding{
+				{ValidationError, "constraints", 3, 3, badConstraint},
+			},
+		},
+		{
+			input: "constraints: [+region=us-east1]\nlease_preferences: [[+region=us-east1], [+a=b=c]]",
+			expected: []ValidationFinding{
+				{ValidationError, "lease_preferences", 2, 42, badConstraint},
+			},
+		},
+		{
+			input: "constraints: '@missing'",
+			expected: []ValidationFinding{
+				{ValidationError, "constraints", 1, 14, `constraint set "missing" is not defined`},
+			},
+		},
+		{
+			input: "constraints: [+region=us-east1]\nexperimental_lease_preferences: [[+region=us-west1]]",
+			expected: []ValidationFinding{
+				{ValidationWarning, "experimental_lease_preferences", 2, 1,
+					"experimental_lease_preferences is deprecated, use lease_preferences"},
+				{ValidationWarning, "lease_preferences", 2, 1, "lease preference +region=us-west1 " +
+					"conflicts with constraint +region=us-east1, which applies to every replica"},
+			},
+		},
+		{
+			input: "num_replicas: 4\ngc:\n  ttlseconds: 600",
+			expected: []ValidationFinding{
+				{ValidationWarning, "num_replicas", 1, 1,
+					"num_replicas is 4, which tolerates no more failures than 3 voters would"},
+				{ValidationWarning, "gc", 2, 1, "gc.ttlseconds is 600, which is shorter than the 1h0m0s " +
+					"interval of hourly incremental backups with revision history"},
+			},
+		},
+		{
+			input: "range_min_bytes: 100\nrange_max_bytes: 100",
+			expected: []ValidationFinding{
+				{ValidationError, "", 0, 0, "RangeMaxBytes 100 less than minimum allowed 67108864"},
+			},
+		},
+	} {
+		report, err := ValidateYAML([]byte(tc.input))
+		require.NoError(t, err, tc.input)
+		require.Equal(t, tc.expected, report.Findings, tc.input)
+		hasErrors := false
+		for _, f := range tc.expected {
+			hasErrors = hasErrors || f.Severity == ValidationError
+		}
+		require.Equal(t, hasErrors, report.HasErrors(), tc.input)
+	}
+
+	// Values which can't be decoded are reported as is.
+	report, err := ValidateYAML([]byte("num_replicas: abc"))
+	require.NoError(t, err)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, ValidationError, report.Findings[0].Severity)
+	require.Contains(t, report.Findings[0].Message, "cannot unmarshal !!str `abc`")
+
+	// References to defined named constraint sets are valid.
+	require.NoError(t, SetNamedConstraintSets(map[string]string{"east": "[+region=us-east1]"}))
+	report, err = ValidateYAML([]byte("constraints: '@east'"))
+	require.NoError(t, err)
+	require.Empty(t, report.Findings)
+
+	// Documents which aren't YAML can't be validated.
+	_, err = ValidateYAML([]byte("num_replicas: ["))
+	require.Error(t, err)
+}
+
+func TestMarshalForVersion(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	region := func(r string) []Constraint {
+		return []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: r}}
 	}
+	zone := *NewZoneConfig()
+	zone.NumReplicas = proto.Int32(5)
+	zone.NumVoters = proto.Int32(3)
+	zone.GlobalReads = proto.Bool(true)
+	zone.Constraints = []ConstraintsConjunction{
+		{NumReplicas: 2, Constraints: region("a")},
+		{NumReplicas: 1, Constraints: region("b")},
+	}
+	zone.InheritedConstraints = false
+	zone.VoterConstraints = []ConstraintsConjunction{{NumReplicas: 2, Constraints: region("a")}}
+	zone.NullVoterConstraintsIsEmpty = true
+	zone.LeasePreferences = []LeasePreference{{Constraints: region("a")}}
+	zone.InheritedLeasePreferences = false
+	zone.PrimaryRegion = proto.String("a")
 
+	testCases := []struct {
+		version     roachpb.Version
+		expectedErr string
+		fields      []string
+		// lossless is set if every field of the zone config is known to the
+		// version.
+		lossless bool
+	}{
+		{
+			version:     roachpb.Version{Major: 1, Minor: 1},
+			expectedErr: "cannot marshal zone configs for version 1.1, which predates 2.0",
+		},
+		{
+			version: roachpb.Version{Major: 2, Minor: 0},
+			fields: []string{"range_min_bytes", "range_max_bytes", "gc", "num_replicas", "constraints",
+				"experimental_lease_preferences"},
+		},
+		{
+			version: roachpb.Version{Major: 20, Minor: 2},
+			fields: []string{"range_min_bytes", "range_max_bytes", "gc", "num_replicas", "constraints",
+				"lease_preferences"},
+		},
+		{
+			version: roachpb.Version{Major: 21, Minor: 1},
+			fields: []string{"range_min_bytes", "range_max_bytes", "gc", "global_reads", "num_replicas",
+				"num_voters", "constraints", "voter_constraints", "lease_preferences"},
+		},
+		{
+			version: clusterversion.ByKey(clusterversion.V23_2Start),
+			fields: []string{"range_min_bytes", "range_max_bytes", "gc", "global_reads", "num_replicas",
+				"num_voters", "constraints", "voter_constraints", "lease_preferences", "primary_region"},
+			lossless: true,
+		},
+	}
 	for _, tc := range testCases {
-		t.Run(tc.input, func(t *testing.T) {
-			var constraints ConstraintsList
-			err := yaml.UnmarshalStrict([]byte(tc.input), &constraints)
-			if err == nil && tc.expectErr {
-				t.Errorf("expected error, but got constraints %+v", constraints)
+		t.Run(tc.version.String(), func(t *testing.T) {
+			out, err := zone.MarshalForVersion(clusterversion.ClusterVersion{Version: tc.version})
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
 			}
-			if err != nil && !tc.expectErr {
-				t.Errorf("expected success, but got %v", err)
+			require.NoError(t, err)
+			var doc yaml.Node
+			require.NoError(t, yaml.Unmarshal(out, &doc))
+			var fields []string
+			for i := 0; i < len(doc.Content[0].Content); i += 2 {
+				fields = append(fields, doc.Content[0].Content[i].Value)
+			}
+			require.Equal(t, tc.fields, fields)
+
+			// The output can be unmarshaled, and only loses the fields which are
+			// unknown to the version.
+			decoded := NewZoneConfig()
+			require.NoError(t, yaml.Unmarshal(out, decoded), "%s", out)
+			require.Equal(t, zone.LeasePreferences, decoded.LeasePreferences)
+			require.Equal(t, zone.Constraints, decoded.Constraints)
+			if tc.lossless {
+				require.True(t, zone.Equal(decoded), "%s", out)
 			}
 		})
 	}
 }
 
-func TestMarshalableZoneConfigRoundTrip(t *testing.T) {
+// TestZoneConfigYAMLVersion makes sure that the version field of the YAML form
+// is respected and that documents are migrated to the current version before
+// being decoded.
+func TestZoneConfigYAMLVersion(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
-	original := NewPopulatedZoneConfig(
-		rand.New(rand.NewSource(timeutil.Now().UnixNano())), false /* easy */)
-	marshalable := zoneConfigToMarshalable(*original)
-	roundTripped := zoneConfigFromMarshalable(marshalable, *original)
+	testCases := []struct {
+		input       string
+		expected    ZoneConfig
+		expectedErr string
+	}{
+		{
+			input:    "num_replicas: 3",
+			expected: ZoneConfig{NumReplicas: proto.Int32(3)},
+		},
+		{
+			input:    "version: 1\nnum_replicas: 3",
+			expected: ZoneConfig{NumReplicas: proto.Int32(3)},
+		},
+		{
+			input: "version: 0\nexperimental_lease_preferences: [[+a=b]]",
+			expected: ZoneConfig{
+				LeasePreferences: []LeasePreference{
+					{Constraints: []Constraint{{Key: "a", Value: "b", Type: Constraint_REQUIRED}}},
+				},
+			},
+		},
+		{
+			input:       "version: 1\nexperimental_lease_preferences: [[+a=b]]",
+			expectedErr: "field experimental_lease_preferences not found",
+		},
+		{
+			input:       "version: 2\nnum_replicas: 3",
+			expectedErr: "unsupported zone config version 2",
+		},
+		{
+			input:       "version: one\nnum_replicas: 3",
+			expectedErr: "invalid zone config version one",
+		},
+	}
 
-	if !reflect.DeepEqual(roundTripped, *original) {
-		t.Errorf("round-tripping a ZoneConfig through a marshalableZoneConfig failed:\noriginal:\n%+v\nmarshable:\n%+v\ngot:\n%+v", original, marshalable, roundTripped)
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var zone ZoneConfig
+			err := yaml.Unmarshal([]byte(tc.input), &zone)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, zone)
+		})
 	}
+
+	// Unknown fields are only rejected by strict unmarshaling.
+	input := []byte("version: 1\nnum_replicas: 3\nnum_witnesses: 2")
+	var zone ZoneConfig
+	require.Error(t, yaml.Unmarshal(input, &zone))
+	require.NoError(t, UnmarshalYAMLNonStrict(input, &zone))
+	require.Equal(t, ZoneConfig{NumReplicas: proto.Int32(3)}, zone)
+	require.Error(t, UnmarshalYAMLNonStrict([]byte("version: 2"), &zone))
+
+	// Marshaled documents only specify their version when they can't be read
+	// as unversioned documents.
+	out, err := MarshalYAML(DefaultZoneConfig())
+	require.NoError(t, err)
+	require.False(t, strings.HasPrefix(string(out), zoneConfigYAMLVersionKey+":"))
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal(out, &doc))
+	require.Equal(t, ZoneConfigYAMLVersionUnversioned, minZoneConfigYAMLVersion(doc.Content[0]))
+	// A field which the migrations of unversioned documents rewrite, as they
+	// do experimental_lease_preferences, needs the current version.
+	var legacyDoc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("experimental_lease_preferences: []"), &legacyDoc))
+	require.Equal(t, CurrentZoneConfigYAMLVersion, minZoneConfigYAMLVersion(legacyDoc.Content[0]))
 }
 
-func TestZoneSpecifiers(t *testing.T) {
+func TestZoneConfigYAMLByteSizes(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
-	// Simulate exactly two named zones: one named default and one named carl.
-	// N.B. DefaultZoneName must always exist in the mapping; it is treated
-	// specially so that it always appears first in the lookup path.
-	defer func(old map[NamedZone]uint32) { NamedZones = old }(NamedZones)
-	NamedZones = map[NamedZone]uint32{
-		DefaultZoneName: 0,
-		"carl":          42,
+	testCases := []struct {
+		input       string
+		expected    ZoneConfig
+		expectedErr string
+	}{
+		{
+			input: "range_min_bytes: 134217728\nrange_max_bytes: 536870912",
+			expected: ZoneConfig{
+				RangeMinBytes: proto.Int64(128 << 20), RangeMaxBytes: proto.Int64(512 << 20),
+			},
+		},
+		{
+			input: "range_min_bytes: 128MiB\nrange_max_bytes: 512 MiB",
+			expected: ZoneConfig{
+				RangeMinBytes: proto.Int64(128 << 20), RangeMaxBytes: proto.Int64(512 << 20),
+			},
+		},
+		{
+			input: "range_min_bytes: 100MB\nrange_max_bytes: 1GiB",
+			expected: ZoneConfig{
+				RangeMinBytes: proto.Int64(100e6), RangeMaxBytes: proto.Int64(1 << 30),
+			},
+		},
+		{
+			input:       "range_max_bytes: 512 mebibytes",
+			expectedErr: `invalid byte size "512 mebibytes"`,
+		},
+		{
+			input:       "range_max_bytes: [512]",
+			expectedErr: "cannot unmarshal",
+		},
 	}
-	defer func(old map[uint32]NamedZone) { NamedZonesByID = old }(NamedZonesByID)
-	NamedZonesByID = map[uint32]NamedZone{
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var zone ZoneConfig
+			err := yaml.Unmarshal([]byte(tc.input), &zone)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, zone)
+
+			// Byte sizes are always marshaled as integers.
+			out, err := MarshalYAML(zone)
+			require.NoError(t, err)
+			require.Contains(t, string(out), fmt.Sprintf("range_max_bytes: %d\n", *zone.RangeMaxBytes))
+		})
+	}
+}
+
+func TestGCPolicyYAML(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		input       string
+		expected    int32
+		expectedErr string
+	}{
+		{input: "ttlseconds: 90000", expected: 90000},
+		{input: "ttl: 25h", expected: 90000},
+		{input: "ttl: 1h30m", expected: 5400},
+		{input: "ttl: 1s", expected: 1},
+		{input: "{}", expected: 42},
+		{input: "ttl: 1.5s", expectedErr: "must be a whole number of seconds"},
+		{input: "ttl: 1000000h", expectedErr: "is out of range"},
+		{input: "ttl: 25", expectedErr: `invalid gc.ttl "25"`},
+		{input: "{ttl: 25h, ttlseconds: 90000}", expectedErr: "cannot both be set"},
+		{input: "ttlsecs: 90000", expectedErr: "field ttlsecs not found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			policy := GCPolicy{TTLSeconds: 42}
+			err := yaml.Unmarshal([]byte(tc.input), &policy)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, policy.TTLSeconds)
+
+			// The TTL is always marshaled in seconds.
+			out, err := MarshalYAML(policy)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("ttlseconds: %d\n", tc.expected), string(out))
+		})
+	}
+}
+
+func TestSplitHints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := DefaultZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`split_hints: ["899288", 8a]`), &zone))
+	require.Equal(t, []roachpb.Key{{0x89, 0x92, 0x88}, {0x8a}}, zone.SplitHints)
+	require.NoError(t, zone.Validate())
+	out, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Contains(t, string(out), "split_hints: [\"899288\", 8a]\n")
+	var roundTripped ZoneConfig
+	require.NoError(t, yaml.Unmarshal(out, &roundTripped))
+	require.Equal(t, zone.SplitHints, roundTripped.SplitHints)
+
+	// The order of the hints doesn't matter.
+	reordered := *protoutil.Clone(&zone).(*ZoneConfig)
+	reordered.SplitHints = []roachpb.Key{{0x8a}, {0x89, 0x92, 0x88}}
+	require.True(t, zone.EquivalentTo(&reordered))
+
+	// The hints are split at along with the boundaries of the subzones, at the
+	// start of their rows, so that the column families of a row aren't split
+	// apart.
+	zone.SubzoneSpans = []SubzoneSpan{{Key: roachpb.Key{0x8a}}}
+	require.Equal(t, []roachpb.RKey{{0x89, 0x92}, {0x8a}, {0x8b}}, zone.SplitKeys())
+	zone.SplitHints = append(zone.SplitHints, roachpb.Key{0x89, 0x92, 0x89, 0x89})
+	require.NoError(t, zone.Validate())
+	require.Equal(t, []roachpb.RKey{{0x89, 0x92}, {0x8a}, {0x8b}}, zone.SplitKeys())
+
+	require.ErrorContains(t, yaml.Unmarshal([]byte(`split_hints: [zz]`), &zone), `invalid split hint "zz"`)
+	for _, tc := range []struct {
+		hints []roachpb.Key
+		err   string
+	}{
+		{[]roachpb.Key{{0x8a}, {}}, "split hints can't be empty"},
+		{[]roachpb.Key{{0x8a}, {0x89}, {0x8a}}, "split hint 8a is repeated"},
+		{make([]roachpb.Key, MaxSplitHints+1), "1025 split hints exceed the maximum of 1024"},
+		// The hint lacks the column family suffix of the row.
+		{[]roachpb.Key{{0x89, 0x92}}, "split hint 8992 is not the key of a row"},
+	} {
+		zone := DefaultZoneConfig()
+		zone.SplitHints = tc.hints
+		require.EqualError(t, zone.Validate(), tc.err)
+	}
+
+	// Split hints only apply to tables.
+	zone = DefaultZoneConfig()
+	zone.Subzones = []Subzone{{IndexID: 2, Config: ZoneConfig{SplitHints: []roachpb.Key{{0x8a}}}}}
+	require.EqualError(t, zone.Validate(), "split hints can't be set on indexes or partitions")
+}
+
+func TestRemapZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	key := func(ids ...uint64) roachpb.Key {
+		var k roachpb.Key
+		for _, id := range ids {
+			k = encoding.EncodeUvarintAscending(k, id)
+		}
+		return k
+	}
+	zone := DefaultZoneConfig()
+	zone.Subzones = []Subzone{
+		{IndexID: 1, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 3600}}},
+		{IndexID: 2, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 7200}}},
+		{IndexID: 2, PartitionName: "p", Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 90000}}},
+	}
+	zone.SubzoneSpans = []SubzoneSpan{
+		{Key: key(1), SubzoneIndex: 0},
+		{Key: key(2, 5), EndKey: key(2, 10), SubzoneIndex: 2},
+		{Key: key(2, 10), EndKey: key(3), SubzoneIndex: 1},
+	}
+	zone.SplitHints = []roachpb.Key{key(2, 7, 0), key(4, 1, 0)}
+	before := protoutil.Clone(&zone).(*ZoneConfig)
+
+	// The indexes swap places.
+	remapped := RemapZoneConfig(zone, map[uint32]uint32{1: 3, 2: 1})
+	require.True(t, before.Equal(&zone))
+	require.Equal(t, []Subzone{
+		{IndexID: 1, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 7200}}},
+		{IndexID: 1, PartitionName: "p", Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 90000}}},
+		{IndexID: 3, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 3600}}},
+	}, remapped.Subzones)
+	// The end of the span of index 2, which is also the start of index 3, is
+	// remapped to the end of index 1.
+	require.Equal(t, []SubzoneSpan{
+		{Key: key(1, 5), EndKey: key(1, 10), SubzoneIndex: 1},
+		{Key: key(1, 10), EndKey: key(2), SubzoneIndex: 0},
+		{Key: key(3), SubzoneIndex: 2},
+	}, remapped.SubzoneSpans)
+	require.NoError(t, ValidateSubzoneSpans(remapped.SubzoneSpans, len(remapped.Subzones)))
+	require.Equal(t, []roachpb.Key{key(1, 7, 0), key(4, 1, 0)}, remapped.SplitHints)
+	require.NoError(t, remapped.Validate())
+
+	// Remapping the indexes back restores the zone config.
+	restored := RemapZoneConfig(remapped, map[uint32]uint32{3: 1, 1: 2})
+	require.True(t, before.Equal(&restored))
+	unchanged := RemapZoneConfig(zone, nil)
+	require.True(t, before.Equal(&unchanged))
+}
+
+// testIndexNames implements IndexNameResolver, mapping index names to IDs.
+type testIndexNames map[string]uint32
+
+func (n testIndexNames) IndexName(id uint32) (string, bool) {
+	for name, indexID := range n {
+		if indexID == id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (n testIndexNames) IndexID(name string) (uint32, bool) {
+	id, ok := n[name]
+	return id, ok
+}
+
+func TestNamedSubzones(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := DefaultZoneConfig()
+	zone.SetSubzone(Subzone{IndexID: 1, Config: ZoneConfig{GC: &GCPolicy{TTLSeconds: 3600}}})
+	zone.SetSubzone(Subzone{IndexID: 2, PartitionName: "p", Config: ZoneConfig{NumReplicas: proto.Int32(5)}})
+	// The subzone of a dropped index is omitted.
+	zone.SetSubzone(Subzone{IndexID: 3, Config: ZoneConfig{NumReplicas: proto.Int32(7)}})
+	zone.SubzoneSpans = []SubzoneSpan{{Key: roachpb.Key{0x89}}}
+
+	named := zone.NamedSubzones(testIndexNames{"t_pkey": 1, "idx": 2})
+	out, err := MarshalYAML(named)
+	require.NoError(t, err)
+	require.Equal(t, `- index: t_pkey
+  config: {gc: {ttlseconds: 3600}}
+- index: idx
+  partition: p
+  config: {num_replicas: 5}
+`, string(out))
+
+	// The subzones carry over to indexes with other IDs, and their unset
+	// fields are inherited.
+	var decoded []NamedSubzone
+	require.NoError(t, yaml.Unmarshal(out, &decoded))
+	require.NoError(t, zone.SetNamedSubzones(decoded, testIndexNames{"t_pkey": 1, "idx": 4}))
+	require.Nil(t, zone.SubzoneSpans)
+	require.Len(t, zone.Subzones, 2)
+	require.Equal(t, uint32(1), zone.Subzones[0].IndexID)
+	require.Equal(t, int32(3600), zone.Subzones[0].Config.GC.TTLSeconds)
+	require.Equal(t, uint32(4), zone.Subzones[1].IndexID)
+	require.Equal(t, "p", zone.Subzones[1].PartitionName)
+	require.Equal(t, int32(5), *zone.Subzones[1].Config.NumReplicas)
+	require.True(t, zone.Subzones[1].Config.InheritedConstraints)
+	require.NoError(t, zone.Validate())
+
+	before := protoutil.Clone(&zone).(*ZoneConfig)
+	require.EqualError(t, zone.SetNamedSubzones(decoded, testIndexNames{"t_pkey": 1}),
+		`index "idx" not found`)
+	require.EqualError(t, zone.SetNamedSubzones(append(decoded, decoded[1]), testIndexNames{"t_pkey": 1, "idx": 4}),
+		`partition "p" of index "idx" is repeated`)
+	require.True(t, before.Equal(&zone))
+
+	require.ErrorContains(t, yaml.Unmarshal([]byte("- {partition: p}"), &decoded),
+		"the index of a subzone must be named")
+	require.ErrorContains(t, yaml.Unmarshal([]byte("- {index: idx, partiton: p}"), &decoded),
+		"field partiton not found")
+}
+
+func TestZoneConfigErrors(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var unknown *ErrUnknownField
+	err := yaml.Unmarshal([]byte("num_replica: 3"), NewZoneConfig())
+	require.ErrorAs(t, err, &unknown)
+	require.Equal(t, "num_replica", unknown.Field)
+	require.Contains(t, err.Error(), "field num_replica not found in type zonepb.marshalableZoneConfig")
+
+	for _, tc := range []struct {
+		yaml  string
+		group string
+		index int
+	}{
+		{`{num_replicas: 3, constraints: {"+region=a": 1, "+region=b": 0}}`, "constraints", 1},
+		{`{num_replicas: 3, num_voters: 3, voter_constraints: [-region=a]}`, "voter_constraints", 0},
+		{`{num_replicas: 3, lease_preferences: [[+region=a], [+region=b, -region=b]]}`, "lease_preferences", 1},
+	} {
+		zone := DefaultZoneConfig()
+		require.NoError(t, yaml.Unmarshal([]byte(tc.yaml), &zone))
+		var invalid *ErrInvalidConstraint
+		require.ErrorAs(t, zone.Validate(), &invalid, tc.yaml)
+		require.Equal(t, tc.group, invalid.Group)
+		require.Equal(t, tc.index, invalid.Index)
+	}
+
+	zone := DefaultZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`{num_replicas: 3, constraints: {"+region=a": 2, "+region=b": 2}}`), &zone))
+	var unsatisfiable *ErrUnsatisfiable
+	require.ErrorAs(t, zone.Validate(), &unsatisfiable)
+	require.Equal(t, ErrUnsatisfiable{Group: "constraints", Constrained: 4, Available: 3}, *unsatisfiable)
+	require.EqualError(t, unsatisfiable, "the number of replicas specified in constraints (4) "+
+		"cannot be greater than the number of replicas configured for the zone (3)")
+
+	var overlap *ErrSubzoneOverlap
+	err = ValidateSubzoneSpans([]SubzoneSpan{
+		{Key: roachpb.Key("a"), EndKey: roachpb.Key("c")},
+		{Key: roachpb.Key("b"), EndKey: roachpb.Key("d")},
+	}, 1)
+	require.ErrorAs(t, err, &overlap)
+	require.Equal(t, 1, overlap.Index)
+	require.Equal(t, 0, overlap.Other)
+	require.Equal(t, roachpb.Key("c"), overlap.OtherEndKey)
+}
+
+func TestInternConstraintStrings(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	data := func(s string) uintptr {
+		return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	}
+
+	// Parsed constraints are interned.
+	var a, b Constraint
+	require.NoError(t, a.FromString("+region=us-east1"))
+	require.NoError(t, b.FromString("-region=us-east1"))
+	require.Equal(t, data(a.Key), data(b.Key))
+	require.Equal(t, data(a.Value), data(b.Value))
+
+	// Decoded zone configs are interned explicitly, including their subzones,
+	// without modifying the lists they share with other zone configs.
+	zone := DefaultZoneConfig()
+	zone.Constraints = []ConstraintsConjunction{{Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: string([]byte("region")), Value: string([]byte("us-east1"))},
+	}}}
+	zone.Subzones = []Subzone{{Config: ZoneConfig{
+		LeasePreferences: []LeasePreference{{Constraints: zone.Constraints[0].Constraints}},
+	}}}
+	shared := zone.Constraints
+	zone.InternConstraintStrings()
+	require.Equal(t, shared, zone.Constraints)
+	require.NotEqual(t, data(a.Key), data(shared[0].Constraints[0].Key))
+	require.Equal(t, data(a.Key), data(zone.Constraints[0].Constraints[0].Key))
+	require.Equal(t, data(a.Value), data(zone.Subzones[0].Config.LeasePreferences[0].Constraints[0].Value))
+}
+
+func TestSurvivalGoalAndRegions(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 5
+constraints: {+region=a: 1, +region=b: 1, +region=c: 1}
+survival_goal: region
+primary_region: a
+secondary_region: b
+`), zone))
+	require.Equal(t, SurvivalGoal_REGION_FAILURE, *zone.SurvivalGoal)
+	require.Equal(t, "a", *zone.PrimaryRegion)
+	require.Equal(t, "b", *zone.SecondaryRegion)
+
+	// The fields are omitted from the YAML when they're unset.
+	out, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Contains(t, string(out), "survival_goal: region\nprimary_region: a\nsecondary_region: b\n")
+	out, err = MarshalYAML(NewZoneConfig())
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "survival_goal")
+	require.NotContains(t, string(out), "_region")
+
+	err = yaml.Unmarshal([]byte(`survival_goal: everywhere`), NewZoneConfig())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid survival_goal "everywhere", expected zone or region`)
+
+	// The fields are inherited from the parent.
+	child := NewZoneConfig()
+	child.InheritFromParent(zone)
+	require.Equal(t, zone.SurvivalGoal, child.SurvivalGoal)
+	require.Equal(t, zone.PrimaryRegion, child.PrimaryRegion)
+	require.Equal(t, zone.SecondaryRegion, child.SecondaryRegion)
+
+	// The flat attributes and the custom resource encode the survival goal by
+	// its YAML name.
+	attrs, err := zone.ToFlatAttributes()
+	require.NoError(t, err)
+	require.Equal(t, "region", attrs["survival_goal"])
+	fromFlat, err := FromFlatAttributes(attrs)
+	require.NoError(t, err)
+	require.Equal(t, *zone, fromFlat)
+	crd := zone.ToCRDSpec()
+	require.Equal(t, "region", *crd.Spec.SurvivalGoal)
+	fromCRD, err := FromCRDSpec(crd)
+	require.NoError(t, err)
+	require.Equal(t, *zone, fromCRD)
+
+	for _, tc := range []struct {
+		yaml        string
+		expectedErr string
+	}{
+		{yaml: "survival_goal: zone"},
+		{yaml: "{num_replicas: 5, constraints: {+region=a: 1, +region=b: 1, +region=c: 1}, survival_goal: region}"},
+		// Voter constraints count towards the regions of the constraints.
+		{yaml: "{num_replicas: 5, num_voters: 5, constraints: {+region=a: 1, +region=b: 1}, " +
+			"voter_constraints: {+region=c: 1}, survival_goal: region}"},
+		{
+			yaml:        "survival_goal: region",
+			expectedErr: "survival_goal region requires constraints in at least 3 regions, found 0",
+		},
+		{
+			yaml:        "{constraints: [+region=a], survival_goal: region}",
+			expectedErr: "survival_goal region requires constraints in at least 3 regions, found 1",
+		},
+		// Wildcards don't name a region.
+		{
+			yaml:        "{constraints: [+region=us-*], survival_goal: region}",
+			expectedErr: "survival_goal region requires constraints in at least 3 regions, found 0",
+		},
+		{yaml: "{primary_region: a, secondary_region: b}"},
+		{yaml: "secondary_region: b", expectedErr: "secondary_region requires primary_region"},
+		{
+			yaml:        "{primary_region: a, secondary_region: a}",
+			expectedErr: `secondary_region "a" must differ from primary_region`,
+		},
+		{yaml: "primary_region: ''", expectedErr: "primary_region must not be empty"},
+		{
+			yaml:        "{constraints: [+region=a], primary_region: b}",
+			expectedErr: `primary_region "b" is not among the regions of the constraints (a)`,
+		},
+		{
+			yaml:        "{constraints: {+region=a: 1, +region=b: 1}, primary_region: a, secondary_region: c}",
+			expectedErr: `secondary_region "c" is not among the regions of the constraints (a, b)`,
+		},
+	} {
+		t.Run(tc.yaml, func(t *testing.T) {
+			zone := DefaultZoneConfig()
+			require.NoError(t, yaml.Unmarshal([]byte(tc.yaml), &zone))
+			if tc.expectedErr == "" {
+				require.NoError(t, zone.Validate())
+			} else {
+				require.EqualError(t, zone.Validate(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalYAMLStrict(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var constraints ConstraintsList
+	require.NoError(t, UnmarshalYAMLStrict([]byte(`{+region=a: 1}`), &constraints))
+	require.Len(t, constraints.Constraints, 1)
+	// Empty documents leave the target unchanged, as with yaml.Unmarshal.
+	require.NoError(t, UnmarshalYAMLStrict(nil, &constraints))
+	require.Len(t, constraints.Constraints, 1)
+
+	var v struct {
+		A int `yaml:"a"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte("a: 1\nb: 2"), &v))
+	require.ErrorContains(t, UnmarshalYAMLStrict([]byte("a: 1\nb: 2"), &v), "field b not found")
+}
+
+func TestConstraintsListYAML(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		input     string
+		expectErr bool
+	}{
+		{},
+		{input: "[]"},
+		{input: "[+a]"},
+		{input: "[+a, -b=2, +c=d, e]"},
+		{input: "{+a: 1}"},
+		{input: "{+a: 1, '+a=1,+b,+c=d': 2}"},
+		{input: "{'+a: 1'}"},  // unfortunately this parses just fine because yaml autoconverts it to a list...
+		{input: "{+a,+b: 1}"}, // this also parses fine but will fail ZoneConfig.Validate()
+		{input: "{+a: 1, '+a=1,+b,+c=d': b}", expectErr: true},
+		{input: "[+a: 1]", expectErr: true},
+		{input: "[+a: 1, '+a=1,+b,+c=d': 2]", expectErr: true},
+		{input: "{\"+a=1,+b=2\": 1}"},    // this will work in SQL: constraints='{"+a=1,+b=2": 1}'
+		{input: "{\"+a=1,+b=2,+c\": 1}"}, // won't work in SQL: constraints='{"+a=1,+b=2,+c": 1}'
+		{input: "{'+a=1,+b=2,+c': 1}"},   // this will work in SQL: constraints=e'{\'+a=1,+b=2,+c\': 1}'
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var constraints ConstraintsList
+			err := yaml.Unmarshal([]byte(tc.input), &constraints)
+			if err == nil && tc.expectErr {
+				t.Errorf("expected error, but got constraints %+v", constraints)
+			}
+			if err != nil && !tc.expectErr {
+				t.Errorf("expected success, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestConstraintsListArithmetic(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	constraint := func(short string) Constraint {
+		var c Constraint
+		require.NoError(t, c.FromString(short))
+		return c
+	}
+	testCases := []struct {
+		input         string
+		total         int32
+		unconstrained int32
+	}{
+		{input: "[]", total: 0, unconstrained: 5},
+		{input: "[+a, -b]", total: 0, unconstrained: 0},
+		{input: "{+a: 1, '+b,+c': 2}", total: 3, unconstrained: 2},
+		{input: "{+a: 3, +b: 3}", total: 6, unconstrained: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var constraints ConstraintsList
+			require.NoError(t, yaml.Unmarshal([]byte(tc.input), &constraints))
+			require.Equal(t, tc.total, constraints.TotalConstrainedReplicas())
+			require.Equal(t, tc.unconstrained, constraints.UnconstrainedReplicas(5))
+		})
+	}
+
+	var constraints ConstraintsList
+	require.NoError(t, yaml.Unmarshal([]byte("{+a: 1, '+b,-c=d': 2}"), &constraints))
+	require.True(t, constraints.ContainsConstraint(constraint("+a")))
+	require.True(t, constraints.ContainsConstraint(constraint("-c=d")))
+	require.False(t, constraints.ContainsConstraint(constraint("-a")))
+	require.False(t, constraints.ContainsConstraint(constraint("+c=d")))
+	require.False(t, constraints.ContainsConstraint(constraint("+b=a")))
+}
+
+func TestMarshalableZoneConfigRoundTrip(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	original := NewPopulatedZoneConfig(
+		rand.New(rand.NewSource(timeutil.Now().UnixNano())), false /* easy */)
+	marshalable := zoneConfigToMarshalable(*original)
+	roundTripped := zoneConfigFromMarshalable(marshalable, *original)
+
+	if !reflect.DeepEqual(roundTripped, *original) {
+		t.Errorf("round-tripping a ZoneConfig through a marshalableZoneConfig failed:\noriginal:\n%+v\nmarshable:\n%+v\ngot:\n%+v", original, marshalable, roundTripped)
+	}
+}
+
+func TestZoneSpecifiers(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// Simulate exactly two named zones: one named default and one named carl.
+	// N.B. DefaultZoneName must always exist in the mapping; it is treated
+	// specially so that it always appears first in the lookup path.
+	defer func(old map[NamedZone]uint32) { NamedZones = old }(NamedZones)
+	NamedZones = map[NamedZone]uint32{
+		DefaultZoneName: 0,
+		"carl":          42,
+	}
+	defer func(old map[uint32]NamedZone) { NamedZonesByID = old }(NamedZonesByID)
+	NamedZonesByID = map[uint32]NamedZone{
 		0:  DefaultZoneName,
 		42: "carl",
 	}
@@ -1443,3 +3028,2677 @@ func TestHardCodedSpanConfigs(t *testing.T) {
 		require.True(t, converted.Equal(roachpb.TestingSystemSpanConfig()))
 	}
 }
+
+func TestLintZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected []LintRule
+	}{
+		{
+			name:  "default zone",
+			input: "",
+		},
+		{
+			name:     "even replicas",
+			input:    "num_replicas: 4",
+			expected: []LintRule{LintEvenReplicaCount},
+		},
+		{
+			name:  "even replicas with odd voters",
+			input: "num_replicas: 4\nnum_voters: 3",
+		},
+		{
+			name:     "even voters",
+			input:    "num_replicas: 5\nnum_voters: 4",
+			expected: []LintRule{LintEvenReplicaCount},
+		},
+		{
+			name:     "short gc ttl",
+			input:    "gc: {ttlseconds: 600}",
+			expected: []LintRule{LintShortGCTTL},
+		},
+		{
+			name:  "gc ttl matching backups",
+			input: "gc: {ttl: 1h}",
+		},
+		{
+			name:     "lease preference in other region",
+			input:    "constraints: [+region=a]\nlease_preferences: [[+region=b]]",
+			expected: []LintRule{LintContradictoryLeasePreferences},
+		},
+		{
+			name:     "lease preference prohibited",
+			input:    "constraints: [-ssd]\nlease_preferences: [[+region=a], [+ssd]]",
+			expected: []LintRule{LintContradictoryLeasePreferences},
+		},
+		{
+			name:     "lease preference prohibiting required",
+			input:    "constraints: [+region=a]\nlease_preferences: [[-region=a]]",
+			expected: []LintRule{LintContradictoryLeasePreferences},
+		},
+		{
+			name:  "lease preference in per-replica constraints",
+			input: "constraints: {+region=a: 1}\nlease_preferences: [[+region=b]]",
+		},
+		{
+			name:  "compatible attributes",
+			input: "constraints: [+ssd]\nlease_preferences: [[+fast]]",
+		},
+		{
+			name: "lease preference outside per-replica constraints",
+			input: "num_replicas: 3\nconstraints: {+region=us-east: 2, +region=us-west: 1}\n" +
+				"lease_preferences: [[+region=eu], [+region=us-west]]",
+			expected: []LintRule{LintUnsatisfiableLeasePreferences},
+		},
+		{
+			name: "lease preference outside voter constraints",
+			input: "num_replicas: 5\nnum_voters: 3\nconstraints: {+region=us: 3, +region=eu: 2}\n" +
+				"voter_constraints: [+region=us]\nlease_preferences: [[+region=eu]]",
+			expected: []LintRule{LintUnsatisfiableLeasePreferences},
+		},
+		{
+			name: "lease preference outside per-voter constraints",
+			input: "num_replicas: 5\nnum_voters: 3\nconstraints: {+region=us: 3, +region=eu: 2}\n" +
+				"voter_constraints: {+region=us: 3}\nlease_preferences: [[+region=eu]]",
+			expected: []LintRule{LintUnsatisfiableLeasePreferences},
+		},
+		{
+			name: "lease preference in partial voter constraints",
+			input: "num_replicas: 5\nnum_voters: 3\nconstraints: {+region=us: 3, +region=eu: 2}\n" +
+				"voter_constraints: {+region=us: 2}\nlease_preferences: [[+region=eu]]",
+		},
+		{
+			name:     "multiple findings",
+			input:    "num_replicas: 4\ngc: {ttlseconds: 60}",
+			expected: []LintRule{LintEvenReplicaCount, LintShortGCTTL},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := DefaultZoneConfig()
+			require.NoError(t, yaml.Unmarshal([]byte(tc.input), &zone))
+			var rules []LintRule
+			for _, f := range LintZoneConfig(zone) {
+				rules = append(rules, f.Rule)
+			}
+			require.Equal(t, tc.expected, rules)
+		})
+	}
+}
+
+func TestLintSubzoneShortGCTTL(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	gc := func(ttl int32) ZoneConfig {
+		return ZoneConfig{GC: &GCPolicy{TTLSeconds: ttl}}
+	}
+	zone := DefaultZoneConfig()
+	zone.GC = &GCPolicy{TTLSeconds: 7200}
+	// An unset GC policy is inherited, and so is never flagged.
+	zone.SetSubzone(Subzone{IndexID: 1, Config: *NewZoneConfig()})
+	zone.SetSubzone(Subzone{IndexID: 1, PartitionName: "longer", Config: gc(90000)})
+	zone.SetSubzone(Subzone{IndexID: 1, PartitionName: "shorter", Config: gc(3600)})
+	zone.SetSubzone(Subzone{IndexID: 2, Config: gc(3600)})
+	// A partition is compared against its index before the zone.
+	zone.SetSubzone(Subzone{IndexID: 2, PartitionName: "same", Config: gc(3600)})
+	zone.SetSubzone(Subzone{IndexID: 3, Config: gc(90000)})
+	zone.SetSubzone(Subzone{IndexID: 3, PartitionName: "p", Config: gc(7200)})
+
+	var messages []string
+	for _, f := range LintZoneConfig(zone) {
+		require.Equal(t, LintSubzoneShortGCTTL, f.Rule)
+		messages = append(messages, f.Message)
+	}
+	require.Equal(t, []string{
+		"gc.ttlseconds of partition shorter of index 1 is 3600, which is shorter than the 7200 of its zone",
+		"gc.ttlseconds of index 2 is 3600, which is shorter than the 7200 of its zone",
+		"gc.ttlseconds of partition p of index 3 is 7200, which is shorter than the 90000 of its index 3",
+	}, messages)
+}
+
+func TestLintMultiRegionLeasePreferences(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	regionPref := func(region string) LeasePreference {
+		return LeasePreference{Constraints: []Constraint{
+			{Type: Constraint_REQUIRED, Key: "region", Value: region},
+		}}
+	}
+	testCases := []struct {
+		name     string
+		input    string
+		expected []LeasePreference
+	}{
+		{
+			name:  "unconstrained",
+			input: "num_replicas: 3",
+		},
+		{
+			name:  "single region",
+			input: "constraints: [+region=us-east1]",
+		},
+		{
+			name:  "prohibited regions",
+			input: "constraints: [-region=us-east1, -region=us-west1]",
+		},
+		{
+			name:  "non-region keys",
+			input: "constraints: {+zone=a: 1, +zone=b: 1}",
+		},
+		{
+			name: "lease preferences set",
+			input: `
+num_replicas: 3
+constraints: {+region=us-east1: 1, +region=us-west1: 1}
+lease_preferences: [[+region=us-west1]]`,
+		},
+		{
+			name: "ordered by replicas",
+			input: `
+num_replicas: 5
+constraints: {+region=us-east1: 1, +region=us-west1: 2, +region=eu-west1: 2}`,
+			expected: []LeasePreference{
+				regionPref("eu-west1"), regionPref("us-west1"), regionPref("us-east1"),
+			},
+		},
+		{
+			name: "voters take precedence",
+			input: `
+num_replicas: 5
+num_voters: 3
+constraints: {+region=us-east1: 1, +region=us-west1: 2}
+voter_constraints: {+region=us-east1: 2}`,
+			expected: []LeasePreference{regionPref("us-east1"), regionPref("us-west1")},
+		},
+		{
+			// Voter constraints in the legacy list format apply to every voter.
+			name: "voters of legacy constraints",
+			input: `
+num_replicas: 5
+num_voters: 3
+constraints: {+region=us-east1: 2, +region=us-west1: 2}
+voter_constraints: [+region=us-west1]`,
+			expected: []LeasePreference{regionPref("us-west1"), regionPref("us-east1")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var zone ZoneConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tc.input), &zone))
+
+			// The rule is opt-in.
+			require.Empty(t, LintZoneConfig(zone))
+
+			findings := LintZoneConfig(zone, LintMultiRegionLeasePreferences)
+			if tc.expected == nil {
+				require.Empty(t, findings)
+				return
+			}
+			require.Len(t, findings, 1)
+			require.Equal(t, LintMultiRegionLeasePreferences, findings[0].Rule)
+			require.Equal(t, tc.expected, findings[0].SuggestedLeasePreferences)
+		})
+	}
+}
+
+func TestInferConstraints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// locality parses "region=a,zone=a1" into a roachpb.Locality.
+	locality := func(s string) roachpb.Locality {
+		var l roachpb.Locality
+		require.NoError(t, l.Set(s))
+		return l
+	}
+	placement := func(localities ...string) []roachpb.Locality {
+		res := make([]roachpb.Locality, len(localities))
+		for i, s := range localities {
+			res[i] = locality(s)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name       string
+		placements [][]roachpb.Locality
+		expected   string
+	}{
+		{
+			name:     "no placements",
+			expected: "[]",
+		},
+		{
+			name: "nothing in common",
+			placements: [][]roachpb.Locality{
+				placement("region=a", "region=b", "region=c"),
+				placement("region=d", "region=e", "region=f"),
+			},
+			expected: "[]",
+		},
+		{
+			name: "common tiers",
+			placements: [][]roachpb.Locality{
+				placement("cloud=gcp,region=a", "cloud=gcp,region=b", "cloud=gcp,region=c"),
+				placement("cloud=gcp,region=a", "cloud=gcp,region=b", "cloud=gcp,region=d"),
+			},
+			expected: `{"+cloud=gcp": 1, "+cloud=gcp,+region=a": 1, "+cloud=gcp,+region=b": 1}`,
+		},
+		{
+			name: "single region",
+			placements: [][]roachpb.Locality{
+				placement("region=a", "region=a", "region=a"),
+				placement("region=a", "region=a", "region=a", "region=a"),
+			},
+			expected: "[+region=a]",
+		},
+		{
+			name: "single region zones",
+			placements: [][]roachpb.Locality{
+				placement("region=a,zone=a1", "region=a,zone=a2", "region=a,zone=a3"),
+				placement("region=a,zone=a1", "region=a,zone=a1", "region=a,zone=a2"),
+			},
+			expected: `{"+region=a": 1, "+region=a,+zone=a1": 1, "+region=a,+zone=a2": 1}`,
+		},
+		{
+			name: "zones",
+			placements: [][]roachpb.Locality{
+				placement("region=a,zone=a1", "region=a,zone=a2", "region=b,zone=b1"),
+				placement("region=a,zone=a1", "region=a,zone=a2", "region=b,zone=b2"),
+			},
+			expected: `{"+region=a,+zone=a1": 1, "+region=a,+zone=a2": 1, "+region=b": 1}`,
+		},
+		{
+			name: "multiple replicas per region",
+			placements: [][]roachpb.Locality{
+				placement("region=a", "region=a", "region=b", "region=b", "region=c"),
+				placement("region=a", "region=a", "region=b", "region=c", "region=c"),
+			},
+			expected: `{"+region=a": 2, "+region=b": 1, "+region=c": 1}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var expected ConstraintsList
+			require.NoError(t, yaml.Unmarshal([]byte(tc.expected), &expected))
+			require.Equal(t, expected, InferConstraints(tc.placements))
+		})
+	}
+}
+
+func TestSuggestConstraints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	topology := func(localities ...string) []roachpb.Locality {
+		res := make([]roachpb.Locality, len(localities))
+		for i, s := range localities {
+			require.NoError(t, res[i].Set(s))
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name       string
+		localities []roachpb.Locality
+		goal       SurvivalGoal
+		expected   string
+	}{
+		{
+			name:     "no nodes",
+			goal:     SurvivalGoal_ZONE_FAILURE,
+			expected: "[]",
+		},
+		{
+			name:       "no regions",
+			localities: topology("dc=a", "dc=b", "dc=c"),
+			goal:       SurvivalGoal_ZONE_FAILURE,
+			expected:   "[]",
+		},
+		{
+			name:       "single region",
+			localities: topology("region=a,zone=a1", "region=a,zone=a2", "region=a,zone=a3"),
+			goal:       SurvivalGoal_ZONE_FAILURE,
+			expected:   "[+region=a]",
+		},
+		{
+			name: "region with the most zones",
+			localities: topology(
+				"region=a,zone=a1", "region=a,zone=a1", "region=a,zone=a1", "region=a,zone=a2",
+				"region=b,zone=b1", "region=b,zone=b2", "region=b,zone=b3",
+			),
+			goal:     SurvivalGoal_ZONE_FAILURE,
+			expected: "[+region=b]",
+		},
+		{
+			name:       "too few zones",
+			localities: topology("region=a,zone=a1", "region=a,zone=a2", "region=b,zone=b1"),
+			goal:       SurvivalGoal_ZONE_FAILURE,
+			expected:   "[]",
+		},
+		{
+			name: "regions",
+			localities: topology(
+				"region=c,zone=c1", "region=a,zone=a1", "region=b,zone=b1", "region=a,zone=a2",
+			),
+			goal:     SurvivalGoal_REGION_FAILURE,
+			expected: `{"+region=a": 1, "+region=b": 1, "+region=c": 1}`,
+		},
+		{
+			name:       "too few regions",
+			localities: topology("region=a", "region=a", "region=b"),
+			goal:       SurvivalGoal_REGION_FAILURE,
+			expected:   "[]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var expected ConstraintsList
+			require.NoError(t, yaml.Unmarshal([]byte(tc.expected), &expected))
+			require.Equal(t, expected, SuggestConstraints(tc.localities, tc.goal))
+		})
+	}
+}
+
+func TestPlanZoneChange(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	store := func(id roachpb.StoreID, locality string) roachpb.StoreDescriptor {
+		var l roachpb.Locality
+		require.NoError(t, l.Set(locality))
+		return roachpb.StoreDescriptor{StoreID: id, Node: roachpb.NodeDescriptor{Locality: l}}
+	}
+	topology := []roachpb.StoreDescriptor{
+		store(1, "region=a"), store(2, "region=a"), store(3, "region=b"),
+		store(4, "region=b"), store(5, "region=c"),
+	}
+
+	testCases := []struct {
+		name              string
+		current, desired  string
+		changed           []tree.Name
+		impact            ZoneChangeImpact
+		additions, remove int32
+		warnings          int
+		invalid           bool
+	}{
+		{
+			name:   "no-op",
+			impact: ZoneChangeImpactNone,
+		},
+		{
+			name:     "gc ttl",
+			desired:  "gc: {ttlseconds: 1}",
+			changed:  []tree.Name{"gc.ttlseconds"},
+			impact:   ZoneChangeImpactMetadata,
+			warnings: 1,
+		},
+		{
+			// The constraints apply to all 3 replicas, but only 2 stores match.
+			name:     "range sizes and lease preferences",
+			current:  "constraints: [+region=a]",
+			desired:  "constraints: [+region=a]\nrange_max_bytes: 1073741824\nlease_preferences: [[+region=a]]",
+			changed:  []tree.Name{"range_max_bytes", "lease_preferences"},
+			impact:   ZoneChangeImpactLeases,
+			warnings: 1,
+		},
+		{
+			name:    "reordered constraints",
+			current: "constraints: {'-region=c,-region=d': 2, +region=b: 1}",
+			desired: "constraints: {+region=b: 1, '-region=d,-region=c': 2}",
+			impact:  ZoneChangeImpactNone,
+		},
+		{
+			name:      "upreplicate with reordered constraints",
+			current:   "constraints: {'-region=c,-region=d': 2, +region=b: 1}",
+			desired:   "num_replicas: 5\nconstraints: {+region=b: 1, '-region=d,-region=c': 2}",
+			changed:   []tree.Name{"num_replicas"},
+			impact:    ZoneChangeImpactReplicas,
+			additions: 2,
+		},
+		{
+			name:      "upreplicate",
+			desired:   "num_replicas: 5",
+			changed:   []tree.Name{"num_replicas"},
+			impact:    ZoneChangeImpactReplicas,
+			additions: 2,
+		},
+		{
+			name:      "per-replica constraints",
+			current:   "constraints: {+region=a: 1, +region=b: 1}",
+			desired:   "constraints: {+region=a: 1, +region=c: 1}",
+			changed:   []tree.Name{"constraints"},
+			impact:    ZoneChangeImpactReplicas,
+			additions: 1,
+			remove:    1,
+		},
+		{
+			name:      "unsatisfiable",
+			desired:   "num_replicas: 7\nconstraints: [+region=d]",
+			changed:   []tree.Name{"num_replicas", "constraints"},
+			impact:    ZoneChangeImpactReplicas,
+			additions: 7,
+			remove:    3,
+			warnings:  2,
+		},
+		{
+			name:     "invalid",
+			desired:  "num_replicas: 2",
+			changed:  []tree.Name{"num_replicas"},
+			impact:   ZoneChangeImpactReplicas,
+			remove:   1,
+			warnings: 1,
+			invalid:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current := zoneFromYAML(t, DefaultZoneConfig(), tc.current)
+			desired := zoneFromYAML(t, DefaultZoneConfig(), tc.desired)
+			plan := PlanZoneChange(current, desired, topology)
+			require.Equal(t, tc.invalid, plan.ValidationError != nil, "%v", plan.ValidationError)
+			require.Len(t, plan.Warnings, tc.warnings)
+			require.Equal(t, tc.changed, plan.ChangedFields)
+			require.Equal(t, tc.impact, plan.Impact)
+			require.Equal(t, tc.additions, plan.ReplicaAdditions)
+			require.Equal(t, tc.remove, plan.ReplicaRemovals)
+		})
+	}
+}
+
+func TestParsePlacementTable(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		name        string
+		input       string
+		delimiter   rune
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "csv",
+			input: `table,region,replicas,lease_region
+users,us-east1,2,us-east1
+users,us-west1,1,
+orders,eu-west1,3,
+`,
+			delimiter: ',',
+			expected: map[string]string{
+				"users": `num_replicas: 3
+constraints: {+region=us-east1: 2, +region=us-west1: 1}
+lease_preferences: [[+region=us-east1]]`,
+				"orders": `num_replicas: 3
+constraints: {+region=eu-west1: 3}`,
+			},
+		},
+		{
+			name:      "tsv without lease regions",
+			input:     "Region\tTable\tReplicas\nus-east1\tusers\t3\nus-west1\tusers\t2\n",
+			delimiter: '\t',
+			expected: map[string]string{
+				"users": `num_replicas: 5
+constraints: {+region=us-east1: 3, +region=us-west1: 2}`,
+			},
+		},
+		{
+			name:        "empty",
+			delimiter:   ',',
+			expectedErr: "placement table is empty",
+		},
+		{
+			name:        "missing column",
+			input:       "table,region\nusers,us-east1\n",
+			delimiter:   ',',
+			expectedErr: `missing the "replicas" column`,
+		},
+		{
+			name:        "unknown column",
+			input:       "table,region,replicas,zone\nusers,us-east1,3,a\n",
+			delimiter:   ',',
+			expectedErr: `unknown placement table column "zone"`,
+		},
+		{
+			name:        "invalid replicas",
+			input:       "table,region,replicas\nusers,us-east1,three\n",
+			delimiter:   ',',
+			expectedErr: `line 2: invalid number of replicas "three"`,
+		},
+		{
+			name:        "duplicate region",
+			input:       "table,region,replicas\nusers,us-east1,1\nusers,us-east1,2\n",
+			delimiter:   ',',
+			expectedErr: `line 3: duplicate region "us-east1" for table "users"`,
+		},
+		{
+			name: "conflicting lease regions",
+			input: "table,region,replicas,lease_region\n" +
+				"users,us-east1,2,us-east1\nusers,us-west1,1,us-west1\n",
+			delimiter:   ',',
+			expectedErr: `conflicting lease regions "us-east1" and "us-west1"`,
+		},
+		{
+			name:        "lease region without replicas",
+			input:       "table,region,replicas,lease_region\nusers,us-east1,3,us-west1\n",
+			delimiter:   ',',
+			expectedErr: `lease region "us-west1" of table "users" is not one of its regions`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zones, err := ParsePlacementTable(strings.NewReader(tc.input), tc.delimiter)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, zones, len(tc.expected))
+			for _, z := range zones {
+				expected := NewZoneConfig()
+				require.NoError(t, yaml.Unmarshal([]byte(tc.expected[z.Table]), expected))
+				require.Equal(t, *expected, z.Zone, "table %s", z.Table)
+				require.NoError(t, z.Zone.ValidateTandemFields())
+				require.NoError(t, z.Zone.Validate())
+			}
+		})
+	}
+}
+
+func TestEstimateImpact(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	store := func(id roachpb.StoreID, region string) roachpb.StoreDescriptor {
+		return roachpb.StoreDescriptor{
+			StoreID: id,
+			Node: roachpb.NodeDescriptor{
+				Locality: roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}},
+			},
+			// 100 replicas of 1 MiB each.
+			Capacity: roachpb.StoreCapacity{RangeCount: 100, LogicalBytes: 100 << 20},
+		}
+	}
+	stores := []roachpb.StoreDescriptor{
+		store(1, "a"), store(2, "a"), store(3, "b"), store(4, "b"), store(5, "c"),
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		desired := zoneFromYAML(t, DefaultZoneConfig(), "gc: {ttlseconds: 600}")
+		report := EstimateImpact(DefaultZoneConfig(), desired, 1000, stores)
+		require.Equal(t, ImpactReport{Impact: ZoneChangeImpactMetadata, Satisfiable: true}, report)
+	})
+
+	t.Run("rebalance", func(t *testing.T) {
+		desired := zoneFromYAML(t, DefaultZoneConfig(),
+			"num_replicas: 5\nconstraints: {+region=a: 2, +region=b: 2}")
+		report := EstimateImpact(DefaultZoneConfig(), desired, 1000, stores)
+		require.Equal(t, ZoneChangeImpactReplicas, report.Impact)
+		// Each range gains 2 replicas and moves 3.
+		require.Equal(t, int64(5000), report.ReplicaAdditions)
+		require.Equal(t, int64(3000), report.ReplicaRemovals)
+		require.Equal(t, int64(5000<<20), report.RebalanceBytes)
+		require.True(t, report.Satisfiable)
+	})
+
+	t.Run("unsatisfiable", func(t *testing.T) {
+		desired := zoneFromYAML(t, DefaultZoneConfig(), "constraints: {+region=a: 3}")
+		report := EstimateImpact(DefaultZoneConfig(), desired, 10, stores)
+		require.False(t, report.Satisfiable)
+		require.Len(t, report.Unsatisfiable, 1)
+		require.Equal(t, LintUnsatisfiableConstraints, report.Unsatisfiable[0].Rule)
+	})
+
+	t.Run("no capacity", func(t *testing.T) {
+		desired := zoneFromYAML(t, DefaultZoneConfig(), "num_replicas: 5")
+		report := EstimateImpact(DefaultZoneConfig(), desired, 10, nil /* stores */)
+		require.Equal(t, int64(20), report.ReplicaAdditions)
+		require.Zero(t, report.RebalanceBytes)
+		require.False(t, report.Satisfiable)
+	})
+}
+
+func TestExplainUnsatisfied(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	store := func(id roachpb.StoreID, region string, attrs ...string) roachpb.StoreDescriptor {
+		return roachpb.StoreDescriptor{
+			StoreID: id,
+			Attrs:   roachpb.Attributes{Attrs: attrs},
+			Node: roachpb.NodeDescriptor{
+				Locality: roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}},
+			},
+		}
+	}
+	stores := []roachpb.StoreDescriptor{
+		store(1, "a", "ssd"), store(2, "a"), store(3, "b", "ssd"), store(4, "b"), store(5, "c"),
+	}
+
+	testCases := []struct {
+		name     string
+		zone     string
+		stores   []roachpb.StoreDescriptor
+		expected []string
+	}{
+		{name: "satisfied", stores: stores},
+		{
+			name:     "num_replicas",
+			zone:     "num_replicas: 7",
+			stores:   stores,
+			expected: []string{"num_replicas is 7 but there are only 5 stores"},
+		},
+		{
+			name:     "no stores",
+			expected: []string{"num_replicas is 3 but there are only 0 stores"},
+		},
+		{
+			name:   "constraints for all replicas",
+			zone:   "constraints: [+region=a]",
+			stores: stores,
+			expected: []string{
+				`constraints "+region=a": 2 of 5 stores match, 3 needed; +region=a rules out 3`,
+			},
+		},
+		{
+			name:   "per-replica constraints",
+			zone:   "constraints: {'+region=a,+ssd': 2}",
+			stores: stores,
+			expected: []string{
+				`constraints "+region=a,+ssd:2": 1 of 5 stores match, 2 needed; ` +
+					`+region=a rules out 3, +ssd rules out 3`,
+			},
+		},
+		{
+			name:   "voter constraints",
+			zone:   "num_voters: 3\nvoter_constraints: [+region=c]",
+			stores: stores,
+			expected: []string{
+				`voter_constraints "+region=c": 1 of 5 stores match, 3 needed; +region=c rules out 4`,
+			},
+		},
+		{
+			// Stores in region c can't hold voters, so they can't hold the lease.
+			name:   "lease preferences",
+			zone:   "constraints: [-region=c]\nlease_preferences: [[+region=c], [+region=a]]",
+			stores: stores,
+			expected: []string{
+				`lease preference "[+region=c]": 0 of 4 stores which may hold voters match, 1 needed; ` +
+					`+region=c rules out 4`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := DefaultZoneConfig()
+			require.NoError(t, yaml.Unmarshal([]byte(tc.zone), &zone))
+			require.Equal(t, tc.expected, ExplainUnsatisfied(zone, tc.stores))
+		})
+	}
+}
+
+func TestZoneConfigProtoText(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var fromYAML ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 5
+gc: {ttlseconds: 600}
+constraints: {+region=b: 1, +region=a: 2}
+lease_preferences: [[+region=a]]
+`), &fromYAML))
+
+	// Per-replica constraints are sorted like they are in YAML.
+	fromText, err := ParseZoneConfigFromText(`
+num_replicas: 5
+gc: < ttl_seconds: 600 >
+constraints: < num_replicas: 1 constraints: < type: REQUIRED key: "region" value: "b" > >
+constraints: < num_replicas: 2 constraints: < type: REQUIRED key: "region" value: "a" > >
+lease_preferences: < constraints: < type: REQUIRED key: "region" value: "a" > >
+`)
+	require.NoError(t, err)
+	require.Equal(t, fromYAML, fromText)
+
+	// Round-trip through the text format.
+	roundTripped, err := ParseZoneConfigFromText(fromText.MarshalProtoText())
+	require.NoError(t, err)
+	require.Equal(t, fromText, roundTripped)
+
+	original := NewPopulatedZoneConfig(
+		rand.New(rand.NewSource(timeutil.Now().UnixNano())), false /* easy */)
+	original.normalizeParsedText()
+	roundTripped, err = ParseZoneConfigFromText(original.MarshalProtoText())
+	require.NoError(t, err)
+	require.True(t, original.Equal(&roundTripped))
+
+	_, err = ParseZoneConfigFromText("num_replicas: five")
+	require.Error(t, err)
+}
+
+func TestZoneConfigCRDSpec(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+range_min_bytes: 1048576
+range_max_bytes: 67108864
+gc: {ttlseconds: 600}
+global_reads: true
+num_replicas: 5
+num_voters: 3
+constraints: {+region=b: 1, +region=a: 2}
+voter_constraints: [+region=a]
+lease_preferences: [[+region=a], [+region=b, -zone=b1]]
+`), zone))
+
+	data, err := json.Marshal(zone.ToCRDSpec())
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "apiVersion": "crdb.cockroachlabs.com/v1alpha1",
+  "kind": "ZoneConfig",
+  "spec": {
+    "rangeMinBytes": 1048576,
+    "rangeMaxBytes": 67108864,
+    "gc": {"ttlSeconds": 600},
+    "globalReads": true,
+    "numReplicas": 5,
+    "numVoters": 3,
+    "constraints": [
+      {"constraints": ["+region=a"], "numReplicas": 2},
+      {"constraints": ["+region=b"], "numReplicas": 1}
+    ],
+    "voterConstraints": [{"constraints": ["+region=a"]}],
+    "leasePreferences": [
+      {"constraints": ["+region=a"]},
+      {"constraints": ["+region=b", "-zone=b1"]}
+    ]
+  }
+}`, string(data))
+
+	var crd ZoneConfigCRD
+	require.NoError(t, json.Unmarshal(data, &crd))
+	roundTripped, err := FromCRDSpec(crd)
+	require.NoError(t, err)
+	require.Equal(t, *zone, roundTripped)
+
+	// Omitted fields are inherited, but empty lists are not.
+	data, err = json.Marshal(NewZoneConfig().ToCRDSpec())
+	require.NoError(t, err)
+	require.JSONEq(t, `{"apiVersion": "crdb.cockroachlabs.com/v1alpha1", "kind": "ZoneConfig", "spec": {}}`,
+		string(data))
+	crd = ZoneConfigCRD{}
+	require.NoError(t, json.Unmarshal(
+		[]byte(`{"apiVersion": "crdb.cockroachlabs.com/v1alpha1", "kind": "ZoneConfig",
+			"spec": {"constraints": [], "leasePreferences": []}}`), &crd))
+	roundTripped, err = FromCRDSpec(crd)
+	require.NoError(t, err)
+	require.False(t, roundTripped.InheritedConstraints)
+	require.Empty(t, roundTripped.Constraints)
+	require.False(t, roundTripped.InheritedLeasePreferences)
+	require.True(t, roundTripped.InheritedVoterConstraints())
+	require.Nil(t, roundTripped.NumReplicas)
+
+	for _, tc := range []struct {
+		crd         ZoneConfigCRD
+		expectedErr string
+	}{
+		{
+			crd:         ZoneConfigCRD{APIVersion: "v1", Kind: ZoneConfigCRDKind},
+			expectedErr: `unsupported apiVersion "v1"`,
+		},
+		{
+			crd:         ZoneConfigCRD{APIVersion: ZoneConfigCRDAPIVersion, Kind: "Zone"},
+			expectedErr: `unsupported kind "Zone"`,
+		},
+		{
+			crd: ZoneConfigCRD{APIVersion: ZoneConfigCRDAPIVersion, Kind: ZoneConfigCRDKind,
+				Spec: ZoneConfigCRDSpec{Constraints: &[]ZoneConfigCRDConstraints{
+					{Constraints: []string{"+region=a=b"}}}}},
+			expectedErr: `constraints: constraint needs to be in the form`,
+		},
+		{
+			crd: ZoneConfigCRD{APIVersion: ZoneConfigCRDAPIVersion, Kind: ZoneConfigCRDKind,
+				Spec: ZoneConfigCRDSpec{VoterConstraints: &[]ZoneConfigCRDConstraints{
+					{Constraints: []string{"+region=a"}, NumReplicas: -1}}}},
+			expectedErr: "voterConstraints: the number of replicas of per-replica constraints must be positive",
+		},
+	} {
+		_, err := FromCRDSpec(tc.crd)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.expectedErr)
+	}
+}
+
+func TestZoneConfigFlatAttributes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+range_min_bytes: 1048576
+range_max_bytes: 67108864
+gc: {ttlseconds: 600}
+global_reads: false
+num_replicas: 5
+num_voters: 3
+constraints: {"+region=a,+zone=a1": 2, +region=b: 1}
+voter_constraints: [+region=a]
+lease_preferences: [[+region=a], [+region=b, -zone=b1]]
+`), zone))
+
+	attrs, err := zone.ToFlatAttributes()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"range_min_bytes":   int64(1048576),
+		"range_max_bytes":   int64(67108864),
+		"gc_ttlseconds":     int64(600),
+		"global_reads":      false,
+		"num_replicas":      int64(5),
+		"num_voters":        int64(3),
+		"constraints":       `{'+region=a,+zone=a1': 2, +region=b: 1}`,
+		"voter_constraints": "[+region=a]",
+		"lease_preferences": []string{"[+region=a]", "[+region=b,-zone=b1]"},
+	}, attrs)
+	roundTripped, err := FromFlatAttributes(attrs)
+	require.NoError(t, err)
+	require.Equal(t, *zone, roundTripped)
+
+	// Terraform providers may pass numbers and lists with other types.
+	roundTripped, err = FromFlatAttributes(map[string]interface{}{
+		"num_replicas":      float64(5),
+		"gc_ttlseconds":     600,
+		"constraints":       "[]",
+		"lease_preferences": []interface{}{"[+region=a]"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(5), *roundTripped.NumReplicas)
+	require.Equal(t, int32(600), roundTripped.GC.TTLSeconds)
+	require.False(t, roundTripped.InheritedConstraints)
+	require.Empty(t, roundTripped.Constraints)
+	require.Equal(t, []LeasePreference{{Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: "region", Value: "a"}}}}, roundTripped.LeasePreferences)
+	require.True(t, roundTripped.InheritedVoterConstraints())
+
+	// Long constraints remain on a single line.
+	long := NewZoneConfig()
+	long.Constraints = []ConstraintsConjunction{{Constraints: make([]Constraint, 10)}}
+	for i := range long.Constraints[0].Constraints {
+		long.Constraints[0].Constraints[i] = Constraint{
+			Type: Constraint_REQUIRED, Key: "region", Value: fmt.Sprintf("region-%d", i)}
+	}
+	long.InheritedConstraints = false
+	attrs, err = long.ToFlatAttributes()
+	require.NoError(t, err)
+	require.NotContains(t, attrs["constraints"], "\n")
+	roundTripped, err = FromFlatAttributes(attrs)
+	require.NoError(t, err)
+	require.Equal(t, *long, roundTripped)
+
+	empty, err := NewZoneConfig().ToFlatAttributes()
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	for _, tc := range []struct {
+		attrs       map[string]interface{}
+		expectedErr string
+	}{
+		{map[string]interface{}{"num_replica": 3, "gc": 1}, "unknown attributes: gc, num_replica"},
+		{map[string]interface{}{"num_replicas": "3"}, "num_replicas: expected an integer, found string"},
+		{map[string]interface{}{"num_replicas": 1.5}, "num_replicas: expected an integer, found 1.5"},
+		{map[string]interface{}{"num_voters": int64(1) << 40}, "num_voters: 1099511627776 is out of range"},
+		{map[string]interface{}{"global_reads": "true"}, "global_reads: expected a bool, found string"},
+		{map[string]interface{}{"constraints": ""}, `constraints: invalid constraints ""`},
+		{map[string]interface{}{"voter_constraints": "[region=a=b]"}, "voter_constraints: line 1, column 1: constraint needs"},
+		{map[string]interface{}{"lease_preferences": "[+region=a]"}, "lease_preferences: expected a list of strings"},
+	} {
+		_, err := FromFlatAttributes(tc.attrs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.expectedErr)
+	}
+}
+
+func TestZoneConfigFormat(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 5
+gc: {ttlseconds: 600}
+constraints: {+region=b: 1, +region=a: 2}
+lease_preferences: [[+region=a]]
+`), zone))
+
+	compact := zone.Format(FormatOptions{Style: FormatCompact})
+	require.Equal(t,
+		"{gc: {ttlseconds: 600}, num_replicas: 5, constraints: {+region=a: 2, +region=b: 1}, "+
+			"lease_preferences: [[+region=a]]}", compact)
+	// The compact rendering is valid YAML for the zone config.
+	roundTripped := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(compact), roundTripped))
+	require.Equal(t, zone, roundTripped)
+	require.Equal(t, "{}", NewZoneConfig().Format(FormatOptions{Style: FormatCompact}))
+
+	expected, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), zone.Format(FormatOptions{Style: FormatYAML}))
+
+	zone = NewZoneConfig()
+	zone.NumReplicas = proto.Int32(5)
+	zone.GC = &GCPolicy{TTLSeconds: 600}
+	require.Equal(t, `range_min_bytes: 134217728 # inherited from default
+range_max_bytes: 536870912 # inherited from default
+gc:
+  ttlseconds: 600
+global_reads: null
+num_replicas: 5
+num_voters: null
+constraints: [] # inherited from default
+voter_constraints: [] # inherited from default
+lease_preferences: [] # inherited from default
+`, zone.Format(FormatOptions{Style: FormatVerbose}))
+
+	parent := DefaultZoneConfig()
+	parent.GlobalReads = proto.Bool(true)
+	require.Contains(t, zone.Format(FormatOptions{
+		Style: FormatVerbose, Parent: &parent, ParentName: "DATABASE db",
+	}), "global_reads: true # inherited from DATABASE db\n")
+}
+
+func TestUnmarshalYAMLWithVars(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	vars := map[string]string{
+		"primary":   "us-east1",
+		"secondary": "us-west1",
+		"replicas":  "5",
+		"voters":    "3",
+		"odd":       "a,b=c",
+	}
+	zone, err := UnmarshalYAMLWithVars([]byte(`
+num_replicas: ${replicas}
+gc: {ttlseconds: 600}
+constraints: {'+region=${primary}': 2, '+region=${secondary}': '${voters}'}
+voter_constraints:
+- +region=${primary}
+lease_preferences: [['+region=${primary}'], ['+region=${odd}']]
+`), vars)
+	require.NoError(t, err)
+	expected := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 5
+gc: {ttlseconds: 600}
+constraints: {+region=us-east1: 2, +region=us-west1: 3}
+voter_constraints: [+region=us-east1]
+lease_preferences: [[+region=us-east1], ['+region=a\,b\=c']]
+`), expected))
+	require.Equal(t, *expected, zone)
+	// Values with separators are escaped rather than split.
+	require.Equal(t, Constraint{Type: Constraint_REQUIRED, Key: "region", Value: "a,b=c"},
+		zone.LeasePreferences[1].Constraints[0])
+
+	zone, err = UnmarshalYAMLWithVars(nil, vars)
+	require.NoError(t, err)
+	require.Equal(t, *NewZoneConfig(), zone)
+
+	for input, expectedErr := range map[string]string{
+		`constraints: ['+region=${tertiary}']`: `constraints: line 1, column 15: undefined variable "tertiary"`,
+		`constraints: ['+region=${primary']`:   `constraints: line 1, column 15: unterminated placeholder`,
+		`num_replicas: ${primary}`: `num_replicas: line 1, column 15: replica count "${primary}" ` +
+			`is not an integer`,
+	} {
+		_, err := UnmarshalYAMLWithVars([]byte(input), vars)
+		require.Error(t, err, input)
+		require.Contains(t, err.Error(), expectedErr, input)
+	}
+}
+
+func TestUnmarshalLegacyYAML(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var warnings []Warning
+	warn := func(w Warning) { warnings = append(warnings, w) }
+	for _, tc := range []struct {
+		input    string
+		expected string
+		warning  string
+	}{
+		{
+			input: `
+range_max_bytes: 67108864
+replicas:
+- attrs: [ssd]
+- attrs: [ssd]
+- attrs: [ssd]
+`,
+			expected: `
+range_max_bytes: 67108864
+num_replicas: 3
+constraints: [+ssd]
+`,
+			warning: "converted to {num_replicas: 3, constraints: [+ssd]}",
+		},
+		{
+			input: `replicas: [{attrs: [ssd, mem]}, {attrs: [hdd]}, {attrs: [mem, ssd]}, {attrs: []}]`,
+			expected: `
+num_replicas: 4
+constraints: {'+mem,+ssd': 2, +hdd: 1}
+`,
+			warning: "converted to {num_replicas: 4, constraints: {'+mem,+ssd': 2, +hdd: 1}}",
+		},
+		{
+			input:    `replicas: [{}, {}]`,
+			expected: `num_replicas: 2`,
+			warning:  "converted to {num_replicas: 2}",
+		},
+		{
+			// Documents without replicas are unmarshaled as is.
+			input:    `num_replicas: 5`,
+			expected: `num_replicas: 5`,
+		},
+	} {
+		warnings = nil
+		zone, err := UnmarshalLegacyYAML([]byte(tc.input), warn)
+		require.NoError(t, err, tc.input)
+		expected := NewZoneConfig()
+		require.NoError(t, yaml.Unmarshal([]byte(tc.expected), expected))
+		require.Equal(t, *expected, zone, tc.input)
+		if tc.warning == "" {
+			require.Empty(t, warnings)
+		} else {
+			require.Len(t, warnings, 1)
+			require.Equal(t, WarningDeprecatedField, warnings[0].Code)
+			require.Equal(t, "replicas", warnings[0].Field)
+			require.Contains(t, warnings[0].Message, tc.warning)
+		}
+	}
+
+	for input, expectedErr := range map[string]string{
+		`replicas: []`: `replicas: line 1, column 11: at least one replica is required`,
+		`{replicas: [{attrs: [ssd]}], num_replicas: 3}`:     `replicas: can't be combined with num_replicas`,
+		`{replicas: [{attrs: [ssd]}], constraints: [+ssd]}`: `replicas: can't be combined with constraints`,
+		`replicas: [{attrs: [""]}]`:                         `replicas: line 1, column 11: attributes must not be empty`,
+		`replicas: [{attributes: [ssd]}]`:                   `field attributes not found`,
+	} {
+		_, err := UnmarshalLegacyYAML([]byte(input), nil)
+		require.Error(t, err, input)
+		require.Contains(t, err.Error(), expectedErr, input)
+	}
+	// Without the legacy decoder, the replicas field is rejected.
+	require.Error(t, yaml.Unmarshal([]byte(`replicas: [{attrs: [ssd]}]`), NewZoneConfig()))
+}
+
+func TestConstraintKind(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for short, expected := range map[string]ConstraintKind{
+		"+ssd":              ConstraintKindAttribute,
+		"-hdd":              ConstraintKindAttribute,
+		"+region=us-east1":  ConstraintKindLocality,
+		"-zone=us-east1-a":  ConstraintKindLocality,
+		"datacenter=us-dc1": ConstraintKindLocality,
+	} {
+		var c Constraint
+		require.NoError(t, c.FromString(short))
+		require.Equal(t, expected, c.Kind(), short)
+	}
+
+	var zone ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 3
+num_voters: 3
+constraints: [+ssd, -zone=a1]
+voter_constraints: {+region=a: 2}
+lease_preferences: [[+region=a, +rack=1]]
+`), &zone))
+	zone.SetSubzone(Subzone{IndexID: 1, Config: ZoneConfig{
+		Constraints: []ConstraintsConjunction{
+			{Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "cloud", Value: "gcp"}}},
+		},
+	}})
+	require.Equal(t, []string{"cloud", "rack", "region", "zone"}, zone.ReferencedTierKeys())
+	require.Empty(t, NewZoneConfig().ReferencedTierKeys())
+}
+
+func TestConstraintMatches(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		constraint string
+		tier       roachpb.Tier
+		expected   bool
+	}{
+		{"+region=us-east1", roachpb.Tier{Key: "region", Value: "us-east1"}, true},
+		{"+region=us-east1", roachpb.Tier{Key: "region", Value: "us-east2"}, false},
+		{"+region=us-east1", roachpb.Tier{Key: "zone", Value: "us-east1"}, false},
+		{"-region=us-*", roachpb.Tier{Key: "region", Value: "us-east1"}, true},
+		{"+region=us-*", roachpb.Tier{Key: "region", Value: "us-"}, true},
+		{"+region=us-*", roachpb.Tier{Key: "region", Value: "eu-west1"}, false},
+		{"+region=*", roachpb.Tier{Key: "region", Value: "eu-west1"}, true},
+		{"+zone=*-east*-a", roachpb.Tier{Key: "zone", Value: "us-east1-a"}, true},
+		{"+zone=*-east*-a", roachpb.Tier{Key: "zone", Value: "us-east1-b"}, false},
+		{"+zone=*-east*-a", roachpb.Tier{Key: "zone", Value: "us-west1-a"}, false},
+		{"+zone=a*a", roachpb.Tier{Key: "zone", Value: "a"}, false},
+		{"+zone=a*a", roachpb.Tier{Key: "zone", Value: "aa"}, true},
+		{"+ssd", roachpb.Tier{Key: "", Value: "ssd"}, false},
+	}
+	for _, tc := range testCases {
+		var c Constraint
+		require.NoError(t, c.FromString(tc.constraint))
+		require.Equal(t, tc.expected, c.Matches(tc.tier), "%s %s", tc.constraint, tc.tier)
+
+		store := roachpb.StoreDescriptor{
+			Node: roachpb.NodeDescriptor{Locality: roachpb.Locality{Tiers: []roachpb.Tier{tc.tier}}},
+		}
+		require.Equal(t, tc.expected, StoreMatchesConstraint(store, c), tc.constraint)
+		spanConfigConstraint := roachpb.Constraint{Key: c.Key, Value: c.Value}
+		require.Equal(t, tc.expected,
+			roachpb.StoreMatchesConstraint(store, spanConfigConstraint), tc.constraint)
+	}
+}
+
+func TestHasWildcardConstraints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		yaml     string
+		expected bool
+	}{
+		{"constraints: [+region=us-east1]", false},
+		{"constraints: [+region=us-*]", true},
+		{"constraints: {'+region=us-east1': 1, '-zone=*-a': 1}", true},
+		{"voter_constraints: [+region=*]", true},
+		{"lease_preferences: [[+region=us-east1], [+region=eu-*]]", true},
+	} {
+		zone := zoneFromYAML(t, ZoneConfig{}, tc.yaml)
+		require.Equal(t, tc.expected, zone.HasWildcardConstraints(), tc.yaml)
+
+		// The constraints of subzones count too.
+		var table ZoneConfig
+		table.SetSubzone(Subzone{IndexID: 1, Config: zone})
+		require.Equal(t, tc.expected, table.HasWildcardConstraints(), tc.yaml)
+	}
+}
+
+func TestStoreConstraints(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var c Constraint
+	require.NoError(t, c.FromString("+store=5"))
+	require.Equal(t, Constraint{Type: Constraint_REQUIRED, Key: StoreConstraintKey, Value: "5"}, c)
+	require.Equal(t, ConstraintKindStore, c.Kind())
+	require.Equal(t, "+store=5", c.String())
+	id, ok := c.StoreID()
+	require.True(t, ok)
+	require.Equal(t, roachpb.StoreID(5), id)
+	_, ok = Constraint{Type: Constraint_REQUIRED, Key: "region", Value: "5"}.StoreID()
+	require.False(t, ok)
+
+	for _, short := range []string{"+store=s1", "-store=0", "+store=-1", "+store=*", "+store=99999999999"} {
+		var c Constraint
+		err := c.FromString(short)
+		require.Error(t, err, short)
+		require.Contains(t, err.Error(), "must be a positive store ID")
+	}
+
+	// Store constraints match the store with their ID, whatever its locality,
+	// even when it has a locality tier named store.
+	store := roachpb.StoreDescriptor{
+		StoreID: 5,
+		Node: roachpb.NodeDescriptor{Locality: roachpb.Locality{Tiers: []roachpb.Tier{
+			{Key: "store", Value: "6"},
+		}}},
+	}
+	for _, tc := range []struct {
+		constraint string
+		matches    bool
+		satisfies  bool
+	}{
+		{"+store=5", true, true},
+		{"+store=6", false, false},
+		{"-store=5", true, false},
+		{"-store=6", false, true},
+	} {
+		var c Constraint
+		require.NoError(t, c.FromString(tc.constraint))
+		require.Equal(t, ConstraintKindStore, c.Kind(), tc.constraint)
+		require.Equal(t, tc.matches, StoreMatchesConstraint(store, c), tc.constraint)
+		require.Equal(t, tc.satisfies, StoreSatisfiesConstraint(store, c), tc.constraint)
+		require.Equal(t, tc.matches,
+			roachpb.StoreMatchesConstraint(store, roachpb.Constraint{Key: c.Key, Value: c.Value}), tc.constraint)
+	}
+
+	var zone ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 3
+constraints: [+store=1]
+lease_preferences: [[+store=2], [+store=1]]
+`), &zone))
+	require.NoError(t, zone.Validate())
+	require.Empty(t, zone.ReferencedTierKeys())
+	require.True(t, zone.HasStoreConstraints())
+	var findings []string
+	for _, f := range LintZoneConfig(zone) {
+		findings = append(findings, f.String())
+	}
+	require.Equal(t, []string{
+		"contradictory_lease_preferences: lease preference +store=2 conflicts with constraint +store=1, " +
+			"which applies to every replica",
+		"store_constraints: constraint +store=1 refers to a store by its ID, which doesn't carry over " +
+			"to the store replacing it; remove it once it's no longer needed",
+		"store_constraints: constraint +store=2 refers to a store by its ID, which doesn't carry over " +
+			"to the store replacing it; remove it once it's no longer needed",
+	}, findings)
+
+	// The constraints of subzones count too.
+	var table ZoneConfig
+	table.SetSubzone(Subzone{IndexID: 1, Config: zone})
+	require.True(t, table.HasStoreConstraints())
+	locality := zoneFromYAML(t, ZoneConfig{}, "constraints: [+region=1]")
+	require.False(t, locality.HasStoreConstraints())
+
+	zone.Constraints[0].Constraints[0].Value = "first"
+	err := zone.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid constraint +store=first")
+}
+
+func TestParseZoneConfigBundle(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	t.Run("valid", func(t *testing.T) {
+		zones, err := ParseZoneConfigBundle(strings.NewReader(`
+target: db.table@idx
+num_replicas: 5
+constraints: {+region=a: 2, +region=b: 2}
+---
+target: RANGE default
+gc: {ttl: 4h}
+---
+---
+target: db.other
+`))
+		require.NoError(t, err)
+		require.Equal(t, map[string]ZoneConfig{
+			"db.table@idx": zoneFromYAML(t, *NewZoneConfig(),
+				"num_replicas: 5\nconstraints: {+region=a: 2, +region=b: 2}"),
+			"RANGE default": zoneFromYAML(t, *NewZoneConfig(), "gc: {ttlseconds: 14400}"),
+			"db.other":      *NewZoneConfig(),
+		}, zones)
+	})
+
+	testCases := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{
+			name:        "missing target",
+			input:       "num_replicas: 3",
+			expectedErr: "document 1: missing target",
+		},
+		{
+			name:        "invalid target",
+			input:       "target: [a, b]\nnum_replicas: 3",
+			expectedErr: "document 1: line 1, column 9: target must be a non-empty string",
+		},
+		{
+			name:        "duplicate target",
+			input:       "target: a\nnum_replicas: 3\n---\ntarget: a\nnum_replicas: 5",
+			expectedErr: `document 2: duplicate target "a"`,
+		},
+		{
+			name:        "invalid config",
+			input:       "target: a\nnum_replicas: 3\n---\ntarget: b\nnum_replica: 5",
+			expectedErr: `document 2: target "b"`,
+		},
+		{
+			name:        "not a mapping",
+			input:       "- a\n- b",
+			expectedErr: "document 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseZoneConfigBundle(strings.NewReader(tc.input))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestZoneConfigBundleDecoder(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The documents are decoded one at a time, and document markers may be
+	// followed by content or preceded by an explicit end of document.
+	var bundle strings.Builder
+	bundle.WriteString("# exported zone configs\n--- {target: a, num_replicas: 3}\n")
+	const numDocs = 1000
+	for i := 1; i < numDocs; i++ {
+		fmt.Fprintf(&bundle, "---\ntarget: t%d\ngc:\n  ttlseconds: %d\n...\n", i, i)
+	}
+	decoder := NewZoneConfigBundleDecoder(strings.NewReader(bundle.String()))
+	target, zone, err := decoder.Next()
+	require.NoError(t, err)
+	require.Equal(t, "a", target)
+	require.Equal(t, int32(3), *zone.NumReplicas)
+	for i := 1; i < numDocs; i++ {
+		target, zone, err := decoder.Next()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("t%d", i), target)
+		require.Equal(t, int32(i), zone.GC.TTLSeconds)
+	}
+	_, _, err = decoder.Next()
+	require.Equal(t, io.EOF, err)
+
+	// Errors refer to the documents of the whole stream.
+	decoder = NewZoneConfigBundleDecoder(strings.NewReader(
+		"---\n---\ntarget: a\n---\ntarget: a\n"))
+	_, _, err = decoder.Next()
+	require.NoError(t, err)
+	_, _, err = decoder.Next()
+	require.EqualError(t, err, `document 3: duplicate target "a"`)
+
+	// Documents are limited to the size of a single zone config, but the
+	// bundle isn't.
+	long := "target: a\n# " + strings.Repeat("x", int(maxYAMLDocumentBytes)) + "\n"
+	decoder = NewZoneConfigBundleDecoder(strings.NewReader("target: b\n---\n" + long))
+	_, _, err = decoder.Next()
+	require.NoError(t, err)
+	_, _, err = decoder.Next()
+	require.EqualError(t, err, "document 2 exceeds the maximum size of 64 KiB")
+}
+
+func TestReconcileZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	current := map[string]ZoneConfig{
+		"RANGE default": zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3"),
+		"DATABASE a":    zoneFromYAML(t, *NewZoneConfig(), "constraints: {+region=a: 1, +region=b: 1}"),
+		"DATABASE b":    zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 5"),
+		"DATABASE c":    zoneFromYAML(t, *NewZoneConfig(), "gc: {ttlseconds: 600}"),
+	}
+	desired := map[string]ZoneConfig{
+		"RANGE default": zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3"),
+		// Equivalent, despite the different order of the constraints.
+		"DATABASE a": zoneFromYAML(t, *NewZoneConfig(), "constraints: {+region=b: 1, +region=a: 1}"),
+		"DATABASE b": zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 7"),
+		"DATABASE d": zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 1"),
+	}
+
+	changes := ReconcileZoneConfigs(current, desired)
+	var summary []string
+	for _, c := range changes {
+		summary = append(summary, fmt.Sprintf("%s %s", c.Type, c.Target))
+		switch c.Type {
+		case ZoneConfigChangeCreate:
+			require.Nil(t, c.Current)
+			require.Equal(t, desired[c.Target], *c.Desired)
+		case ZoneConfigChangeUpdate:
+			require.Equal(t, current[c.Target], *c.Current)
+			require.Equal(t, desired[c.Target], *c.Desired)
+		case ZoneConfigChangeDelete:
+			require.Equal(t, current[c.Target], *c.Current)
+			require.Nil(t, c.Desired)
+		}
+	}
+	require.Equal(t, []string{
+		"update DATABASE b",
+		"delete DATABASE c",
+		"create DATABASE d",
+	}, summary)
+
+	require.Empty(t, ReconcileZoneConfigs(current, current))
+	require.Empty(t, ReconcileZoneConfigs(nil, nil))
+}
+
+func TestOrderZoneConfigChanges(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	current := map[string]ZoneConfig{
+		"DATABASE db": zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3"),
+		"TABLE db.t":  zoneFromYAML(t, *NewZoneConfig(), ""),
+	}
+	desired := map[string]ZoneConfig{
+		"PARTITION p OF INDEX db.t@idx": zoneFromYAML(t, *NewZoneConfig(),
+			"{num_replicas: 3, gc: {ttlseconds: 100}}"),
+		"INDEX db.t@idx": zoneFromYAML(t, *NewZoneConfig(), "global_reads: true"),
+		"TABLE db.t":     zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 7"),
+		"DATABASE db":    zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 5"),
+		"RANGE meta":     zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 5"),
+		"RANGE default":  zoneFromYAML(t, *NewZoneConfig(), "gc: {ttlseconds: 600}"),
+	}
+
+	ordered, warnings, err := OrderZoneConfigChanges(ReconcileZoneConfigs(current, desired))
+	require.NoError(t, err)
+	var targets []string
+	for _, c := range ordered {
+		targets = append(targets, c.Target)
+	}
+	require.Equal(t, []string{
+		"RANGE default",
+		"RANGE meta",
+		"DATABASE db",
+		"TABLE db.t",
+		"INDEX db.t@idx",
+		"PARTITION p OF INDEX db.t@idx",
+	}, targets)
+
+	var masked []string
+	for _, w := range warnings {
+		masked = append(masked, w.String())
+	}
+	require.Equal(t, []string{
+		"the change to RANGE default is masked by PARTITION p OF INDEX db.t@idx, which sets gc.ttlseconds",
+		"the change to DATABASE db is masked by TABLE db.t, which sets num_replicas",
+		"the change to DATABASE db is masked by PARTITION p OF INDEX db.t@idx, which sets num_replicas",
+		"the change to TABLE db.t is masked by PARTITION p OF INDEX db.t@idx, which sets num_replicas",
+	}, masked)
+
+	_, _, err = OrderZoneConfigChanges([]ZoneConfigChange{{Target: "INDEX idx"}})
+	require.EqualError(t, err, `invalid zone config target "INDEX idx": missing table`)
+}
+
+func TestScorePlacement(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	store := func(region, zone string) roachpb.StoreDescriptor {
+		return roachpb.StoreDescriptor{Node: roachpb.NodeDescriptor{Locality: roachpb.Locality{
+			Tiers: []roachpb.Tier{{Key: "region", Value: region}, {Key: "zone", Value: zone}},
+		}}}
+	}
+	var zone ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 3
+num_voters: 2
+constraints: {+region=a: 1, +region=b: 1}
+voter_constraints: [+region=a]
+lease_preferences: [[+zone=a1], [+region=a]]
+`), &zone))
+
+	score := ScorePlacement(zone, []roachpb.StoreDescriptor{
+		store("a", "a1"), store("a", "a2"), store("b", "b1"),
+	})
+	require.False(t, score.ViolatesConstraints())
+	require.Empty(t, score.Violations)
+	require.Equal(t, 0, score.LeasePreference)
+	// Two of the three pairs differ by region, and one only by zone.
+	require.InDelta(t, (1+1+0.5)/3.0, score.Diversity, 1e-9)
+
+	// A voter outside of region a, and no replica in region b.
+	score = ScorePlacement(zone, []roachpb.StoreDescriptor{
+		store("a", "a2"), store("d", "d1"), store("a", "a1"),
+	})
+	require.True(t, score.ViolatesConstraints())
+	require.Equal(t, 2, score.ConstraintViolations)
+	require.Equal(t, []string{
+		`constraints "+region=b:1": 1 replicas in violation`,
+		`voter_constraints "+region=a": 1 replicas in violation`,
+	}, score.Violations)
+	require.Equal(t, 1, score.LeasePreference)
+
+	// No replica is in region a, which neither voter is, and the leaseholder
+	// satisfies no lease preference.
+	score = ScorePlacement(zone, []roachpb.StoreDescriptor{
+		store("c", "c1"), store("b", "b1"), store("c", "c2"),
+	})
+	require.Equal(t, 3, score.ConstraintViolations)
+	require.Equal(t, []string{
+		`constraints "+region=a:1": 1 replicas in violation`,
+		`voter_constraints "+region=a": 2 replicas in violation`,
+	}, score.Violations)
+	require.Equal(t, -1, score.LeasePreference)
+
+	// A single replica has no diversity, and no lease preferences are always
+	// satisfied.
+	score = ScorePlacement(ZoneConfig{}, []roachpb.StoreDescriptor{store("a", "a1")})
+	require.Equal(t, PlacementScore{}, score)
+}
+
+func TestSimulate(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	topology := func(regions ...string) []roachpb.Locality {
+		var res []roachpb.Locality
+		for _, region := range regions {
+			res = append(res, roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}})
+		}
+		return res
+	}
+	testCases := []struct {
+		name        string
+		zone        string
+		topology    []roachpb.Locality
+		expected    Placement
+		expectedErr string
+	}{
+		{
+			name:     "unconstrained",
+			zone:     "num_replicas: 3",
+			topology: topology("a", "b", "c", "d"),
+			expected: Placement{Voters: []int{0, 1, 2}, Leaseholder: 0, LeasePreference: -1},
+		},
+		{
+			name: "per-replica constraints and lease preferences",
+			zone: `
+num_replicas: 3
+constraints: {+region=b: 2}
+lease_preferences: [[+region=c], [+region=b]]`,
+			topology: topology("a", "a", "b", "b"),
+			expected: Placement{Voters: []int{0, 2, 3}, Leaseholder: 2, LeasePreference: 1},
+		},
+		{
+			name: "non-voters",
+			zone: `
+num_replicas: 5
+num_voters: 3
+constraints: {+region=c: 1}
+voter_constraints: {+region=a: 2}`,
+			topology: topology("a", "a", "b", "b", "c"),
+			expected: Placement{
+				Voters: []int{0, 1, 4}, NonVoters: []int{2, 3}, Leaseholder: 0, LeasePreference: -1,
+			},
+		},
+		{
+			name: "wildcards",
+			zone: `
+num_replicas: 3
+constraints: [-region=eu-*]
+lease_preferences: [[+region=us-*]]`,
+			topology: topology("eu-west1", "asia-east1", "eu-west2", "us-east1", "us-west1"),
+			expected: Placement{Voters: []int{1, 3, 4}, Leaseholder: 3, LeasePreference: 0},
+		},
+		{
+			name:        "too few nodes",
+			zone:        "num_replicas: 5",
+			topology:    topology("a", "b", "c"),
+			expectedErr: "num_replicas requires 5 replicas but only 3 can be placed",
+		},
+		{
+			name:        "too few nodes for all replicas",
+			zone:        "num_replicas: 3\nconstraints: [+region=a]",
+			topology:    topology("a", "a", "b"),
+			expectedErr: `constraints "+region=a" requires 3 replicas but only 2 can be placed`,
+		},
+		{
+			name:        "too few nodes for per-replica constraints",
+			zone:        "num_replicas: 3\nconstraints: {+region=b: 2}",
+			topology:    topology("a", "a", "b"),
+			expectedErr: `constraints "+region=b:2" requires 2 replicas but only 1 can be placed`,
+		},
+		{
+			name: "too few nodes for voter constraints",
+			zone: `
+num_replicas: 3
+num_voters: 3
+voter_constraints: {+region=a: 2}`,
+			topology:    topology("a", "b", "c"),
+			expectedErr: `voter_constraints "+region=a:2" requires 2 replicas but only 1 can be placed`,
+		},
+		{
+			name: "too few non-voters",
+			zone: `
+num_replicas: 4
+num_voters: 3
+constraints: {+region=b: 1, +region=c: 1}
+voter_constraints: [+region=a]`,
+			topology:    topology("a", "a", "a", "b", "c"),
+			expectedErr: "constraints requires 5 replicas but only 4 can be placed",
+		},
+		{
+			name:        "attribute constraints",
+			zone:        "num_replicas: 1\nconstraints: [+ssd]",
+			topology:    topology("a"),
+			expectedErr: `constraints "+ssd" requires 1 replicas but only 0 can be placed`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := DefaultZoneConfig()
+			require.NoError(t, yaml.Unmarshal([]byte(tc.zone), &zone))
+			placement, err := Simulate(zone, tc.topology)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				var placementErr *PlacementError
+				require.ErrorAs(t, err, &placementErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, placement)
+		})
+	}
+
+	_, err := Simulate(ZoneConfig{}, topology("a"))
+	require.EqualError(t, err, "num_replicas must be set")
+
+	// Invalid zone configs aren't simulated.
+	_, err = Simulate(zoneFromYAML(t, DefaultZoneConfig(), `
+num_replicas: 3
+constraints: {+region=a: 2, +region=b: 2}`), topology("a", "a", "b", "b"))
+	require.EqualError(t, err, "the number of replicas specified in constraints (4) cannot be "+
+		"greater than the number of replicas configured for the zone (3)")
+}
+
+func TestEstimateGCImpact(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const day = 24 * 60 * 60
+	// stats returns the stats of a range with 1000 live bytes and 9000
+	// non-live bytes of the given average age, in days.
+	stats := func(avgAge int64) enginepb.MVCCStats {
+		return enginepb.MVCCStats{
+			LiveBytes:  1000,
+			KeyBytes:   2000,
+			ValBytes:   8000,
+			GCBytesAge: 9000 * avgAge * day,
+		}
+	}
+	testCases := []struct {
+		name           string
+		oldTTL, newTTL int32
+		stats          enginepb.MVCCStats
+		expected       GCImpact
+	}{
+		{
+			name:   "drop to a day",
+			oldTTL: 90 * day, newTTL: day, stats: stats(45),
+			expected: GCImpact{LostHistory: 89 * 24 * time.Hour, EligibleBytes: 8900},
+		},
+		{
+			name:   "drop to a third",
+			oldTTL: 90 * day, newTTL: 30 * day, stats: stats(45),
+			expected: GCImpact{LostHistory: 60 * 24 * time.Hour, EligibleBytes: 6000},
+		},
+		{
+			// A quarter of the non-live bytes were already eligible.
+			name:   "backlog of eligible bytes",
+			oldTTL: 90 * day, newTTL: 30 * day, stats: stats(60),
+			expected: GCImpact{LostHistory: 60 * 24 * time.Hour, EligibleBytes: 4500},
+		},
+		{
+			name:   "no non-live bytes",
+			oldTTL: 90 * day, newTTL: day, stats: enginepb.MVCCStats{LiveBytes: 10, KeyBytes: 5, ValBytes: 5},
+			expected: GCImpact{LostHistory: 89 * 24 * time.Hour},
+		},
+		{
+			name:   "increase",
+			oldTTL: day, newTTL: 90 * day, stats: stats(45),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, EstimateGCImpact(tc.oldTTL, tc.newTTL, tc.stats))
+		})
+	}
+}
+
+func TestZoneConfigView(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	require.True(t, ZoneConfigView{}.IsNil())
+	require.Nil(t, ZoneConfigView{}.Mutate())
+
+	zone := DefaultZoneConfig()
+	zone.NumVoters = proto.Int32(3)
+	zone.Constraints = []ConstraintsConjunction{
+		{Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "a"}}},
+	}
+	zone.SubzoneSpans = []SubzoneSpan{{Key: roachpb.Key("a")}}
+	before := protoutil.Clone(&zone).(*ZoneConfig)
+
+	view := MakeZoneConfigView(&zone)
+	require.False(t, view.IsNil())
+	require.Equal(t, int32(3), view.NumReplicas())
+	require.Equal(t, int32(3), view.NumVoters())
+	require.Equal(t, zone.GC.TTLSeconds, view.GCTTLSeconds())
+	require.Equal(t, zone.AsSpanConfig(), view.AsSpanConfig())
+	require.True(t, view.EquivalentTo(MakeZoneConfigView(before)))
+	require.Same(t, &zone, view.Unwrap())
+
+	// Modifying the copy returned by Mutate, including its slices, doesn't
+	// modify the viewed zone config.
+	mutated := view.Mutate()
+	mutated.NumReplicas = proto.Int32(5)
+	mutated.Constraints[0].Constraints[0].Value = "b"
+	mutated.SubzoneSpans[0].Key[0] = 'b'
+	mutated.GC.TTLSeconds++
+	require.Equal(t, before, &zone)
+	require.False(t, view.EquivalentTo(MakeZoneConfigView(mutated)))
+}
+
+func TestZoneConfigYAMLAliases(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	require.Equal(t,
+		zoneFromYAML(t, *NewZoneConfig(),
+			"constraints: [+region=east]\nlease_preferences: [[+region=east]]"),
+		zoneFromYAML(t, *NewZoneConfig(),
+			"constraints: &east [+region=east]\nlease_preferences: [*east]"))
+	require.Equal(t,
+		zoneFromYAML(t, *NewZoneConfig(), `
+num_replicas: 5
+constraints: {+region=east: 2, +region=west: 2}
+lease_preferences: [[+region=east], [+region=west]]`),
+		zoneFromYAML(t, *NewZoneConfig(), `
+num_replicas: 5
+constraints: {&east +region=east: 2, &west +region=west: 2}
+lease_preferences: [[*east], [*west]]`))
+	require.Equal(t,
+		zoneFromYAML(t, *NewZoneConfig(), "num_replicas: 3\ngc: {ttlseconds: 600}"),
+		zoneFromYAML(t, *NewZoneConfig(), "<<: &base {gc: {ttlseconds: 600}}\nnum_replicas: 3"))
+
+	// Aliases can be reused across the documents of a bundle, as long as
+	// they're defined in each document.
+	zones, err := ParseZoneConfigBundle(strings.NewReader(`
+target: a
+constraints: &east [+region=east]
+lease_preferences: [*east]
+---
+target: b
+constraints: &east [+region=east]
+lease_preferences: [*east]`))
+	require.NoError(t, err)
+	require.Equal(t, zones["a"], zones["b"])
+
+	// A "billion laughs" document, which would expand to 10^9 constraints.
+	var bomb strings.Builder
+	bomb.WriteString(`l0: &l0 ["+a", "+a", "+a", "+a", "+a", "+a", "+a", "+a", "+a", "+a"]` + "\n")
+	for i := 1; i < 9; i++ {
+		fmt.Fprintf(&bomb, "l%d: &l%d [", i, i)
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				bomb.WriteString(", ")
+			}
+			fmt.Fprintf(&bomb, "*l%d", i-1)
+		}
+		bomb.WriteString("]\n")
+	}
+	bomb.WriteString("constraints: *l8\n")
+	const expectedErr = "YAML document expands to more than 100000 nodes"
+	require.EqualError(t, CheckYAMLAliases([]byte(bomb.String())), expectedErr)
+	_, err = ParseZoneConfigBundle(strings.NewReader("target: a\n" + bomb.String()))
+	require.EqualError(t, err, expectedErr)
+
+	// Aliases to an enclosing anchor are rejected.
+	require.EqualError(t, CheckYAMLAliases([]byte("constraints: &c [+a, *c]")),
+		`YAML anchor "c" contains an alias to itself`)
+
+	// Documents which can't be parsed are rejected.
+	require.Error(t, CheckYAMLAliases([]byte("constraints: [")))
+	require.NoError(t, CheckYAMLAliases(nil))
+}
+
+func TestZoneConfigYAMLLimits(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// constraints returns n distinct constraints, joined with sep.
+	constraints := func(n int, sep string) string {
+		strs := make([]string, n)
+		for i := range strs {
+			strs[i] = fmt.Sprintf("+c%d", i)
+		}
+		return strings.Join(strs, sep)
+	}
+	// leasePreferences returns n distinct lease preferences.
+	leasePreferences := func(n int) string {
+		strs := make([]string, n)
+		for i := range strs {
+			strs[i] = fmt.Sprintf("[+c%d]", i)
+		}
+		return strings.Join(strs, ", ")
+	}
+	// perReplica returns n distinct per-replica constraints.
+	perReplica := func(n int) string {
+		strs := make([]string, n)
+		for i := range strs {
+			strs[i] = fmt.Sprintf("+c%d: 1", i)
+		}
+		return strings.Join(strs, ", ")
+	}
+
+	testCases := []struct {
+		input       string
+		expectedErr string
+	}{
+		{fmt.Sprintf("constraints: [%s]", constraints(32, ", ")), ""},
+		{fmt.Sprintf("constraints: [%s]", constraints(33, ", ")),
+			"line 1, column 14: at most 32 constraints are allowed per conjunction, found 33"},
+		{fmt.Sprintf("constraints: {'%s': 1}", constraints(33, ",")),
+			"line 1, column 15: at most 32 constraints are allowed per conjunction, found 33"},
+		{fmt.Sprintf("num_replicas: 128\nconstraints: {%s}", perReplica(128)), ""},
+		{fmt.Sprintf("num_replicas: 129\nconstraints: {%s}", perReplica(129)),
+			"line 2, column 14: at most 128 per-replica constraints are allowed, found 129"},
+		{fmt.Sprintf("lease_preferences: [[%s]]", constraints(33, ", ")),
+			"line 1, column 21: at most 32 constraints are allowed per conjunction, found 33"},
+		{fmt.Sprintf("lease_preferences: [%s]", leasePreferences(32)), ""},
+		{fmt.Sprintf("lease_preferences: [%s]", leasePreferences(33)),
+			"line 1, column 20: at most 32 lease preferences are allowed, found 33"},
+		{fmt.Sprintf("constraints: [+region=%s]", strings.Repeat("a", 64<<10)),
+			"zone config YAML exceeds the maximum size of 64 KiB"},
+		{fmt.Sprintf("constraints: &c [+region=%s]\nvoter_constraints: *c", strings.Repeat("a", 32<<10)),
+			"zone config YAML exceeds the maximum size of 64 KiB"},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			var zone ZoneConfig
+			err := yaml.Unmarshal([]byte(tc.input), &zone)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestGlobalReadsYAML11Booleans(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		input       string
+		expected    bool
+		expectedErr string
+	}{
+		{input: "true", expected: true},
+		{input: "false", expected: false},
+		{input: "yes", expected: true},
+		{input: "Yes", expected: true},
+		{input: "on", expected: true},
+		{input: "ON", expected: true},
+		{input: "y", expected: true},
+		{input: "no", expected: false},
+		{input: "Off", expected: false},
+		{input: "N", expected: false},
+		{input: "'yes'", expectedErr: "cannot unmarshal !!str `yes` into bool"},
+		{input: "maybe", expectedErr: "cannot unmarshal !!str `maybe` into bool"},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			var zone ZoneConfig
+			err := yaml.Unmarshal([]byte("global_reads: "+tc.input), &zone)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, zone.GlobalReads)
+			require.Equal(t, tc.expected, *zone.GlobalReads)
+
+			// The booleans are always marshaled as true or false.
+			out, err := yaml.Marshal(zone)
+			require.NoError(t, err)
+			require.Contains(t, string(out), fmt.Sprintf("global_reads: %t\n", tc.expected))
+		})
+	}
+}
+
+func TestZoneConfigYAMLErrorPositions(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		input       string
+		expectedErr string
+	}{
+		{"num_replicas: 3\nnum_replica: 3",
+			"line 2, column 1: field num_replica not found in type zonepb.marshalableZoneConfig"},
+		{"gc:\n  ttlsecs: 60", "line 2, column 3: field ttlsecs not found"},
+		{"gc:\n  ttl: 25x", `line 2, column 3: invalid gc.ttl "25x"`},
+		{"range_max_bytes: 512 mebibytes", `line 1, column 18: invalid byte size "512 mebibytes"`},
+		{"num_replicas: 3\nconstraints: {+region=a: 1, region=a=b: 1}",
+			"line 2, column 29: constraint needs to be in the form"},
+		{"lease_preferences: [[+region=a], [region=a=b]]",
+			"line 1, column 34: constraint needs to be in the form"},
+		{"constraints: {+region=a: [1]}", "line 1, column 14: invalid constraints format"},
+		{"survival_goal: planet", `line 1, column 16: invalid survival_goal "planet"`},
+		{"num_replicas: 3\nversion: 2", "line 2, column 10: unsupported zone config version 2"},
+		{"num_replicas: abc", "line 1: cannot unmarshal !!str `abc` into int32"},
+	} {
+		t.Run("", func(t *testing.T) {
+			var zone ZoneConfig
+			err := yaml.Unmarshal([]byte(tc.input), &zone)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestUnmarshalYAMLFastPath(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// base has every field which the documents may leave unchanged.
+	base := *NewZoneConfig()
+	base.GC = &GCPolicy{TTLSeconds: 600}
+	base.GlobalReads = proto.Bool(true)
+	base.NumVoters = proto.Int32(3)
+	base.Constraints = []ConstraintsConjunction{
+		{NumReplicas: 1, Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "us-west1"}}},
+	}
+	base.InheritedConstraints = false
+	base.LeasePreferences = []LeasePreference{
+		{Constraints: []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "us-west1"}}},
+	}
+	base.InheritedLeasePreferences = false
+
+	testCases := []struct {
+		input string
+		fast  bool
+	}{
+		{"num_replicas: 5", true},
+		{"num_replicas: 5\nnum_voters: 3\n", true},
+		{"range_min_bytes: 1048576\nrange_max_bytes: 67108864", true},
+		{"range_min_bytes: null\nglobal_reads: false", true},
+		{"gc: {ttlseconds: 90000}", true},
+		{"gc:\n  ttlseconds: 90000\nnum_replicas: 3", true},
+		{"constraints: [+region=us-east1, -ssd]", true},
+		{"constraints: []\nvoter_constraints: [+region=us-east1]", true},
+		{"lease_preferences: [[+region=us-east1, +ssd], [+region=us-*]]", true},
+		{"lease_preferences: []", true},
+		{"constraints: [+region=us-east1, -ssd]\n\nlease_preferences: [[+region=us-east1]]\n", true},
+		{"version: 1\nnum_replicas: 3", true},
+
+		// Documents which are left to the generic decoder.
+		{"", false},
+		{"{}", false},
+		{"num_replicas: 5 # five replicas", false},
+		{"num_replicas: 010", false},
+		{"num_replicas: 1_000", false},
+		{"num_replicas: 3000000000", false},
+		{"num_replicas: 3\nnum_replicas: 5", false},
+		{"range_min_bytes: 1MiB", false},
+		{"global_reads: yes", false},
+		{"gc: {ttl: 25h}", false},
+		{"gc:\n  ttlseconds: 600\n  ttl: 10m", false},
+		{"gc:", false},
+		{"constraints: ['+region=us-east1']", false},
+		{"constraints: [+region=us-east1:2]", false},
+		{"constraints: {+region=us-east1: 2}", false},
+		{"constraints: [on]", false},
+		{"constraints: [+region=us-east1, ]", false},
+		{"constraints: [+region=us-east1,\n  +ssd]", false},
+		{"constraints: &east [+region=us-east1]\nlease_preferences: [*east]", false},
+		{"constraints: [+a=b=c]", false},
+		{"lease_preferences: [[+region=us-east1], ]", false},
+		{"lease_preferences: [+region=us-east1]", false},
+		{"experimental_lease_preferences: [[+region=us-east1]]", false},
+		{"version: 0\nnum_replicas: 3", false},
+		{"version: 01\nnum_replicas: 3", false},
+		{"num_replicas: 3\nsubzones: []", false},
+		{" num_replicas: 3", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			for _, start := range []ZoneConfig{*NewZoneConfig(), base} {
+				expected := start
+				expectedErr := yaml.Unmarshal([]byte(tc.input), &expected)
+
+				actual := start
+				require.Equal(t, tc.fast, unmarshalYAMLFast([]byte(tc.input), &actual))
+				if !tc.fast {
+					require.Equal(t, start, actual)
+				}
+				actual = start
+				err := UnmarshalYAML([]byte(tc.input), &actual)
+				if expectedErr != nil {
+					require.EqualError(t, err, expectedErr.Error())
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	// The fast path agrees with the generic decoder on random zone configs.
+	rng := rand.New(rand.NewSource(timeutil.Now().UnixNano()))
+	corpus, err := GenerateZoneConfigYAMLCorpus(rng, 500)
+	require.NoError(t, err)
+	var fast int
+	for _, data := range corpus {
+		expected := *NewZoneConfig()
+		expectedErr := yaml.Unmarshal(data, &expected)
+		actual := *NewZoneConfig()
+		if !unmarshalYAMLFast(data, &actual) {
+			continue
+		}
+		fast++
+		require.NoError(t, expectedErr, "%s", data)
+		require.Equal(t, expected, actual, "%s", data)
+	}
+	require.NotZero(t, fast)
+}
+
+func BenchmarkUnmarshalYAML(b *testing.B) {
+	docs := []struct {
+		name string
+		yaml string
+	}{
+		{"flat", "range_min_bytes: 134217728\nrange_max_bytes: 536870912\ngc:\n  ttlseconds: 90000\n" +
+			"num_replicas: 3\nconstraints: []\nlease_preferences: []\n"},
+		{"constraints", "num_replicas: 5\nnum_voters: 3\nconstraints: [+region=us-east1, +ssd]\n" +
+			"voter_constraints: [+region=us-east1]\nlease_preferences: [[+region=us-east1, +zone=a], [+region=us-east1]]\n"},
+		// Per-replica constraints aren't supported by the fast path.
+		{"per-replica", "num_replicas: 5\nconstraints: {+region=us-east1: 2, +region=us-west1: 2}\n"},
+	}
+	for _, doc := range docs {
+		data := []byte(doc.yaml)
+		b.Run(doc.name, func(b *testing.B) {
+			b.Run("generic", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					zone := NewZoneConfig()
+					if err := yaml.Unmarshal(data, zone); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("fast", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					zone := NewZoneConfig()
+					if err := UnmarshalYAML(data, zone); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestLeasePreferenceString(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{input: "[+region=us-east1,+ssd]", expected: "[+region=us-east1,+ssd]"},
+		{input: " [ +region=us-east1, -zone=us-east1-a ] ", expected: "[+region=us-east1,-zone=us-east1-a]"},
+		{input: "[+ssd]", expected: "[+ssd]"},
+		{input: "[]", expected: "[]"},
+		{input: "+region=us-east1", expectedErr: "lease preference needs to be in the form"},
+		{input: "[+region=us-east1", expectedErr: "lease preference needs to be in the form"},
+		{input: "[+region=us=east1]", expectedErr: "constraint needs to be in the form"},
+		{input: "[+a,,+b]", expectedErr: "the empty string is not a valid constraint"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			pref, err := ParseLeasePreference(tc.input)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, pref.String())
+
+			// The short form round-trips, and agrees with the YAML form.
+			roundTripped, err := ParseLeasePreference(pref.String())
+			require.NoError(t, err)
+			require.Equal(t, pref, roundTripped)
+			out, err := MarshalYAML(pref)
+			require.NoError(t, err)
+			var fromYAML LeasePreference
+			require.NoError(t, yaml.Unmarshal(out, &fromYAML))
+			require.Equal(t, pref.String(), fromYAML.String())
+		})
+	}
+}
+
+func TestZoneConfigToSQL(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 5
+num_voters: 3
+gc: {ttlseconds: 600}
+global_reads: true
+constraints: {+region=us-east1: 2, "+region=us-west1": 1}
+voter_constraints: [+region=us-east1]
+lease_preferences: [[+region=us-east1], [-region=eu-west1]]
+`), zone))
+	stmt := zone.ToSQL("TABLE db.public.t")
+	require.Equal(t, "ALTER TABLE db.public.t CONFIGURE ZONE USING\n"+
+		"\tgc.ttlseconds = 600,\n"+
+		"\tglobal_reads = true,\n"+
+		"\tnum_replicas = 5,\n"+
+		"\tnum_voters = 3,\n"+
+		"\tconstraints = '{+region=us-east1: 2, +region=us-west1: 1}',\n"+
+		"\tvoter_constraints = '[+region=us-east1]',\n"+
+		"\tlease_preferences = '[[+region=us-east1], [-region=eu-west1]]'", stmt)
+
+	target, parsed, err := ParseZoneConfigSQL(stmt + ";")
+	require.NoError(t, err)
+	require.Equal(t, "TABLE db.public.t", target)
+	require.True(t, zone.EquivalentTo(&parsed), "%s", parsed.Format(FormatOptions{}))
+
+	// Quotes within constraints are escaped.
+	quoted := NewZoneConfig()
+	quoted.InheritedConstraints = false
+	quoted.Constraints = []ConstraintsConjunction{{Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: "rack", Value: "it's"},
+	}}}
+	stmt = quoted.ToSQL("RANGE default")
+	require.Contains(t, stmt, `constraints = e'`)
+	_, parsed, err = ParseZoneConfigSQL(stmt)
+	require.NoError(t, err)
+	require.Equal(t, quoted.Constraints, parsed.Constraints)
+
+	_, parsed, err = ParseZoneConfigSQL(
+		`alter database db configure zone using constraints = '[''+region=a'']', NUM_REPLICAS = 3`)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), *parsed.NumReplicas)
+	require.Equal(t, []ConstraintsConjunction{{Constraints: []Constraint{
+		{Type: Constraint_REQUIRED, Key: "region", Value: "a"},
+	}}}, parsed.Constraints)
+
+	for _, tc := range []struct {
+		stmt, err string
+	}{
+		{"SHOW ZONE CONFIGURATION FOR RANGE default", `expected "ALTER" at the start`},
+		{"ALTER RANGE default CONFIGURE ZONE DISCARD", `expected "CONFIGURE ZONE USING"`},
+		{"ALTER RANGE default CONFIGURE ZONE USING", "no fields are set"},
+		{"ALTER RANGE default CONFIGURE ZONE USING num_replicas = COPY FROM PARENT", "unsupported value"},
+		{"ALTER RANGE default CONFIGURE ZONE USING num_replicas = 3, num_replicas = 5", "duplicate assignment"},
+		{"ALTER RANGE default CONFIGURE ZONE USING bogus = 3", "unsupported zone config field"},
+		{"ALTER RANGE default CONFIGURE ZONE USING constraints = '[+a", "unterminated string"},
+	} {
+		_, _, err := ParseZoneConfigSQL(tc.stmt)
+		require.Error(t, err, tc.stmt)
+		require.Contains(t, err.Error(), tc.err, tc.stmt)
+	}
+}
+
+func TestLeaseAntiPreferences(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var zone ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+num_replicas: 3
+constraints: [+ssd]
+lease_preferences: [[+region=us-east1, -zone=a], [-region=eu-west1]]
+`), &zone))
+	require.NoError(t, zone.Validate())
+	prefer, avoid := zone.LeasePreferences[0], zone.LeasePreferences[1]
+	require.False(t, prefer.IsAntiPreference())
+	require.True(t, avoid.IsAntiPreference())
+	require.False(t, LeasePreference{}.IsAntiPreference())
+	require.Equal(t, []Constraint{{Type: Constraint_REQUIRED, Key: "region", Value: "us-east1"}}, prefer.Required())
+	require.Equal(t, []Constraint{{Type: Constraint_PROHIBITED, Key: "zone", Value: "a"}}, prefer.Prohibited())
+	require.Empty(t, avoid.Required())
+	require.Equal(t, avoid.Constraints, avoid.Prohibited())
+
+	store := func(region, zone string) roachpb.StoreDescriptor {
+		return roachpb.StoreDescriptor{Node: roachpb.NodeDescriptor{Locality: roachpb.Locality{
+			Tiers: []roachpb.Tier{{Key: "region", Value: region}, {Key: "zone", Value: zone}},
+		}}}
+	}
+	for _, tc := range []struct {
+		store roachpb.StoreDescriptor
+		index int
+		ok    bool
+	}{
+		{store("us-east1", "b"), 0, true},
+		{store("us-east1", "a"), 1, true},
+		{store("us-west1", "a"), 1, true},
+		{store("eu-west1", "a"), 0, false},
+	} {
+		index, ok := zone.LeasePreferenceIndex(tc.store)
+		require.Equal(t, tc.ok, ok, "%s", tc.store.Node.Locality)
+		require.Equal(t, tc.index, index, "%s", tc.store.Node.Locality)
+	}
+
+	// Lease preferences which no store can satisfy are rejected.
+	for _, pref := range []string{"[+region=a,-region=a]", "[+region=a,+region=b]", "[-region=*,+region=a]"} {
+		zone := zone
+		p, err := ParseLeasePreference(pref)
+		require.NoError(t, err)
+		zone.LeasePreferences = []LeasePreference{p}
+		err = zone.Validate()
+		require.Error(t, err, pref)
+		require.Contains(t, err.Error(), "can't be satisfied")
+	}
+}
+
+func TestRandomZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	rng := rand.New(rand.NewSource(timeutil.Now().UnixNano()))
+	var withSubzones, placeholders int
+	for i := 0; i < 1000; i++ {
+		zone := RandomZoneConfig(rng)
+		require.NoError(t, zone.Validate(), "%+v", zone)
+		require.NoError(t, zone.ValidateTandemFields(), "%+v", zone)
+		if len(zone.Subzones) > 0 {
+			withSubzones++
+		}
+		if zone.IsSubzonePlaceholder() {
+			placeholders++
+		}
+
+		// The zone config round-trips through its protobuf encoding.
+		buf, err := protoutil.Marshal(&zone)
+		require.NoError(t, err)
+		var decoded ZoneConfig
+		require.NoError(t, protoutil.Unmarshal(buf, &decoded))
+		require.True(t, zone.Equal(&decoded), "%+v", zone)
+		require.True(t, zone.EquivalentTo(&decoded))
+		require.Equal(t, zone.Hash(), decoded.Hash())
+	}
+	require.NotZero(t, withSubzones)
+	require.NotZero(t, placeholders)
+}
+
+func TestEncodeZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	rng := rand.New(rand.NewSource(timeutil.Now().UnixNano()))
+	// Batches of more than minZoneConfigsPerWorker zone configs are split
+	// among several workers.
+	for _, n := range []int{0, 1, 10, 1000} {
+		zones := make([]ZoneConfig, n)
+		for i := range zones {
+			zones[i] = RandomZoneConfig(rng)
+		}
+		encoded, err := EncodeZoneConfigs(zones)
+		require.NoError(t, err)
+		require.Len(t, encoded, n)
+		for i := range zones {
+			expected, err := protoutil.Marshal(&zones[i])
+			require.NoError(t, err)
+			require.Equal(t, expected, encoded[i])
+			require.Equal(t, len(encoded[i]), cap(encoded[i]))
+		}
+
+		decoded, err := DecodeZoneConfigs(encoded)
+		require.NoError(t, err)
+		require.Len(t, decoded, n)
+		for i := range zones {
+			require.True(t, zones[i].Equal(&decoded[i]), "%+v", zones[i])
+		}
+	}
+
+	encoded, err := EncodeZoneConfigs([]ZoneConfig{DefaultZoneConfig(), DefaultZoneConfig()})
+	require.NoError(t, err)
+	encoded[1] = []byte("not a zone config")
+	_, err = DecodeZoneConfigs(encoded)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decoding zone config 1")
+}
+
+func BenchmarkEncodeZoneConfigs(b *testing.B) {
+	rng := rand.New(rand.NewSource(0))
+	zones := make([]ZoneConfig, 5000)
+	for i := range zones {
+		zones[i] = RandomZoneConfig(rng)
+	}
+	b.Run("protoutil", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoded := make([][]byte, len(zones))
+			for j := range zones {
+				var err error
+				if encoded[j], err = protoutil.Marshal(&zones[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := EncodeZoneConfigs(zones); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDecodeZoneConfigs(b *testing.B) {
+	rng := rand.New(rand.NewSource(0))
+	zones := make([]ZoneConfig, 5000)
+	for i := range zones {
+		zones[i] = RandomZoneConfig(rng)
+	}
+	encoded, err := EncodeZoneConfigs(zones)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("protoutil", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decoded := make([]*ZoneConfig, len(encoded))
+			for j := range encoded {
+				decoded[j] = &ZoneConfig{}
+				if err := protoutil.Unmarshal(encoded[j], decoded[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DecodeZoneConfigs(encoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGenerateZoneConfigYAMLCorpus(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	rng := rand.New(rand.NewSource(timeutil.Now().UnixNano()))
+	const n = 100
+	corpus, err := GenerateZoneConfigYAMLCorpus(rng, n)
+	require.NoError(t, err)
+	require.Len(t, corpus, len(zoneConfigYAMLSeeds)+n)
+	for _, data := range corpus {
+		// Every entry of the corpus can be unmarshaled, and round-trips through
+		// YAML.
+		require.NoError(t, CheckYAMLAliases(data))
+		zone := NewZoneConfig()
+		require.NoError(t, yaml.Unmarshal(data, zone), "%s", data)
+		out, err := MarshalYAML(zone)
+		require.NoError(t, err)
+		reparsed := NewZoneConfig()
+		require.NoError(t, yaml.Unmarshal(out, reparsed), "%s", out)
+		out2, err := MarshalYAML(reparsed)
+		require.NoError(t, err)
+		require.Equal(t, string(out), string(out2))
+	}
+}
+
+func TestZoneConfigAuditInfo(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := DefaultZoneConfig()
+	require.True(t, zone.LastModified().IsEmpty())
+	require.Empty(t, zone.ModifiedBy())
+	require.Empty(t, zone.StatementFingerprint())
+	require.Equal(t, ZoneConfigAuditInfo{}, MakeZoneConfigView(&zone).AuditInfo())
+
+	audited := zone
+	audited.AuditInfo = &ZoneConfigAuditInfo{
+		LastModified:         hlc.Timestamp{WallTime: 1700000000123456789, Logical: 2},
+		ModifiedBy:           "root",
+		StatementFingerprint: "ALTER TABLE t CONFIGURE ZONE USING gc.ttlseconds = _",
+	}
+	require.Equal(t, hlc.Timestamp{WallTime: 1700000000123456789, Logical: 2}, audited.LastModified())
+	require.Equal(t, "root", audited.ModifiedBy())
+	require.Equal(t, "ALTER TABLE t CONFIGURE ZONE USING gc.ttlseconds = _", audited.StatementFingerprint())
+	require.Equal(t, *audited.AuditInfo, MakeZoneConfigView(&audited).AuditInfo())
+
+	// The audit info doesn't affect the meaning of the zone config.
+	require.False(t, zone.Equal(&audited))
+	require.True(t, zone.EquivalentTo(&audited))
+	require.Equal(t, zone.Hash(), audited.Hash())
+
+	// The audit info is omitted from the YAML encoding of the zone config, and
+	// isn't accepted in it.
+	out, err := MarshalYAML(audited)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "audit_info")
+	var fromYAML ZoneConfig
+	require.NoError(t, yaml.Unmarshal(out, &fromYAML))
+	require.Nil(t, fromYAML.AuditInfo)
+	require.True(t, zone.Equal(&fromYAML))
+
+	// Unless it is requested.
+	out, err = MarshalYAML(ZoneConfigWithAuditInfo{audited})
+	require.NoError(t, err)
+	require.Contains(t, string(out), "audit_info:\n  last_modified: 1700000000.123456789,2\n  modified_by: root\n")
+	err = yaml.Unmarshal(out, &fromYAML)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "field audit_info not found")
+
+	var withAuditInfo ZoneConfigWithAuditInfo
+	require.NoError(t, yaml.Unmarshal(out, &withAuditInfo))
+	require.True(t, audited.Equal(&withAuditInfo.ZoneConfig))
+
+	// Zone configs without audit info are encoded as usual.
+	out, err = MarshalYAML(ZoneConfigWithAuditInfo{zone})
+	require.NoError(t, err)
+	expected, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+
+	withAuditInfo = ZoneConfigWithAuditInfo{}
+	err = yaml.Unmarshal([]byte("num_replicas: 3\naudit_info: {last_modified: yesterday}"), &withAuditInfo)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid audit_info.last_modified")
+}
+
+func TestZoneConfigApplicationID(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := DefaultZoneConfig()
+	require.False(t, zone.AppliedBy(""))
+	require.False(t, zone.AppliedBy("abc123"))
+	require.False(t, (*ZoneConfig)(nil).AppliedBy("abc123"))
+
+	applied := zone
+	applied.ApplicationID = "abc123"
+	require.Equal(t, "abc123", MakeZoneConfigView(&applied).ApplicationID())
+	require.True(t, applied.AppliedBy("abc123"))
+	require.False(t, applied.AppliedBy("def456"))
+	require.False(t, applied.AppliedBy(""))
+
+	// The application ID doesn't affect the meaning of the zone config, isn't
+	// encoded in YAML, and is kept by subzone placeholders.
+	require.True(t, zone.EquivalentTo(&applied))
+	require.Equal(t, zone.Hash(), applied.Hash())
+	out, err := MarshalYAML(applied)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "abc123")
+	placeholder := applied
+	placeholder.DeleteTableConfig()
+	require.Equal(t, "abc123", placeholder.ApplicationID)
+
+	// Zone config bundles record the application ID of their documents, and
+	// targets already written by it aren't updated again.
+	bundle, err := ParseZoneConfigBundle(strings.NewReader(`
+target: DATABASE a
+application_id: abc123
+num_replicas: 5
+---
+target: DATABASE b
+num_replicas: 5
+`))
+	require.NoError(t, err)
+	require.Equal(t, "abc123", bundle["DATABASE a"].ApplicationID)
+	require.Equal(t, "", bundle["DATABASE b"].ApplicationID)
+	current := map[string]ZoneConfig{"DATABASE a": applied, "DATABASE b": applied}
+	changes := ReconcileZoneConfigs(current, bundle)
+	require.Len(t, changes, 1)
+	require.Equal(t, "DATABASE b", changes[0].Target)
+
+	_, err = ParseZoneConfigBundle(strings.NewReader("target: DATABASE a\napplication_id: [a]"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "application_id must be a string")
+}
+
+func TestMarshalYAMLWithInheritedFields(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	zone := ZoneConfig{
+		RangeMinBytes:               proto.Int64(1),
+		RangeMaxBytes:               proto.Int64(1),
+		GC:                          &GCPolicy{TTLSeconds: 1},
+		GlobalReads:                 proto.Bool(true),
+		NullVoterConstraintsIsEmpty: true,
+		NumReplicas:                 proto.Int32(2),
+		NumVoters:                   proto.Int32(1),
+	}
+	out, err := MarshalYAMLWithInheritedFields(zone, map[string]string{
+		"gc.ttlseconds": "DATABASE db",
+		"num_replicas":  "RANGE default",
+		"constraints":   "TABLE db.public.t",
+	})
+	require.NoError(t, err)
+	require.Equal(t, `range_min_bytes: 1
+range_max_bytes: 1
+gc:
+  ttlseconds: 1 # inherited from DATABASE db
+global_reads: true
+num_replicas: 2 # inherited from RANGE default
+num_voters: 1
+constraints: [] # inherited from TABLE db.public.t
+voter_constraints: []
+lease_preferences: []
+`, string(out))
+
+	// The comments are ignored when unmarshaling.
+	var roundTripped ZoneConfig
+	require.NoError(t, yaml.Unmarshal(out, &roundTripped))
+	require.True(t, zone.Equal(&roundTripped))
+
+	// Without inherited fields, the encoding is the usual one.
+	out, err = MarshalYAMLWithInheritedFields(zone, nil)
+	require.NoError(t, err)
+	expected, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+}
+
+func TestSetAllowedLocalities(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer SetAllowedLocalities(nil)
+
+	SetAllowedLocalities(map[string][]string{
+		"region": {"us-east1", "us-west1"},
+		"zone":   nil,
+	})
+	for _, tc := range []struct {
+		yaml string
+		err  string
+	}{
+		{yaml: "constraints: [+region=us-east1, -zone=us-east1-b, +ssd]"},
+		{yaml: "constraints: [+region=us-*]"},
+		{yaml: "num_replicas: 3\nconstraints: {'+region=us-west1,+ssd': 1}"},
+		{yaml: "lease_preferences: [[+region=us-west1]]"},
+		{
+			yaml: "constraints: [+rack=1]",
+			err:  `constraint "+rack=1" refers to locality tier key "rack", which is not allowed`,
+		},
+		{
+			yaml: "constraints: [+region=eu-*]",
+			err:  `constraint "+region=eu-*" refers to value "eu-*" of locality tier key "region", which is not allowed`,
+		},
+		{
+			yaml: "num_replicas: 3\nconstraints: {'+region=eu-west1,+ssd': 1}",
+			err:  `constraint "+region=eu-west1" refers to value "eu-west1" of locality tier key "region", which is not allowed`,
+		},
+		{
+			yaml: "lease_preferences: [[+dc=1]]",
+			err:  `constraint "+dc=1" refers to locality tier key "dc", which is not allowed`,
+		},
+	} {
+		var zone ZoneConfig
+		err := yaml.Unmarshal([]byte(tc.yaml), &zone)
+		if tc.err == "" {
+			require.NoError(t, err, tc.yaml)
+		} else {
+			require.Error(t, err, tc.yaml)
+			require.Contains(t, err.Error(), tc.err, tc.yaml)
+		}
+	}
+
+	// Without an allowlist, any locality tier is allowed.
+	SetAllowedLocalities(nil)
+	var zone ZoneConfig
+	require.NoError(t, yaml.Unmarshal([]byte("constraints: [+rack=1]"), &zone))
+}
+
+func TestNamedConstraintSets(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer func() { require.NoError(t, SetNamedConstraintSets(nil)) }()
+
+	for _, tc := range []struct {
+		defs map[string]string
+		err  string
+	}{
+		{
+			defs: map[string]string{"a": "'@b'"},
+			err:  `constraint set "b" is not defined`,
+		},
+		{
+			defs: map[string]string{"a": "'@a'"},
+			err:  `constraint set "a" refers to itself: a -> a`,
+		},
+		{
+			defs: map[string]string{"a": "'@b'", "b": "'@a'"},
+			err:  `constraint set "a" refers to itself: a -> b -> a`,
+		},
+		{
+			defs: map[string]string{"us-east": "[+region=us-east1]"},
+			err:  `invalid constraint set name "us-east": names consist of letters, digits and underscores`,
+		},
+		{
+			defs: map[string]string{"a": "[+a=b=c]"},
+			err: `constraint set "a": line 1, column 1: constraint needs to be in the form ` +
+				`"(key=)value", not "a=b=c"`,
+		},
+	} {
+		require.EqualError(t, SetNamedConstraintSets(tc.defs), tc.err)
+	}
+
+	region := func(r string) Constraint {
+		return Constraint{Type: Constraint_REQUIRED, Key: "region", Value: r}
+	}
+	eastSSD := []ConstraintsConjunction{
+		{Constraints: []Constraint{region("us-east1"), {Type: Constraint_REQUIRED, Value: "ssd"}}},
+	}
+	spread := []ConstraintsConjunction{
+		{NumReplicas: 2, Constraints: []Constraint{region("us-east1")}},
+		{NumReplicas: 1, Constraints: []Constraint{region("us-west1")}},
+	}
+	west := []ConstraintsConjunction{{Constraints: []Constraint{region("us-west1")}}}
+
+	require.NoError(t, SetNamedConstraintSets(map[string]string{
+		"us_east_ssd": "[+region=us-east1, +ssd]",
+		"spread":      "{+region=us-east1: 2, +region=us-west1: 1}",
+		"default":     "'@us_east_ssd'",
+	}))
+	zone := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal(
+		[]byte("num_replicas: 3\nconstraints: '@default'\nvoter_constraints: '@us_east_ssd'"), zone))
+	require.Equal(t, eastSSD, zone.Constraints)
+	require.Equal(t, eastSSD, zone.VoterConstraints)
+	require.Equal(t, "default", zone.ConstraintsSet)
+	require.Equal(t, "us_east_ssd", zone.VoterConstraintsSet)
+	require.EqualError(t, yaml.Unmarshal([]byte("constraints: '@missing'"), NewZoneConfig()),
+		`line 1, column 14: constraint set "missing" is not defined`)
+
+	// The references round-trip through YAML.
+	out, err := MarshalYAML(zone)
+	require.NoError(t, err)
+	reparsed := NewZoneConfig()
+	require.NoError(t, yaml.Unmarshal(out, reparsed), "%s", out)
+	require.Equal(t, zone.Constraints, reparsed.Constraints)
+	require.Equal(t, zone.VoterConstraints, reparsed.VoterConstraints)
+	require.Equal(t, zone.ConstraintsSet, reparsed.ConstraintsSet)
+	require.Equal(t, zone.VoterConstraintsSet, reparsed.VoterConstraintsSet)
+
+	// Changes to the sets apply to the zone configs which are unmarshaled from
+	// then on, while the others keep the constraints they were resolved to.
+	require.NoError(t, SetNamedConstraintSets(map[string]string{
+		"us_east_ssd": "[+region=us-west1]",
+		"spread":      "{+region=us-east1: 2, +region=us-west1: 1}",
+		"default":     "'@spread'",
+	}))
+	require.NoError(t, yaml.Unmarshal(out, reparsed), "%s", out)
+	require.Equal(t, spread, reparsed.Constraints)
+	require.Equal(t, west, reparsed.VoterConstraints)
+	require.Equal(t, eastSSD, zone.Constraints)
+
+	// The definitions can be validated without being registered.
+	require.NoError(t, ValidateNamedConstraintSets(map[string]string{"east": "[+region=us-east1]"}))
+	require.EqualError(t, ValidateNamedConstraintSets(map[string]string{"a": "'@b'"}),
+		`constraint set "b" is not defined`)
+}
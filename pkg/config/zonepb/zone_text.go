@@ -0,0 +1,67 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// MarshalProtoText returns the protobuf text format of the zone config. It's
+// the inverse of ParseZoneConfigFromText.
+//
+// NB: This isn't called MarshalText so that ZoneConfig doesn't implement
+// encoding.TextMarshaler, which would change how it's encoded as JSON.
+func (c ZoneConfig) MarshalProtoText() string {
+	return proto.MarshalTextString(&c)
+}
+
+// ParseZoneConfigFromText parses the protobuf text format of a zone config.
+// The result is normalized as if it had been unmarshaled from YAML:
+// constraints and lease preferences which are set are not inherited, and
+// per-replica constraints are sorted.
+func ParseZoneConfigFromText(text string) (ZoneConfig, error) {
+	var c ZoneConfig
+	if err := proto.UnmarshalText(text, &c); err != nil {
+		return ZoneConfig{}, err
+	}
+	c.normalizeParsedText()
+	return c, nil
+}
+
+// normalizeParsedText normalizes the zone config and its subzones. See
+// ParseZoneConfigFromText.
+func (c *ZoneConfig) normalizeParsedText() {
+	if len(c.Constraints) > 0 {
+		c.InheritedConstraints = false
+		sortPerReplicaConstraints(c.Constraints)
+	}
+	sortPerReplicaConstraints(c.VoterConstraints)
+	if len(c.LeasePreferences) > 0 {
+		c.InheritedLeasePreferences = false
+	}
+	for i := range c.Subzones {
+		c.Subzones[i].Config.normalizeParsedText()
+	}
+}
+
+// sortPerReplicaConstraints sorts the conjunctions the same way as
+// ConstraintsList.UnmarshalYAML does for per-replica constraints. Constraints
+// which apply to all replicas are left as is.
+func sortPerReplicaConstraints(conjunctions []ConstraintsConjunction) {
+	if len(conjunctions) == 1 && conjunctions[0].NumReplicas == 0 {
+		return
+	}
+	sort.Slice(conjunctions, func(i, j int) bool {
+		return conjunctions[i].Compare(conjunctions[j]) < 0
+	})
+}
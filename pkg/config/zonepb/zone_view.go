@@ -0,0 +1,99 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+)
+
+// ZoneConfigView is a read-only view of a zone config which is shared with
+// others, such as the zone configs cached by config.SystemConfig. Modifying a
+// shared zone config, including the contents of its slices (e.g. Constraints
+// or SubzoneSpans), corrupts it for everyone else, so the view only exposes
+// copies of it. Use Mutate to obtain a copy which may be modified.
+//
+// The zero value is a nil view, which doesn't refer to any zone config.
+type ZoneConfigView struct {
+	zone *ZoneConfig
+}
+
+// MakeZoneConfigView returns a view of the given zone config, which may be
+// nil. The caller must not modify the zone config afterwards.
+func MakeZoneConfigView(zone *ZoneConfig) ZoneConfigView {
+	return ZoneConfigView{zone: zone}
+}
+
+// IsNil returns whether the view doesn't refer to a zone config. The other
+// methods, except for Mutate, must not be called on a nil view.
+func (v ZoneConfigView) IsNil() bool {
+	return v.zone == nil
+}
+
+// Mutate returns a deep copy of the zone config, which the caller is free to
+// modify, or nil if the view is nil.
+func (v ZoneConfigView) Mutate() *ZoneConfig {
+	if v.zone == nil {
+		return nil
+	}
+	return protoutil.Clone(v.zone).(*ZoneConfig)
+}
+
+// NumReplicas returns the zone config's number of replicas, or 0 if it's
+// unset.
+func (v ZoneConfigView) NumReplicas() int32 {
+	return int32Value(v.zone.NumReplicas)
+}
+
+// NumVoters returns the zone config's number of voters, or 0 if it's unset.
+func (v ZoneConfigView) NumVoters() int32 {
+	return int32Value(v.zone.NumVoters)
+}
+
+// GCTTLSeconds returns the TTL of the zone config's GC policy. The GC policy
+// must be set.
+func (v ZoneConfigView) GCTTLSeconds() int32 {
+	return v.zone.GC.TTLSeconds
+}
+
+// AuditInfo returns the audit info of the zone config, which is empty if none
+// was recorded.
+func (v ZoneConfigView) AuditInfo() ZoneConfigAuditInfo {
+	if v.zone.AuditInfo == nil {
+		return ZoneConfigAuditInfo{}
+	}
+	return *v.zone.AuditInfo
+}
+
+// ApplicationID returns the ID of the change which last wrote the zone config,
+// which is empty if none was recorded. See ZoneConfig.AppliedBy.
+func (v ZoneConfigView) ApplicationID() string {
+	return v.zone.ApplicationID
+}
+
+// AsSpanConfig converts the zone config, which must be fully hydrated, to an
+// equivalent SpanConfig. See ZoneConfig.AsSpanConfig.
+func (v ZoneConfigView) AsSpanConfig() roachpb.SpanConfig {
+	return v.zone.AsSpanConfig()
+}
+
+// EquivalentTo returns whether the zone configs of the views are equivalent.
+// See ZoneConfig.EquivalentTo.
+func (v ZoneConfigView) EquivalentTo(other ZoneConfigView) bool {
+	return v.zone.EquivalentTo(other.zone)
+}
+
+// Unwrap returns the shared zone config. It exists for adapters which only
+// read the zone config, and need it by pointer to avoid copying it. The zone
+// config must not be modified.
+func (v ZoneConfigView) Unwrap() *ZoneConfig {
+	return v.zone
+}
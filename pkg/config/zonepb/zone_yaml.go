@@ -11,44 +11,238 @@
 package zonepb
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"reflect"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/util/envutil"
+	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/errors"
 	"github.com/gogo/protobuf/proto"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
+// Limits on the zone configs which may be unmarshaled from YAML. Zone configs
+// come from user input and are gossiped to every node, so they must stay
+// reasonably small.
+var (
+	// maxYAMLConstraintsPerConjunction is the maximum number of constraints
+	// in a conjunction of constraints or in a lease preference.
+	maxYAMLConstraintsPerConjunction = envutil.EnvOrDefaultInt(
+		"COCKROACH_ZONE_CONFIG_MAX_CONSTRAINTS_PER_CONJUNCTION", 32)
+	// maxYAMLConjunctions is the maximum number of per-replica conjunctions in
+	// a list of constraints.
+	maxYAMLConjunctions = envutil.EnvOrDefaultInt(
+		"COCKROACH_ZONE_CONFIG_MAX_CONJUNCTIONS", 128)
+	// maxYAMLLeasePreferences is the maximum number of lease preferences.
+	maxYAMLLeasePreferences = envutil.EnvOrDefaultInt(
+		"COCKROACH_ZONE_CONFIG_MAX_LEASE_PREFERENCES", 32)
+	// maxYAMLDocumentBytes is the maximum size of the YAML document of a zone
+	// config, once its aliases are expanded.
+	maxYAMLDocumentBytes = envutil.EnvOrDefaultBytes(
+		"COCKROACH_ZONE_CONFIG_MAX_YAML_BYTES", 64<<10 /* 64 KiB */)
+)
+
+// MarshalYAML returns the YAML encoding of v, like yaml.Marshal, but with the
+// two-space indentation which zone configs have always been marshaled with.
+func MarshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalYAMLStrict is like yaml.Unmarshal(data, v), but rejects the fields
+// of the document which aren't fields of the structs they're decoded into, as
+// yaml.v2's UnmarshalStrict did. Zone configs always reject unknown fields
+// (see ZoneConfig.UnmarshalYAML), so this is mostly useful for decoding the
+// other types of the package, such as constraints, in tests.
+func UnmarshalYAMLStrict(data []byte, v interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// yamlNodeError annotates the error with the position of the YAML node which
+// it's about, e.g. "line 3, column 14: ...".
+func yamlNodeError(n *yaml.Node, err error) error {
+	return errors.Wrapf(err, "line %d, column %d", n.Line, n.Column)
+}
+
+// yamlNodeErrorf is like yamlNodeError, but formats a new error.
+func yamlNodeErrorf(n *yaml.Node, format string, args ...interface{}) error {
+	return yamlNodeError(n, errors.Newf(format, args...))
+}
+
+// resolveYAMLAlias returns the node which the node refers to if it's an
+// alias, and the node itself otherwise.
+func resolveYAMLAlias(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		return n.Alias
+	}
+	return n
+}
+
+// isYAMLNull returns whether the node is null, e.g. "~", "null" or an empty
+// value.
+func isYAMLNull(n *yaml.Node) bool {
+	return resolveYAMLAlias(n).ShortTag() == "!!null"
+}
+
+// yamlNodeSize returns the total length of the scalars of the YAML node, with
+// its aliases expanded, which approximates the size of its encoding without
+// having to encode it. Counting stops as soon as the size exceeds limit, so
+// that documents whose aliases expand to huge documents are cheap to reject.
+// An error is returned if an alias refers to a node which contains it.
+func yamlNodeSize(n *yaml.Node, limit int64) (int64, error) {
+	var size int64
+	active := make(map[*yaml.Node]bool)
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if size > limit {
+			return nil
+		}
+		if active[n] {
+			return yamlNodeErrorf(n, "anchor %q value contains itself", n.Anchor)
+		}
+		active[n] = true
+		defer delete(active, n)
+		switch n.Kind {
+		case yaml.AliasNode:
+			if n.Alias != nil {
+				return walk(n.Alias)
+			}
+		case yaml.ScalarNode:
+			size += int64(len(n.Value))
+		default:
+			for _, c := range n.Content {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := walk(n)
+	return size, err
+}
+
+// checkYAMLFields returns an error located at the first key of the mapping
+// node, or of the mappings merged into it, which isn't a field of the YAML
+// encoding of the struct which out points to. Unlike yaml.v2's
+// UnmarshalStrict, yaml.Node.Decode ignores unknown fields, so the
+// unmarshalers check them explicitly.
+func checkYAMLFields(n *yaml.Node, out interface{}) error {
+	return checkYAMLMappingFields(n, reflect.TypeOf(out).Elem())
+}
+
+func checkYAMLMappingFields(n *yaml.Node, t reflect.Type) error {
+	n = resolveYAMLAlias(n)
+	switch n.Kind {
+	case yaml.SequenceNode:
+		// A sequence of mappings merged into a mapping.
+		for _, m := range n.Content {
+			if err := checkYAMLMappingFields(m, t); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := resolveYAMLAlias(n.Content[i])
+			if isYAMLMergeKey(key) {
+				if err := checkYAMLMappingFields(n.Content[i+1], t); err != nil {
+					return err
+				}
+				continue
+			}
+			if !hasYAMLField(t, key.Value) {
+				return yamlNodeError(key, &ErrUnknownField{Field: key.Value, Type: t.String()})
+			}
+		}
+	}
+	return nil
+}
+
+// hasYAMLField returns whether the YAML encoding of the struct type has a
+// field with the given name.
+func hasYAMLField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		fieldName, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case fieldName == "-":
+		case strings.Contains(opts, "inline"):
+			if hasYAMLField(f.Type, name) {
+				return true
+			}
+		case fieldName == "" && strings.ToLower(f.Name) == name, fieldName == name:
+			return true
+		}
+	}
+	return false
+}
+
+// checkYAMLConstraintCount returns an error if there are too many constraints
+// in a conjunction or lease preference.
+func checkYAMLConstraintCount(n int) error {
+	if n > maxYAMLConstraintsPerConjunction {
+		return errors.Newf("at most %d constraints are allowed per conjunction, found %d",
+			maxYAMLConstraintsPerConjunction, n)
+	}
+	return nil
+}
+
 var _ yaml.Marshaler = LeasePreference{}
 var _ yaml.Unmarshaler = &LeasePreference{}
 
 // MarshalYAML implements yaml.Marshaler.
 func (l LeasePreference) MarshalYAML() (interface{}, error) {
-	short := make([]string, len(l.Constraints))
-	for i, c := range l.Constraints {
-		short[i] = c.String()
-	}
-	return short, nil
+	return shortConstraints(l.Constraints), nil
 }
 
 // UnmarshalYAML implements yaml.Unmarshaler.
-func (l *LeasePreference) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var shortConstraints []string
-	if err := unmarshal(&shortConstraints); err != nil {
+func (l *LeasePreference) UnmarshalYAML(value *yaml.Node) error {
+	constraints, err := decodeShortConstraints(value)
+	if err != nil {
 		return err
 	}
-	constraints := make([]Constraint, len(shortConstraints))
-	for i, short := range shortConstraints {
-		if err := constraints[i].FromString(short); err != nil {
-			return err
-		}
-	}
 	l.Constraints = constraints
 	return nil
 }
 
+// decodeShortConstraints decodes a list of constraints in the shorthand
+// notation, e.g. [+region=us-east1, -ssd].
+func decodeShortConstraints(value *yaml.Node) ([]Constraint, error) {
+	var short []string
+	if err := value.Decode(&short); err != nil {
+		return nil, err
+	}
+	constraints, err := parseShortConstraints(short)
+	if err != nil {
+		return nil, yamlNodeError(value, err)
+	}
+	return constraints, nil
+}
+
 var _ yaml.Marshaler = ConstraintsConjunction{}
 var _ yaml.Unmarshaler = &ConstraintsConjunction{}
 
@@ -58,8 +252,8 @@ func (c ConstraintsConjunction) MarshalYAML() (interface{}, error) {
 		"MarshalYAML should never be called directly on Constraints (%v): %v", c, debug.Stack())
 }
 
-// UnmarshalYAML implements yaml.Marshaler.
-func (c *ConstraintsConjunction) UnmarshalYAML(unmarshal func(interface{}) error) error {
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *ConstraintsConjunction) UnmarshalYAML(value *yaml.Node) error {
 	return fmt.Errorf(
 		"UnmarshalYAML should never be called directly on Constraints: %v", debug.Stack())
 }
@@ -69,6 +263,48 @@ func (c *ConstraintsConjunction) UnmarshalYAML(unmarshal func(interface{}) error
 type ConstraintsList struct {
 	Constraints []ConstraintsConjunction
 	Inherited   bool
+	// SetName is the name of the named constraint set which Constraints were
+	// resolved from, if any. See SetNamedConstraintSets.
+	SetName string
+}
+
+// TotalConstrainedReplicas returns the number of replicas constrained by
+// per-replica constraints. Constraints which apply to all replicas don't
+// count towards it.
+func (c ConstraintsList) TotalConstrainedReplicas() int32 {
+	var total int32
+	for _, conjunction := range c.Constraints {
+		total += conjunction.NumReplicas
+	}
+	return total
+}
+
+// UnconstrainedReplicas returns the number of replicas, out of numReplicas,
+// which aren't subject to any constraints. It's zero if there are constraints
+// which apply to all replicas.
+func (c ConstraintsList) UnconstrainedReplicas(numReplicas int32) int32 {
+	for _, conjunction := range c.Constraints {
+		if conjunction.NumReplicas == 0 {
+			return 0
+		}
+	}
+	if unconstrained := numReplicas - c.TotalConstrainedReplicas(); unconstrained > 0 {
+		return unconstrained
+	}
+	return 0
+}
+
+// ContainsConstraint returns whether any of the conjunctions in the list
+// contains the constraint.
+func (c ConstraintsList) ContainsConstraint(constraint Constraint) bool {
+	for _, conjunction := range c.Constraints {
+		for _, other := range conjunction.Constraints {
+			if other == constraint {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 var _ yaml.Marshaler = ConstraintsList{}
@@ -83,155 +319,302 @@ var _ yaml.Unmarshaler = &ConstraintsList{}
 //     [c1, c2, c3]
 //  2. A per-replica format when NumReplicas is non-zero:
 //     {"c1,c2,c3": numReplicas1, "c4,c5": numReplicas2}
+//
+// Constraints resolved from a named constraint set are marshaled as the name
+// of the set instead, e.g. "@us_east_ssd".
 func (c ConstraintsList) MarshalYAML() (interface{}, error) {
+	if c.SetName != "" && !c.Inherited {
+		return namedConstraintSetPrefix + c.SetName, nil
+	}
 	// If per-replica Constraints aren't in use, marshal everything into a list
 	// for compatibility with pre-2.0-style configs.
 	if c.Inherited || len(c.Constraints) == 0 {
 		return []string{}, nil
 	}
 	if len(c.Constraints) == 1 && c.Constraints[0].NumReplicas == 0 {
-		short := make([]string, len(c.Constraints[0].Constraints))
-		for i, constraint := range c.Constraints[0].Constraints {
-			short[i] = constraint.String()
-		}
-		return short, nil
+		return shortConstraints(c.Constraints[0].Constraints), nil
 	}
 
 	// Otherwise, convert into a map from Constraints to NumReplicas.
 	constraintsMap := make(map[string]int32)
 	for _, constraints := range c.Constraints {
-		short := make([]string, len(constraints.Constraints))
-		for i, constraint := range constraints.Constraints {
-			short[i] = constraint.String()
-		}
+		short := shortConstraints(constraints.Constraints)
 		constraintsMap[strings.Join(short, ",")] = constraints.NumReplicas
 	}
 	return constraintsMap, nil
 }
 
 // UnmarshalYAML implements yaml.Unmarshaler.
-func (c *ConstraintsList) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	// Note that we're intentionally checking for err == nil here. This handles
-	// unmarshaling the legacy Constraints format, which is just a list of
-	// strings.
-	var strs []string
-	c.Inherited = true
-	if err := unmarshal(&strs); err == nil {
-		constraints := make([]Constraint, len(strs))
-		for i, short := range strs {
-			if err := constraints[i].FromString(short); err != nil {
-				return err
-			}
+func (c *ConstraintsList) UnmarshalYAML(value *yaml.Node) error {
+	invalidFormat := yamlNodeErrorf(value, "invalid constraints format. expected an array "+
+		"of strings or a map of strings to ints")
+	switch n := resolveYAMLAlias(value); n.Kind {
+	case yaml.ScalarNode:
+		// A reference to a named constraint set, e.g. "@us_east_ssd".
+		if !strings.HasPrefix(n.Value, namedConstraintSetPrefix) {
+			return invalidFormat
+		}
+		name := strings.TrimPrefix(n.Value, namedConstraintSetPrefix)
+		constraints, err := lookupNamedConstraintSet(name)
+		if err != nil {
+			return yamlNodeError(value, err)
 		}
-		if len(constraints) == 0 {
-			c.Constraints = []ConstraintsConjunction{}
-			c.Inherited = false
-		} else {
-			c.Constraints = []ConstraintsConjunction{
-				{
-					Constraints: constraints,
-					NumReplicas: 0,
-				},
+		c.Constraints, c.Inherited, c.SetName = constraints, false, name
+		return nil
+
+	case yaml.SequenceNode:
+		// The legacy Constraints format, which is just a list of strings.
+		var strs []string
+		if err := value.Decode(&strs); err != nil {
+			if errors.HasType(err, (*yaml.TypeError)(nil)) {
+				return invalidFormat
 			}
-			c.Inherited = false
+			return err
+		}
+		list, err := legacyConstraintsList(strs)
+		if err != nil {
+			return yamlNodeError(value, err)
 		}
+		*c = list
 		return nil
-	}
 
-	// Otherwise, the input must be a map that can be converted to per-replica
-	// constraints.
-	constraintsMap := make(map[string]int32)
-	if err := unmarshal(&constraintsMap); err != nil {
-		return errors.New(
-			"invalid constraints format. expected an array of strings or a map of strings to ints")
-	}
+	case yaml.MappingNode:
+		// Otherwise, the input must be a map that can be converted to per-replica
+		// constraints.
+		constraintsMap := make(map[string]int32)
+		if err := value.Decode(&constraintsMap); err != nil {
+			if errors.HasType(err, (*yaml.TypeError)(nil)) {
+				return invalidFormat
+			}
+			return err
+		}
+		if len(constraintsMap) > maxYAMLConjunctions {
+			return yamlNodeErrorf(value, "at most %d per-replica constraints are allowed, found %d",
+				maxYAMLConjunctions, len(constraintsMap))
+		}
 
-	constraintsList := make([]ConstraintsConjunction, 0, len(constraintsMap))
-	for constraintsStr, numReplicas := range constraintsMap {
-		shortConstraints := strings.Split(constraintsStr, ",")
-		constraints := make([]Constraint, len(shortConstraints))
-		for i, short := range shortConstraints {
-			if err := constraints[i].FromString(short); err != nil {
-				return err
+		constraintsList := make([]ConstraintsConjunction, 0, len(constraintsMap))
+		for constraintsStr, numReplicas := range constraintsMap {
+			constraints, err := parseShortConstraints(splitEscapedConstraints(constraintsStr, ','))
+			if err != nil {
+				// Locate the error at the key, unless it comes from a merged mapping.
+				key := value
+				if i := yamlMappingIndex(n, constraintsStr); i >= 0 {
+					key = n.Content[i]
+				}
+				return yamlNodeError(key, err)
 			}
+			constraintsList = append(constraintsList, ConstraintsConjunction{
+				Constraints: constraints,
+				NumReplicas: numReplicas,
+			})
 		}
-		constraintsList = append(constraintsList, ConstraintsConjunction{
-			Constraints: constraints,
-			NumReplicas: numReplicas,
+
+		// Sort the resulting list for reproducible orderings in tests.
+		sort.Slice(constraintsList, func(i, j int) bool {
+			return constraintsList[i].Compare(constraintsList[j]) < 0
 		})
+
+		c.Constraints = constraintsList
+		c.Inherited = false
+		c.SetName = ""
+		return nil
+
+	default:
+		return invalidFormat
 	}
+}
 
-	// Sort the resulting list for reproducible orderings in tests.
-	sort.Slice(constraintsList, func(i, j int) bool {
-		// First, try to find which Constraints sort first alphabetically in string
-		// format, considering the shorter list lesser if they're otherwise equal.
-		for k := range constraintsList[i].Constraints {
-			if k >= len(constraintsList[j].Constraints) {
-				return false
-			}
-			lStr := constraintsList[i].Constraints[k].String()
-			rStr := constraintsList[j].Constraints[k].String()
-			if lStr < rStr {
-				return true
-			}
-			if lStr > rStr {
-				return false
-			}
+// legacyConstraintsList returns the constraints list of the legacy format, a
+// list of constraints which apply to all replicas.
+func legacyConstraintsList(short []string) (ConstraintsList, error) {
+	constraints, err := parseShortConstraints(short)
+	if err != nil {
+		return ConstraintsList{}, err
+	}
+	if len(constraints) == 0 {
+		return ConstraintsList{Constraints: []ConstraintsConjunction{}}, nil
+	}
+	return ConstraintsList{
+		Constraints: []ConstraintsConjunction{
+			{
+				Constraints: constraints,
+				NumReplicas: 0,
+			},
+		},
+	}, nil
+}
+
+// byteSize is a byte count which can be unmarshaled from YAML either as an
+// integer or as a human-readable size, such as 512MiB. It is always marshaled
+// as an integer, so that the output remains readable by older versions.
+type byteSize int64
+
+var _ yaml.Unmarshaler = (*byteSize)(nil)
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (b *byteSize) UnmarshalYAML(value *yaml.Node) error {
+	var n int64
+	if err := value.Decode(&n); err == nil {
+		*b = byteSize(n)
+		return nil
+	}
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	n, err := humanizeutil.ParseBytes(s)
+	if err != nil {
+		return yamlNodeError(value, errors.Wrapf(err, "invalid byte size %q", s))
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+func byteSizeRef(n int64) *byteSize {
+	b := byteSize(n)
+	return &b
+}
+
+// yamlBool is a bool which can also be unmarshaled from the YAML 1.1 booleans
+// which yaml.v2 accepted, such as yes, no, on and off, while yaml.v3 only
+// accepts true and false. It is always marshaled as true or false.
+type yamlBool bool
+
+var _ yaml.Unmarshaler = (*yamlBool)(nil)
+
+// yaml11Bools maps the unquoted YAML 1.1 booleans which yaml.v3 doesn't
+// resolve as booleans to their values.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"on": true, "On": true, "ON": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"off": false, "Off": false, "OFF": false,
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (b *yamlBool) UnmarshalYAML(value *yaml.Node) error {
+	var v bool
+	err := value.Decode(&v)
+	if err != nil {
+		n := resolveYAMLAlias(value)
+		if n.Kind != yaml.ScalarNode || n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) != 0 {
+			return err
 		}
-		if len(constraintsList[i].Constraints) < len(constraintsList[j].Constraints) {
-			return true
+		var ok bool
+		if v, ok = yaml11Bools[n.Value]; !ok {
+			return err
 		}
-		// If they're completely equal and the same length, go by NumReplicas.
-		return constraintsList[i].NumReplicas < constraintsList[j].NumReplicas
-	})
+	}
+	*b = yamlBool(v)
+	return nil
+}
+
+var _ yaml.Unmarshaler = &GCPolicy{}
 
-	c.Constraints = constraintsList
-	c.Inherited = false
+// UnmarshalYAML implements yaml.Unmarshaler.
+//
+// In addition to ttlseconds, the TTL can be specified as a duration string
+// through the ttl field, e.g. {ttl: 25h}. GCPolicy is still marshaled with
+// ttlseconds so that the output remains readable by older versions.
+func (p *GCPolicy) UnmarshalYAML(value *yaml.Node) error {
+	var aux struct {
+		TTLSeconds *int32  `yaml:"ttlseconds"`
+		TTL        *string `yaml:"ttl"`
+	}
+	if err := checkYAMLFields(value, &aux); err != nil {
+		return err
+	}
+	if err := value.Decode(&aux); err != nil {
+		return err
+	}
+	switch {
+	case aux.TTLSeconds != nil && aux.TTL != nil:
+		return yamlNodeErrorf(value, "gc.ttlseconds and gc.ttl cannot both be set")
+	case aux.TTLSeconds != nil:
+		p.TTLSeconds = *aux.TTLSeconds
+	case aux.TTL != nil:
+		ttl, err := time.ParseDuration(*aux.TTL)
+		if err != nil {
+			return yamlNodeError(value, errors.Wrapf(err, "invalid gc.ttl %q", *aux.TTL))
+		}
+		if ttl%time.Second != 0 {
+			return yamlNodeErrorf(value, "gc.ttl %q must be a whole number of seconds", *aux.TTL)
+		}
+		if secs := ttl / time.Second; secs > math.MaxInt32 || secs < math.MinInt32 {
+			return yamlNodeErrorf(value, "gc.ttl %q is out of range", *aux.TTL)
+		}
+		p.TTLSeconds = int32(ttl / time.Second)
+	}
 	return nil
 }
 
 // marshalableZoneConfig should be kept up-to-date with the real,
 // auto-generated ZoneConfig type, but with []Constraints changed to
 // ConstraintsList for backwards-compatible yaml marshaling and unmarshaling.
-// We also support parsing both lease_preferences (for v2.1+) and
-// experimental_lease_preferences (for v2.0), copying both into the same proto
-// field as needed.
 //
-// TODO(a-robinson,v2.2): Remove the experimental_lease_preferences field.
+// marshalableZoneConfig describes CurrentZoneConfigYAMLVersion of the
+// encoding. Documents using older encodings (e.g. the v2.0
+// experimental_lease_preferences field) are upgraded by the migrations in
+// zone_yaml_migration.go before they are decoded into this struct.
 type marshalableZoneConfig struct {
-	RangeMinBytes                *int64            `json:"range_min_bytes" yaml:"range_min_bytes"`
-	RangeMaxBytes                *int64            `json:"range_max_bytes" yaml:"range_max_bytes"`
-	GC                           *GCPolicy         `json:"gc"`
-	GlobalReads                  *bool             `json:"global_reads" yaml:"global_reads"`
-	NumReplicas                  *int32            `json:"num_replicas" yaml:"num_replicas"`
-	NumVoters                    *int32            `json:"num_voters" yaml:"num_voters"`
-	Constraints                  ConstraintsList   `json:"constraints" yaml:"constraints,flow"`
-	VoterConstraints             ConstraintsList   `json:"voter_constraints" yaml:"voter_constraints,flow"`
-	LeasePreferences             []LeasePreference `json:"lease_preferences" yaml:"lease_preferences,flow"`
-	ExperimentalLeasePreferences []LeasePreference `json:"experimental_lease_preferences" yaml:"experimental_lease_preferences,flow,omitempty"`
-	Subzones                     []Subzone         `json:"subzones" yaml:"-"`
-	SubzoneSpans                 []SubzoneSpan     `json:"subzone_spans" yaml:"-"`
+	RangeMinBytes    *byteSize         `json:"range_min_bytes" yaml:"range_min_bytes"`
+	RangeMaxBytes    *byteSize         `json:"range_max_bytes" yaml:"range_max_bytes"`
+	GC               *GCPolicy         `json:"gc"`
+	GlobalReads      *yamlBool         `json:"global_reads" yaml:"global_reads"`
+	NumReplicas      *int32            `json:"num_replicas" yaml:"num_replicas"`
+	NumVoters        *int32            `json:"num_voters" yaml:"num_voters"`
+	Constraints      ConstraintsList   `json:"constraints" yaml:"constraints,flow"`
+	VoterConstraints ConstraintsList   `json:"voter_constraints" yaml:"voter_constraints,flow"`
+	LeasePreferences []LeasePreference `json:"lease_preferences" yaml:"lease_preferences,flow"`
+	Subzones         []Subzone         `json:"subzones" yaml:"-"`
+	SubzoneSpans     []SubzoneSpan     `json:"subzone_spans" yaml:"-"`
+
+	// The survival goal and regions are omitted when they're unset, unlike the
+	// other fields, so that the YAML of zone configs which don't set them is
+	// unchanged.
+	SurvivalGoal    *survivalGoal `json:"survival_goal,omitempty" yaml:"survival_goal,omitempty"`
+	PrimaryRegion   *string       `json:"primary_region,omitempty" yaml:"primary_region,omitempty"`
+	SecondaryRegion *string       `json:"secondary_region,omitempty" yaml:"secondary_region,omitempty"`
+
+	// Split hints are omitted when there are none, for the same reason.
+	SplitHints []splitHint `json:"split_hints,omitempty" yaml:"split_hints,omitempty,flow"`
 }
 
 func zoneConfigToMarshalable(c ZoneConfig) marshalableZoneConfig {
 	var m marshalableZoneConfig
 	if c.RangeMinBytes != nil {
-		m.RangeMinBytes = proto.Int64(*c.RangeMinBytes)
+		m.RangeMinBytes = byteSizeRef(*c.RangeMinBytes)
 	}
 	if c.RangeMaxBytes != nil {
-		m.RangeMaxBytes = proto.Int64(*c.RangeMaxBytes)
+		m.RangeMaxBytes = byteSizeRef(*c.RangeMaxBytes)
 	}
 	if c.GC != nil {
 		tempGC := *c.GC
 		m.GC = &tempGC
 	}
 	if c.GlobalReads != nil {
-		m.GlobalReads = proto.Bool(*c.GlobalReads)
+		b := yamlBool(*c.GlobalReads)
+		m.GlobalReads = &b
+	}
+	if c.SurvivalGoal != nil {
+		g := survivalGoal(*c.SurvivalGoal)
+		m.SurvivalGoal = &g
+	}
+	if c.PrimaryRegion != nil {
+		m.PrimaryRegion = proto.String(*c.PrimaryRegion)
+	}
+	if c.SecondaryRegion != nil {
+		m.SecondaryRegion = proto.String(*c.SecondaryRegion)
 	}
 	if c.NumReplicas != nil && *c.NumReplicas != 0 {
 		m.NumReplicas = proto.Int32(*c.NumReplicas)
 	}
-	m.Constraints = ConstraintsList{c.Constraints, c.InheritedConstraints}
+	m.Constraints = ConstraintsList{
+		Constraints: c.Constraints,
+		Inherited:   c.InheritedConstraints,
+		SetName:     c.ConstraintsSet,
+	}
 	if c.NumVoters != nil && *c.NumVoters != 0 {
 		m.NumVoters = proto.Int32(*c.NumVoters)
 	}
@@ -239,14 +622,17 @@ func zoneConfigToMarshalable(c ZoneConfig) marshalableZoneConfig {
 	// `NullVoterConstraintsIsEmpty` as opposed to calling
 	// `c.InheritedVoterConstraints()`. This is copacetic as long as the value is
 	// unmarshalled correctly in zoneConfigFromMarshalable().
-	m.VoterConstraints = ConstraintsList{c.VoterConstraints, !c.NullVoterConstraintsIsEmpty}
+	m.VoterConstraints = ConstraintsList{
+		Constraints: c.VoterConstraints,
+		Inherited:   !c.NullVoterConstraintsIsEmpty,
+		SetName:     c.VoterConstraintsSet,
+	}
 	if !c.InheritedLeasePreferences {
 		m.LeasePreferences = c.LeasePreferences
 	}
-	// We intentionally do not round-trip ExperimentalLeasePreferences. We never
-	// want to return yaml containing it.
 	m.Subzones = c.Subzones
 	m.SubzoneSpans = c.SubzoneSpans
+	m.SplitHints = splitHintsToMarshalable(c.SplitHints)
 	return m
 }
 
@@ -255,66 +641,182 @@ func zoneConfigToMarshalable(c ZoneConfig) marshalableZoneConfig {
 // the original value of the InheritedLeasePreferences field in the output.
 func zoneConfigFromMarshalable(m marshalableZoneConfig, c ZoneConfig) ZoneConfig {
 	if m.RangeMinBytes != nil {
-		c.RangeMinBytes = proto.Int64(*m.RangeMinBytes)
+		c.RangeMinBytes = proto.Int64(int64(*m.RangeMinBytes))
 	}
 	if m.RangeMaxBytes != nil {
-		c.RangeMaxBytes = proto.Int64(*m.RangeMaxBytes)
+		c.RangeMaxBytes = proto.Int64(int64(*m.RangeMaxBytes))
 	}
 	if m.GC != nil {
 		tempGC := *m.GC
 		c.GC = &tempGC
 	}
 	if m.GlobalReads != nil {
-		c.GlobalReads = proto.Bool(*m.GlobalReads)
+		c.GlobalReads = proto.Bool(bool(*m.GlobalReads))
+	}
+	if m.SurvivalGoal != nil {
+		g := SurvivalGoal(*m.SurvivalGoal)
+		c.SurvivalGoal = &g
+	}
+	if m.PrimaryRegion != nil {
+		c.PrimaryRegion = proto.String(*m.PrimaryRegion)
+	}
+	if m.SecondaryRegion != nil {
+		c.SecondaryRegion = proto.String(*m.SecondaryRegion)
 	}
 	if m.NumReplicas != nil {
 		c.NumReplicas = proto.Int32(*m.NumReplicas)
 	}
 	c.Constraints = m.Constraints.Constraints
 	c.InheritedConstraints = m.Constraints.Inherited
+	c.ConstraintsSet = m.Constraints.SetName
 	if m.NumVoters != nil {
 		c.NumVoters = proto.Int32(*m.NumVoters)
 	}
 	c.VoterConstraints = m.VoterConstraints.Constraints
 	c.NullVoterConstraintsIsEmpty = !m.VoterConstraints.Inherited
+	c.VoterConstraintsSet = m.VoterConstraints.SetName
 	if m.LeasePreferences != nil {
 		c.LeasePreferences = m.LeasePreferences
-	}
-
-	// Prefer a provided m.ExperimentalLeasePreferences value over whatever is in
-	// m.LeasePreferences, since we know that m.ExperimentalLeasePreferences can
-	// only possibly come from the user-specified input, whereas
-	// m.LeasePreferences could be the old value of the field retrieved from
-	// internal storage that the user is now trying to overwrite.
-	if m.ExperimentalLeasePreferences != nil {
-		c.LeasePreferences = m.ExperimentalLeasePreferences
-	}
-
-	if m.LeasePreferences != nil || m.ExperimentalLeasePreferences != nil {
 		c.InheritedLeasePreferences = false
 	}
 	c.Subzones = m.Subzones
 	c.SubzoneSpans = m.SubzoneSpans
+	c.SplitHints = splitHintsFromMarshalable(m.SplitHints)
 	return c
 }
 
 var _ yaml.Marshaler = ZoneConfig{}
 var _ yaml.Unmarshaler = &ZoneConfig{}
 
-// MarshalYAML implements yaml.Marshaler.
+// zoneConfigYAMLKeyOrder is the documented order of the keys of the YAML
+// encoding of a zone config. Tools which diff zone configs rely on it, so it
+// must not change, whatever the order of the fields of marshalableZoneConfig.
+// Keys which aren't listed, such as those of fields added later on, follow the
+// listed keys in the order of the fields.
+var zoneConfigYAMLKeyOrder = []string{
+	"range_min_bytes",
+	"range_max_bytes",
+	"gc",
+	"global_reads",
+	"num_replicas",
+	"num_voters",
+	"constraints",
+	"voter_constraints",
+	"lease_preferences",
+	"survival_goal",
+	"primary_region",
+	"secondary_region",
+	"split_hints",
+}
+
+// zoneConfigYAMLNode encodes v, a marshalableZoneConfig or a struct which
+// inlines one, as a YAML mapping whose keys are in zoneConfigYAMLKeyOrder.
+// The mapping is preceded by the version of the encoding if it can't be read
+// as an unversioned document (see minZoneConfigYAMLVersion), so that the YAML
+// of zone configs is otherwise unchanged by the versioning of the encoding.
+func zoneConfigYAMLNode(v interface{}) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	rank := func(key string) int {
+		for i, k := range zoneConfigYAMLKeyOrder {
+			if k == key {
+				return i
+			}
+		}
+		return len(zoneConfigYAMLKeyOrder)
+	}
+	pairs := make([][2]*yaml.Node, len(node.Content)/2)
+	for i := range pairs {
+		pairs[i] = [2]*yaml.Node{node.Content[2*i], node.Content[2*i+1]}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return rank(pairs[i][0].Value) < rank(pairs[j][0].Value)
+	})
+	for i, pair := range pairs {
+		node.Content[2*i], node.Content[2*i+1] = pair[0], pair[1]
+	}
+	if version := minZoneConfigYAMLVersion(&node); version != ZoneConfigYAMLVersionUnversioned {
+		node.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: zoneConfigYAMLVersionKey},
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(int(version))},
+		}, node.Content...)
+	}
+	return &node, nil
+}
+
+// MarshalYAML implements yaml.Marshaler. The keys are in the documented order
+// of zoneConfigYAMLKeyOrder.
 func (c ZoneConfig) MarshalYAML() (interface{}, error) {
-	return zoneConfigToMarshalable(c), nil
+	return zoneConfigYAMLNode(zoneConfigToMarshalable(c))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+//
+// The document is first upgraded to CurrentZoneConfigYAMLVersion by the
+// registered migrations (see RegisterZoneConfigMigration), and is then decoded
+// into a marshalableZoneConfig. The document and the lists of constraints and
+// lease preferences it contains are subject to size limits. Errors are located
+// at the line and column of the offending node where possible.
+//
+// yaml.v3 doesn't tell unmarshalers whether the caller asked for unknown
+// fields to be rejected, so they always are, as with yaml.v2's
+// UnmarshalStrict. Callers which need unknown fields to be ignored use
+// UnmarshalYAMLNonStrict instead.
+func (c *ZoneConfig) UnmarshalYAML(value *yaml.Node) error {
+	return c.unmarshalYAML(value, true /* strict */)
 }
 
+// nonStrictZoneConfig is a ZoneConfig whose YAML unmarshaler ignores unknown
+// top-level fields.
+type nonStrictZoneConfig ZoneConfig
+
 // UnmarshalYAML implements yaml.Unmarshaler.
-func (c *ZoneConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (c *nonStrictZoneConfig) UnmarshalYAML(value *yaml.Node) error {
+	return (*ZoneConfig)(c).unmarshalYAML(value, false /* strict */)
+}
+
+// UnmarshalYAMLNonStrict is like yaml.Unmarshal(data, c), but ignores the
+// top-level fields of the document which aren't fields of the YAML encoding of
+// zone configs, as yaml.v2's Unmarshal did, rather than rejecting them. This
+// is meant for documents which may have been written by newer versions.
+func UnmarshalYAMLNonStrict(data []byte, c *ZoneConfig) error {
+	return yaml.Unmarshal(data, (*nonStrictZoneConfig)(c))
+}
+
+// unmarshalYAML implements ZoneConfig.UnmarshalYAML. Unknown top-level fields
+// are only rejected if strict is set.
+func (c *ZoneConfig) unmarshalYAML(value *yaml.Node, strict bool) error {
+	doc, err := migrateZoneConfigYAML(value)
+	if err != nil {
+		return err
+	}
+	size, err := yamlNodeSize(doc, maxYAMLDocumentBytes)
+	if err != nil {
+		return err
+	}
+	if size > maxYAMLDocumentBytes {
+		return errors.Newf("zone config YAML exceeds the maximum size of %s",
+			humanizeutil.IBytes(maxYAMLDocumentBytes))
+	}
 	// Pre-initialize aux with the contents of c. This is important for
 	// maintaining the behavior of not overwriting existing fields unless the
 	// user provided new values for them.
 	aux := zoneConfigToMarshalable(*c)
-	if err := unmarshal(&aux); err != nil {
+	if strict {
+		if err := checkYAMLFields(doc, &aux); err != nil {
+			return err
+		}
+	}
+	if err := doc.Decode(&aux); err != nil {
 		return err
 	}
+	if len(aux.LeasePreferences) > maxYAMLLeasePreferences {
+		return yamlNodeErrorf(yamlMappingValue(doc, "lease_preferences"),
+			"at most %d lease preferences are allowed, found %d",
+			maxYAMLLeasePreferences, len(aux.LeasePreferences))
+	}
 	*c = zoneConfigFromMarshalable(aux, *c)
 	return nil
 }
@@ -0,0 +1,88 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// maxYAMLExpandedNodes is the maximum number of nodes a YAML document may have
+// once its aliases are expanded. Zone configs are orders of magnitude smaller,
+// even when they're written with anchors.
+const maxYAMLExpandedNodes = 100000
+
+// CheckYAMLAliases returns an error if a YAML document of the stream refers to
+// an anchor from within the anchored node, or would have more than
+// maxYAMLExpandedNodes nodes once its aliases are expanded.
+//
+// Anchors and aliases (e.g. "constraints: &east [+region=east]" and
+// "lease_preferences: [*east]") are supported when unmarshaling zone configs,
+// but the YAML decoder expands every alias, so a small document with nested
+// aliases (a "billion laughs" document) can expand to an arbitrary size. YAML
+// supplied by users must be checked before it's unmarshaled. The check itself
+// doesn't expand the aliases.
+func CheckYAMLAliases(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		sizes := make(map[*yaml.Node]int)
+		size, err := expandedYAMLSize(&doc, sizes, make(map[*yaml.Node]bool))
+		if err != nil {
+			return err
+		}
+		if size > maxYAMLExpandedNodes {
+			return errors.Newf("YAML document expands to more than %d nodes", maxYAMLExpandedNodes)
+		}
+	}
+}
+
+// expandedYAMLSize returns the number of nodes of the subtree rooted at n once
+// its aliases are expanded, capped at maxYAMLExpandedNodes+1. The sizes of the
+// visited subtrees are memoized, so that each node is only visited once.
+func expandedYAMLSize(
+	n *yaml.Node, sizes map[*yaml.Node]int, visiting map[*yaml.Node]bool,
+) (int, error) {
+	if size, ok := sizes[n]; ok {
+		return size, nil
+	}
+	if visiting[n] {
+		return 0, errors.Newf("YAML anchor %q contains an alias to itself", n.Anchor)
+	}
+	visiting[n] = true
+	size, children := 1, n.Content
+	if n.Kind == yaml.AliasNode {
+		size, children = 0, []*yaml.Node{n.Alias}
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		childSize, err := expandedYAMLSize(child, sizes, visiting)
+		if err != nil {
+			return 0, err
+		}
+		if size += childSize; size > maxYAMLExpandedNodes {
+			size = maxYAMLExpandedNodes + 1
+		}
+	}
+	delete(visiting, n)
+	sizes[n] = size
+	return size, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAMLWithInheritedFields returns the YAML encoding of the zone config,
+// in which the value of each field of inheritedFrom is followed by a comment
+// naming the target the field is inherited from, e.g.:
+//
+//	num_replicas: 3 # inherited from RANGE default
+//
+// Fields are named as in ALTER ... CONFIGURE ZONE, e.g. num_replicas or
+// gc.ttlseconds. The comments are ignored when the YAML is unmarshaled.
+func MarshalYAMLWithInheritedFields(c ZoneConfig, inheritedFrom map[string]string) ([]byte, error) {
+	node, err := YAMLNodeWithInheritedFields(c, inheritedFrom)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalYAML(node)
+}
+
+// YAMLNodeWithInheritedFields is like MarshalYAMLWithInheritedFields, but
+// returns the YAML encoding as a node, so that it can be embedded in a larger
+// document.
+func YAMLNodeWithInheritedFields(
+	c ZoneConfig, inheritedFrom map[string]string,
+) (*yaml.Node, error) {
+	var m yaml.Node
+	if err := m.Encode(c); err != nil {
+		return nil, err
+	}
+	if m.Kind != yaml.MappingNode {
+		return nil, errors.AssertionFailedf("unexpected YAML encoding of zone config: %v", m.Kind)
+	}
+	annotate := func(field string, value *yaml.Node) {
+		if target, ok := inheritedFrom[field]; ok {
+			value.LineComment = "inherited from " + target
+		}
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i], m.Content[i+1]
+		if key.Value != "gc" || value.Kind != yaml.MappingNode {
+			annotate(key.Value, value)
+			continue
+		}
+		// The GC policy is a nested mapping, whose fields are annotated
+		// individually.
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			annotate("gc."+value.Content[j].Value, value.Content[j+1])
+		}
+	}
+	return &m, nil
+}
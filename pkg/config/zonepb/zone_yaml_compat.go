@@ -0,0 +1,82 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// minMarshalForVersion is the oldest version which MarshalForVersion can
+	// target. v2.0 introduced per-replica constraints, which can't be
+	// expressed in older versions.
+	minMarshalForVersion = roachpb.Version{Major: 2, Minor: 0}
+	// leasePreferencesVersion is the version which renamed
+	// experimental_lease_preferences to lease_preferences.
+	leasePreferencesVersion = roachpb.Version{Major: 2, Minor: 1}
+	// namedConstraintSetsVersion is the version which introduced references
+	// to named constraint sets, e.g. constraints: '@us_east_ssd'.
+	namedConstraintSetsVersion = clusterversion.ByKey(clusterversion.V23_2Start)
+)
+
+// zoneConfigYAMLFieldVersions maps the top-level fields of the YAML encoding
+// of zone configs which were introduced after minMarshalForVersion to the
+// version which introduced them.
+var zoneConfigYAMLFieldVersions = map[string]roachpb.Version{
+	zoneConfigYAMLVersionKey: clusterversion.ByKey(clusterversion.V23_2Start),
+	"num_voters":             {Major: 21, Minor: 1},
+	"voter_constraints":      {Major: 21, Minor: 1},
+	"global_reads":           {Major: 21, Minor: 1},
+	"survival_goal":          clusterversion.ByKey(clusterversion.V23_2Start),
+	"primary_region":         clusterversion.ByKey(clusterversion.V23_2Start),
+	"secondary_region":       clusterversion.ByKey(clusterversion.V23_2Start),
+	"split_hints":            clusterversion.ByKey(clusterversion.V23_2Start),
+}
+
+// MarshalForVersion marshals the zone config to YAML which nodes running the
+// given cluster version can unmarshal, so that zone configs written by newer
+// nodes can be handed to older nodes of mixed-version clusters. Fields which
+// are unknown to the version are dropped. Named constraint sets are written as
+// the constraints they were last resolved to, and lease preferences are
+// written as experimental_lease_preferences for v2.0. The result may thus be
+// less restrictive than the zone config.
+//
+// Versions before v2.0 aren't supported.
+func (c ZoneConfig) MarshalForVersion(cv clusterversion.ClusterVersion) ([]byte, error) {
+	v := cv.Version
+	if v.Less(minMarshalForVersion) {
+		return nil, errors.Newf("cannot marshal zone configs for version %s, which predates %s",
+			v, minMarshalForVersion)
+	}
+	if v.Less(namedConstraintSetsVersion) {
+		c.ConstraintsSet, c.VoterConstraintsSet = "", ""
+	}
+	var doc yaml.Node
+	if err := doc.Encode(c); err != nil {
+		return nil, err
+	}
+	compatible := doc
+	compatible.Content = make([]*yaml.Node, 0, len(doc.Content))
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		if introduced, ok := zoneConfigYAMLFieldVersions[key.Value]; ok && v.Less(introduced) {
+			continue
+		}
+		if key.Value == "lease_preferences" && v.Less(leasePreferencesVersion) {
+			key.Value = "experimental_lease_preferences"
+		}
+		compatible.Content = append(compatible.Content, key, value)
+	}
+	return MarshalYAML(&compatible)
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalYAML is like yaml.Unmarshal(data, c), but decodes the common
+// documents directly, without the generic decoding of ZoneConfig.UnmarshalYAML,
+// which decodes every document twice through reflection so that it can be
+// migrated. This matters when many zone configs are parsed at once, e.g. when
+// restoring schemas with many per-table zone configs.
+//
+// The fast path handles documents with one field per line, such as those
+// produced by MarshalYAML, made up of integer, boolean and null values, gc
+// as {ttlseconds: N} or as a nested block, and the legacy list format of
+// constraints, voter constraints and lease preferences, whose constraints are
+// plain scalars, e.g.:
+//
+//	range_max_bytes: 536870912
+//	gc:
+//	  ttlseconds: 90000
+//	num_replicas: 3
+//	constraints: [+region=us-east1, +ssd]
+//	lease_preferences: [[+region=us-east1]]
+//
+// Any other document, including invalid documents, documents with comments,
+// quoted strings, aliases, per-replica constraints or a version other than
+// CurrentZoneConfigYAMLVersion, and documents which would fail to decode, is
+// handed to yaml.Unmarshal, so that the result and the errors are the same
+// either way. Like yaml.Unmarshal, it doesn't check the aliases of the
+// document (see CheckYAMLAliases).
+func UnmarshalYAML(data []byte, c *ZoneConfig) error {
+	if unmarshalYAMLFast(data, c) {
+		return nil
+	}
+	return yaml.Unmarshal(data, c)
+}
+
+// unmarshalYAMLFast decodes the document on top of c if it's supported by the
+// fast path of UnmarshalYAML, and returns whether it was. c is left unchanged
+// if it wasn't.
+//
+// Documents decoded by the fast path either are of
+// CurrentZoneConfigYAMLVersion, or don't have a version and only contain
+// fields of CurrentZoneConfigYAMLVersion, so they're unaffected by the
+// migrations of unversioned documents. Fields which are added by a new version
+// must not be decoded by the fast path until the migrations of older versions
+// are taken into account.
+func unmarshalYAMLFast(data []byte, c *ZoneConfig) bool {
+	// Once re-marshaled by ZoneConfig.UnmarshalYAML, the document grows by at
+	// most a few bytes per constraint and lease preference, so documents well
+	// within the limit on its size can't exceed it.
+	if int64(len(data)) > maxYAMLDocumentBytes/4 {
+		return false
+	}
+	z := *c
+	seen := make(map[string]bool, 8)
+	s := string(data)
+	for len(s) > 0 {
+		var line string
+		line, s = nextYAMLLine(s)
+		if strings.TrimRight(line, " ") == "" {
+			continue
+		}
+		if line[0] == ' ' {
+			return false
+		}
+		key, value, ok := splitYAMLKeyValue(line)
+		if !ok || seen[key] {
+			return false
+		}
+		seen[key] = true
+		switch key {
+		case "range_min_bytes", "range_max_bytes", "num_replicas", "num_voters", "global_reads", "gc":
+			if value == "null" {
+				// Null values leave the scalar fields unchanged.
+				continue
+			}
+		}
+		switch key {
+		case zoneConfigYAMLVersionKey:
+			v, ok := parseYAMLInt(value, 32)
+			if !ok || ZoneConfigYAMLVersion(v) != CurrentZoneConfigYAMLVersion {
+				return false
+			}
+		case "range_min_bytes", "range_max_bytes":
+			n, ok := parseYAMLInt(value, 64)
+			if !ok {
+				return false
+			}
+			if key == "range_min_bytes" {
+				z.RangeMinBytes = proto.Int64(n)
+			} else {
+				z.RangeMaxBytes = proto.Int64(n)
+			}
+		case "num_replicas", "num_voters":
+			n, ok := parseYAMLInt(value, 32)
+			if !ok {
+				return false
+			}
+			if key == "num_replicas" {
+				z.NumReplicas = proto.Int32(int32(n))
+			} else {
+				z.NumVoters = proto.Int32(int32(n))
+			}
+		case "global_reads":
+			switch value {
+			case "true":
+				z.GlobalReads = proto.Bool(true)
+			case "false":
+				z.GlobalReads = proto.Bool(false)
+			default:
+				return false
+			}
+		case "gc":
+			var ttlKey string
+			switch {
+			case value == "":
+				// The nested block form, as produced by MarshalYAML.
+				line, s = nextYAMLLine(s)
+				nested := strings.TrimLeft(line, " ")
+				if len(nested) == len(line) {
+					return false
+				}
+				ttlKey, value, ok = splitYAMLKeyValue(nested)
+			case value[0] == '{' && value[len(value)-1] == '}':
+				ttlKey, value, ok = splitYAMLKeyValue(strings.Trim(value[1:len(value)-1], " "))
+			default:
+				return false
+			}
+			if !ok || ttlKey != "ttlseconds" {
+				return false
+			}
+			ttl, ok := parseYAMLInt(value, 32)
+			if !ok {
+				return false
+			}
+			var gc GCPolicy
+			if z.GC != nil {
+				gc = *z.GC
+			}
+			gc.TTLSeconds = int32(ttl)
+			z.GC = &gc
+		case "constraints", "voter_constraints":
+			short, ok := parseYAMLFlowList(value)
+			if !ok {
+				return false
+			}
+			list, err := legacyConstraintsList(short)
+			if err != nil {
+				return false
+			}
+			if key == "constraints" {
+				z.Constraints = list.Constraints
+				z.InheritedConstraints = false
+				z.ConstraintsSet = ""
+			} else {
+				z.VoterConstraints = list.Constraints
+				z.NullVoterConstraintsIsEmpty = true
+				z.VoterConstraintsSet = ""
+			}
+		case "lease_preferences":
+			prefs, ok := parseYAMLLeasePreferences(value)
+			if !ok {
+				return false
+			}
+			z.LeasePreferences = prefs
+			z.InheritedLeasePreferences = false
+		default:
+			return false
+		}
+	}
+	if len(seen) == 0 {
+		// yaml.Unmarshal leaves c unchanged if the document is empty, but
+		// documents made of whitespace are rare enough not to bother.
+		return false
+	}
+	*c = z
+	return true
+}
+
+// nextYAMLLine splits the first line off of s.
+func nextYAMLLine(s string) (line, rest string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// splitYAMLKeyValue splits a line of the form "key: value" of the fast path of
+// UnmarshalYAML, whose key is made of lowercase letters and underscores. The
+// value is empty if there's nothing after the colon.
+func splitYAMLKeyValue(line string) (key, value string, ok bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	key, value = line[:i], line[i+1:]
+	for j := 0; j < len(key); j++ {
+		if (key[j] < 'a' || key[j] > 'z') && key[j] != '_' {
+			return "", "", false
+		}
+	}
+	if value != "" && value[0] != ' ' {
+		return "", "", false
+	}
+	value = strings.Trim(value, " ")
+	for j := 0; j < len(value); j++ {
+		// Comments, quotes, anchors, aliases, tags, block scalars and the like
+		// are left to the generic decoder, as are non-ASCII characters.
+		switch ch := value[j]; {
+		case ch < ' ' || ch > '~':
+			return "", "", false
+		case strings.IndexByte("#'\"&!|>%@`", ch) >= 0:
+			return "", "", false
+		}
+	}
+	return key, value, true
+}
+
+// parseYAMLInt parses an integer of the given bit size written in decimal,
+// without the leading zeros, signs and underscores which YAML would interpret
+// differently.
+func parseYAMLInt(s string, bitSize int) (int64, bool) {
+	if s == "" || (s[0] == '0' && len(s) > 1) || strings.HasPrefix(s, "-0") {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && !(i == 0 && s[i] == '-' && len(s) > 1) {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, bitSize)
+	return n, err == nil
+}
+
+// parseYAMLFlowList parses a flow sequence of constraints, e.g. [+a, -b]. The
+// result isn't nil, even if the sequence is empty.
+func parseYAMLFlowList(s string) ([]string, bool) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, false
+	}
+	s = strings.Trim(s[1:len(s)-1], " ")
+	if s == "" {
+		return []string{}, true
+	}
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.Trim(items[i], " ")
+		if !isPlainYAMLConstraint(items[i]) {
+			return nil, false
+		}
+	}
+	return items, true
+}
+
+// parseYAMLLeasePreferences parses a flow sequence of lease preferences, e.g.
+// [[+a, +b], [+c]].
+func parseYAMLLeasePreferences(s string) ([]LeasePreference, bool) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, false
+	}
+	s = strings.Trim(s[1:len(s)-1], " ")
+	prefs := []LeasePreference{}
+	for s != "" {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, false
+		}
+		short, ok := parseYAMLFlowList(s[:end+1])
+		if !ok {
+			return nil, false
+		}
+		constraints, err := parseShortConstraints(short)
+		if err != nil {
+			return nil, false
+		}
+		prefs = append(prefs, LeasePreference{Constraints: constraints})
+		if len(prefs) > maxYAMLLeasePreferences {
+			return nil, false
+		}
+		s = strings.TrimLeft(s[end+1:], " ")
+		if s != "" {
+			if s[0] != ',' {
+				return nil, false
+			}
+			if s = strings.TrimLeft(s[1:], " "); s == "" {
+				return nil, false
+			}
+		}
+	}
+	return prefs, true
+}
+
+// isPlainYAMLConstraint returns whether s is a constraint which YAML decodes
+// as the same string when it appears unquoted in a flow sequence. It's
+// conservative: it must start with a letter, optionally preceded by + or -, so
+// that it can't be a number or an alias, mustn't contain flow indicators,
+// colons or spaces, and mustn't be a boolean or null.
+func isPlainYAMLConstraint(s string) bool {
+	letters := s
+	if letters != "" && (letters[0] == '+' || letters[0] == '-') {
+		letters = letters[1:]
+	}
+	if letters == "" || !isASCIILetter(letters[0]) {
+		return false
+	}
+	for i := 1; i < len(letters); i++ {
+		ch := letters[i]
+		if !isASCIILetter(ch) && (ch < '0' || ch > '9') &&
+			strings.IndexByte("+-=_./*", ch) < 0 {
+			return false
+		}
+	}
+	for _, word := range []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null"} {
+		if strings.EqualFold(s, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIILetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// legacyReplicasKey is the top-level YAML field of the replica attributes of
+// zone configs before v1.0, which were replaced by num_replicas and
+// constraints.
+const legacyReplicasKey = "replicas"
+
+// legacyReplicaAttrs are the attributes of a replica in the replicas field of
+// zone configs before v1.0.
+type legacyReplicaAttrs struct {
+	Attrs []string `yaml:"attrs"`
+}
+
+// UnmarshalLegacyYAML unmarshals the YAML of a zone config on top of
+// NewZoneConfig(), like ALTER ... CONFIGURE ZONE, but also accepts the replica
+// attributes of zone configs before v1.0, as found in very old backups:
+//
+//	replicas:
+//	- attrs: [ssd]
+//	- attrs: [ssd]
+//	- attrs: [hdd]
+//
+// The number of replicas is the length of the list, and the attributes of the
+// replicas become required constraints: those of all of the replicas if they
+// have the same attributes, e.g. [+ssd], and per-replica constraints
+// otherwise, e.g. {+ssd: 2, +hdd: 1}. The replicas field can't be combined
+// with num_replicas or constraints. Since the replicas field is deprecated,
+// the conversion is reported to warn, if any, as a WarningDeprecatedField.
+// Documents without the replicas field are unmarshaled as is.
+func UnmarshalLegacyYAML(data []byte, warn func(Warning)) (ZoneConfig, error) {
+	if err := CheckYAMLAliases(data); err != nil {
+		return ZoneConfig{}, err
+	}
+	zone := NewZoneConfig()
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return ZoneConfig{}, err
+	}
+	if len(root.Content) == 0 || isYAMLNull(root.Content[0]) {
+		return *zone, nil
+	}
+	doc := resolveYAMLAlias(root.Content[0])
+	if doc.Kind != yaml.MappingNode {
+		return ZoneConfig{}, doc.Decode(zone)
+	}
+	doc = flattenYAMLMapping(doc)
+	if i := yamlMappingIndex(doc, legacyReplicasKey); i >= 0 {
+		replacement, err := convertLegacyReplicas(doc, i)
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, legacyReplicasKey)
+		}
+		if warn != nil {
+			converted, err := MarshalYAMLFlow(&yaml.Node{
+				Kind: yaml.MappingNode, Tag: "!!map", Content: replacement,
+			})
+			if err != nil {
+				return ZoneConfig{}, err
+			}
+			warn(Warning{
+				Code:  WarningDeprecatedField,
+				Field: legacyReplicasKey,
+				Message: fmt.Sprintf("%s is deprecated, use num_replicas and constraints; converted to %s",
+					legacyReplicasKey, converted),
+			})
+		}
+		doc.Content = append(append(doc.Content[:i:i], replacement...), doc.Content[i+2:]...)
+	}
+	if err := doc.Decode(zone); err != nil {
+		return ZoneConfig{}, err
+	}
+	return *zone, nil
+}
+
+// convertLegacyReplicas returns the keys and values of the num_replicas and
+// constraints fields which are equivalent to the replicas field at index i of
+// the content of the mapping node.
+func convertLegacyReplicas(doc *yaml.Node, i int) ([]*yaml.Node, error) {
+	for _, key := range []string{"num_replicas", "constraints"} {
+		if yamlMappingIndex(doc, key) >= 0 {
+			return nil, errors.Newf("can't be combined with %s", key)
+		}
+	}
+	value := doc.Content[i+1]
+	var replicas []legacyReplicaAttrs
+	if n := resolveYAMLAlias(value); n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			if err := checkYAMLFields(item, &legacyReplicaAttrs{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := value.Decode(&replicas); err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return nil, yamlNodeErrorf(value, "at least one replica is required")
+	}
+
+	// Group the replicas by their set of attributes, in the order in which the
+	// sets first appear.
+	var sets [][]string
+	setReplicas := make(map[string]int)
+	for _, r := range replicas {
+		var set []string
+		for _, attr := range r.Attrs {
+			if attr == "" {
+				return nil, yamlNodeErrorf(value, "attributes must not be empty")
+			}
+			set = append(set, Constraint{Type: Constraint_REQUIRED, Value: attr}.String())
+		}
+		sort.Strings(set)
+		key := strings.Join(set, ",")
+		if _, ok := setReplicas[key]; !ok {
+			sets = append(sets, set)
+		}
+		setReplicas[key]++
+	}
+
+	// The nodes are located at the replicas field, so that the errors of the
+	// converted fields point to it.
+	node := func(kind yaml.Kind, tag, v string) *yaml.Node {
+		return &yaml.Node{Kind: kind, Tag: tag, Value: v, Line: value.Line, Column: value.Column}
+	}
+	res := []*yaml.Node{
+		node(yaml.ScalarNode, "!!str", "num_replicas"),
+		node(yaml.ScalarNode, "!!int", strconv.Itoa(len(replicas))),
+	}
+	if len(sets) == 1 {
+		// All of the replicas have the same attributes.
+		if len(sets[0]) > 0 {
+			constraints := node(yaml.SequenceNode, "!!seq", "")
+			for _, c := range sets[0] {
+				constraints.Content = append(constraints.Content, node(yaml.ScalarNode, "!!str", c))
+			}
+			res = append(res, node(yaml.ScalarNode, "!!str", "constraints"), constraints)
+		}
+	} else {
+		// Replicas without attributes are left unconstrained.
+		constraints := node(yaml.MappingNode, "!!map", "")
+		for _, set := range sets {
+			if len(set) > 0 {
+				key := strings.Join(set, ",")
+				constraints.Content = append(constraints.Content,
+					node(yaml.ScalarNode, "!!str", key),
+					node(yaml.ScalarNode, "!!int", strconv.Itoa(setReplicas[key])))
+			}
+		}
+		res = append(res, node(yaml.ScalarNode, "!!str", "constraints"), constraints)
+	}
+	return res, nil
+}
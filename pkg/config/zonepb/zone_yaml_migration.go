@@ -0,0 +1,251 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ZoneConfigYAMLVersion identifies the encoding used by the YAML form of a
+// ZoneConfig. Documents may specify it explicitly through the top-level
+// `version` field; documents that don't are assumed to use
+// ZoneConfigYAMLVersionUnversioned.
+type ZoneConfigYAMLVersion int32
+
+const (
+	// ZoneConfigYAMLVersionUnversioned is the encoding that predates explicit
+	// versioning. It may contain fields from v2.0, such as
+	// experimental_lease_preferences.
+	ZoneConfigYAMLVersionUnversioned ZoneConfigYAMLVersion = iota
+	// ZoneConfigYAMLVersion1 is the first explicitly versioned encoding. It
+	// no longer accepts experimental_lease_preferences.
+	ZoneConfigYAMLVersion1
+
+	// CurrentZoneConfigYAMLVersion is the encoding understood by
+	// marshalableZoneConfig. Every document is migrated to this version before
+	// it is decoded.
+	CurrentZoneConfigYAMLVersion = ZoneConfigYAMLVersion1
+)
+
+// zoneConfigYAMLVersionKey is the name of the top-level YAML field which holds
+// the encoding version of a document.
+const zoneConfigYAMLVersionKey = "version"
+
+// ZoneConfigMigration upgrades the raw YAML form of a zone config by a single
+// encoding version. It is handed a copy of the top-level mapping node of the
+// document, with merge keys expanded and the version field already removed,
+// and upgrades it in place. The copy's content may be replaced, but the nodes
+// it contains are shared with the original document and must not be modified.
+type ZoneConfigMigration func(doc *yaml.Node) error
+
+// zoneConfigMigrations maps an encoding version to the migration which
+// upgrades documents from that version to the next one.
+var zoneConfigMigrations = map[ZoneConfigYAMLVersion]ZoneConfigMigration{}
+
+// RegisterZoneConfigMigration registers a migration which upgrades the YAML
+// form of zone configs from fromVersion to fromVersion+1. Migrations run at
+// unmarshal time, in version order, until the document reaches
+// CurrentZoneConfigYAMLVersion. Versions without a registered migration are
+// upgraded as-is. Packages other than zonepb register their migrations through
+// config.RegisterZoneConfigMigration.
+//
+// RegisterZoneConfigMigration is not thread safe and is meant to be called
+// from init functions.
+func RegisterZoneConfigMigration(fromVersion ZoneConfigYAMLVersion, fn ZoneConfigMigration) {
+	if fromVersion < ZoneConfigYAMLVersionUnversioned || fromVersion >= CurrentZoneConfigYAMLVersion {
+		panic(errors.AssertionFailedf(
+			"cannot register zone config migration from version %d; must be in [%d, %d)",
+			fromVersion, ZoneConfigYAMLVersionUnversioned, CurrentZoneConfigYAMLVersion))
+	}
+	if _, ok := zoneConfigMigrations[fromVersion]; ok {
+		panic(errors.AssertionFailedf(
+			"zone config migration from version %d already registered", fromVersion))
+	}
+	zoneConfigMigrations[fromVersion] = fn
+}
+
+// migrateZoneConfigYAML extracts the version of the supplied document and runs
+// all of the migrations needed to bring it up to CurrentZoneConfigYAMLVersion.
+// The returned mapping is a copy of the document which no longer contains a
+// version field nor merge keys. Nodes which aren't mappings are returned as
+// is, for the caller to report when decoding them.
+func migrateZoneConfigYAML(value *yaml.Node) (*yaml.Node, error) {
+	value = resolveYAMLAlias(value)
+	if value.Kind != yaml.MappingNode {
+		return value, nil
+	}
+	doc := flattenYAMLMapping(value)
+	version := ZoneConfigYAMLVersionUnversioned
+	if i := yamlMappingIndex(doc, zoneConfigYAMLVersionKey); i >= 0 {
+		n := resolveYAMLAlias(doc.Content[i+1])
+		var v int
+		if n.ShortTag() != "!!int" || n.Decode(&v) != nil {
+			return nil, yamlNodeErrorf(n, "invalid zone config version %s: must be an integer", n.Value)
+		}
+		version = ZoneConfigYAMLVersion(v)
+		if version < ZoneConfigYAMLVersionUnversioned || version > CurrentZoneConfigYAMLVersion {
+			return nil, yamlNodeErrorf(n,
+				"unsupported zone config version %d; this node supports up to version %d",
+				version, CurrentZoneConfigYAMLVersion)
+		}
+		doc.Content = append(doc.Content[:i:i], doc.Content[i+2:]...)
+	}
+	if err := runZoneConfigMigrations(doc, version); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// runZoneConfigMigrations runs the migrations which upgrade the supplied
+// mapping from the given version to CurrentZoneConfigYAMLVersion.
+func runZoneConfigMigrations(doc *yaml.Node, version ZoneConfigYAMLVersion) error {
+	for ; version < CurrentZoneConfigYAMLVersion; version++ {
+		fn, ok := zoneConfigMigrations[version]
+		if !ok {
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return errors.Wrapf(err, "migrating zone config from version %d", version)
+		}
+	}
+	return nil
+}
+
+// minZoneConfigYAMLVersion returns the oldest version under which the supplied
+// mapping, which uses CurrentZoneConfigYAMLVersion, decodes the same, i.e. the
+// oldest version from which the migrations leave it unchanged. Documents which
+// don't contain anything the migrations of older versions would rewrite, such
+// as experimental_lease_preferences, can thus be written without a version.
+func minZoneConfigYAMLVersion(doc *yaml.Node) ZoneConfigYAMLVersion {
+	for version := ZoneConfigYAMLVersionUnversioned; version < CurrentZoneConfigYAMLVersion; version++ {
+		// Migrations replace the content of the mapping rather than modifying
+		// the nodes it contains, so it's enough to compare the content.
+		migrated := *doc
+		migrated.Content = append([]*yaml.Node(nil), doc.Content...)
+		if err := runZoneConfigMigrations(&migrated, version); err != nil {
+			continue
+		}
+		if sameYAMLNodes(migrated.Content, doc.Content) {
+			return version
+		}
+	}
+	return CurrentZoneConfigYAMLVersion
+}
+
+// sameYAMLNodes returns whether the two slices hold the same nodes, in the
+// same order.
+func sameYAMLNodes(a, b []*yaml.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// flattenYAMLMapping returns a copy of the mapping node in which the merge
+// keys are replaced by the entries they merge in. As with yaml.Node.Decode,
+// the entries of the mapping take precedence over merged ones, and earlier
+// merged mappings take precedence over later ones.
+func flattenYAMLMapping(n *yaml.Node) *yaml.Node {
+	flat := *n
+	flat.Content = make([]*yaml.Node, 0, len(n.Content))
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if isYAMLMergeKey(n.Content[i]) {
+			merged = append(merged, n.Content[i+1])
+			continue
+		}
+		flat.Content = append(flat.Content, n.Content[i], n.Content[i+1])
+	}
+	var mergeFrom func(m *yaml.Node)
+	mergeFrom = func(m *yaml.Node) {
+		switch m = resolveYAMLAlias(m); m.Kind {
+		case yaml.SequenceNode:
+			for _, item := range m.Content {
+				mergeFrom(item)
+			}
+		case yaml.MappingNode:
+			m = flattenYAMLMapping(m)
+			for i := 0; i+1 < len(m.Content); i += 2 {
+				if yamlMappingIndex(&flat, resolveYAMLAlias(m.Content[i]).Value) < 0 {
+					flat.Content = append(flat.Content, m.Content[i], m.Content[i+1])
+				}
+			}
+		}
+	}
+	for _, m := range merged {
+		mergeFrom(m)
+	}
+	return &flat
+}
+
+// isYAMLMergeKey returns whether the node is the "<<" key, which merges
+// mappings into the mapping it's part of.
+func isYAMLMergeKey(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!merge"
+}
+
+// yamlMappingIndex returns the index of the key with the given value in the
+// content of the supplied mapping node, or -1 if there is no such key. The
+// value of the entry is at the following index.
+func yamlMappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if resolveYAMLAlias(m.Content[i]).Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlMappingValue returns the value of the entry with the given key in the
+// supplied mapping node, or the mapping itself if there is no such entry.
+func yamlMappingValue(m *yaml.Node, key string) *yaml.Node {
+	if i := yamlMappingIndex(m, key); i >= 0 {
+		return m.Content[i+1]
+	}
+	return m
+}
+
+// migrateExperimentalLeasePreferences upgrades unversioned documents by
+// renaming experimental_lease_preferences, which was accepted in v2.0, to
+// lease_preferences. A provided experimental_lease_preferences value takes
+// precedence over lease_preferences, since it can only come from user input,
+// whereas lease_preferences could be the old value of the field retrieved from
+// internal storage that the user is now trying to overwrite.
+//
+// TODO(a-robinson,v2.2): Remove the experimental_lease_preferences field.
+func migrateExperimentalLeasePreferences(doc *yaml.Node) error {
+	i := yamlMappingIndex(doc, "experimental_lease_preferences")
+	if i < 0 {
+		return nil
+	}
+	key, prefs := doc.Content[i], doc.Content[i+1]
+	doc.Content = append(doc.Content[:i:i], doc.Content[i+2:]...)
+	if j := yamlMappingIndex(doc, "lease_preferences"); j >= 0 {
+		doc.Content[j+1] = prefs
+	} else {
+		renamed := &yaml.Node{
+			Kind: yaml.ScalarNode, Tag: "!!str", Value: "lease_preferences",
+			Line: key.Line, Column: key.Column,
+		}
+		doc.Content = append(doc.Content, renamed, prefs)
+	}
+	return nil
+}
+
+func init() {
+	RegisterZoneConfigMigration(ZoneConfigYAMLVersionUnversioned, migrateExperimentalLeasePreferences)
+}
@@ -0,0 +1,300 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationSeverity is the severity of a ValidationFinding.
+type ValidationSeverity int
+
+const (
+	// ValidationError is the severity of findings which prevent the document
+	// from being applied.
+	ValidationError ValidationSeverity = iota + 1
+	// ValidationWarning is the severity of findings about documents which can
+	// be applied, but likely not as intended.
+	ValidationWarning
+)
+
+// String implements the fmt.Stringer interface.
+func (s ValidationSeverity) String() string {
+	switch s {
+	case ValidationError:
+		return "error"
+	case ValidationWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("ValidationSeverity(%d)", int(s))
+	}
+}
+
+// ValidationFinding is a finding of ValidateYAML about the YAML of a zone
+// config.
+type ValidationFinding struct {
+	Severity ValidationSeverity
+	// Field is the top-level YAML field which the finding is about, or empty if
+	// it's about the document as a whole.
+	Field string
+	// Line and Column locate the finding in the document, starting at 1. They
+	// are 0 if the finding can't be located.
+	Line, Column int
+	// Message is the human-readable finding.
+	Message string
+}
+
+// String implements the fmt.Stringer interface.
+func (f ValidationFinding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", f.Line, f.Column, f.Severity, f.Message)
+}
+
+// ValidationReport is the result of ValidateYAML.
+type ValidationReport struct {
+	// Findings are ordered by their position in the document, followed by the
+	// findings which can't be located.
+	Findings []ValidationFinding
+}
+
+// HasErrors returns whether any of the findings is an error, in which case
+// the document can't be applied.
+func (r ValidationReport) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == ValidationError {
+			return true
+		}
+	}
+	return false
+}
+
+// lintRuleYAMLFields maps the rules of LintZoneConfig to the top-level YAML
+// field their findings are about. LintEvenReplicaCount is about num_voters if
+// it's set, and about num_replicas otherwise.
+var lintRuleYAMLFields = map[LintRule]string{
+	LintEvenReplicaCount:              "num_replicas",
+	LintShortGCTTL:                    "gc",
+	LintContradictoryLeasePreferences: "lease_preferences",
+	LintUnsatisfiableLeasePreferences: "lease_preferences",
+}
+
+// ValidateYAML checks the YAML of a zone config, as supplied to ALTER ...
+// CONFIGURE ZONE, without applying it. It reports unknown and duplicate
+// fields, deprecated fields, constraints and lease preferences which can't be
+// parsed, references to undefined named constraint sets, values which the
+// zone config fails to validate with, and values which LintZoneConfig finds
+// suspicious. The findings are located through the positions of the nodes of
+// the document where possible.
+//
+// An error is only returned if the document isn't valid YAML, or if its
+// aliases expand to too large a document (see CheckYAMLAliases). The document
+// is checked on its own, without being hydrated from its parents, so findings
+// which depend on the parents, such as per-replica constraints which exceed
+// an inherited num_replicas, aren't reported.
+func ValidateYAML(data []byte) (ValidationReport, error) {
+	if err := CheckYAMLAliases(data); err != nil {
+		return ValidationReport{}, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ValidationReport{}, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].ShortTag() == "!!null" {
+		// Empty documents leave the zone config unchanged.
+		return ValidationReport{}, nil
+	}
+
+	var v yamlValidator
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.errorf(root, "", "zone config must be a mapping of fields to values")
+		return v.report(), nil
+	}
+	keys := make(map[string]*yaml.Node, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		field := key.Value
+		if key.Kind != yaml.ScalarNode {
+			v.errorf(key, "", "field names must be scalars")
+			continue
+		}
+		if _, ok := keys[field]; ok {
+			v.errorf(key, field, "field %q is specified more than once", field)
+			continue
+		}
+		keys[field] = key
+		if replacement, ok := deprecatedZoneConfigYAMLFields[field]; ok {
+			v.warnf(key, field, "%s is deprecated, use %s", field, replacement)
+		} else if !isZoneConfigYAMLField(field) {
+			v.errorf(key, field, "unknown field %q", field)
+			continue
+		}
+		switch field {
+		case "constraints", "voter_constraints":
+			v.checkConstraintsList(field, value)
+		case "lease_preferences", "experimental_lease_preferences":
+			if value.Kind == yaml.SequenceNode {
+				for _, pref := range value.Content {
+					v.checkConstraintSequence(field, pref)
+				}
+			}
+		}
+	}
+
+	zone := NewZoneConfig()
+	if err := yaml.Unmarshal(data, zone); err != nil {
+		// The errors of the fields were already reported, and are likely the
+		// reason the document can't be decoded.
+		if !v.hasErrors() {
+			v.errorf(nil, "", "%v", err)
+		}
+		return v.report(), nil
+	}
+	if err := zone.Validate(); err != nil {
+		v.errorf(nil, "", "%v", err)
+	}
+	if err := zone.ValidateTandemFields(); err != nil {
+		v.errorf(nil, "", "%v", err)
+	}
+	for _, f := range LintZoneConfig(*zone) {
+		field := lintRuleYAMLFields[f.Rule]
+		if f.Rule == LintEvenReplicaCount && zone.NumVoters != nil && *zone.NumVoters > 0 {
+			field = "num_voters"
+		}
+		key := keys[field]
+		if key == nil {
+			// The field may be set through its deprecated name.
+			for deprecated, replacement := range deprecatedZoneConfigYAMLFields {
+				if replacement == field && keys[deprecated] != nil {
+					key = keys[deprecated]
+				}
+			}
+		}
+		v.warnf(key, field, "%s", f.Message)
+	}
+	return v.report(), nil
+}
+
+// isZoneConfigYAMLField returns whether field is a top-level field of the
+// YAML encoding of zone configs, other than the deprecated ones.
+func isZoneConfigYAMLField(field string) bool {
+	if field == zoneConfigYAMLVersionKey {
+		return true
+	}
+	for _, f := range formatFields {
+		if f.key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlValidator accumulates the findings of ValidateYAML.
+type yamlValidator struct {
+	findings []ValidationFinding
+}
+
+// errorf adds an error about the field at the position of the given node,
+// which may be nil if the finding can't be located.
+func (v *yamlValidator) errorf(node *yaml.Node, field, format string, args ...interface{}) {
+	v.add(ValidationError, node, field, fmt.Sprintf(format, args...))
+}
+
+// warnf is like errorf, but adds a warning.
+func (v *yamlValidator) warnf(node *yaml.Node, field, format string, args ...interface{}) {
+	v.add(ValidationWarning, node, field, fmt.Sprintf(format, args...))
+}
+
+func (v *yamlValidator) add(
+	severity ValidationSeverity, node *yaml.Node, field, message string,
+) {
+	f := ValidationFinding{Severity: severity, Field: field, Message: message}
+	if node != nil {
+		f.Line, f.Column = node.Line, node.Column
+	}
+	v.findings = append(v.findings, f)
+}
+
+func (v *yamlValidator) hasErrors() bool {
+	return ValidationReport{Findings: v.findings}.HasErrors()
+}
+
+// report returns the findings, ordered by position.
+func (v *yamlValidator) report() ValidationReport {
+	sort.SliceStable(v.findings, func(i, j int) bool {
+		a, b := v.findings[i], v.findings[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return ValidationReport{Findings: v.findings}
+}
+
+// checkConstraintsList checks the constraints of a field in one of the
+// formats of ConstraintsList: a reference to a named constraint set, a list of
+// constraints, or a map of comma-separated constraints to per-replica counts.
+// The counts are checked when the document is decoded.
+func (v *yamlValidator) checkConstraintsList(field string, value *yaml.Node) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.ShortTag() != "!!str" || !strings.HasPrefix(value.Value, namedConstraintSetPrefix) {
+			return
+		}
+		if _, err := lookupNamedConstraintSet(
+			strings.TrimPrefix(value.Value, namedConstraintSetPrefix),
+		); err != nil {
+			v.errorf(value, field, "%v", err)
+		}
+	case yaml.SequenceNode:
+		v.checkConstraintSequence(field, value)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key := value.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				continue
+			}
+			for _, c := range splitEscapedConstraints(key.Value, ',') {
+				v.checkConstraint(field, key, c)
+			}
+		}
+	}
+}
+
+// checkConstraintSequence checks a sequence of constraints, such as the
+// legacy format of constraints or a lease preference.
+func (v *yamlValidator) checkConstraintSequence(field string, value *yaml.Node) {
+	if value.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range value.Content {
+		if item.Kind == yaml.ScalarNode && item.ShortTag() == "!!str" {
+			v.checkConstraint(field, item, item.Value)
+		}
+	}
+}
+
+// checkConstraint reports an error at the position of the node if the
+// constraint can't be parsed.
+func (v *yamlValidator) checkConstraint(field string, node *yaml.Node, constraint string) {
+	if _, err := parseShortConstraints([]string{constraint}); err != nil {
+		v.errorf(node, field, "%v", err)
+	}
+}
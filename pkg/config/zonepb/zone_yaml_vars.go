@@ -0,0 +1,167 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalYAMLWithVars unmarshals the YAML of a zone config on top of
+// NewZoneConfig(), after substituting the ${var} placeholders of its
+// constraints, voter constraints, lease preferences and replica counts with
+// the values of the given variables, e.g.:
+//
+//	num_replicas: ${replicas}
+//	constraints: {'+region=${primary}': 2, '+region=${secondary}': 1}
+//	lease_preferences: [['+region=${primary}']]
+//
+// Note that the braces of placeholders can't appear unquoted in the YAML flow
+// notation.
+// The substitution is applied to the decoded document rather than to its text,
+// so the values can't change its structure. Values substituted in constraints
+// are escaped (see Constraint.String), so they may contain any character, and
+// replica counts with placeholders must be integers once they're substituted.
+// Placeholders of undefined variables are rejected, and those of other fields
+// are left as is.
+func UnmarshalYAMLWithVars(data []byte, vars map[string]string) (ZoneConfig, error) {
+	if err := CheckYAMLAliases(data); err != nil {
+		return ZoneConfig{}, err
+	}
+	zone := NewZoneConfig()
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return ZoneConfig{}, err
+	}
+	if len(root.Content) == 0 || isYAMLNull(root.Content[0]) {
+		return *zone, nil
+	}
+	doc := resolveYAMLAlias(root.Content[0])
+	if doc.Kind == yaml.MappingNode {
+		doc = flattenYAMLMapping(doc)
+	}
+	for i := 0; doc.Kind == yaml.MappingNode && i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i].Value, resolveYAMLAlias(doc.Content[i+1])
+		var err error
+		switch key {
+		case "num_replicas", "num_voters":
+			err = substituteYAMLCount(value, vars)
+		case "constraints", "voter_constraints":
+			err = substituteYAMLConstraints(value, vars)
+		case "lease_preferences":
+			if value.Kind == yaml.SequenceNode {
+				for _, pref := range value.Content {
+					if err = substituteYAMLConstraints(resolveYAMLAlias(pref), vars); err != nil {
+						break
+					}
+				}
+			}
+		}
+		if err != nil {
+			return ZoneConfig{}, errors.Wrap(err, key)
+		}
+	}
+	if err := doc.Decode(zone); err != nil {
+		return ZoneConfig{}, err
+	}
+	return *zone, nil
+}
+
+// isYAMLString returns whether the node is a string scalar.
+func isYAMLString(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!str"
+}
+
+// substituteYAMLConstraints substitutes the placeholders of a list of
+// constraints, or of the keys and counts of per-replica constraints, in place.
+func substituteYAMLConstraints(n *yaml.Node, vars map[string]string) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if err := substituteYAMLConstraint(resolveYAMLAlias(item), vars); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if err := substituteYAMLConstraint(resolveYAMLAlias(n.Content[i]), vars); err != nil {
+				return err
+			}
+			if err := substituteYAMLCount(resolveYAMLAlias(n.Content[i+1]), vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// substituteYAMLConstraint substitutes the placeholders of a string scalar
+// holding constraints, in place.
+func substituteYAMLConstraint(n *yaml.Node, vars map[string]string) error {
+	if !isYAMLString(n) {
+		return nil
+	}
+	substituted, err := substituteZoneConfigVars(n.Value, vars, escapeConstraintPart)
+	if err != nil {
+		return yamlNodeError(n, err)
+	}
+	n.Value = substituted
+	return nil
+}
+
+// substituteYAMLCount substitutes the placeholders of a replica count in
+// place. The count must then be an integer.
+func substituteYAMLCount(n *yaml.Node, vars map[string]string) error {
+	if !isYAMLString(n) || !strings.Contains(n.Value, "${") {
+		return nil
+	}
+	substituted, err := substituteZoneConfigVars(n.Value, vars, func(s string) string { return s })
+	if err != nil {
+		return yamlNodeError(n, err)
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(substituted), 10, 32)
+	if err != nil {
+		return yamlNodeErrorf(n, "replica count %q is not an integer once substituted: %q",
+			n.Value, substituted)
+	}
+	n.Kind, n.Tag, n.Style, n.Value = yaml.ScalarNode, "!!int", 0, strconv.FormatInt(v, 10)
+	return nil
+}
+
+// substituteZoneConfigVars replaces the ${var} placeholders of s with the
+// escaped values of the variables.
+func substituteZoneConfigVars(
+	s string, vars map[string]string, escape func(string) string,
+) (string, error) {
+	var sb strings.Builder
+	for rest := s; ; {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			sb.WriteString(rest)
+			return sb.String(), nil
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return "", errors.Newf("unterminated placeholder in %q", s)
+		}
+		name := rest[i+2 : i+j]
+		value, ok := vars[name]
+		if !ok {
+			return "", errors.Newf("undefined variable %q in %q", name, s)
+		}
+		sb.WriteString(rest[:i])
+		sb.WriteString(escape(value))
+		rest = rest[i+j+1:]
+	}
+}
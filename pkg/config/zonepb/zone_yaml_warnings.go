@@ -0,0 +1,87 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepb
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WarningCode identifies the kind of a Warning.
+type WarningCode int
+
+const (
+	// WarningDeprecatedField is the code of warnings about deprecated fields,
+	// which are converted to their replacement.
+	WarningDeprecatedField WarningCode = iota + 1
+)
+
+// Warning is a machine-readable warning about legacy syntax which was accepted
+// when unmarshaling the YAML of a zone config.
+type Warning struct {
+	Code WarningCode
+	// Field is the top-level YAML field which the warning is about.
+	Field string
+	// Message is the human-readable warning.
+	Message string
+}
+
+// String implements the fmt.Stringer interface.
+func (w Warning) String() string {
+	return w.Message
+}
+
+// deprecatedZoneConfigYAMLFields maps the deprecated top-level fields of
+// unversioned documents, which are migrated by migrateZoneConfigYAML, to their
+// replacement.
+var deprecatedZoneConfigYAMLFields = map[string]string{
+	"experimental_lease_preferences": "lease_preferences",
+}
+
+// UnmarshalYAMLWithWarnings is like yaml.Unmarshal(data, c), but also returns
+// warnings about the legacy syntax which the document uses, such as
+// experimental_lease_preferences, so that they can be surfaced to users rather
+// than silently accepted. Like yaml.Unmarshal, it doesn't check the
+// aliases of the document (see CheckYAMLAliases).
+func UnmarshalYAMLWithWarnings(data []byte, c *ZoneConfig) ([]Warning, error) {
+	if unmarshalYAMLFast(data, c) {
+		// The fast path of UnmarshalYAML doesn't accept legacy syntax.
+		return nil, nil
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	if err := root.Content[0].Decode(c); err != nil {
+		return nil, err
+	}
+	doc := resolveYAMLAlias(root.Content[0])
+	if doc.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var warnings []Warning
+	doc = flattenYAMLMapping(doc)
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		field := doc.Content[i].Value
+		if replacement, ok := deprecatedZoneConfigYAMLFields[field]; ok {
+			warnings = append(warnings, Warning{
+				Code:    WarningDeprecatedField,
+				Field:   field,
+				Message: fmt.Sprintf("%s is deprecated, use %s", field, replacement),
+			})
+		}
+	}
+	return warnings, nil
+}
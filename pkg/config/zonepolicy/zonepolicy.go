@@ -0,0 +1,91 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package zonepolicy is the stable interface to zone configs for tools built
+// outside of CockroachDB. It exposes the zone config types along with the
+// operations tools need (parsing, validating, diffing and marshaling) so that
+// they needn't depend on the internals of pkg/config, which change freely
+// between releases.
+//
+// The package is versioned through APIVersion. Within a version, exported
+// identifiers are neither removed nor changed incompatibly.
+package zonepolicy
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"gopkg.in/yaml.v3"
+)
+
+// APIVersion is the version of the interface exposed by this package. It is
+// bumped whenever an exported identifier is removed or changed incompatibly.
+const APIVersion = 1
+
+// ZoneConfig is a zone config. Its fields mirror those of the zone config
+// protobuf, whose encoding is itself kept backwards compatible.
+type ZoneConfig = zonepb.ZoneConfig
+
+// Constraint constrains the stores a replica can be placed on.
+type Constraint = zonepb.Constraint
+
+// ConstraintsConjunction is a set of constraints which must all be satisfied
+// by the stores of a number of replicas.
+type ConstraintsConjunction = zonepb.ConstraintsConjunction
+
+// LeasePreference is a set of constraints which a leaseholder should satisfy.
+type LeasePreference = zonepb.LeasePreference
+
+// GCPolicy is the garbage collection policy of a zone.
+type GCPolicy = zonepb.GCPolicy
+
+// Default returns the zone config which applies when no zone config has been
+// set.
+func Default() ZoneConfig {
+	return zonepb.DefaultZoneConfig()
+}
+
+// Parse parses the YAML form of a zone config, as accepted by ALTER ...
+// CONFIGURE ZONE, on top of base. Fields which aren't set in the document
+// retain their value from base. Use Default() as the base to fill in unset
+// fields with defaults, or an empty ZoneConfig to leave them unset.
+func Parse(base ZoneConfig, data []byte) (ZoneConfig, error) {
+	if err := zonepb.CheckYAMLAliases(data); err != nil {
+		return ZoneConfig{}, err
+	}
+	if err := yaml.Unmarshal(data, &base); err != nil {
+		return ZoneConfig{}, err
+	}
+	return base, nil
+}
+
+// Marshal returns the YAML form of the zone config.
+func Marshal(zone ZoneConfig) ([]byte, error) {
+	return zonepb.MarshalYAML(zone)
+}
+
+// Validate returns an error if the zone config is invalid or specifies a
+// known-dangerous configuration.
+func Validate(zone ZoneConfig) error {
+	if err := zone.ValidateTandemFields(); err != nil {
+		return err
+	}
+	return zone.Validate()
+}
+
+// Diff returns the names of the fields which differ between the two zone
+// configs, as used by ALTER ... CONFIGURE ZONE (e.g. num_replicas or
+// gc.ttlseconds).
+func Diff(a, b ZoneConfig) []string {
+	changed := zonepb.PlanZoneChange(a, b, nil /* topology */).ChangedFields
+	res := make([]string, len(changed))
+	for i, name := range changed {
+		res[i] = string(name)
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package zonepolicy_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepolicy"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZonePolicy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	base := zonepolicy.Default()
+	zone, err := zonepolicy.Parse(base, []byte(`
+num_replicas: 5
+constraints: {+region=a: 2, +region=b: 2}
+lease_preferences: [[+region=a]]
+`))
+	require.NoError(t, err)
+	require.NoError(t, zonepolicy.Validate(zone))
+	require.Equal(t, []string{"num_replicas", "constraints", "lease_preferences"},
+		zonepolicy.Diff(base, zone))
+	require.Empty(t, zonepolicy.Diff(zone, zone))
+
+	out, err := zonepolicy.Marshal(zone)
+	require.NoError(t, err)
+	roundTripped, err := zonepolicy.Parse(zonepolicy.ZoneConfig{}, out)
+	require.NoError(t, err)
+	require.Empty(t, zonepolicy.Diff(zone, roundTripped))
+
+	_, err = zonepolicy.Parse(base, []byte("num_replicas: five"))
+	require.Error(t, err)
+	invalid, err := zonepolicy.Parse(base, []byte("num_replicas: 2"))
+	require.NoError(t, err)
+	require.Error(t, zonepolicy.Validate(invalid))
+}
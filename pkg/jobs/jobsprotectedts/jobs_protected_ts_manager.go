@@ -126,7 +126,7 @@ func (p *Manager) TryToProtectBeforeGC(
 		if err != nil {
 			return err
 		}
-		waitBeforeProtectedTS := time.Duration((time.Duration(zoneCfg.GC.TTLSeconds) * time.Second).Seconds() *
+		waitBeforeProtectedTS := time.Duration((time.Duration(zoneCfg.GCTTLSeconds()) * time.Second).Seconds() *
 			timedProtectTimeStampGCPct)
 
 		select {
@@ -51,7 +51,6 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/require"
-	yaml "gopkg.in/yaml.v2"
 )
 
 func TestConformanceReport(t *testing.T) {
@@ -472,7 +471,7 @@ func (z zone) toZoneConfig() zonepb.ZoneConfig {
 	}
 	if z.constraints != "" {
 		var constraintsList zonepb.ConstraintsList
-		if err := yaml.UnmarshalStrict([]byte(z.constraints), &constraintsList); err != nil {
+		if err := zonepb.UnmarshalYAMLStrict([]byte(z.constraints), &constraintsList); err != nil {
 			panic(err)
 		}
 		cfg.Constraints = constraintsList.Constraints
@@ -480,7 +479,7 @@ func (z zone) toZoneConfig() zonepb.ZoneConfig {
 	}
 	if z.voterConstraints != "" {
 		var constraintsList zonepb.ConstraintsList
-		if err := yaml.UnmarshalStrict([]byte(z.voterConstraints), &constraintsList); err != nil {
+		if err := zonepb.UnmarshalYAMLStrict([]byte(z.voterConstraints), &constraintsList); err != nil {
 			panic(err)
 		}
 		cfg.VoterConstraints = constraintsList.Constraints
@@ -28,9 +28,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
-	"github.com/cockroachdb/cockroach/pkg/sql/catalog"
-	"github.com/cockroachdb/cockroach/pkg/sql/catalog/catalogkeys"
-	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descbuilder"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
@@ -447,36 +444,24 @@ func visitAncestors(
 	cfg *config.SystemConfig,
 	visitor func(context.Context, *zonepb.ZoneConfig, ZoneKey) bool,
 ) (bool, error) {
-	// This is a bug: see https://github.com/cockroachdb/cockroach/issues/48123.
-	var FIXMEIDONTKNOWWHICHCODECTOUSE = keys.MakeSQLCodec(roachpb.SystemTenantID)
-
 	// Check to see if it's a table. If so, inherit from the database.
 	// For all other cases, inherit from the default.
-	descVal := cfg.GetValue(catalogkeys.MakeDescMetadataKey(FIXMEIDONTKNOWWHICHCODECTOUSE, descpb.ID(id)))
-	if descVal == nil {
-		// Couldn't find a descriptor. This is not expected to happen.
-		// Let's just look at the default zone config.
-		return visitDefaultZone(ctx, cfg, visitor), nil
-	}
-
-	// TODO(ajwerner): Reconsider how this zone config picking apart happens. This
-	// isn't how we want to be retreiving table descriptors in general.
-	b, err := descbuilder.FromSerializedValue(descVal)
+	desc, ok, err := cfg.GetDescriptorInfo(id)
 	if err != nil {
 		return false, err
 	}
-	// If it's a database, the parent is the default zone.
-	if b == nil || b.DescriptorType() != catalog.Table {
+	// If it's a database, or the descriptor couldn't be found, which is not
+	// expected to happen, the parent is the default zone.
+	if !ok || desc.Kind != config.DescriptorKindTable {
 		return visitDefaultZone(ctx, cfg, visitor), nil
 	}
-	tableDesc := b.BuildImmutable()
 	// If it's a table, the parent is a database.
-	zone, err := getZoneByID(config.ObjectID(tableDesc.GetParentID()), cfg)
+	zone, err := getZoneByID(desc.ParentID, cfg)
 	if err != nil {
 		return false, err
 	}
 	if zone != nil {
-		if visitor(ctx, zone, MakeZoneKey(config.ObjectID(tableDesc.GetParentID()), NoSubzone)) {
+		if visitor(ctx, zone, MakeZoneKey(desc.ParentID, NoSubzone)) {
 			return true, nil
 		}
 	}
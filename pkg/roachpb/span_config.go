@@ -12,17 +12,23 @@ package roachpb
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
 )
 
-// StoreMatchesConstraint returns whether a store's attributes or node's
+// storeConstraintKey is the key of the constraints which match a single store
+// by its ID, e.g. +store=5. See zonepb.StoreConstraintKey.
+const storeConstraintKey = "store"
+
+// StoreMatchesConstraint returns whether a store's attributes, ID or node's
 // locality match the constraint's spec. It notably ignores whether the
 // constraint is required, prohibited, positive, or otherwise.
 func StoreMatchesConstraint(store StoreDescriptor, c Constraint) bool {
-	if c.Key == "" {
+	switch c.Key {
+	case "":
 		for _, attrs := range []Attributes{store.Attrs, store.Node.Attrs} {
 			for _, attr := range attrs.Attrs {
 				if attr == c.Value {
@@ -31,15 +37,43 @@ func StoreMatchesConstraint(store StoreDescriptor, c Constraint) bool {
 			}
 		}
 		return false
+	case storeConstraintKey:
+		id, err := strconv.ParseInt(c.Value, 10, 32)
+		return err == nil && StoreID(id) == store.StoreID
 	}
 	for _, tier := range store.Node.Locality.Tiers {
-		if c.Key == tier.Key && c.Value == tier.Value {
+		if c.Key == tier.Key && LocalityValueMatches(c.Value, tier.Value) {
 			return true
 		}
 	}
 	return false
 }
 
+// LocalityValueMatches returns whether the value of a locality tier matches the
+// value of a locality constraint, which may contain '*' wildcards matching any
+// sequence of characters. For example, "us-*" matches "us-east1". A value
+// without wildcards only matches itself.
+func LocalityValueMatches(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	// Match the parts between wildcards as early as possible, which leaves as
+	// much of the value as possible to the last part, which must be a suffix.
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
 var emptySpanConfig = &SpanConfig{}
 
 // IsEmpty returns true if s is an empty SpanConfig.
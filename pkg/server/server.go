@@ -691,6 +691,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 
 		scKVAccessor := spanconfigkvaccessor.New(
 			db, internalExecutor, cfg.Settings, clock,
+			spanconfigstore.NewBoundsReader(tenantCapabilitiesWatcher),
 			systemschema.SpanConfigurationsTableName.FQString(),
 			spanConfigKnobs,
 		)
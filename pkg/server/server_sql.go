@@ -24,6 +24,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cloud"
 	"github.com/cockroachdb/cockroach/pkg/cloud/externalconn"
 	"github.com/cockroachdb/cockroach/pkg/clusterversion"
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/featureflag"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/jobs"
@@ -943,6 +944,8 @@ func newSQLServer(ctx context.Context, cfg sqlServerArgs) (*SQLServer, error) {
 		&contentionMetrics,
 	)
 
+	zoneConfigMetrics := config.NewZoneConfigMetrics(cfg.registry)
+
 	storageEngineClient := kvserver.NewStorageEngineClient(cfg.nodeDialer)
 	*execCfg = sql.ExecutorConfig{
 		Settings:                  cfg.Settings,
@@ -955,6 +958,7 @@ func newSQLServer(ctx context.Context, cfg sqlServerArgs) (*SQLServer, error) {
 		Gossip:                    cfg.gossip,
 		NodeLiveness:              cfg.nodeLiveness,
 		SystemConfig:              cfg.systemConfigWatcher,
+		ZoneConfigMetrics:         zoneConfigMetrics,
 		MetricsRecorder:           cfg.recorder,
 		DistSender:                cfg.distSender,
 		RPCContext:                cfg.rpcContext,
@@ -1591,6 +1595,12 @@ func (s *SQLServer) preStart(
 	if err := s.systemConfigWatcher.Start(ctx, s.stopper); err != nil {
 		return errors.Wrap(err, "initializing system config watcher")
 	}
+	if err := s.execCfg.ZoneConfigMetrics.Start(ctx, s.stopper, s.systemConfigWatcher); err != nil {
+		return errors.Wrap(err, "starting zone config metrics")
+	}
+	if s.execCfg.Codec.ForSystemTenant() {
+		config.WatchNamedConstraintSets(ctx, &s.cfg.Settings.SV)
+	}
 
 	clusterVersionMetrics := clusterversion.MakeMetricsAndRegisterOnVersionChangeCallback(&s.cfg.Settings.SV)
 	s.metricsRegistry.AddMetricStruct(clusterVersionMetrics)
@@ -13,6 +13,7 @@ package systemconfigwatcher
 import (
 	"context"
 	"sort"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
@@ -22,9 +23,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed/rangefeedcache"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/envutil"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 )
 
 // Cache caches a set of KVs in a set of spans using a rangefeed. The
@@ -32,9 +36,27 @@ import (
 // may be stale.
 type Cache struct {
 	w                   *rangefeedcache.Watcher
+	codec               keys.SQLCodec
 	defaultZoneConfig   *zonepb.ZoneConfig
 	additionalKVsSource config.SystemConfigProvider
-	mu                  struct {
+	stopper             *stop.Stopper
+
+	// zoneChangeMu serializes updates to the cached config so that zone change
+	// notifications, which are queued without holding mu, are queued in
+	// snapshot order. The callbacks themselves run asynchronously.
+	zoneChangeMu syncutil.Mutex
+
+	// slowUpdate is protected by zoneChangeMu.
+	slowUpdate struct {
+		threshold time.Duration
+		fn        SlowUpdateFunc
+	}
+
+	// preloaded is set once the zone configs of the first complete update
+	// are being preloaded. It's protected by zoneChangeMu.
+	preloaded bool
+
+	mu struct {
 		syncutil.RWMutex
 
 		cfg       *config.SystemConfig
@@ -51,6 +73,38 @@ type Cache struct {
 	}
 }
 
+// defaultSlowUpdateThreshold is the duration after which processing an update
+// to the cached SystemConfig is reported as slow, unless overridden through
+// SetSlowUpdateHook.
+var defaultSlowUpdateThreshold = envutil.EnvOrDefaultDuration(
+	"COCKROACH_SYSTEM_CONFIG_SLOW_UPDATE_THRESHOLD", 5*time.Second)
+
+// preloadZoneConfigWorkers is the number of goroutines across which the zone
+// configs of the first complete system config are preloaded.
+var preloadZoneConfigWorkers = envutil.EnvOrDefaultInt(
+	"COCKROACH_SYSTEM_CONFIG_PRELOAD_WORKERS", 4)
+
+// SlowUpdate describes an update to the cached SystemConfig which took longer
+// than the slow update threshold to process.
+type SlowUpdate struct {
+	// Duration is the time from the receipt of the update until the updated
+	// SystemConfig was made available and its zone change callbacks were
+	// queued.
+	Duration time.Duration
+	// NumKVs is the number of KVs in the updated SystemConfig.
+	NumKVs int
+	// Timestamp is the timestamp of the updated SystemConfig.
+	Timestamp hlc.Timestamp
+}
+
+// SlowUpdateFunc is invoked with slow SystemConfig updates.
+type SlowUpdateFunc func(ctx context.Context, update SlowUpdate)
+
+func logSlowUpdate(ctx context.Context, update SlowUpdate) {
+	log.Warningf(ctx, "processing system config update at %s took %s (%d KVs)",
+		update.Timestamp, update.Duration, update.NumKVs)
+}
+
 // New constructs a new Cache.
 func New(
 	codec keys.SQLCodec, clock *hlc.Clock, f *rangefeed.Factory, defaultZoneConfig *zonepb.ZoneConfig,
@@ -84,10 +138,13 @@ func NewWithAdditionalProvider(
 	const bufferSize = 1 << 20 // infinite?
 	const withPrevValue = false
 	c := Cache{
+		codec:             codec,
 		defaultZoneConfig: defaultZoneConfig,
 	}
 	c.mu.registry = notificationRegistry{}
 	c.additionalKVsSource = additional
+	c.slowUpdate.threshold = defaultSlowUpdateThreshold
+	c.slowUpdate.fn = logSlowUpdate
 
 	spans := []roachpb.Span{
 		{
@@ -112,13 +169,14 @@ func NewWithAdditionalProvider(
 
 // Start starts the cache.
 func (c *Cache) Start(ctx context.Context, stopper *stop.Stopper) error {
+	c.stopper = stopper
 	if err := rangefeedcache.Start(ctx, stopper, c.w, nil /* onError */); err != nil {
 		return err
 	}
 	if c.additionalKVsSource != nil {
-		setAdditionalKeys := func() {
+		setAdditionalKeys := func(ctx context.Context) {
 			if cfg := c.additionalKVsSource.GetSystemConfig(); cfg != nil {
-				c.setAdditionalKeys(cfg.Values)
+				c.setAdditionalKeys(ctx, cfg.Values)
 			}
 		}
 		ch, unregister := c.additionalKVsSource.RegisterSystemConfigChannel()
@@ -126,7 +184,7 @@ func (c *Cache) Start(ctx context.Context, stopper *stop.Stopper) error {
 		// start. This is mostly to make tests deterministic.
 		select {
 		case <-ch:
-			setAdditionalKeys()
+			setAdditionalKeys(ctx)
 		default:
 		}
 		if err := stopper.RunAsyncTask(ctx, "systemconfigwatcher-additional", func(ctx context.Context) {
@@ -137,7 +195,7 @@ func (c *Cache) Start(ctx context.Context, stopper *stop.Stopper) error {
 				case <-stopper.ShouldQuiesce():
 					return
 				case <-ch:
-					setAdditionalKeys()
+					setAdditionalKeys(ctx)
 				}
 			}
 		}); err != nil {
@@ -182,7 +240,27 @@ func (c *Cache) LastUpdated() hlc.Timestamp {
 	return c.mu.timestamp
 }
 
-func (c *Cache) setAdditionalKeys(kvs []roachpb.KeyValue) {
+// SetSlowUpdateHook overrides the function invoked when processing an update to
+// the cached SystemConfig takes longer than threshold. By default, such updates
+// are logged.
+func (c *Cache) SetSlowUpdateHook(threshold time.Duration, fn SlowUpdateFunc) {
+	c.zoneChangeMu.Lock()
+	defer c.zoneChangeMu.Unlock()
+	c.slowUpdate.threshold = threshold
+	c.slowUpdate.fn = fn
+}
+
+func (c *Cache) setAdditionalKeys(ctx context.Context, kvs []roachpb.KeyValue) {
+	c.zoneChangeMu.Lock()
+	defer c.zoneChangeMu.Unlock()
+	start := timeutil.Now()
+	prev := c.GetSystemConfig()
+	c.applyAdditionalKeys(kvs)
+	c.notifyZoneChanges(ctx, prev)
+	c.maybeReportSlowUpdateLocked(ctx, prev, start)
+}
+
+func (c *Cache) applyAdditionalKeys(kvs []roachpb.KeyValue) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -230,7 +308,76 @@ func (k keyValues) Less(i, j int) bool { return k[i].Key.Compare(k[j].Key) < 0 }
 
 var _ sort.Interface = (keyValues)(nil)
 
-func (c *Cache) handleUpdate(_ context.Context, update rangefeedcache.Update) {
+// notifyZoneChanges queues the invocation of the zone change callbacks
+// registered on prev if the cached config has since been replaced.
+func (c *Cache) notifyZoneChanges(ctx context.Context, prev *config.SystemConfig) {
+	if cur := c.GetSystemConfig(); prev != nil && cur != prev {
+		cur.NotifyZoneChanges(ctx, prev)
+	}
+}
+
+// maybeReportSlowUpdateLocked invokes the slow update hook if the cached config
+// has been replaced since prev and doing so took longer than the threshold.
+// zoneChangeMu must be held.
+func (c *Cache) maybeReportSlowUpdateLocked(
+	ctx context.Context, prev *config.SystemConfig, start time.Time,
+) {
+	took := timeutil.Since(start)
+	if c.slowUpdate.fn == nil || took < c.slowUpdate.threshold {
+		return
+	}
+	c.mu.RLock()
+	cur, ts := c.mu.cfg, c.mu.timestamp
+	c.mu.RUnlock()
+	if cur == nil || cur == prev {
+		return
+	}
+	c.slowUpdate.fn(ctx, SlowUpdate{
+		Duration:  took,
+		NumKVs:    len(cur.Values),
+		Timestamp: ts,
+	})
+}
+
+func (c *Cache) handleUpdate(ctx context.Context, update rangefeedcache.Update) {
+	c.zoneChangeMu.Lock()
+	defer c.zoneChangeMu.Unlock()
+	start := timeutil.Now()
+	prev := c.GetSystemConfig()
+	c.applyUpdate(update)
+	c.notifyZoneChanges(ctx, prev)
+	c.maybeReportSlowUpdateLocked(ctx, prev, start)
+	if update.Type == rangefeedcache.CompleteUpdate {
+		c.maybePreloadZoneConfigsLocked(ctx)
+	}
+}
+
+// maybePreloadZoneConfigsLocked preloads the zone configs of the cached
+// config in the background if it's the first complete one, so that the first
+// lookups of zone configs, e.g. by the split and replicate queues, don't pay
+// for decoding them. Only the zone configs of the system tenant are preloaded.
+// The problems found along the way are logged. zoneChangeMu must be held.
+func (c *Cache) maybePreloadZoneConfigsLocked(ctx context.Context) {
+	if c.preloaded || c.stopper == nil || !c.codec.ForSystemTenant() {
+		return
+	}
+	cfg := c.GetSystemConfig()
+	if cfg == nil {
+		return
+	}
+	c.preloaded = true
+	if err := c.stopper.RunAsyncTask(ctx, "systemconfigwatcher-preload", func(ctx context.Context) {
+		ctx, cancel := c.stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		for _, err := range cfg.PreloadZoneConfigs(ctx, preloadZoneConfigWorkers) {
+			log.Warningf(ctx, "preloading zone configs: %v", err)
+		}
+	}); err != nil {
+		log.Warningf(ctx, "could not preload zone configs: %v", err)
+	}
+}
+
+func (c *Cache) applyUpdate(update rangefeedcache.Update) {
 	updateKVs := rangefeedbuffer.EventsToKVs(update.Events,
 		rangefeedbuffer.RangeFeedValueEventToKV)
 	c.mu.Lock()
@@ -251,7 +398,8 @@ func (c *Cache) handleUpdate(_ context.Context, update rangefeedcache.Update) {
 			c.setUpdatedConfigLocked(prev, update.Timestamp)
 			return
 		}
-		updatedData = rangefeedbuffer.MergeKVs(prev.Values, updateKVs)
+		c.setUpdatedConfigLocked(prev.ApplyDelta(updateKVs), update.Timestamp)
+		return
 	}
 
 	updatedCfg := config.NewSystemConfig(c.defaultZoneConfig)
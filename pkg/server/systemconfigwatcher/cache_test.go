@@ -22,11 +22,13 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/systemconfigwatcher"
 	"github.com/cockroachdb/cockroach/pkg/server/systemconfigwatcher/systemconfigwatchertest"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,6 +110,67 @@ func TestNewWithAdditionalProvider(t *testing.T) {
 	require.Equal(t, tenantA, getValues()[2].Key)
 }
 
+func TestSlowUpdateHook(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, _, kvDB := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	codec := keys.MakeSQLCodec(roachpb.MustMakeTenantID(10))
+	cache := systemconfigwatcher.New(
+		codec, s.Clock(), s.RangeFeedFactory().(*rangefeed.Factory),
+		zonepb.DefaultZoneConfigRef(),
+	)
+	updates := make(chan systemconfigwatcher.SlowUpdate, 1)
+	cache.SetSlowUpdateHook(0 /* threshold */, func(
+		ctx context.Context, update systemconfigwatcher.SlowUpdate,
+	) {
+		updates <- update
+	})
+	require.NoError(t, cache.Start(ctx, s.Stopper()))
+
+	// The initial scan is reported.
+	update := <-updates
+	require.Zero(t, update.NumKVs)
+	require.False(t, update.Timestamp.IsEmpty())
+
+	require.NoError(t, kvDB.Put(ctx, append(codec.TablePrefix(keys.ZonesTableID), "a"...), "value"))
+	update = <-updates
+	require.Equal(t, 1, update.NumKVs)
+	require.True(t, update.Timestamp.LessEq(cache.LastUpdated()))
+}
+
+func TestPreloadZoneConfigs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, _, kvDB := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	cache := systemconfigwatcher.New(
+		keys.SystemSQLCodec, s.Clock(), s.RangeFeedFactory().(*rangefeed.Factory),
+		zonepb.DefaultZoneConfigRef(),
+	)
+	ch, unregister := cache.RegisterSystemConfigChannel()
+	defer unregister()
+	require.NoError(t, cache.Start(ctx, s.Stopper()))
+	<-ch
+
+	// The zone configs of the first complete config are preloaded, and the
+	// configs which are derived from it share its readiness.
+	first := cache.GetSystemConfig()
+	<-first.ZoneConfigsReady()
+	require.NoError(t, kvDB.Put(ctx, append(keys.SystemSQLCodec.TablePrefix(keys.ZonesTableID), "a"...), "value"))
+	testutils.SucceedsSoon(t, func() error {
+		if cache.GetSystemConfig() == first {
+			return errors.New("update not received")
+		}
+		return nil
+	})
+	<-cache.GetSystemConfig().ZoneConfigsReady()
+}
+
 type fakeProvider struct {
 	ch chan struct{}
 	mu struct {
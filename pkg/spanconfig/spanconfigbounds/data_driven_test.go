@@ -61,6 +61,11 @@ import (
 //     semantics are identical to clamp, but the output is the detailed error
 //     returned from the Check method on the Bounds, if there is an error.
 //
+//   - check-fixed bounds=<bounds-name> prev=<config-name> config=<config-name>
+//     Checks whether the config changes a field fixed by the specified bounds
+//     from its value in the prev config. The output is the error returned from
+//     the CheckFixedFields method on the Bounds, if there is an error, or ok.
+//
 //   - bounds-fields bounds=<bounds-name>
 //     Prints the fields of the specified bounds. This is used to exercise the
 //     field retrieval and printing logic for bounds.
@@ -162,6 +167,14 @@ func TestDataDriven(t *testing.T) {
 				err := (*spanconfigbounds.Bounds).Check(getBoundsAndConfig()).AsError()
 				// Exercise both the short and long form of the error.
 				return fmt.Sprintf("%v\n%+v", err, err)
+			case "check-fixed":
+				var prevName string
+				d.ScanArgs(tt, "prev", &prevName)
+				require.Contains(t, configs, prevName, "prev")
+				if err := getBounds().CheckFixedFields(configs[prevName], getConfig()); err != nil {
+					return fmt.Sprintf("%v\n%+v", err, err)
+				}
+				return "ok"
 			case "bounds-fields":
 				b := getBounds()
 				var buf strings.Builder
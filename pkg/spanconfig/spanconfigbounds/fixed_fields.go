@@ -0,0 +1,124 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package spanconfigbounds
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/errors"
+)
+
+// fieldFromName returns the field with the given name.
+func fieldFromName(name string) (Field, bool) {
+	for _, f := range fields {
+		if f.String() == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// ValidateFixedFields returns an error if the fixed fields of the bounds
+// aren't fields of span configs.
+func (b *Bounds) ValidateFixedFields() error {
+	for _, name := range b.FixedFields {
+		if _, ok := fieldFromName(name); !ok {
+			return errors.Newf("unknown span config field %q", name)
+		}
+	}
+	return nil
+}
+
+// HasFixedFields returns whether the bounds fix any field.
+func (b *Bounds) HasFixedFields() bool {
+	return len(b.FixedFields) > 0
+}
+
+// CheckFixedFields returns a FixedFieldError if c, which replaces prev,
+// changes one of the fixed fields of the bounds. Fields which the binary
+// doesn't know about, which may be fixed by the bounds written by a newer
+// one, are ignored.
+func (b *Bounds) CheckFixedFields(prev, c *roachpb.SpanConfig) error {
+	for _, name := range b.FixedFields {
+		f, ok := fieldFromName(name)
+		if !ok {
+			continue
+		}
+		if !fieldValuesEqual(f, prev, c) {
+			return &FixedFieldError{Field: f, Prev: f.FieldValue(prev), Value: f.FieldValue(c)}
+		}
+	}
+	return nil
+}
+
+// fieldValuesEqual returns whether the given field has the same value in both
+// span configs.
+func fieldValuesEqual(f Field, a, b *roachpb.SpanConfig) bool {
+	switch f := f.(type) {
+	case int32Field:
+		return *f.fieldValue(a) == *f.fieldValue(b)
+	case int64Field:
+		return *f.fieldValue(a) == *f.fieldValue(b)
+	case boolField:
+		return *f.fieldValue(a) == *f.fieldValue(b)
+	case constraintsConjunctionField:
+		x, y := *f.fieldValue(a), *f.fieldValue(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !x[i].Equal(&y[i]) {
+				return false
+			}
+		}
+		return true
+	case leasePreferencesField:
+		x, y := *f.fieldValue(a), *f.fieldValue(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !x[i].Equal(&y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		panic(errors.AssertionFailedf("failed to compare field %s", f))
+	}
+}
+
+// FixedFieldError is returned when a span config changes a field which is
+// fixed by the bounds of its tenant.
+type FixedFieldError struct {
+	Field       Field
+	Prev, Value Value
+}
+
+func (e *FixedFieldError) Format(f fmt.State, verb rune) {
+	errors.FormatError(e, f, verb)
+}
+
+func (e *FixedFieldError) SafeFormatError(p errors.Printer) (next error) {
+	p.Printf("span config field %v is fixed by the span config bounds", e.Field)
+	if p.Detail() {
+		p.Printf("%v may not be changed from %v to %v", e.Field, e.Prev, e.Value)
+	}
+	return nil
+}
+
+func (e *FixedFieldError) Error() string {
+	return fmt.Sprintf("%v", e)
+}
+
+var _ errors.SafeFormatter = (*FixedFieldError)(nil)
+var _ fmt.Formatter = (*FixedFieldError)(nil)
@@ -39,6 +39,9 @@ func (b *Bounds) SafeFormat(s interfaces.SafePrinter, verb rune) {
 		fieldID := config.Field(i)
 		s.Printf("%v%v: %v", delim, fieldID, field.FieldBound(b))
 	}
+	if b.HasFixedFields() {
+		s.Printf(", fixed_fields: %v", b.FixedFields)
+	}
 	s.Printf("}")
 }
 
@@ -59,6 +62,9 @@ func (b *Bounds) String() string {
 		_, _ = fmt.Fprintf(&buf, "%s%s: %s", sep, field, field.FieldBound(b))
 		sep = "\n"
 	}
+	if b.HasFixedFields() {
+		_, _ = fmt.Fprintf(&buf, "%sfixed_fields: %s", sep, b.FixedFields)
+	}
 	return buf.String()
 }
 
@@ -17,11 +17,13 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/spanconfig"
+	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigstore"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
@@ -55,6 +57,10 @@ type KVAccessor struct {
 	settings    *cluster.Settings
 	clock       *hlc.Clock
 
+	// boundsReader provides the span config bounds of secondary tenants, whose
+	// fixed fields the span configs they write may not change.
+	boundsReader spanconfigstore.BoundsReader
+
 	// configurationsTableFQN is typically 'system.public.span_configurations',
 	// but left configurable for ease-of-testing.
 	configurationsTableFQN string
@@ -70,6 +76,7 @@ func New(
 	ie isql.Executor,
 	settings *cluster.Settings,
 	clock *hlc.Clock,
+	boundsReader spanconfigstore.BoundsReader,
 	configurationsTableFQN string,
 	knobs *spanconfig.TestingKnobs,
 ) *KVAccessor {
@@ -77,7 +84,7 @@ func New(
 		panic(fmt.Sprintf("unabled to parse configurations table FQN: %s", configurationsTableFQN))
 	}
 
-	return newKVAccessor(db, ie, settings, clock, configurationsTableFQN, knobs, nil /* optionalTxn */)
+	return newKVAccessor(db, ie, settings, clock, boundsReader, configurationsTableFQN, knobs, nil /* optionalTxn */)
 }
 
 // WithTxn is part of the KVAccessor interface.
@@ -85,7 +92,7 @@ func (k *KVAccessor) WithTxn(ctx context.Context, txn *kv.Txn) spanconfig.KVAcce
 	if k.optionalTxn != nil {
 		log.Fatalf(ctx, "KVAccessor already scoped to txn (was .WithTxn(...) chained multiple times?)")
 	}
-	return newKVAccessor(k.db, k.ie, k.settings, k.clock, k.configurationsTableFQN, k.knobs, txn)
+	return newKVAccessor(k.db, k.ie, k.settings, k.clock, k.boundsReader, k.configurationsTableFQN, k.knobs, txn)
 }
 
 // GetAllSystemSpanConfigsThatApply is part of the spanconfig.KVAccessor
@@ -184,6 +191,7 @@ func newKVAccessor(
 	ie isql.Executor,
 	settings *cluster.Settings,
 	clock *hlc.Clock,
+	boundsReader spanconfigstore.BoundsReader,
 	configurationsTableFQN string,
 	knobs *spanconfig.TestingKnobs,
 	optionalTxn *kv.Txn,
@@ -198,6 +206,7 @@ func newKVAccessor(
 		clock:                  clock,
 		optionalTxn:            optionalTxn,
 		settings:               settings,
+		boundsReader:           boundsReader,
 		configurationsTableFQN: configurationsTableFQN,
 		knobs:                  knobs,
 	}
@@ -303,6 +312,12 @@ func (k *KVAccessor) updateSpanConfigRecordsWithTxn(
 		return err
 	}
 
+	// The records are checked against the fixed fields before any of them are
+	// deleted, so that the records which replace them are checked against them.
+	if err := k.checkFixedFields(ctx, toUpsert, txn); err != nil {
+		return err
+	}
+
 	if len(toDelete) > 0 {
 		if err := k.paginate(len(toDelete), func(startIdx, endIdx int) error {
 			toDeleteBatch := toDelete[startIdx:endIdx]
@@ -355,6 +370,68 @@ func (k *KVAccessor) updateSpanConfigRecordsWithTxn(
 	})
 }
 
+// checkFixedFields returns a spanconfigbounds.FixedFieldError if the records
+// upserted by a secondary tenant change a field fixed by the span config bounds
+// of the tenant. Each record is compared to the existing records of the tenant
+// which it overlaps or, if it overlaps none, e.g. because it's the record of a
+// new table, to the one preceding it; otherwise a tenant could choose the fixed
+// fields of the tables it creates. System span configs can't set any of the
+// fields, so they aren't checked.
+func (k *KVAccessor) checkFixedFields(
+	ctx context.Context, toUpsert []spanconfig.Record, txn *kv.Txn,
+) error {
+	tenID, ok := roachpb.ClientTenantFromContext(ctx)
+	if !ok || tenID.IsSystem() || len(toUpsert) == 0 {
+		return nil
+	}
+	bounds, found := k.boundsReader.Bounds(tenID)
+	if !found || !bounds.HasFixedFields() {
+		return nil
+	}
+
+	// Only the tenants with fixed fields pay for reading all of their records,
+	// which is simpler than reading the ones surrounding each upserted record.
+	tenantPrefix := keys.MakeTenantPrefix(tenID)
+	existing, err := k.getSpanConfigRecordsWithTxn(ctx, []spanconfig.Target{
+		spanconfig.MakeTargetFromSpan(roachpb.Span{Key: tenantPrefix, EndKey: tenantPrefix.PrefixEnd()}),
+	}, txn)
+	if err != nil {
+		return err
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].GetTarget().Less(existing[j].GetTarget())
+	})
+
+	for i := range toUpsert {
+		if !toUpsert[i].GetTarget().IsSpanTarget() {
+			continue
+		}
+		sp, conf := toUpsert[i].GetTarget().GetSpan(), toUpsert[i].GetConfig()
+		check := func(r *spanconfig.Record) error {
+			prev := r.GetConfig()
+			return bounds.CheckFixedFields(&prev, &conf)
+		}
+		// The records which overlap the span precede the first one which starts
+		// at or after its end, as records don't overlap each other.
+		j := sort.Search(len(existing), func(j int) bool {
+			return existing[j].GetTarget().GetSpan().Key.Compare(sp.EndKey) >= 0
+		}) - 1
+		overlapped := false
+		for ; j >= 0 && existing[j].GetTarget().GetSpan().Overlaps(sp); j-- {
+			if err := check(&existing[j]); err != nil {
+				return err
+			}
+			overlapped = true
+		}
+		if !overlapped && j >= 0 {
+			if err := check(&existing[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // constructGetStmtAndArgs constructs the statement and query arguments needed
 // to fetch span configs for the given spans.
 func (k *KVAccessor) constructGetStmtAndArgs(targets []spanconfig.Target) (string, []interface{}) {
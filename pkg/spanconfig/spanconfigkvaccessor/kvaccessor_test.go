@@ -18,9 +18,13 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/multitenant/tenantcapabilities/tenantcapabilitiespb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/spanconfig"
+	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigbounds"
 	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigkvaccessor"
+	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigstore"
 	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigtestutils"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
@@ -83,6 +87,7 @@ func TestDataDriven(t *testing.T) {
 			tc.Server(0).InternalExecutor().(isql.Executor),
 			tc.Server(0).ClusterSettings(),
 			tc.Server(0).Clock(),
+			spanconfigstore.NewEmptyBoundsReader(),
 			dummySpanConfigurationsFQN,
 			nil, /* knobs */
 		)
@@ -165,6 +170,7 @@ func BenchmarkKVAccessorUpdate(b *testing.B) {
 				tc.Server(0).InternalExecutor().(isql.Executor),
 				tc.Server(0).ClusterSettings(),
 				tc.Server(0).Clock(),
+				spanconfigstore.NewEmptyBoundsReader(),
 				dummySpanConfigurationsFQN,
 				nil, /* knobs */
 			)
@@ -208,6 +214,7 @@ func TestKVAccessorPagination(t *testing.T) {
 		tc.Server(0).InternalExecutor().(isql.Executor),
 		tc.Server(0).ClusterSettings(),
 		tc.Server(0).Clock(),
+		spanconfigstore.NewEmptyBoundsReader(),
 		dummySpanConfigurationsFQN,
 		&spanconfig.TestingKnobs{
 			KVAccessorPaginationInterceptor: func() {
@@ -309,6 +316,89 @@ span [j,k)
 	}
 }
 
+// TestKVAccessorFixedFields ensures that the updates of secondary tenants
+// can't change the span config fields fixed by their span config bounds.
+func TestKVAccessorFixedFields(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	ctx := context.Background()
+
+	tc := testcluster.StartTestCluster(t, 1, base.TestClusterArgs{
+		ServerArgs: base.TestServerArgs{
+			// Requires span_configuration table which is not visible
+			// from secondary tenants.
+			DefaultTestTenant: base.TestTenantDisabled,
+		},
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	const dummySpanConfigurationsFQN = "defaultdb.public.dummy_span_configurations"
+	tdb := sqlutils.MakeSQLRunner(tc.ServerConn(0))
+	tdb.Exec(t, fmt.Sprintf("CREATE TABLE %s (LIKE system.span_configurations INCLUDING ALL)", dummySpanConfigurationsFQN))
+
+	tenID := roachpb.MustMakeTenantID(10)
+	accessor := spanconfigkvaccessor.New(
+		tc.Server(0).DB(),
+		tc.Server(0).InternalExecutor().(isql.Executor),
+		tc.Server(0).ClusterSettings(),
+		tc.Server(0).Clock(),
+		testBoundsReader{tenID: spanconfigbounds.New(&tenantcapabilitiespb.SpanConfigBounds{
+			FixedFields: []string{"num_replicas"},
+		})},
+		dummySpanConfigurationsFQN,
+		nil, /* knobs */
+	)
+
+	record := func(start, end string, numReplicas, ttl int32) spanconfig.Record {
+		prefix := keys.MakeTenantPrefix(tenID)
+		sp := roachpb.Span{
+			Key:    append(prefix[:len(prefix):len(prefix)], start...),
+			EndKey: append(prefix[:len(prefix):len(prefix)], end...),
+		}
+		conf := roachpb.SpanConfig{NumReplicas: numReplicas}
+		conf.GCPolicy.TTLSeconds = ttl
+		r, err := spanconfig.MakeRecord(spanconfig.MakeTargetFromSpan(sp), conf)
+		require.NoError(t, err)
+		return r
+	}
+	update := func(ctx context.Context, toDelete []spanconfig.Record, toUpsert ...spanconfig.Record) error {
+		var targets []spanconfig.Target
+		for _, r := range toDelete {
+			targets = append(targets, r.GetTarget())
+		}
+		return accessor.UpdateSpanConfigRecords(ctx, targets, toUpsert, hlc.MinTimestamp, hlc.MaxTimestamp)
+	}
+	tenantCtx := roachpb.ContextWithClientTenant(ctx, tenID)
+	var fixedErr *spanconfigbounds.FixedFieldError
+
+	// The host isn't restricted by the bounds of the tenant.
+	require.NoError(t, update(ctx, nil, record("a", "c", 3, 100)))
+
+	// The tenant may change the fields which aren't fixed.
+	require.NoError(t, update(tenantCtx, nil, record("a", "c", 3, 200)))
+	require.ErrorAs(t, update(tenantCtx, nil, record("a", "c", 1, 200)), &fixedErr)
+
+	// Records which replace the ones they overlap are compared to them.
+	require.NoError(t, update(tenantCtx,
+		[]spanconfig.Record{record("a", "c", 3, 200)},
+		record("a", "b", 3, 200), record("b", "c", 3, 300),
+	))
+	require.ErrorAs(t, update(tenantCtx,
+		[]spanconfig.Record{record("a", "b", 3, 200), record("b", "c", 3, 300)},
+		record("a", "c", 5, 200),
+	), &fixedErr)
+
+	// Records which overlap none are compared to the preceding one.
+	require.ErrorAs(t, update(tenantCtx, nil, record("d", "e", 1, 100)), &fixedErr)
+	require.NoError(t, update(tenantCtx, nil, record("d", "e", 3, 100)))
+}
+
+type testBoundsReader map[roachpb.TenantID]*spanconfigbounds.Bounds
+
+func (r testBoundsReader) Bounds(tenID roachpb.TenantID) (*spanconfigbounds.Bounds, bool) {
+	b, ok := r[tenID]
+	return b, ok
+}
+
 // TestKVAccessorCommitMinTSWaitRespondsToCtxCancellation ensures that
 // KVAccessor updates which are waiting for their local clocks to be in advance
 // of the minimum commit timestamp respond to context cancellations.
@@ -327,6 +417,7 @@ func TestKVAccessorCommitMinTSWaitRespondsToCtxCancellation(t *testing.T) {
 		tc.Server(0).InternalExecutor().(isql.Executor),
 		tc.Server(0).ClusterSettings(),
 		tc.Server(0).Clock(),
+		spanconfigstore.NewEmptyBoundsReader(),
 		dummySpanConfigurationsFQN,
 		&spanconfig.TestingKnobs{
 			KVAccessorPreCommitMinTSWaitInterceptor: func() {
@@ -129,6 +129,7 @@ func TestDataDriven(t *testing.T) {
 			tc.Server(0).InternalExecutor().(isql.Executor),
 			tc.Server(0).ClusterSettings(),
 			tc.Server(0).Clock(),
+			spanconfigstore.NewEmptyBoundsReader(),
 			fmt.Sprintf("defaultdb.public.%s", dummyTableName),
 			nil, /* knobs */
 		)
@@ -405,6 +405,12 @@ func (s *SQLTranslator) generateSpanConfigurationsForTable(
 		// See #73749.
 	}
 
+	// Corrupted subzone spans would otherwise apply the wrong span configs to
+	// parts of the table, or none at all.
+	if err := zonepb.ValidateSubzoneSpans(zone.SubzoneSpans, len(zone.Subzones)); err != nil {
+		return nil, errors.NewAssertionErrorWithWrappedErrf(err,
+			"invalid subzone spans in the zone config of table %d", table.GetID())
+	}
 	prevEndKey := tableStartKey
 	for i := range zone.SubzoneSpans {
 		// We need to prepend the tablePrefix to the spans stored inside the
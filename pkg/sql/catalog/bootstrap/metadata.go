@@ -20,6 +20,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/multitenant/mtinfopb"
@@ -543,8 +544,19 @@ func InitialZoneConfigKVs(
 	// Liveness zone config entry with a shorter GC time.
 	livenessZoneConf.GC.TTLSeconds = 10 * 60 // 10m
 
+	// The defaults above may be overridden at startup.
+	if zc, ok := config.DefaultSystemZoneConfigOverride(keys.MetaRangesID); ok {
+		metaRangeZoneConf = zc
+	}
+	if zc, ok := config.DefaultSystemZoneConfigOverride(keys.LivenessRangesID); ok {
+		livenessZoneConf = zc
+	}
+
 	add(keys.MetaRangesID, metaRangeZoneConf)
 	add(keys.LivenessRangesID, livenessZoneConf)
+	if zc, ok := config.DefaultSystemZoneConfigOverride(keys.TimeseriesRangesID); ok {
+		add(keys.TimeseriesRangesID, zc)
+	}
 	add(keys.SystemRangesID, systemZoneConf)
 	add(keys.SystemDatabaseID, systemZoneConf)
 	add(keys.ReplicationConstraintStatsTableID, replicationConstraintStatsZoneConf)
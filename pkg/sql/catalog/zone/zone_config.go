@@ -13,7 +13,6 @@ package zone
 import (
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog"
-	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 )
 
 // ZoneConfigWithRawBytes wraps a zone config together with its expected
@@ -48,13 +47,15 @@ func (zc *ZoneConfigWithRawBytes) GetRawBytesInStorage() []byte {
 	return zc.rb
 }
 
-// Clone returns a ZoneConfigWithRawBytes with a deep copy of ZoneConfig. It's
-// safe to not clone the raw bytes because the cput would fail if raw bytes
-// differs from the original bytes.
+// Clone returns a ZoneConfigWithRawBytes with a copy of ZoneConfig, which
+// shares its slices with the original until they're modified (see
+// zonepb.ZoneConfig.Clone), so that cloning the zone config of a table with
+// many partitions doesn't copy all of its subzones. It's safe to not clone the
+// raw bytes because the cput would fail if raw bytes differs from the original
+// bytes.
 func (zc *ZoneConfigWithRawBytes) Clone() catalog.ZoneConfig {
 	if zc == nil {
 		return nil
 	}
-	clone := protoutil.Clone(zc.zc).(*zonepb.ZoneConfig)
-	return NewZoneConfigWithRawBytes(clone, zc.rb)
+	return NewZoneConfigWithRawBytes(zc.zc.Clone(), zc.rb)
 }
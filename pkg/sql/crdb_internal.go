@@ -4381,6 +4381,7 @@ CREATE TABLE crdb_internal.zones (
 					)
 				}
 
+				materialized := fullZone.MaterializeSubzoneConfigs()
 				for i, s := range subzones {
 					index := catalog.FindActiveIndex(table, func(idx catalog.Index) bool {
 						return idx.GetID() == descpb.IndexID(s.IndexID)
@@ -4398,22 +4399,9 @@ CREATE TABLE crdb_internal.zones (
 					zoneSpecifier = &zs
 
 					// Generate information about full / inherited constraints.
-					// There are two cases -- the subzone we are looking at refers
-					// to an index, or to a partition.
-					subZoneConfig := s.Config
-
-					// In this case, we have an index. Inherit from the parent zone.
-					if s.PartitionName == "" {
-						subZoneConfig.InheritFromParent(&fullZone)
-					} else {
-						// We have a partition. Get the parent index partition from the zone and
-						// have it inherit constraints.
-						if indexSubzone := fullZone.GetSubzone(uint32(index.GetID()), ""); indexSubzone != nil {
-							subZoneConfig.InheritFromParent(&indexSubzone.Config)
-						}
-						// Inherit remaining fields from the full parent zone.
-						subZoneConfig.InheritFromParent(&fullZone)
-					}
+					subZoneConfig := materialized[zonepb.SubzoneKey{
+						IndexID: s.IndexID, PartitionName: s.PartitionName,
+					}]
 
 					if err := generateZoneConfigIntrospectionValues(
 						values,
@@ -1249,6 +1249,8 @@ type ExecutorConfig struct {
 	Gossip            gossip.OptionalGossip
 	NodeLiveness      optionalnodeliveness.Container
 	SystemConfig      config.SystemConfigProvider
+	// ZoneConfigMetrics track the zone configs of the SystemConfig.
+	ZoneConfigMetrics *config.ZoneConfigMetrics
 	DistSender        *kvcoord.DistSender
 	RPCContext        *rpc.Context
 	LeaseManager      *lease.Manager
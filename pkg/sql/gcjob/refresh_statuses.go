@@ -292,10 +292,10 @@ func getIndexTTL(tableTTL int32, placeholder *zonepb.ZoneConfig, indexID descpb.
 	return ttlSeconds
 }
 
-func getTableTTL(defTTL int32, zoneCfg *zonepb.ZoneConfig) int32 {
+func getTableTTL(defTTL int32, zoneCfg zonepb.ZoneConfigView) int32 {
 	ttlSeconds := defTTL
-	if zoneCfg != nil {
-		ttlSeconds = zoneCfg.GC.TTLSeconds
+	if !zoneCfg.IsNil() {
+		ttlSeconds = zoneCfg.GCTTLSeconds()
 	}
 	return ttlSeconds
 }
@@ -466,7 +466,7 @@ func refreshTenant(
 	tenantTTLSeconds := execCfg.DefaultZoneConfig.GC.TTLSeconds
 	zoneCfg, err := cfg.GetZoneConfigForObject(keys.SystemSQLCodec, keys.TenantsRangesID)
 	if err == nil {
-		tenantTTLSeconds = zoneCfg.GC.TTLSeconds
+		tenantTTLSeconds = zoneCfg.GCTTLSeconds()
 	} else {
 		log.Errorf(ctx, "zone config for tenants range: err = %+v", err)
 	}
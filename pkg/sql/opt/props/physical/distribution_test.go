@@ -21,7 +21,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/opt/cat"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
-	"gopkg.in/yaml.v2"
 )
 
 func TestUnion(t *testing.T) {
@@ -168,7 +167,7 @@ func TestGetRegionsFromZone(t *testing.T) {
 
 		if tc.constraints != "" {
 			constraintsList := &zonepb.ConstraintsList{}
-			if err := yaml.UnmarshalStrict([]byte(tc.constraints), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.constraints), constraintsList); err != nil {
 				t.Fatal(err)
 			}
 			zone.Constraints = constraintsList.Constraints
@@ -176,14 +175,14 @@ func TestGetRegionsFromZone(t *testing.T) {
 
 		if tc.voterConstraints != "" {
 			constraintsList := &zonepb.ConstraintsList{}
-			if err := yaml.UnmarshalStrict([]byte(tc.voterConstraints), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.voterConstraints), constraintsList); err != nil {
 				t.Fatal(err)
 			}
 			zone.VoterConstraints = constraintsList.Constraints
 		}
 
 		if tc.leasePrefs != "" {
-			if err := yaml.UnmarshalStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
 				t.Fatal(err)
 			}
 		}
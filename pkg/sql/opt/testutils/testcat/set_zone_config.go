@@ -16,7 +16,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/sql/opt/cat"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
-	"gopkg.in/yaml.v2"
 )
 
 // SetZoneConfig is a partial implementation of the ALTER TABLE ... CONFIGURE
@@ -82,7 +81,7 @@ func makeZoneConfig(options tree.KVOptions) cat.Zone {
 		case "constraints":
 			constraintsList := &zonepb.ConstraintsList{}
 			value := options[i].Value.(*tree.StrVal).RawString()
-			if err := yaml.UnmarshalStrict([]byte(value), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(value), constraintsList); err != nil {
 				panic(err)
 			}
 			zone.Constraints = constraintsList.Constraints
@@ -90,7 +89,7 @@ func makeZoneConfig(options tree.KVOptions) cat.Zone {
 		case "voter_constraints":
 			constraintsList := &zonepb.ConstraintsList{}
 			value := options[i].Value.(*tree.StrVal).RawString()
-			if err := yaml.UnmarshalStrict([]byte(value), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(value), constraintsList); err != nil {
 				panic(err)
 			}
 			zone.VoterConstraints = constraintsList.Constraints
@@ -98,7 +97,7 @@ func makeZoneConfig(options tree.KVOptions) cat.Zone {
 
 		case "lease_preferences":
 			value := options[i].Value.(*tree.StrVal).RawString()
-			if err := yaml.UnmarshalStrict([]byte(value), &zone.LeasePreferences); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(value), &zone.LeasePreferences); err != nil {
 				panic(err)
 			}
 		}
@@ -19,7 +19,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/opt/cat"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
-	"gopkg.in/yaml.v2"
 )
 
 func TestLocalityMatchScore(t *testing.T) {
@@ -80,14 +79,14 @@ func TestLocalityMatchScore(t *testing.T) {
 
 		if tc.constraints != "" {
 			constraintsList := &zonepb.ConstraintsList{}
-			if err := yaml.UnmarshalStrict([]byte(tc.constraints), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.constraints), constraintsList); err != nil {
 				t.Fatal(err)
 			}
 			zone.Constraints = constraintsList.Constraints
 		}
 
 		if tc.leasePrefs != "" {
-			if err := yaml.UnmarshalStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
 				t.Fatal(err)
 			}
 		}
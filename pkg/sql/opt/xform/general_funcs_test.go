@@ -18,7 +18,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/opt/partition"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
-	"gopkg.in/yaml.v2"
 )
 
 func TestIsZoneLocal(t *testing.T) {
@@ -83,7 +82,7 @@ func TestIsZoneLocal(t *testing.T) {
 
 		if tc.constraints != "" {
 			constraintsList := &zonepb.ConstraintsList{}
-			if err := yaml.UnmarshalStrict([]byte(tc.constraints), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.constraints), constraintsList); err != nil {
 				t.Fatal(err)
 			}
 			zone.Constraints = constraintsList.Constraints
@@ -91,14 +90,14 @@ func TestIsZoneLocal(t *testing.T) {
 
 		if tc.voterConstraints != "" {
 			constraintsList := &zonepb.ConstraintsList{}
-			if err := yaml.UnmarshalStrict([]byte(tc.voterConstraints), constraintsList); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.voterConstraints), constraintsList); err != nil {
 				t.Fatal(err)
 			}
 			zone.VoterConstraints = constraintsList.Constraints
 		}
 
 		if tc.leasePrefs != "" {
-			if err := yaml.UnmarshalStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
+			if err := zonepb.UnmarshalYAMLStrict([]byte(tc.leasePrefs), &zone.LeasePreferences); err != nil {
 				t.Fatal(err)
 			}
 		}
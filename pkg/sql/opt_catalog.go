@@ -565,11 +565,11 @@ func (oc *optCatalog) getZoneConfig(desc catalog.TableDescriptor) (cat.Zone, err
 	if err != nil {
 		return nil, err
 	}
-	if zone == nil {
+	if zone.IsNil() {
 		// This can happen with tests that override the hook.
 		return emptyZoneConfig, nil
 	}
-	return cat.AsZone(zone), nil
+	return cat.AsZone(zone.Unwrap()), nil
 }
 
 func (oc *optCatalog) codec() keys.SQLCodec {
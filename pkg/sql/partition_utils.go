@@ -11,8 +11,6 @@
 package sql
 
 import (
-	"bytes"
-
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
@@ -106,19 +104,20 @@ func GenerateSubzoneSpans(
 		}
 	}
 
-	var indexCovering covering.Covering
-	var partitionCoverings []covering.Covering
+	// The boundaries of the partitions come first, with the highest precedence,
+	// followed by those of the indexes.
+	var boundaries [][]subzoneBoundary
+	var indexBoundaries []subzoneBoundary
 	if err := catalog.ForEachIndex(tableDesc, catalog.IndexOpts{
 		AddMutations: true,
 	}, func(idx catalog.Index) error {
 		_, indexSubzoneExists := subzoneIndexByIndexID[idx.GetID()]
 		if indexSubzoneExists {
-			idxSpan := tableDesc.IndexSpan(codec, idx.GetID())
 			// Each index starts with a unique prefix, so (from a precedence
-			// perspective) it's safe to append them all together.
-			indexCovering = append(indexCovering, covering.Range{
-				Start: idxSpan.Key, End: idxSpan.EndKey,
-				Payload: zonepb.Subzone{IndexID: uint32(idx.GetID())},
+			// perspective) it's safe to put them all together.
+			indexBoundaries = append(indexBoundaries, subzoneBoundary{
+				IndexID: uint32(idx.GetID()),
+				Span:    tableDesc.IndexSpan(codec, idx.GetID()),
 			})
 		}
 
@@ -132,48 +131,26 @@ func GenerateSubzoneSpans(
 		// precedence first. They all start with the index prefix, so cannot
 		// overlap with the partition coverings for any other index, so (from a
 		// precedence perspective) it's safe to append them all together.
-		partitionCoverings = append(partitionCoverings, indexPartitionCoverings...)
-
+		for _, c := range indexPartitionCoverings {
+			group := make([]subzoneBoundary, len(c))
+			for i, r := range c {
+				group[i] = subzoneBoundary{
+					IndexID:       uint32(idx.GetID()),
+					PartitionName: r.Payload.(zonepb.Subzone).PartitionName,
+					Span:          roachpb.Span{Key: r.Start, EndKey: r.End},
+				}
+			}
+			boundaries = append(boundaries, group)
+		}
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 
-	// OverlapCoveringMerge returns the payloads for any coverings that overlap
-	// in the same order they were input. So, we require that they be ordered
-	// with highest precedence first, so the first payload of each range is the
-	// one we need.
-	ranges := covering.OverlapCoveringMerge(append(partitionCoverings, indexCovering))
-
 	// NB: This assumes that none of the indexes are interleaved, which is
 	// checked in PartitionDescriptor validation.
 	sharedPrefix := codec.TablePrefix(uint32(tableDesc.GetID()))
-
-	var subzoneSpans []zonepb.SubzoneSpan
-	for _, r := range ranges {
-		payloads := r.Payload.([]interface{})
-		if len(payloads) == 0 {
-			continue
-		}
-		subzoneSpan := zonepb.SubzoneSpan{
-			Key:    bytes.TrimPrefix(r.Start, sharedPrefix),
-			EndKey: bytes.TrimPrefix(r.End, sharedPrefix),
-		}
-		var ok bool
-		if subzone := payloads[0].(zonepb.Subzone); len(subzone.PartitionName) > 0 {
-			subzoneSpan.SubzoneIndex, ok = subzoneIndexByPartition[subzone.PartitionName]
-		} else {
-			subzoneSpan.SubzoneIndex, ok = subzoneIndexByIndexID[descpb.IndexID(subzone.IndexID)]
-		}
-		if !ok {
-			continue
-		}
-		if bytes.Equal(subzoneSpan.Key.PrefixEnd(), subzoneSpan.EndKey) {
-			subzoneSpan.EndKey = nil
-		}
-		subzoneSpans = append(subzoneSpans, subzoneSpan)
-	}
-	return subzoneSpans, nil
+	return subzoneSpansFromBoundaries(sharedPrefix, subzones, append(boundaries, indexBoundaries))
 }
 
 // indexCoveringsForPartitioning returns span coverings representing the
@@ -17,9 +17,11 @@ import (
 	"strings"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog"
@@ -28,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/zone"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgcode"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
+	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgnotice"
 	"github.com/cockroachdb/cockroach/pkg/sql/privilege"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/eval"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
@@ -40,7 +43,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/errors"
 	"github.com/gogo/protobuf/proto"
-	yaml "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 type optionValue struct {
@@ -132,6 +135,7 @@ func init() {
 				loadYAML(&constraintsList, string(tree.MustBeDString(d)))
 				c.Constraints = constraintsList.Constraints
 				c.InheritedConstraints = false
+				c.ConstraintsSet = constraintsList.SetName
 			},
 		},
 		{
@@ -145,6 +149,7 @@ func init() {
 				loadYAML(&voterConstraintsList, string(tree.MustBeDString(d)))
 				c.VoterConstraints = voterConstraintsList.Constraints
 				c.NullVoterConstraintsIsEmpty = true
+				c.VoterConstraintsSet = voterConstraintsList.SetName
 			},
 		},
 		{
@@ -171,7 +176,7 @@ func init() {
 }
 
 func loadYAML(dst interface{}, yamlString string) {
-	if err := yaml.UnmarshalStrict([]byte(yamlString), dst); err != nil {
+	if err := yaml.Unmarshal([]byte(yamlString), dst); err != nil {
 		panic(err)
 	}
 }
@@ -378,6 +383,11 @@ func evaluateYAMLConfig(expr tree.TypedExpr, params runParams) (string, bool, er
 		// Trim spaces, to detect empty zonfigurations.
 		// We'll add back the missing newline below.
 		yamlConfig = strings.TrimSpace(yamlConfig)
+		// Reject aliases which expand to a huge document before it's
+		// unmarshaled.
+		if err := zonepb.CheckYAMLAliases([]byte(yamlConfig)); err != nil {
+			return "", false, pgerror.Wrap(err, pgcode.CheckViolation, "could not parse zone config")
+		}
 	}
 	return yamlConfig, deleteZone, nil
 }
@@ -521,6 +531,7 @@ func (n *setZoneConfigNode) startExec(params runParams) error {
 		specifiers = append(specifiers, n.zoneSpecifier)
 	}
 
+	var reportedWarnings bool
 	applyZoneConfig := func(zs tree.ZoneSpecifier) error {
 		subzonePlaceholder := false
 		// resolveZone determines the ID of the target object of the zone
@@ -581,6 +592,9 @@ func (n *setZoneConfigNode) startExec(params runParams) error {
 			}
 		}
 		partialZone := partialZoneWithRaw.ZoneConfigProto()
+		// The stored zone config is kept around so that rewrites which don't
+		// change it can be skipped.
+		prevPartialZone := protoutil.Clone(partialZone).(*zonepb.ZoneConfig)
 
 		var partialSubzone *zonepb.Subzone
 		if index != nil {
@@ -707,12 +721,22 @@ func (n *setZoneConfigNode) startExec(params runParams) error {
 			// query specified CONFIGURE ZONE USING), the YAML string will be
 			// empty, in which case the unmarshaling will be a no-op. This is
 			// innocuous.
-			if err := yaml.UnmarshalStrict([]byte(yamlConfig), &newZone); err != nil {
+			warnings, err := zonepb.UnmarshalYAMLWithWarnings([]byte(yamlConfig), &newZone)
+			if err != nil {
+				params.ExecCfg().ZoneConfigMetrics.RecordYAMLParseError()
 				return pgerror.Wrap(err, pgcode.CheckViolation, "could not parse zone config")
 			}
+			// The YAML is the same for all of the specifiers, so its warnings
+			// are only reported once.
+			if !reportedWarnings {
+				for _, w := range warnings {
+					params.p.BufferClientNotice(params.ctx, pgnotice.Newf("%s", w))
+				}
+				reportedWarnings = true
+			}
 
 			// Load settings from YAML into the partial zone as well.
-			if err := yaml.UnmarshalStrict([]byte(yamlConfig), &finalZone); err != nil {
+			if err := zonepb.UnmarshalYAML([]byte(yamlConfig), &finalZone); err != nil {
 				return pgerror.Wrap(err, pgcode.CheckViolation, "could not parse zone config")
 			}
 
@@ -754,6 +778,14 @@ func (n *setZoneConfigNode) startExec(params runParams) error {
 				return err
 			}
 
+			// Enforce the cluster-wide policy, if any, on the zone config the
+			// target ends up with.
+			if err := config.CheckZoneConfigPolicy(
+				tree.AsStringWithFQNames(&zs, params.Ann()), newZone,
+			); err != nil {
+				return pgerror.WithCandidateCode(err, pgcode.CheckViolation)
+			}
+
 			// Are we operating on an index?
 			if index == nil {
 				// No: the final zone config is the one we just processed.
@@ -844,10 +876,48 @@ func (n *setZoneConfigNode) startExec(params runParams) error {
 			}
 		}
 
+		// Nodes which predate wildcard constraints would take them literally.
+		if partialZone.HasWildcardConstraints() && !params.ExecCfg().Settings.Version.IsActive(
+			params.ctx, clusterversion.V23_2_ZoneConfigWildcardConstraints,
+		) {
+			return pgerror.Newf(pgcode.FeatureNotSupported,
+				"wildcard constraints are not supported until upgrade to version %v is finalized",
+				clusterversion.ByKey(clusterversion.V23_2_ZoneConfigWildcardConstraints))
+		}
+
+		// Nodes which predate store constraints would take them for constraints
+		// on a locality tier named store.
+		if partialZone.HasStoreConstraints() && !params.ExecCfg().Settings.Version.IsActive(
+			params.ctx, clusterversion.V23_2_ZoneConfigStoreConstraints,
+		) {
+			return pgerror.Newf(pgcode.FeatureNotSupported,
+				"store constraints are not supported until upgrade to version %v is finalized",
+				clusterversion.ByKey(clusterversion.V23_2_ZoneConfigStoreConstraints))
+		}
+
+		// An existing zone config which would be rewritten into an equivalent
+		// one, e.g. with its constraints in another order, is left as is, as
+		// with the deletion of a zone config which doesn't exist.
+		if partialZoneWithRaw.GetRawBytesInStorage() != nil && partialZone.EquivalentTo(prevPartialZone) {
+			return nil
+		}
+
 		// Write the partial zone configuration.
 		hasNewSubzones := !deleteZone && index != nil
 		execConfig := params.extendedEvalCtx.ExecCfg
 		zoneToWrite := partialZone
+		// Record who made the change, and when, so that it can be audited later
+		// on. Nodes which predate the audit info would drop it when they rewrite
+		// the zone config, so it's only recorded once they're all upgraded.
+		if params.ExecCfg().Settings.Version.IsActive(
+			params.ctx, clusterversion.V23_2_ZoneConfigAuditInfo,
+		) {
+			zoneToWrite.AuditInfo = &zonepb.ZoneConfigAuditInfo{
+				LastModified:         params.p.txn.ReadTimestamp(),
+				ModifiedBy:           params.p.User().Normalized(),
+				StatementFingerprint: params.p.stmt.StmtNoConstants,
+			}
+		}
 		// TODO(ajwerner): This is extremely fragile because we accept a nil table
 		// all the way down here.
 		n.run.numAffected, err = writeZoneConfig(
@@ -1094,20 +1164,28 @@ func validateZoneLocalitiesForSecondaryTenants(
 	if err != nil {
 		return err
 	}
-	regions := make(map[string]struct{})
-	zones := make(map[string]struct{})
+	var localities []roachpb.Tier
 	for regionName, regionMeta := range resp.Regions {
-		regions[regionName] = struct{}{}
+		localities = append(localities, roachpb.Tier{Key: "region", Value: regionName})
 		for _, zone := range regionMeta.Zones {
-			zones[zone] = struct{}{}
+			localities = append(localities, roachpb.Tier{Key: "zone", Value: zone})
+		}
+	}
+	// matches returns whether the constraint, whose value may contain
+	// wildcards, matches any of the tenant's regions or zones.
+	matches := func(constraint zonepb.Constraint) bool {
+		for _, tier := range localities {
+			if constraint.Matches(tier) {
+				return true
+			}
 		}
+		return false
 	}
 
 	for _, constraint := range toValidate {
 		switch constraint.Key {
 		case "zone":
-			_, found := zones[constraint.Value]
-			if !found {
+			if !matches(constraint) {
 				return pgerror.Newf(
 					pgcode.CheckViolation,
 					"zone %q not found",
@@ -1115,8 +1193,7 @@ func validateZoneLocalitiesForSecondaryTenants(
 				)
 			}
 		case "region":
-			_, found := regions[constraint.Value]
-			if !found {
+			if !matches(constraint) {
 				return pgerror.Newf(
 					pgcode.CheckViolation,
 					"region %q not found",
@@ -23,7 +23,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/require"
-	yaml "gopkg.in/yaml.v2"
 )
 
 func TestValidateNoRepeatKeysInZone(t *testing.T) {
@@ -49,7 +48,7 @@ func TestValidateNoRepeatKeysInZone(t *testing.T) {
 	}
 	validate := func(constraint []byte, expectSuccess bool) {
 		var zone zonepb.ZoneConfig
-		err := yaml.UnmarshalStrict(constraint, &zone)
+		err := zonepb.UnmarshalYAML(constraint, &zone)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -107,6 +106,18 @@ func TestValidateZoneAttrsAndLocalitiesForSecondaryTenants(t *testing.T) {
 			cfg:   `voter_constraints: ["+zone=us-east1-a"]`,
 			errRe: "",
 		},
+		{
+			cfg:   `constraints: ["+region=us-*"]`,
+			errRe: "",
+		},
+		{
+			cfg:   `lease_preferences: [["+zone=*-b"]]`,
+			errRe: "",
+		},
+		{
+			cfg:   `constraints: ["+region=eu-*"]`,
+			errRe: `region "eu-\*" not found`,
+		},
 		{
 			cfg:   `constraints: ["+zone=does-not-exist"]`,
 			errRe: `zone "does-not-exist" not found`,
@@ -143,7 +154,7 @@ func TestValidateZoneAttrsAndLocalitiesForSecondaryTenants(t *testing.T) {
 
 	for _, tc := range testCases {
 		var zone zonepb.ZoneConfig
-		err := yaml.UnmarshalStrict([]byte(tc.cfg), &zone)
+		err := zonepb.UnmarshalYAML([]byte(tc.cfg), &zone)
 		require.NoError(t, err)
 
 		err = validateZoneLocalitiesForSecondaryTenants(context.Background(), getRegions, &zone)
@@ -245,6 +256,8 @@ func TestValidateZoneAttrsAndLocalitiesForSystemTenant(t *testing.T) {
 		{`constraints: ["+region=us-east1"]`, expectSuccess, getNodes},
 		{`constraints: {"+region=us-east1": 2, "+region=eu-west1": 1}`, expectSuccess, getNodes},
 		{`constraints: ["+region=us-eas1"]`, expectValidateErr, getNodes},
+		{`constraints: ["+region=us-*"]`, expectSuccess, getNodes},
+		{`constraints: ["+region=asia-*"]`, expectValidateErr, getNodes},
 		{`constraints: {"+region=us-eas1": 2, "+region=eu-west1": 1}`, expectValidateErr, getNodes},
 		{`constraints: {"+region=us-east1": 2, "+region=eu-wes1": 1}`, expectValidateErr, getNodes},
 		{`constraints: ["+regio=us-east1"]`, expectValidateErr, getNodes},
@@ -294,7 +307,7 @@ func TestValidateZoneAttrsAndLocalitiesForSystemTenant(t *testing.T) {
 		{`voter_constraints: ["-fake"]`, expectSuccess, getNodes},
 	} {
 		var zone zonepb.ZoneConfig
-		err := yaml.UnmarshalStrict([]byte(tc.cfg), &zone)
+		err := zonepb.UnmarshalYAML([]byte(tc.cfg), &zone)
 		if err != nil && tc.expectErr == expectSuccess {
 			t.Fatalf("#%d: expected success for %q; got %v", i, tc.cfg, err)
 		} else if err == nil && tc.expectErr == expectParseErr {
@@ -377,8 +390,8 @@ func TestValidateVoterConstraints(t *testing.T) {
 		zone.NumVoters = proto.Int32(3)
 		zone.NumReplicas = proto.Int32(3)
 
-		require.NoError(t, yaml.UnmarshalStrict([]byte(`constraints: `+tc.constraints), &zone))
-		require.NoError(t, yaml.UnmarshalStrict([]byte(`voter_constraints: `+tc.voterConstraints), &zone))
+		require.NoError(t, zonepb.UnmarshalYAML([]byte(`constraints: `+tc.constraints), zone))
+		require.NoError(t, zonepb.UnmarshalYAML([]byte(`voter_constraints: `+tc.voterConstraints), zone))
 		err := zone.Validate()
 		if err != nil && tc.shouldFail {
 			require.Regexp(t, tc.errRegex, err)
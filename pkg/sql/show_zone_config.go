@@ -11,20 +11,16 @@
 package sql
 
 import (
-	"bytes"
 	"context"
-	"strings"
 
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/colinfo"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
-	"github.com/cockroachdb/cockroach/pkg/sql/lexbase"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/types"
-	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/errors"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // These must match crdb_internal.zones.
@@ -161,86 +157,6 @@ func getShowZoneConfigRow(
 	return vals, nil
 }
 
-// zoneConfigToSQL pretty prints a zone configuration as a SQL string.
-func zoneConfigToSQL(zs *tree.ZoneSpecifier, zone *zonepb.ZoneConfig) (string, error) {
-	// Use FutureLineWrap to avoid wrapping long lines. This is required for
-	// cases where one of the zone config fields is longer than 80 characters.
-	// In that case, without FutureLineWrap, the output will have `\n`
-	// characters interspersed every 80 characters. FutureLineWrap ensures that
-	// the whole field shows up as a single line.
-	yaml.FutureLineWrap()
-	constraints, err := yamlMarshalFlow(zonepb.ConstraintsList{
-		Constraints: zone.Constraints,
-		Inherited:   zone.InheritedConstraints})
-	if err != nil {
-		return "", err
-	}
-	constraints = strings.TrimSpace(constraints)
-	voterConstraints, err := yamlMarshalFlow(zonepb.ConstraintsList{
-		Constraints: zone.VoterConstraints,
-		Inherited:   zone.InheritedVoterConstraints(),
-	})
-	if err != nil {
-		return "", err
-	}
-	voterConstraints = strings.TrimSpace(voterConstraints)
-	prefs, err := yamlMarshalFlow(zone.LeasePreferences)
-	if err != nil {
-		return "", err
-	}
-	prefs = strings.TrimSpace(prefs)
-
-	useComma := false
-	maybeWriteComma := func(f *tree.FmtCtx) {
-		if useComma {
-			f.Printf(",\n")
-		}
-		useComma = true
-	}
-
-	f := tree.NewFmtCtx(tree.FmtParsable)
-	f.WriteString("ALTER ")
-	f.FormatNode(zs)
-	f.WriteString(" CONFIGURE ZONE USING\n")
-	if zone.RangeMinBytes != nil {
-		maybeWriteComma(f)
-		f.Printf("\trange_min_bytes = %d", *zone.RangeMinBytes)
-	}
-	if zone.RangeMaxBytes != nil {
-		maybeWriteComma(f)
-		f.Printf("\trange_max_bytes = %d", *zone.RangeMaxBytes)
-	}
-	if zone.GC != nil {
-		maybeWriteComma(f)
-		f.Printf("\tgc.ttlseconds = %d", zone.GC.TTLSeconds)
-	}
-	if zone.GlobalReads != nil {
-		maybeWriteComma(f)
-		f.Printf("\tglobal_reads = %t", *zone.GlobalReads)
-	}
-	if zone.NumReplicas != nil {
-		maybeWriteComma(f)
-		f.Printf("\tnum_replicas = %d", *zone.NumReplicas)
-	}
-	if zone.NumVoters != nil {
-		maybeWriteComma(f)
-		f.Printf("\tnum_voters = %d", *zone.NumVoters)
-	}
-	if !zone.InheritedConstraints {
-		maybeWriteComma(f)
-		f.Printf("\tconstraints = %s", lexbase.EscapeSQLString(constraints))
-	}
-	if !zone.InheritedVoterConstraints() && zone.NumVoters != nil && *zone.NumVoters > 0 {
-		maybeWriteComma(f)
-		f.Printf("\tvoter_constraints = %s", lexbase.EscapeSQLString(voterConstraints))
-	}
-	if !zone.InheritedLeasePreferences {
-		maybeWriteComma(f)
-		f.Printf("\tlease_preferences = %s", lexbase.EscapeSQLString(prefs))
-	}
-	return f.String(), nil
-}
-
 // generateZoneConfigIntrospectionValues creates a result row
 // suitable for populating crdb_internal.zones or SHOW ZONE CONFIG.
 // The values are populated into the `values` first argument.
@@ -292,68 +208,22 @@ func generateZoneConfigIntrospectionValues(
 		}
 	}
 
-	// Populate the YAML column.
-	yamlConfig, err := yaml.Marshal(zone)
+	row, err := config.MakeZoneConfigRow(zs, zone, fullZoneConfig)
 	if err != nil {
 		return err
 	}
-	values[rawConfigYAMLCol] = tree.NewDString(string(yamlConfig))
-
-	// Populate the SQL column.
-	if zs == nil {
-		values[rawConfigSQLCol] = tree.DNull
-	} else {
-		sqlStr, err := zoneConfigToSQL(zs, zone)
-		if err != nil {
-			return err
-		}
-		values[rawConfigSQLCol] = tree.NewDString(sqlStr)
-	}
-
-	// Populate the protobuf column.
-	protoConfig, err := protoutil.Marshal(zone)
-	if err != nil {
-		return err
-	}
-	values[rawConfigProtobufCol] = tree.NewDBytes(tree.DBytes(protoConfig))
-
-	// Populate the full_config_yaml and full_config_sql columns.
-	inheritedConfig := fullZoneConfig
-	if inheritedConfig == nil {
-		inheritedConfig = zone
-	}
-
-	yamlConfig, err = yaml.Marshal(inheritedConfig)
-	if err != nil {
-		return err
-	}
-	values[fullConfigYamlCol] = tree.NewDString(string(yamlConfig))
-
-	if zs == nil {
-		values[fullConfigSQLCol] = tree.DNull
-	} else {
-		sqlStr, err := zoneConfigToSQL(zs, inheritedConfig)
-		if err != nil {
-			return err
-		}
-		values[fullConfigSQLCol] = tree.NewDString(sqlStr)
+	values[rawConfigYAMLCol] = tree.NewDString(row.RawConfigYAML)
+	values[rawConfigSQLCol] = tree.DNull
+	values[rawConfigProtobufCol] = tree.NewDBytes(tree.DBytes(row.RawConfigProtobuf))
+	values[fullConfigYamlCol] = tree.NewDString(row.FullConfigYAML)
+	values[fullConfigSQLCol] = tree.DNull
+	if zs != nil {
+		values[rawConfigSQLCol] = tree.NewDString(row.RawConfigSQL)
+		values[fullConfigSQLCol] = tree.NewDString(row.FullConfigSQL)
 	}
 	return nil
 }
 
-func yamlMarshalFlow(v interface{}) (string, error) {
-	var buf bytes.Buffer
-	e := yaml.NewEncoder(&buf)
-	e.UseStyle(yaml.FlowStyle)
-	if err := e.Encode(v); err != nil {
-		return "", err
-	}
-	if err := e.Close(); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
-
 // ascendZoneSpecifier logically ascends the zone hierarchy for the zone
 // specified by (zs, resolvedID) until the zone matching actualID is found, and
 // returns that zone's specifier. Results are undefined if actualID is not in
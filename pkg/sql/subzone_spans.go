@@ -0,0 +1,171 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/covering"
+	"github.com/cockroachdb/errors"
+)
+
+// subzoneBoundary is a span of a table's keyspace which belongs to an index,
+// or to a partition of an index. Indexes and range partitions have one span,
+// while list partitions may have several.
+type subzoneBoundary struct {
+	IndexID uint32
+	// PartitionName is the name of the partition, or empty if the span is the
+	// span of the index itself.
+	PartitionName string
+	Span          roachpb.Span
+}
+
+// subzoneKey identifies the subzone of an index or partition.
+type subzoneKey struct {
+	indexID   uint32
+	partition string
+}
+
+// subzoneSpansFromBoundaries regenerates the SubzoneSpans of the zone config
+// of the table with the given key prefix from its subzones, given the spans of
+// the table's indexes and partitions.
+//
+// The boundaries are grouped by precedence, highest first: when the spans of
+// several groups overlap, the keys they share belong to the subzone of the
+// first group. Subpartitions must thus precede their partitions, list
+// partitions with fewer DEFAULT values must precede those with more, and all
+// partitions must precede the indexes. The spans of a group must not overlap.
+// Boundaries without a subzone are ignored, and so are subzones without a
+// boundary, such as the subzones of dropped indexes.
+//
+// The returned spans are in the format of the SubzoneSpans field: they're
+// sorted, don't overlap, and omit the table prefix, as well as their EndKey if
+// it's the PrefixEnd of their Key. They are checked to cover the boundaries of
+// every subzone, so that a bug in the computation of the spans is caught here
+// rather than silently applying the wrong zone config to some keys.
+func subzoneSpansFromBoundaries(
+	tablePrefix roachpb.Key, subzones []zonepb.Subzone, boundaries [][]subzoneBoundary,
+) ([]zonepb.SubzoneSpan, error) {
+	subzoneIndexes := make(map[subzoneKey]int32, len(subzones))
+	for i := range subzones {
+		subzoneIndexes[subzoneKey{subzones[i].IndexID, subzones[i].PartitionName}] = int32(i)
+	}
+
+	var coverings []covering.Covering
+	var covered []roachpb.Span
+	for _, group := range boundaries {
+		if err := checkSubzoneBoundaries(group); err != nil {
+			return nil, err
+		}
+		var c covering.Covering
+		for _, b := range group {
+			i, ok := subzoneIndexes[subzoneKey{b.IndexID, b.PartitionName}]
+			if !ok || b.Span.Key.Equal(b.Span.EndKey) {
+				continue
+			}
+			c = append(c, covering.Range{Start: b.Span.Key, End: b.Span.EndKey, Payload: i})
+			covered = append(covered, roachpb.Span{
+				Key:    bytes.TrimPrefix(b.Span.Key, tablePrefix),
+				EndKey: bytes.TrimPrefix(b.Span.EndKey, tablePrefix),
+			})
+		}
+		if len(c) > 0 {
+			coverings = append(coverings, c)
+		}
+	}
+
+	// OverlapCoveringMerge returns the payloads of the overlapping coverings in
+	// the order of the coverings, so the first payload of each range is the one
+	// with the highest precedence.
+	var spans []zonepb.SubzoneSpan
+	for _, r := range covering.OverlapCoveringMerge(coverings) {
+		payloads := r.Payload.([]interface{})
+		if len(payloads) == 0 {
+			continue
+		}
+		span := zonepb.SubzoneSpan{
+			Key:          bytes.TrimPrefix(r.Start, tablePrefix),
+			EndKey:       bytes.TrimPrefix(r.End, tablePrefix),
+			SubzoneIndex: payloads[0].(int32),
+		}
+		if bytes.Equal(span.Key.PrefixEnd(), span.EndKey) {
+			span.EndKey = nil
+		}
+		spans = append(spans, span)
+	}
+	if err := checkSubzoneSpans(spans, len(subzones), covered); err != nil {
+		return nil, errors.NewAssertionErrorWithWrappedErrf(err, "invalid subzone spans")
+	}
+	return spans, nil
+}
+
+// checkSubzoneBoundaries returns an error if a span of the group of boundaries
+// is inverted, or overlaps with another.
+func checkSubzoneBoundaries(group []subzoneBoundary) error {
+	sorted := make([]subzoneBoundary, 0, len(group))
+	for _, b := range group {
+		if b.Span.Key.Compare(b.Span.EndKey) > 0 {
+			return errors.Newf("inverted span %s for %s", b.Span, subzoneBoundaryName(b))
+		}
+		if !b.Span.Key.Equal(b.Span.EndKey) {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Span.Key.Compare(sorted[j].Span.Key) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		if prev, cur := sorted[i-1], sorted[i]; prev.Span.EndKey.Compare(cur.Span.Key) > 0 {
+			return errors.Newf("span %s for %s overlaps with span %s for %s",
+				cur.Span, subzoneBoundaryName(cur), prev.Span, subzoneBoundaryName(prev))
+		}
+	}
+	return nil
+}
+
+func subzoneBoundaryName(b subzoneBoundary) string {
+	if b.PartitionName == "" {
+		return fmt.Sprintf("index %d", b.IndexID)
+	}
+	return fmt.Sprintf("partition %q of index %d", b.PartitionName, b.IndexID)
+}
+
+// checkSubzoneSpans returns an error if the subzone spans are invalid (see
+// zonepb.ValidateSubzoneSpans) or don't cover all of the covered spans.
+func checkSubzoneSpans(spans []zonepb.SubzoneSpan, numSubzones int, covered []roachpb.Span) error {
+	if err := zonepb.ValidateSubzoneSpans(spans, numSubzones); err != nil {
+		return err
+	}
+	endKey := func(s zonepb.SubzoneSpan) roachpb.Key {
+		if s.EndKey == nil {
+			return s.Key.PrefixEnd()
+		}
+		return s.EndKey
+	}
+	for _, c := range covered {
+		for key := c.Key; key.Compare(c.EndKey) < 0; {
+			// Find the first span which ends after the key, which must contain
+			// it.
+			i := sort.Search(len(spans), func(i int) bool {
+				return endKey(spans[i]).Compare(key) > 0
+			})
+			if i == len(spans) || spans[i].Key.Compare(key) > 0 {
+				return errors.Newf("key %s of span %s isn't covered", key, c)
+			}
+			key = endKey(spans[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubzoneSpansFromBoundaries(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const tableID = 100
+	tablePrefix := keys.SystemSQLCodec.TablePrefix(tableID)
+	indexPrefix := func(indexID uint32) roachpb.Key {
+		return keys.SystemSQLCodec.IndexPrefix(tableID, indexID)
+	}
+	// key returns a key of the given index, with the given suffix.
+	key := func(indexID uint32, suffix string) roachpb.Key {
+		return append(indexPrefix(indexID), suffix...)
+	}
+	trimmed := func(k roachpb.Key) roachpb.Key {
+		return k[len(tablePrefix):]
+	}
+	index := func(indexID uint32) subzoneBoundary {
+		return subzoneBoundary{
+			IndexID: indexID,
+			Span:    roachpb.Span{Key: indexPrefix(indexID), EndKey: indexPrefix(indexID).PrefixEnd()},
+		}
+	}
+	partition := func(indexID uint32, name, start, end string) subzoneBoundary {
+		return subzoneBoundary{
+			IndexID:       indexID,
+			PartitionName: name,
+			Span:          roachpb.Span{Key: key(indexID, start), EndKey: key(indexID, end)},
+		}
+	}
+	subzones := []zonepb.Subzone{
+		{IndexID: 1},
+		{IndexID: 1, PartitionName: "p"},
+		{IndexID: 1, PartitionName: "q"},
+		// A partition of another index with the same name as one of index 1.
+		{IndexID: 2, PartitionName: "p"},
+		// The subzone of a dropped index.
+		{IndexID: 3},
+	}
+
+	spans, err := subzoneSpansFromBoundaries(tablePrefix, subzones, [][]subzoneBoundary{
+		// q takes precedence over p where they overlap.
+		{partition(1, "q", "e", "i")},
+		{partition(1, "p", "c", "g"), partition(1, "p", "k", "m")},
+		// Index 2 has no subzone, and neither does its partition r.
+		{partition(2, "r", "a", "b"), partition(2, "p", "b", "c")},
+		{index(1), index(2)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []zonepb.SubzoneSpan{
+		{Key: trimmed(indexPrefix(1)), EndKey: trimmed(key(1, "c")), SubzoneIndex: 0},
+		{Key: trimmed(key(1, "c")), EndKey: trimmed(key(1, "e")), SubzoneIndex: 1},
+		// Spans are split at every boundary, even when they belong to the same
+		// subzone.
+		{Key: trimmed(key(1, "e")), EndKey: trimmed(key(1, "g")), SubzoneIndex: 2},
+		{Key: trimmed(key(1, "g")), EndKey: trimmed(key(1, "i")), SubzoneIndex: 2},
+		{Key: trimmed(key(1, "i")), EndKey: trimmed(key(1, "k")), SubzoneIndex: 0},
+		{Key: trimmed(key(1, "k")), EndKey: trimmed(key(1, "m")), SubzoneIndex: 1},
+		{Key: trimmed(key(1, "m")), EndKey: trimmed(indexPrefix(1).PrefixEnd()), SubzoneIndex: 0},
+		// The EndKey of a span is omitted when it's the PrefixEnd of its Key.
+		{Key: trimmed(key(2, "b")), SubzoneIndex: 3},
+	}, spans)
+
+	// The spans of a group can't overlap.
+	_, err = subzoneSpansFromBoundaries(tablePrefix, subzones, [][]subzoneBoundary{
+		{partition(1, "p", "b", "d"), partition(1, "q", "c", "e")},
+	})
+	require.ErrorContains(t, err, `for partition "q" of index 1 overlaps with span`)
+	_, err = subzoneSpansFromBoundaries(tablePrefix, subzones, [][]subzoneBoundary{
+		{partition(1, "p", "d", "b")},
+	})
+	require.ErrorContains(t, err, `inverted span`)
+}
@@ -196,6 +196,9 @@ func (n *alterTenantCapabilityNode) startExec(params runParams) error {
 				// also want to check tandem fields to ensure they make sense -- for
 				// example, the range for min/max range sizes should have some overlap.
 				bounds = spanconfigbounds.New(boundspb)
+				if err := bounds.ValidateFixedFields(); err != nil {
+					return pgerror.Wrapf(err, pgcode.InvalidParameterValue, "invalid %q value", capability)
+				}
 			} else {
 				return errors.WithDetailf(
 					pgerror.Newf(
@@ -19,7 +19,9 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog"
+	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descbuilder"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descs"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/resolver"
@@ -36,6 +38,49 @@ func init() {
 	// TODO(marc): we use a hook to avoid a dependency on the sql package. We
 	// should probably move keys/protos elsewhere.
 	config.ZoneConfigHook = zoneConfigHook
+	config.SetDescriptorProvider(catalogDescriptorProvider{})
+}
+
+// catalogDescriptorProvider implements config.DescriptorProvider by building
+// catalog descriptors, which upgrades the descriptors written by older
+// versions.
+type catalogDescriptorProvider struct{}
+
+// DecodeDescriptor implements config.DescriptorProvider.
+func (catalogDescriptorProvider) DecodeDescriptor(
+	id config.ObjectID, value *roachpb.Value,
+) (config.DescriptorInfo, error) {
+	b, err := descbuilder.FromSerializedValue(value)
+	if err != nil {
+		return config.DescriptorInfo{}, errors.Wrapf(err, "decoding descriptor %d", id)
+	}
+	if b == nil {
+		return config.DescriptorInfo{}, nil
+	}
+	desc := b.BuildImmutable()
+	info := config.DescriptorInfo{
+		Name:           desc.GetName(),
+		ParentID:       config.ObjectID(desc.GetParentID()),
+		ParentSchemaID: config.ObjectID(desc.GetParentSchemaID()),
+		Dropped:        desc.Dropped(),
+	}
+	switch desc.DescriptorType() {
+	case catalog.Database:
+		info.Kind = config.DescriptorKindDatabase
+	case catalog.Schema:
+		info.Kind = config.DescriptorKindSchema
+	case catalog.Table:
+		info.Kind = config.DescriptorKindTable
+		if table, ok := desc.(catalog.TableDescriptor); ok {
+			info.IndexNames = make(map[uint32]string)
+			for _, index := range table.ActiveIndexes() {
+				info.IndexNames[uint32(index.GetID())] = index.GetName()
+			}
+		}
+	default:
+		info.Kind = config.DescriptorKindOther
+	}
+	return info, nil
 }
 
 var errNoZoneConfigApplies = errors.New("no zone config applies")
@@ -314,24 +359,15 @@ func GetHydratedZoneConfigForTable(
 		zone.SubzoneSpans = placeholder.SubzoneSpans
 	}
 
-	for i, subzone := range zone.Subzones {
-		// Check if a zone configuration exists for the index this subzone applies
-		// to by passing in a an empty partition below.
-		indexSubzone := zone.GetSubzone(subzone.IndexID, "" /* partition  */)
-		// Partitions, in terms of the inheritance hierarchy, first inherit from the
-		// zone configuration fields on their parent index (if such a zone
-		// configuration exists).
-		// NB: If the subzone we're dealing with belongs to an index and not a
-		// partition, then the call below is a no-op.
-		if indexSubzone != nil {
-			zone.Subzones[i].Config.InheritFromParent(&indexSubzone.Config)
-		}
-		// After inheriting from the index's zone configuration, any fields that are
-		// left empty must be filled in from the table's zone configuration. Note
-		// that the table's zone configuration was fully hydrated above and
-		// inheriting from it will result in the subzone config being fully hydrated
-		// as well.
-		zone.Subzones[i].Config.InheritFromParent(zone)
+	// The table's zone configuration was fully hydrated above, so the
+	// materialized subzone configs are fully hydrated as well. The subzones are
+	// hydrated in place, so they must not be shared with other zone configs (see
+	// zonepb.ZoneConfig.Clone).
+	materialized := zone.MaterializeSubzoneConfigs()
+	zone.CloneSubzones()
+	for i := range zone.Subzones {
+		s := &zone.Subzones[i]
+		s.Config = materialized[zonepb.SubzoneKey{IndexID: s.IndexID, PartitionName: s.PartitionName}]
 	}
 
 	return zone, nil